@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/magomedcoder/legion/internal/delivery/event"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -20,12 +21,14 @@ import (
 	"github.com/magomedcoder/legion/api/pb/projectpb"
 	"github.com/magomedcoder/legion/api/pb/runnerpb"
 	"github.com/magomedcoder/legion/api/pb/searchpb"
+	"github.com/magomedcoder/legion/api/pb/usagepb"
 	"github.com/magomedcoder/legion/api/pb/userpb"
 	"github.com/magomedcoder/legion/internal/bootstrap"
 	"github.com/magomedcoder/legion/internal/config"
 	"github.com/magomedcoder/legion/internal/delivery/consume"
 	"github.com/magomedcoder/legion/internal/delivery/handler"
 	"github.com/magomedcoder/legion/internal/delivery/middleware"
+	"github.com/magomedcoder/legion/internal/delivery/openai"
 	"github.com/magomedcoder/legion/internal/delivery/process"
 	"github.com/magomedcoder/legion/internal/pkg/socket"
 	"github.com/magomedcoder/legion/internal/repository/postgres"
@@ -86,6 +89,8 @@ func main() {
 	userSessionRepo := postgres.NewUserSessionRepository(db)
 	aiChatSessionRepo := postgres.NewAIChatSessionRepository(db)
 	messageRepo := postgres.NewMessageRepository(db)
+	aiPersonaRepo := postgres.NewAIPersonaRepository(db)
+	knowledgeBaseRepo := postgres.NewKnowledgeBaseRepository(db)
 	chatRepo := postgres.NewChatRepository(db)
 	chatMessageRepo := postgres.NewChatMessageRepository(db)
 	userDeletedMessageRepo := postgres.NewUserDeletedMessageRepository(db)
@@ -96,6 +101,9 @@ func main() {
 	projectTaskCommentRepo := postgres.NewProjectTaskCommentRepository(db)
 	projectColumnRepo := postgres.NewProjectColumnRepository(db)
 	projectActivityRepo := postgres.NewProjectActivityRepository(db)
+	runnerCredentialRepo := postgres.NewRunnerCredentialRepository(db)
+	runnerRepo := postgres.NewRunnerRepository(db)
+	tokenUsageRepo := postgres.NewTokenUsageRepository(db)
 
 	redisClient, err := redis_repository.NewRedisClient(conf)
 	if err != nil {
@@ -105,6 +113,8 @@ func main() {
 
 	serverCache := redis_repository.NewServerCacheRepository(redisClient)
 	clientCache := redis_repository.NewClientCacheRepository(conf, redisClient, serverCache)
+	aiQuotaRepo := redis_repository.NewAIQuotaRepository(redisClient)
+	aiStreamRepo := redis_repository.NewAIStreamRepository(redisClient)
 
 	storageUseCase := usecase.NewStorageUseCase(conf, minioClient)
 
@@ -115,7 +125,37 @@ func main() {
 		os.Exit(1)
 	}
 
-	runnerPool := runner.NewPool(conf.Runners.Addresses)
+	runnerCreds, err := runner.ClientTLS{
+		Enabled:    conf.Runners.TLS.Enabled,
+		CAFile:     conf.Runners.TLS.CAFile,
+		CertFile:   conf.Runners.TLS.CertFile,
+		KeyFile:    conf.Runners.TLS.KeyFile,
+		ServerName: conf.Runners.TLS.ServerName,
+	}.TransportCredentials()
+	if err != nil {
+		logger.E("Ошибка настройки TLS для раннеров: %v", err)
+		os.Exit(1)
+	}
+
+	runnerPool := runner.NewPool(
+		conf.Runners.Addresses,
+		runner.WithTransportCredentials(runnerCreds),
+		runner.WithRunnerRepository(runnerRepo),
+		runner.WithHealthCheck(
+			conf.Runners.HealthCheckInterval.Duration,
+			conf.Runners.HealthCheckTimeout.Duration,
+			conf.Runners.HealthCheckFailures,
+		),
+		runner.WithGenerateAttempts(conf.Runners.GenerateAttempts),
+		runner.WithScheduling(conf.Runners.Scheduling),
+		runner.WithConcurrencyLimits(conf.Runners.MaxConcurrency, conf.Runners.Concurrency),
+		runner.WithQueueTimeout(conf.Runners.QueueTimeout.Duration),
+	)
+	restoredRunners, err := runnerPool.Restore(ctx)
+	if err != nil {
+		logger.W("Не удалось восстановить раннеры из базы: %v", err)
+	}
+	runnerPool.StartHealthCheck(ctx)
 	authUseCase := usecase.NewAuthUseCase(userRepo, userSessionRepo, jwtService)
 	chatUseCase := usecase.NewChatUseCase(
 		chatRepo,
@@ -126,10 +166,22 @@ func main() {
 		usecase.WithChatServerCache(serverCache),
 		usecase.WithChatClientCache(clientCache),
 	)
-	aiChatUseCase := usecase.NewAIChatUseCase(aiChatSessionRepo, messageRepo, fileRepo, runnerPool, storageUseCase)
-	editorUseCase := usecase.NewEditorUseCase(runnerPool)
+	var quotaUseCase *usecase.QuotaUseCase
+	if conf.AIQuotas.Enabled {
+		quotaPolicy, err := conf.AIQuotas.Policy()
+		if err != nil {
+			logger.E("Ошибка настройки квот: %v", err)
+			os.Exit(1)
+		}
+		quotaUseCase = usecase.NewQuotaUseCase(aiQuotaRepo, userRepo, quotaPolicy)
+	}
+	knowledgeUseCase := usecase.NewKnowledgeUseCase(knowledgeBaseRepo, fileRepo, runnerPool, storageUseCase, conf.AIKnowledge.Settings())
 	userUseCase := usecase.NewUserUseCase(userRepo, userSessionRepo, jwtService)
 	searchUseCase := usecase.NewSearchUseCase(userRepo)
+	runnerCredentialUseCase := usecase.NewRunnerCredentialUseCase(runnerCredentialRepo)
+	aiPersonaUseCase := usecase.NewAIPersonaUseCase(aiPersonaRepo)
+	usageUseCase := usecase.NewUsageUseCase(tokenUsageRepo)
+	editorUseCase := usecase.NewEditorUseCase(runnerPool, quotaUseCase, usageUseCase)
 	projectUseCase := usecase.NewProjectUseCase(
 		projectRepo, projectMemberRepo, projectTaskRepo, projectTaskCommentRepo, projectColumnRepo, projectActivityRepo, userRepo,
 		usecase.WithProjectRedis(redisClient),
@@ -137,12 +189,31 @@ func main() {
 		usecase.WithProjectClientCache(clientCache),
 		usecase.WithProjectConf(conf),
 	)
+	var aiTools *usecase.AIToolRegistry
+	if conf.AITools.Enabled {
+		aiTools = usecase.NewLegionAIToolRegistry(searchUseCase, projectUseCase)
+	}
+	aiChatUseCase := usecase.NewAIChatUseCase(aiChatSessionRepo, messageRepo, fileRepo, runnerPool, storageUseCase, quotaUseCase,
+		usecase.WithAIContextWindow(conf.AIContext.Window()),
+		usecase.WithAIHistoryLimit(conf.AIContext.HistoryLimit),
+		usecase.WithAISummaries(conf.AIContext.Summarize),
+		usecase.WithAIStreams(aiStreamRepo),
+		usecase.WithAIPersonas(aiPersonaRepo),
+		usecase.WithAIKnowledge(knowledgeUseCase),
+		usecase.WithAIUsage(usageUseCase),
+		usecase.WithAITools(aiTools),
+		usecase.WithAIToolRounds(conf.AITools.MaxRounds),
+		usecase.WithAIRedis(redisClient),
+		usecase.WithAIServerCache(serverCache),
+		usecase.WithAIClientCache(clientCache),
+	)
 
 	consumeHandler := &consume.Handler{
 		Conf:           conf,
 		ClientCache:    clientCache,
 		ChatUseCase:    chatUseCase,
 		ProjectUseCase: projectUseCase,
+		AIChatUseCase:  aiChatUseCase,
 	}
 	chatSubscribe := consume.NewChatSubscribe(consumeHandler)
 
@@ -164,15 +235,30 @@ func main() {
 	authHandler := handler.NewAuthHandler(conf, authUseCase)
 	accountHandler := handler.NewAccountHandler(conf, authUseCase, clientCache, chatEvent)
 	chatHandler := handler.NewAIChatHandler(aiChatUseCase, authUseCase)
+	personaHandler := handler.NewAIPersonaHandler(aiPersonaUseCase, authUseCase)
+	knowledgeHandler := handler.NewAIKnowledgeHandler(knowledgeUseCase, authUseCase)
 	userChatHandler := handler.NewChatHandler(chatUseCase, authUseCase)
 	editorHandler := handler.NewEditorHandler(editorUseCase, authUseCase)
 	userHandler := handler.NewUserHandler(userUseCase, authUseCase)
 	searchHandler := handler.NewSearchHandler(searchUseCase, authUseCase)
 	projectHandler := handler.NewProjectHandler(projectUseCase)
+	usageHandler := handler.NewUsageHandler(usageUseCase)
 
 	authMiddleware := middleware.NewMiddleware(authUseCase)
 
+	serverCreds, err := runner.ServerTLS{
+		Enabled:      conf.Server.TLS.Enabled,
+		CertFile:     conf.Server.TLS.CertFile,
+		KeyFile:      conf.Server.TLS.KeyFile,
+		ClientCAFile: conf.Server.TLS.ClientCAFile,
+	}.TransportCredentials()
+	if err != nil {
+		logger.E("Ошибка настройки TLS сервера: %v", err)
+		os.Exit(1)
+	}
+
 	grpcServer := grpc.NewServer(
+		grpc.Creds(serverCreds),
 		grpc.ChainUnaryInterceptor(authMiddleware.UnaryAuthInterceptor),
 		grpc.ChainStreamInterceptor(authMiddleware.StreamAuthInterceptor),
 	)
@@ -180,13 +266,25 @@ func main() {
 	authpb.RegisterAuthServiceServer(grpcServer, authHandler)
 	accountpb.RegisterAccountServiceServer(grpcServer, accountHandler)
 	aichatpb.RegisterAIChatServiceServer(grpcServer, chatHandler)
+	aichatpb.RegisterAIPersonaServiceServer(grpcServer, personaHandler)
+	aichatpb.RegisterAIKnowledgeServiceServer(grpcServer, knowledgeHandler)
 	chatpb.RegisterChatServiceServer(grpcServer, userChatHandler)
 	editorpb.RegisterEditorServiceServer(grpcServer, editorHandler)
 	userpb.RegisterUserServiceServer(grpcServer, userHandler)
 	searchpb.RegisterSearchServiceServer(grpcServer, searchHandler)
 	projectpb.RegisterProjectServiceServer(grpcServer, projectHandler)
-	runnerpb.RegisterRunnerAdminServiceServer(grpcServer, handler.NewRunnerHandler(runnerPool, authUseCase))
-	runnerpb.RegisterRunnerServiceServer(grpcServer, runner.NewRegistry(runnerPool, conf.Runners.RegistrationToken))
+	usagepb.RegisterUsageServiceServer(grpcServer, usageHandler)
+	runnerpb.RegisterRunnerAdminServiceServer(grpcServer, handler.NewRunnerHandler(runnerPool, authUseCase, runnerCredentialUseCase))
+	runnerRegistry := runner.NewRegistry(
+		runnerPool,
+		conf.Runners.RegistrationToken,
+		runner.WithLeaseTTL(conf.Runners.LeaseTTL.Duration),
+		runner.WithStaticAddresses(conf.Runners.Addresses),
+		runner.WithRunnerAuthenticator(runnerCredentialUseCase, conf.Runners.RequireCredentials),
+	)
+	runnerRegistry.Adopt(restoredRunners)
+	runnerRegistry.StartLeaseReaper(ctx)
+	runnerpb.RegisterRunnerServiceServer(grpcServer, runnerRegistry)
 
 	reflection.Register(grpcServer)
 
@@ -198,7 +296,7 @@ func main() {
 	}
 	defer listener.Close()
 
-	logger.I("gRPC сервер слушает на %s (PID: %d)", addr, os.Getpid())
+	logger.I("gRPC сервер слушает на %s (PID: %d, TLS: %v, mTLS: %v)", addr, os.Getpid(), conf.Server.TLS.Enabled, conf.Server.TLS.Enabled && conf.Server.TLS.ClientCAFile != "")
 
 	group, groupCtx := errgroup.WithContext(ctx)
 
@@ -217,15 +315,39 @@ func main() {
 		return grpcServer.Serve(listener)
 	})
 
+	var openAIServer *http.Server
+	if conf.OpenAIAPI.Enabled {
+		openAIAddr := fmt.Sprintf("%s:%s", conf.OpenAIAPI.Host, conf.OpenAIAPI.Port)
+		openAIServer = &http.Server{
+			Addr:              openAIAddr,
+			Handler:           openai.NewHandler(runnerPool, authUseCase, quotaUseCase, usageUseCase),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		logger.I("OpenAI-совместимый API слушает на %s", openAIAddr)
+		group.Go(func() error {
+			if err := openAIServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return fmt.Errorf("OpenAI API: %w", err)
+			}
+			return nil
+		})
+	}
+
 	group.Go(func() error {
 		select {
 		case <-groupCtx.Done():
 			grpcServer.Stop()
+			if openAIServer != nil {
+				_ = openAIServer.Close()
+			}
 			return groupCtx.Err()
 		case sig := <-sigCh:
 			logger.I("Получен сигнал %v, остановка сервера...", sig)
 			cancel()
 			grpcServer.Stop()
+			if openAIServer != nil {
+				_ = openAIServer.Close()
+			}
 			return nil
 		}
 	})
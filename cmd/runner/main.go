@@ -16,7 +16,7 @@ import (
 	"github.com/magomedcoder/legion/runner/gpu"
 	"github.com/magomedcoder/legion/runner/provider"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/metadata"
 )
 
@@ -48,38 +48,70 @@ func main() {
 	}
 	defer lis.Close()
 
-	grpcServer := grpc.NewServer()
+	serverCreds, err := runner.ServerTLS{
+		Enabled:      cfg.TLS.Enabled,
+		CertFile:     cfg.TLS.CertFile,
+		KeyFile:      cfg.TLS.KeyFile,
+		ClientCAFile: cfg.TLS.ClientCAFile,
+	}.TransportCredentials()
+	if err != nil {
+		logger.E("Ошибка настройки TLS: %v", err)
+		os.Exit(1)
+	}
+
+	coreCreds, err := runner.ClientTLS{
+		Enabled:    cfg.CoreTLS.Enabled,
+		CAFile:     cfg.CoreTLS.CAFile,
+		CertFile:   cfg.CoreTLS.CertFile,
+		KeyFile:    cfg.CoreTLS.KeyFile,
+		ServerName: cfg.CoreTLS.ServerName,
+	}.TransportCredentials()
+	if err != nil {
+		logger.E("Ошибка настройки TLS для подключения к ядру: %v", err)
+		os.Exit(1)
+	}
+
+	grpcServer := grpc.NewServer(grpc.Creds(serverCreds))
 	runnerpb.RegisterRunnerServiceServer(grpcServer, runnerServer)
 
 	go func() {
-		logger.I("Раннер слушает на %s", cfg.ListenAddr)
+		logger.I("Раннер слушает на %s (TLS: %v, mTLS: %v)", cfg.ListenAddr, cfg.TLS.Enabled, cfg.TLS.Enabled && cfg.TLS.ClientCAFile != "")
 		if err := grpcServer.Serve(lis); err != nil {
 			logger.E("Ошибка gRPC: %v", err)
 			os.Exit(1)
 		}
 	}()
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	if cfg.CoreAddr != "" && cfg.ListenAddr != "" {
-		if err := registerWithCore(cfg.CoreAddr, cfg.ListenAddr, cfg.RegistrationToken); err != nil {
+		interval := defaultHeartbeatInterval
+		if lease, err := registerWithCore(cfg.CoreAddr, coreCreds, cfg.ListenAddr, cfg.RegistrationToken); err != nil {
 			logger.W("Регистрация в ядре не удалась: %v", err)
 		} else {
-			logger.I("Зарегистрирован в ядре %s как %s", cfg.CoreAddr, cfg.ListenAddr)
-			defer unregisterFromCore(cfg.CoreAddr, cfg.ListenAddr, cfg.RegistrationToken)
+			logger.I("Зарегистрирован в ядре %s как %s (аренда %d с)", cfg.CoreAddr, cfg.ListenAddr, lease.LeaseTtlSeconds)
+			interval = heartbeatInterval(lease)
+			defer unregisterFromCore(cfg.CoreAddr, coreCreds, cfg.ListenAddr, cfg.RegistrationToken)
 		}
+		go heartbeatLoop(ctx, cfg, coreCreds, runnerServer, interval)
 	}
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	cancel()
 
 	grpcServer.GracefulStop()
 	logger.I("Раннер остановлен")
 }
 
-func registerWithCore(coreAddr, registerAddress, registrationToken string) error {
-	conn, err := grpc.NewClient(coreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+const defaultHeartbeatInterval = 10 * time.Second
+
+func registerWithCore(coreAddr string, creds credentials.TransportCredentials, registerAddress, registrationToken string) (*runnerpb.RunnerLease, error) {
+	conn, err := grpc.NewClient(coreAddr, grpc.WithTransportCredentials(creds))
 	if err != nil {
-		return fmt.Errorf("подключение к ядру: %w", err)
+		return nil, fmt.Errorf("подключение к ядру: %w", err)
 	}
 	defer conn.Close()
 
@@ -88,14 +120,56 @@ func registerWithCore(coreAddr, registerAddress, registrationToken string) error
 	ctx = outgoingContextWithRunnerToken(ctx, registrationToken)
 
 	client := runnerpb.NewRunnerServiceClient(conn)
-	_, err = client.Register(ctx, &runnerpb.RegisterRunnerRequest{
+	return client.Register(ctx, &runnerpb.RegisterRunnerRequest{
 		Address: registerAddress,
 	})
-	return err
 }
 
-func unregisterFromCore(coreAddr, registerAddress, registrationToken string) {
-	conn, err := grpc.NewClient(coreAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+func heartbeatInterval(lease *runnerpb.RunnerLease) time.Duration {
+	if lease == nil || lease.HeartbeatIntervalSeconds <= 0 {
+		return defaultHeartbeatInterval
+	}
+
+	return time.Duration(lease.HeartbeatIntervalSeconds) * time.Second
+}
+
+func heartbeatLoop(ctx context.Context, cfg *config.Config, creds credentials.TransportCredentials, server *runner.Server, interval time.Duration) {
+	conn, err := grpc.NewClient(cfg.CoreAddr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		logger.W("Heartbeat: подключение к ядру: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	client := runnerpb.NewRunnerServiceClient(conn)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		lease, err := client.Heartbeat(
+			outgoingContextWithRunnerToken(reqCtx, cfg.RegistrationToken),
+			server.HeartbeatRequest(reqCtx, cfg.ListenAddr),
+		)
+		cancel()
+		if err != nil {
+			logger.W("Heartbeat: ядро %s недоступно: %v", cfg.CoreAddr, err)
+		} else {
+			interval = heartbeatInterval(lease)
+		}
+
+		timer.Reset(interval)
+	}
+}
+
+func unregisterFromCore(coreAddr string, creds credentials.TransportCredentials, registerAddress, registrationToken string) {
+	conn, err := grpc.NewClient(coreAddr, grpc.WithTransportCredentials(creds))
 	if err != nil {
 		logger.W("Unregister: подключение к ядру: %v", err)
 		return
@@ -5,6 +5,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/magomedcoder/legion/internal/domain"
 	"github.com/magomedcoder/legion/pkg/encrypt"
 	"github.com/magomedcoder/legion/pkg/minio"
 	"github.com/magomedcoder/legion/pkg/strutil"
@@ -36,14 +37,79 @@ type LogConfig struct {
 	Level string `yaml:"level"`
 }
 
+type RunnersTLSConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
+}
+
 type RunnersConfig struct {
-	Addresses         []string `yaml:"addresses"`
-	RegistrationToken string   `yaml:"registration_token"`
+	Addresses           []string         `yaml:"addresses"`
+	RegistrationToken   string           `yaml:"registration_token"`
+	HealthCheckInterval Duration         `yaml:"health_check_interval"`
+	HealthCheckTimeout  Duration         `yaml:"health_check_timeout"`
+	HealthCheckFailures int              `yaml:"health_check_failures"`
+	GenerateAttempts    int              `yaml:"generate_attempts"`
+	Scheduling          string           `yaml:"scheduling"`
+	MaxConcurrency      int              `yaml:"max_concurrency"`
+	Concurrency         map[string]int   `yaml:"concurrency"`
+	QueueTimeout        Duration         `yaml:"queue_timeout"`
+	LeaseTTL            Duration         `yaml:"lease_ttl"`
+	RequireCredentials  bool             `yaml:"require_credentials"`
+	TLS                 RunnersTLSConfig `yaml:"tls"`
+}
+
+type ServerTLSConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
 }
 
 type ServerConfig struct {
-	Port string `yaml:"port"`
-	Host string `yaml:"host"`
+	Port string          `yaml:"port"`
+	Host string          `yaml:"host"`
+	TLS  ServerTLSConfig `yaml:"tls"`
+}
+
+type OpenAIAPIConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Host    string `yaml:"host"`
+	Port    string `yaml:"port"`
+}
+
+type AIQuotaLimits struct {
+	RequestsPerMinute int   `yaml:"requests_per_minute"`
+	TokensPerDay      int64 `yaml:"tokens_per_day"`
+	ConcurrentStreams int   `yaml:"concurrent_streams"`
+}
+
+type AIQuotasConfig struct {
+	Enabled bool                     `yaml:"enabled"`
+	Roles   map[string]AIQuotaLimits `yaml:"roles"`
+	Users   map[int]AIQuotaLimits    `yaml:"users"`
+}
+
+type AIContextConfig struct {
+	DefaultTokens int            `yaml:"default_tokens"`
+	ReserveTokens int            `yaml:"reserve_tokens"`
+	Models        map[string]int `yaml:"models"`
+	HistoryLimit  int            `yaml:"history_limit"`
+	Summarize     bool           `yaml:"summarize"`
+}
+
+type AIKnowledgeConfig struct {
+	EmbeddingModel string `yaml:"embedding_model"`
+	ChunkSize      int    `yaml:"chunk_size"`
+	ChunkOverlap   int    `yaml:"chunk_overlap"`
+	TopK           int    `yaml:"top_k"`
+}
+
+type AIToolsConfig struct {
+	Enabled   bool `yaml:"enabled"`
+	MaxRounds int  `yaml:"max_rounds"`
 }
 
 type Postgres struct {
@@ -78,13 +144,18 @@ type JWTConfig struct {
 }
 
 type Config struct {
-	Server         ServerConfig  `yaml:"server"`
-	Postgres       Postgres      `yaml:"postgres"`
-	Redis          *Redis        `yaml:"redis"`
-	Minio          *Minio        `yaml:"minio"`
-	JWT            JWTConfig     `yaml:"jwt"`
-	Runners        RunnersConfig `yaml:"runners"`
-	Log            LogConfig     `yaml:"log"`
+	Server         ServerConfig      `yaml:"server"`
+	Postgres       Postgres          `yaml:"postgres"`
+	Redis          *Redis            `yaml:"redis"`
+	Minio          *Minio            `yaml:"minio"`
+	JWT            JWTConfig         `yaml:"jwt"`
+	Runners        RunnersConfig     `yaml:"runners"`
+	OpenAIAPI      OpenAIAPIConfig   `yaml:"openai_api"`
+	AIQuotas       AIQuotasConfig    `yaml:"ai_quotas"`
+	AIContext      AIContextConfig   `yaml:"ai_context"`
+	AIKnowledge    AIKnowledgeConfig `yaml:"ai_knowledge"`
+	AITools        AIToolsConfig     `yaml:"ai_tools"`
+	Log            LogConfig         `yaml:"log"`
 	MinClientBuild int32
 	sid            string
 }
@@ -109,6 +180,55 @@ func (r *Redis) Options() *redis.Options {
 	}
 }
 
+func (l AIQuotaLimits) toDomain() domain.AIQuota {
+	return domain.AIQuota{
+		RequestsPerMinute: l.RequestsPerMinute,
+		TokensPerDay:      l.TokensPerDay,
+		ConcurrentStreams: l.ConcurrentStreams,
+	}
+}
+
+func (q *AIQuotasConfig) Policy() (domain.AIQuotaPolicy, error) {
+	policy := domain.AIQuotaPolicy{
+		Roles: make(map[domain.UserRole]domain.AIQuota, len(q.Roles)),
+		Users: make(map[int]domain.AIQuota, len(q.Users)),
+	}
+
+	for name, limits := range q.Roles {
+		switch name {
+		case "user":
+			policy.Roles[domain.UserRoleUser] = limits.toDomain()
+		case "admin":
+			policy.Roles[domain.UserRoleAdmin] = limits.toDomain()
+		default:
+			return domain.AIQuotaPolicy{}, fmt.Errorf("ai_quotas: неизвестная роль %q (ожидается user или admin)", name)
+		}
+	}
+
+	for userId, limits := range q.Users {
+		policy.Users[userId] = limits.toDomain()
+	}
+
+	return policy, nil
+}
+
+func (c *AIContextConfig) Window() domain.ContextWindow {
+	return domain.ContextWindow{
+		DefaultTokens: c.DefaultTokens,
+		ReserveTokens: c.ReserveTokens,
+		Models:        c.Models,
+	}
+}
+
+func (c *AIKnowledgeConfig) Settings() domain.KnowledgeSettings {
+	return domain.KnowledgeSettings{
+		EmbeddingModel: c.EmbeddingModel,
+		ChunkSize:      c.ChunkSize,
+		ChunkOverlap:   c.ChunkOverlap,
+		TopK:           c.TopK,
+	}
+}
+
 func NewMinioClient(conf *Config) minio.IMinio {
 	if conf.Minio == nil {
 		return nil
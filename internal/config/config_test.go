@@ -60,3 +60,37 @@ func TestNewMinioClient_emptyMinio(t *testing.T) {
 		t.Errorf("NewMinioClient при пустом Config должен возвращать nil, получено %v", got)
 	}
 }
+
+func TestAIQuotasConfig_Policy(t *testing.T) {
+	q := AIQuotasConfig{
+		Roles: map[string]AIQuotaLimits{"user": {RequestsPerMinute: 20, ConcurrentStreams: 2}},
+		Users: map[int]AIQuotaLimits{5: {TokensPerDay: 1000}},
+	}
+
+	policy, err := q.Policy()
+	if err != nil {
+		t.Fatalf("Policy: %v", err)
+	}
+
+	if got := policy.For(1, 0); got.RequestsPerMinute != 20 || got.ConcurrentStreams != 2 {
+		t.Errorf("квота роли user: %+v", got)
+	}
+
+	if got := policy.For(5, 0); got.TokensPerDay != 1000 {
+		t.Errorf("квота пользователя 5: %+v", got)
+	}
+
+	q.Roles["guest"] = AIQuotaLimits{}
+	if _, err := q.Policy(); err == nil {
+		t.Error("ожидалась ошибка для неизвестной роли")
+	}
+}
+
+func TestAIContextConfig_Window(t *testing.T) {
+	c := AIContextConfig{DefaultTokens: 2048, ReserveTokens: 256, Models: map[string]int{"llama3": 8192}}
+
+	w := c.Window()
+	if w.Tokens("llama3") != 8192 || w.Tokens("other") != 2048 || w.Budget("other", nil) != 2048-256 {
+		t.Errorf("Window: %+v", w)
+	}
+}
@@ -19,18 +19,22 @@ var (
 )
 
 type Handler struct {
-	Conf          *config.Config
-	ClientCache   *redisRepo.ClientCacheRepository
-	ChatUseCase   *usecase.ChatUseCase
+	Conf           *config.Config
+	ClientCache    *redisRepo.ClientCacheRepository
+	ChatUseCase    *usecase.ChatUseCase
 	ProjectUseCase *usecase.ProjectUseCase
+	AIChatUseCase  *usecase.AIChatUseCase
 }
 
 func (h *Handler) registerHandlers() {
 	eventHandlers = map[string]EventHandler{
-		domain.SubEventUserStatus:  h.handleUserStatus,
-		domain.SubEventNewMessage:  h.onConsumeMessage,
-		domain.SubEventNewTask:     h.onConsumeNewTask,
-		domain.SubEventTaskChanged: h.onConsumeTaskChanged,
+		domain.SubEventUserStatus:         h.handleUserStatus,
+		domain.SubEventNewMessage:         h.onConsumeMessage,
+		domain.SubEventNewTask:            h.onConsumeNewTask,
+		domain.SubEventTaskChanged:        h.onConsumeTaskChanged,
+		domain.SubEventAISessionTitle:     h.onConsumeAISessionTitle,
+		domain.SubEventAIStopGeneration:   h.onConsumeAIStopGeneration,
+		domain.SubEventAIMessageCompleted: h.onConsumeAIMessageCompleted,
 	}
 }
 
@@ -0,0 +1,45 @@
+package consume
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/magomedcoder/legion/api/pb/accountpb"
+	"github.com/magomedcoder/legion/internal/domain/event"
+	"github.com/magomedcoder/legion/internal/pkg/socket"
+)
+
+func (h *Handler) onConsumeAIMessageCompleted(ctx context.Context, body []byte) {
+	var in event.ConsumeAIMessageCompleted
+	if err := json.Unmarshal(body, &in); err != nil {
+		log.Printf("onConsumeAIMessageCompleted: ошибка декодирования json: %s", err)
+		return
+	}
+	if in.SessionId == "" || in.MessageId == "" {
+		return
+	}
+
+	clientIds := h.ClientCache.GetUidFromClientIds(ctx,
+		h.Conf.ServerId(),
+		socket.Session.Chat.Name(),
+		strconv.Itoa(in.UserId),
+	)
+	if len(clientIds) == 0 {
+		return
+	}
+
+	c := socket.NewSenderContent()
+	c.SetReceive(clientIds...)
+	c.SetAck(true)
+	c.SetUpdateAIMessageCompleted(&accountpb.Update_AiMessageCompleted{
+		AiMessageCompleted: &accountpb.UpdateAIMessageCompleted{
+			SessionId:    in.SessionId,
+			MessageId:    in.MessageId,
+			FinishReason: in.FinishReason,
+		},
+	})
+
+	socket.Session.Chat.Write(c)
+}
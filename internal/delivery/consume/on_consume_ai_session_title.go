@@ -0,0 +1,44 @@
+package consume
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/magomedcoder/legion/api/pb/accountpb"
+	"github.com/magomedcoder/legion/internal/domain/event"
+	"github.com/magomedcoder/legion/internal/pkg/socket"
+)
+
+func (h *Handler) onConsumeAISessionTitle(ctx context.Context, body []byte) {
+	var in event.ConsumeAISessionTitle
+	if err := json.Unmarshal(body, &in); err != nil {
+		log.Printf("onConsumeAISessionTitle: ошибка декодирования json: %s", err)
+		return
+	}
+	if in.SessionId == "" || in.Title == "" {
+		return
+	}
+
+	clientIds := h.ClientCache.GetUidFromClientIds(ctx,
+		h.Conf.ServerId(),
+		socket.Session.Chat.Name(),
+		strconv.Itoa(in.UserId),
+	)
+	if len(clientIds) == 0 {
+		return
+	}
+
+	c := socket.NewSenderContent()
+	c.SetReceive(clientIds...)
+	c.SetAck(true)
+	c.SetUpdateAISessionTitle(&accountpb.Update_AiSessionTitle{
+		AiSessionTitle: &accountpb.UpdateAISessionTitle{
+			SessionId: in.SessionId,
+			Title:     in.Title,
+		},
+	})
+
+	socket.Session.Chat.Write(c)
+}
@@ -0,0 +1,22 @@
+package consume
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/magomedcoder/legion/internal/domain/event"
+)
+
+func (h *Handler) onConsumeAIStopGeneration(_ context.Context, body []byte) {
+	var in event.ConsumeAIStopGeneration
+	if err := json.Unmarshal(body, &in); err != nil {
+		log.Printf("onConsumeAIStopGeneration: ошибка декодирования json: %s", err)
+		return
+	}
+	if in.SessionId == "" || in.MessageId == "" || h.AIChatUseCase == nil {
+		return
+	}
+
+	h.AIChatUseCase.StopLocalGeneration(in.SessionId, in.MessageId)
+}
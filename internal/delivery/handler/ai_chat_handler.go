@@ -2,16 +2,20 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"github.com/magomedcoder/legion/api/pb/commonpb"
 	"github.com/magomedcoder/legion/internal/delivery/mappers"
 	"github.com/magomedcoder/legion/internal/delivery/middleware"
+	"github.com/magomedcoder/legion/internal/domain"
 	"github.com/magomedcoder/legion/pkg"
 	error2 "github.com/magomedcoder/legion/pkg/error"
+	"strings"
 	"time"
 
 	"github.com/magomedcoder/legion/api/pb/aichatpb"
 	"github.com/magomedcoder/legion/internal/usecase"
 	"github.com/magomedcoder/legion/pkg/logger"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -60,18 +64,154 @@ func (c *AIChatHandler) SendMessage(req *aichatpb.SendMessageRequest, stream aic
 		attachmentContent = lastMessage.AttachmentContent
 	}
 
+	generationOptions := mappers.GenerationOptionsFromProto(req.GetGenerationOptions())
+	if err := generationOptions.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	logger.D("ChatHandler: отправка сообщения в сессию %s", req.SessionId)
-	responseChan, messageId, err := c.aiChatUseCase.SendMessage(ctx, userId, req.SessionId, req.GetModel(), userMessage, attachmentName, attachmentContent)
+	responseChan, reply, err := c.aiChatUseCase.SendMessage(ctx, userId, req.SessionId, req.GetModel(), userMessage, attachmentName, attachmentContent, generationOptions)
 	if err != nil {
 		logger.E("ChatHandler: ошибка отправки сообщения: %v", err)
-		return error2.ToStatusError(codes.Internal, err)
+		return generationStatusError(err)
+	}
+
+	return streamAIResponse(stream, responseChan, reply)
+}
+
+func (c *AIChatHandler) RegenerateMessage(req *aichatpb.RegenerateMessageRequest, stream aichatpb.AIChatService_RegenerateMessageServer) error {
+	ctx := stream.Context()
+	userId, err := c.getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	generationOptions := mappers.GenerationOptionsFromProto(req.GetGenerationOptions())
+	if err := generationOptions.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	responseChan, reply, err := c.aiChatUseCase.RegenerateMessage(ctx, userId, req.GetSessionId(), req.GetModel(), generationOptions)
+	if err != nil {
+		logger.W("ChatHandler: ошибка повторной генерации: %v", err)
+		return generationStatusError(err)
+	}
+
+	return streamAIResponse(stream, responseChan, reply)
+}
+
+func (c *AIChatHandler) EditMessage(req *aichatpb.EditMessageRequest, stream aichatpb.AIChatService_EditMessageServer) error {
+	ctx := stream.Context()
+	userId, err := c.getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if req.GetMessageId() == "" {
+		return status.Error(codes.InvalidArgument, "не указано сообщение")
+	}
+
+	if strings.TrimSpace(req.GetContent()) == "" {
+		return status.Error(codes.InvalidArgument, "текст сообщения не может быть пустым")
+	}
+
+	generationOptions := mappers.GenerationOptionsFromProto(req.GetGenerationOptions())
+	if err := generationOptions.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	responseChan, reply, err := c.aiChatUseCase.EditMessage(ctx, userId, req.GetSessionId(), req.GetMessageId(), req.GetContent(), req.GetModel(), generationOptions)
+	if err != nil {
+		logger.W("ChatHandler: ошибка редактирования сообщения: %v", err)
+		return generationStatusError(err)
 	}
 
+	return streamAIResponse(stream, responseChan, reply)
+}
+
+func (c *AIChatHandler) SelectBranch(ctx context.Context, req *aichatpb.SelectBranchRequest) (*aichatpb.ChatSession, error) {
+	userId, err := c.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetMessageId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указано сообщение")
+	}
+
+	session, err := c.aiChatUseCase.SelectBranch(ctx, userId, req.GetSessionId(), req.GetMessageId())
+	if err != nil {
+		if errors.Is(err, domain.ErrAIMessageNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	return mappers.AIChatSessionToProto(session), nil
+}
+
+func generationStatusError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidGenerationOptions), errors.Is(err, domain.ErrAIMessageNotEditable), errors.Is(err, domain.ErrInvalidChunkOffset):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrAIMessageNotFound), errors.Is(err, domain.ErrGenerationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrNothingToRegenerate):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	if st, ok := quotaStatusError(err); ok {
+		return st
+	}
+
+	return error2.ToStatusError(codes.Internal, err)
+}
+
+func (c *AIChatHandler) StopGeneration(ctx context.Context, req *aichatpb.StopGenerationRequest) (*commonpb.Empty, error) {
+	userId, err := c.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetMessageId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "не указано сообщение")
+	}
+
+	if err := c.aiChatUseCase.StopGeneration(ctx, userId, req.GetSessionId(), req.GetMessageId()); err != nil {
+		if errors.Is(err, domain.ErrGenerationNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	return &commonpb.Empty{}, nil
+}
+
+func (c *AIChatHandler) ResumeGeneration(req *aichatpb.ResumeGenerationRequest, stream aichatpb.AIChatService_ResumeGenerationServer) error {
+	ctx := stream.Context()
+	userId, err := c.getUserID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if req.GetMessageId() == "" {
+		return status.Error(codes.InvalidArgument, "не указано сообщение")
+	}
+
+	responseChan, reply, err := c.aiChatUseCase.ResumeGeneration(ctx, userId, req.GetSessionId(), req.GetMessageId(), req.GetChunkOffset())
+	if err != nil {
+		return generationStatusError(err)
+	}
+
+	return streamAIResponse(stream, responseChan, reply)
+}
+
+func streamAIResponse(stream grpc.ServerStreamingServer[aichatpb.ChatResponse], responseChan chan string, reply *domain.AIReply) error {
 	createdAt := time.Now().Unix()
 
 	for chunk := range responseChan {
 		err := stream.Send(&aichatpb.ChatResponse{
-			Id:        messageId,
+			Id:        reply.MessageId,
 			Content:   chunk,
 			Role:      "assistant",
 			CreatedAt: createdAt,
@@ -82,12 +222,17 @@ func (c *AIChatHandler) SendMessage(req *aichatpb.SendMessageRequest, stream aic
 		}
 	}
 
+	if err := stream.Context().Err(); err != nil {
+		return err
+	}
+
 	return stream.Send(&aichatpb.ChatResponse{
-		Id:        messageId,
-		Content:   "",
-		Role:      "assistant",
-		CreatedAt: createdAt,
-		Done:      true,
+		Id:           reply.MessageId,
+		Content:      "",
+		Role:         "assistant",
+		CreatedAt:    createdAt,
+		Done:         true,
+		FinishReason: string(reply.FinishReason),
 	})
 }
 
@@ -98,8 +243,11 @@ func (c *AIChatHandler) CreateSession(ctx context.Context, req *aichatpb.CreateS
 	}
 
 	logger.D("ChatHandler: создание сессии \"%s\" пользователем %d", req.GetTitle(), userId)
-	session, err := c.aiChatUseCase.CreateSession(ctx, userId, req.GetTitle(), req.GetModel())
+	session, err := c.aiChatUseCase.CreateSession(ctx, userId, req.GetTitle(), req.GetModel(), req.GetPersonaId())
 	if err != nil {
+		if errors.Is(err, domain.ErrAIPersonaNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
 		logger.E("ChatHandler: ошибка создания сессии: %v", err)
 		return nil, error2.ToStatusError(codes.Internal, err)
 	}
@@ -215,15 +363,82 @@ func (c *AIChatHandler) UpdateSessionModel(ctx context.Context, req *aichatpb.Up
 	return mappers.AIChatSessionToProto(session), nil
 }
 
+func (c *AIChatHandler) UpdateSessionKnowledgeBase(ctx context.Context, req *aichatpb.UpdateSessionKnowledgeBaseRequest) (*aichatpb.ChatSession, error) {
+	userId, err := c.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := c.aiChatUseCase.UpdateSessionKnowledgeBase(ctx, userId, req.GetSessionId(), req.GetKnowledgeBaseId())
+	if err != nil {
+		if errors.Is(err, domain.ErrKnowledgeBaseNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	return mappers.AIChatSessionToProto(session), nil
+}
+
+func (c *AIChatHandler) UpdateSessionGenerationOptions(ctx context.Context, req *aichatpb.UpdateSessionGenerationOptionsRequest) (*aichatpb.ChatSession, error) {
+	userId, err := c.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := c.aiChatUseCase.UpdateSessionGenerationOptions(ctx, userId, req.SessionId, mappers.GenerationOptionsFromProto(req.GetGenerationOptions()))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidGenerationOptions) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	return mappers.AIChatSessionToProto(session), nil
+}
+
+func (c *AIChatHandler) Embed(ctx context.Context, req *aichatpb.EmbedRequest) (*aichatpb.EmbedResponse, error) {
+	if _, err := c.getUserID(ctx); err != nil {
+		return nil, err
+	}
+
+	vectors, err := c.aiChatUseCase.Embed(ctx, req.GetModel(), req.GetInputs())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidEmbeddingInput):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, domain.ErrEmbeddingsNotSupported):
+			return nil, status.Error(codes.Unimplemented, err.Error())
+		}
+		logger.E("ChatHandler: ошибка получения эмбеддингов: %v", err)
+		return nil, error2.ToStatusError(codes.Unavailable, err)
+	}
+
+	return &aichatpb.EmbedResponse{
+		Model:      req.GetModel(),
+		Embeddings: mappers.EmbeddingsToProto(vectors),
+	}, nil
+}
+
 func (c *AIChatHandler) CheckConnection(ctx context.Context, req *commonpb.Empty) (*aichatpb.ConnectionResponse, error) {
 	return &aichatpb.ConnectionResponse{IsConnected: true}, nil
 }
 
 func (c *AIChatHandler) GetModels(ctx context.Context, req *commonpb.Empty) (*aichatpb.GetModelsResponse, error) {
-	models, err := c.aiChatUseCase.GetModels(ctx)
+	models, err := c.aiChatUseCase.GetModelsInfo(ctx)
 	if err != nil {
 		return nil, error2.ToStatusError(codes.Internal, err)
 	}
 
-	return &aichatpb.GetModelsResponse{Models: models}, nil
+	names := make([]string, len(models))
+	infos := make([]*aichatpb.ModelInfo, len(models))
+	for i, m := range models {
+		names[i] = m.Name
+		infos[i] = mappers.AIModelToProto(m)
+	}
+
+	return &aichatpb.GetModelsResponse{
+		Models:     names,
+		ModelInfos: infos,
+	}, nil
 }
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"github.com/magomedcoder/legion/internal/usecase"
 	"testing"
 
@@ -123,3 +124,133 @@ func TestAIChatHandler_UpdateSessionModel_noAuth(t *testing.T) {
 		t.Errorf("UpdateSessionModel: код %v, ожидался Unauthenticated", code)
 	}
 }
+
+func TestAIChatHandler_Embed_noAuth(t *testing.T) {
+	h := NewAIChatHandler(nil, nil)
+	ctx := context.Background()
+
+	_, err := h.Embed(ctx, &aichatpb.EmbedRequest{
+		Model:  "m",
+		Inputs: []string{"текст"},
+	})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("Embed: код %v, ожидался Unauthenticated", code)
+	}
+}
+
+func TestAIChatHandler_SelectBranch_noAuth(t *testing.T) {
+	h := NewAIChatHandler(nil, nil)
+	ctx := context.Background()
+
+	_, err := h.SelectBranch(ctx, &aichatpb.SelectBranchRequest{
+		SessionId: "id",
+		MessageId: "m",
+	})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("SelectBranch: код %v, ожидался Unauthenticated", code)
+	}
+}
+
+func TestAIChatHandler_StopGeneration_noAuth(t *testing.T) {
+	h := NewAIChatHandler(nil, nil)
+	ctx := context.Background()
+
+	_, err := h.StopGeneration(ctx, &aichatpb.StopGenerationRequest{
+		SessionId: "id",
+		MessageId: "m",
+	})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("StopGeneration: код %v, ожидался Unauthenticated", code)
+	}
+}
+
+func TestGenerationStatusError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{domain.ErrInvalidGenerationOptions, codes.InvalidArgument},
+		{domain.ErrAIMessageNotEditable, codes.InvalidArgument},
+		{domain.ErrAIMessageNotFound, codes.NotFound},
+		{domain.ErrGenerationNotFound, codes.NotFound},
+		{domain.ErrNothingToRegenerate, codes.FailedPrecondition},
+		{&domain.QuotaExceededError{Limit: domain.AIQuotaLimitRequestsPerMinute}, codes.ResourceExhausted},
+		{domain.ErrUnauthorized, codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(generationStatusError(tt.err)); got != tt.want {
+			t.Errorf("generationStatusError(%v) = %v, ожидался %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAIPersonaHandler_noAuth(t *testing.T) {
+	h := NewAIPersonaHandler(nil, nil)
+	ctx := context.Background()
+
+	if _, err := h.CreatePersona(ctx, &aichatpb.CreatePersonaRequest{Name: "n", SystemPrompt: "p"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("CreatePersona: код %v, ожидался Unauthenticated", status.Code(err))
+	}
+
+	if _, err := h.GetPersonas(ctx, &commonpb.Empty{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("GetPersonas: код %v, ожидался Unauthenticated", status.Code(err))
+	}
+
+	if _, err := h.DeletePersona(ctx, &aichatpb.DeletePersonaRequest{PersonaId: "p"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("DeletePersona: код %v, ожидался Unauthenticated", status.Code(err))
+	}
+}
+
+func TestPersonaStatusError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{domain.ErrInvalidAIPersona, codes.InvalidArgument},
+		{domain.ErrInvalidGenerationOptions, codes.InvalidArgument},
+		{domain.ErrAIPersonaNotFound, codes.NotFound},
+		{domain.ErrUnauthorized, codes.PermissionDenied},
+		{errors.New("db"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(personaStatusError(tt.err)); got != tt.want {
+			t.Errorf("personaStatusError(%v) = %v, ожидался %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAIKnowledgeHandler_noAuth(t *testing.T) {
+	h := NewAIKnowledgeHandler(nil, nil)
+	ctx := context.Background()
+
+	if _, err := h.CreateKnowledgeBase(ctx, &aichatpb.CreateKnowledgeBaseRequest{Name: "n"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("CreateKnowledgeBase: код %v, ожидался Unauthenticated", status.Code(err))
+	}
+
+	if _, err := h.AddKnowledgeDocument(ctx, &aichatpb.AddKnowledgeDocumentRequest{KnowledgeBaseId: "k", FileName: "a.txt", Content: []byte("x")}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("AddKnowledgeDocument: код %v, ожидался Unauthenticated", status.Code(err))
+	}
+
+	if _, err := h.SearchKnowledgeBase(ctx, &aichatpb.SearchKnowledgeBaseRequest{KnowledgeBaseId: "k", Query: "q"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("SearchKnowledgeBase: код %v, ожидался Unauthenticated", status.Code(err))
+	}
+}
+
+func TestKnowledgeStatusError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{domain.ErrInvalidKnowledgeBase, codes.InvalidArgument},
+		{domain.ErrEmptyKnowledgeDocument, codes.InvalidArgument},
+		{domain.ErrInvalidEmbeddingInput, codes.InvalidArgument},
+		{domain.ErrKnowledgeBaseNotFound, codes.NotFound},
+		{domain.ErrEmbeddingsNotSupported, codes.FailedPrecondition},
+		{errors.New("db"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(knowledgeStatusError(tt.err)); got != tt.want {
+			t.Errorf("knowledgeStatusError(%v) = %v, ожидался %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/api/pb/commonpb"
+	"github.com/magomedcoder/legion/internal/delivery/mappers"
+	"github.com/magomedcoder/legion/internal/delivery/middleware"
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/internal/usecase"
+	error2 "github.com/magomedcoder/legion/pkg/error"
+	"github.com/magomedcoder/legion/pkg/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AIKnowledgeHandler struct {
+	aichatpb.UnimplementedAIKnowledgeServiceServer
+	knowledgeUseCase *usecase.KnowledgeUseCase
+	authUseCase      usecase.TokenValidator
+}
+
+func NewAIKnowledgeHandler(knowledgeUseCase *usecase.KnowledgeUseCase, authUseCase usecase.TokenValidator) *AIKnowledgeHandler {
+	return &AIKnowledgeHandler{
+		knowledgeUseCase: knowledgeUseCase,
+		authUseCase:      authUseCase,
+	}
+}
+
+func (h *AIKnowledgeHandler) getUserID(ctx context.Context) (int, error) {
+	session := middleware.GetSession(ctx)
+	if session == nil {
+		return 0, status.Error(codes.Unauthenticated, "сессия не найдена")
+	}
+
+	return session.Uid, nil
+}
+
+func (h *AIKnowledgeHandler) CreateKnowledgeBase(ctx context.Context, req *aichatpb.CreateKnowledgeBaseRequest) (*aichatpb.KnowledgeBase, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	kb, err := h.knowledgeUseCase.Create(ctx, userId, req.GetName(), req.GetEmbeddingModel())
+	if err != nil {
+		return nil, knowledgeStatusError(err)
+	}
+
+	return mappers.KnowledgeBaseToProto(kb), nil
+}
+
+func (h *AIKnowledgeHandler) GetKnowledgeBases(ctx context.Context, _ *commonpb.Empty) (*aichatpb.GetKnowledgeBasesResponse, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := h.knowledgeUseCase.List(ctx, userId)
+	if err != nil {
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	items := make([]*aichatpb.KnowledgeBase, len(list))
+	for i, kb := range list {
+		items[i] = mappers.KnowledgeBaseToProto(kb)
+	}
+
+	return &aichatpb.GetKnowledgeBasesResponse{
+		KnowledgeBases: items,
+	}, nil
+}
+
+func (h *AIKnowledgeHandler) DeleteKnowledgeBase(ctx context.Context, req *aichatpb.DeleteKnowledgeBaseRequest) (*commonpb.Empty, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.knowledgeUseCase.Delete(ctx, userId, req.GetKnowledgeBaseId()); err != nil {
+		return nil, knowledgeStatusError(err)
+	}
+
+	return &commonpb.Empty{}, nil
+}
+
+func (h *AIKnowledgeHandler) AddKnowledgeDocument(ctx context.Context, req *aichatpb.AddKnowledgeDocumentRequest) (*aichatpb.KnowledgeDocument, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.GetFileName() == "" || len(req.GetContent()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "документ не предоставлен")
+	}
+
+	doc, err := h.knowledgeUseCase.AddDocument(ctx, userId, req.GetKnowledgeBaseId(), req.GetFileName(), req.GetContent())
+	if err != nil {
+		logger.E("KnowledgeHandler: ошибка добавления документа %q: %v", req.GetFileName(), err)
+		return nil, knowledgeStatusError(err)
+	}
+
+	return mappers.KnowledgeDocumentToProto(doc), nil
+}
+
+func (h *AIKnowledgeHandler) GetKnowledgeDocuments(ctx context.Context, req *aichatpb.GetKnowledgeDocumentsRequest) (*aichatpb.GetKnowledgeDocumentsResponse, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := h.knowledgeUseCase.ListDocuments(ctx, userId, req.GetKnowledgeBaseId())
+	if err != nil {
+		return nil, knowledgeStatusError(err)
+	}
+
+	items := make([]*aichatpb.KnowledgeDocument, len(docs))
+	for i, doc := range docs {
+		items[i] = mappers.KnowledgeDocumentToProto(doc)
+	}
+
+	return &aichatpb.GetKnowledgeDocumentsResponse{
+		Documents: items,
+	}, nil
+}
+
+func (h *AIKnowledgeHandler) SearchKnowledgeBase(ctx context.Context, req *aichatpb.SearchKnowledgeBaseRequest) (*aichatpb.SearchKnowledgeBaseResponse, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := h.knowledgeUseCase.Search(ctx, userId, req.GetKnowledgeBaseId(), req.GetQuery(), int(req.GetTopK()))
+	if err != nil {
+		return nil, knowledgeStatusError(err)
+	}
+
+	items := make([]*aichatpb.KnowledgeMatch, len(matches))
+	for i, match := range matches {
+		items[i] = mappers.KnowledgeMatchToProto(match)
+	}
+
+	return &aichatpb.SearchKnowledgeBaseResponse{
+		Matches: items,
+	}, nil
+}
+
+func knowledgeStatusError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidKnowledgeBase),
+		errors.Is(err, domain.ErrEmptyKnowledgeDocument),
+		errors.Is(err, domain.ErrInvalidEmbeddingInput):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrKnowledgeBaseNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrEmbeddingsNotSupported):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return error2.ToStatusError(codes.Internal, err)
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/api/pb/commonpb"
+	"github.com/magomedcoder/legion/internal/delivery/mappers"
+	"github.com/magomedcoder/legion/internal/delivery/middleware"
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/internal/usecase"
+	error2 "github.com/magomedcoder/legion/pkg/error"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AIPersonaHandler struct {
+	aichatpb.UnimplementedAIPersonaServiceServer
+	personaUseCase *usecase.AIPersonaUseCase
+	authUseCase    usecase.TokenValidator
+}
+
+func NewAIPersonaHandler(personaUseCase *usecase.AIPersonaUseCase, authUseCase usecase.TokenValidator) *AIPersonaHandler {
+	return &AIPersonaHandler{
+		personaUseCase: personaUseCase,
+		authUseCase:    authUseCase,
+	}
+}
+
+func (h *AIPersonaHandler) getUserID(ctx context.Context) (int, error) {
+	session := middleware.GetSession(ctx)
+	if session == nil {
+		return 0, status.Error(codes.Unauthenticated, "сессия не найдена")
+	}
+
+	return session.Uid, nil
+}
+
+func (h *AIPersonaHandler) CreatePersona(ctx context.Context, req *aichatpb.CreatePersonaRequest) (*aichatpb.Persona, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	persona, err := h.personaUseCase.Create(ctx, userId,
+		req.GetName(),
+		req.GetSystemPrompt(),
+		req.GetModel(),
+		mappers.GenerationOptionsFromProto(req.GetGenerationOptions()),
+		req.GetShared(),
+	)
+	if err != nil {
+		return nil, personaStatusError(err)
+	}
+
+	return mappers.AIPersonaToProto(persona), nil
+}
+
+func (h *AIPersonaHandler) GetPersonas(ctx context.Context, _ *commonpb.Empty) (*aichatpb.GetPersonasResponse, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	personas, err := h.personaUseCase.List(ctx, userId)
+	if err != nil {
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	items := make([]*aichatpb.Persona, len(personas))
+	for i, persona := range personas {
+		items[i] = mappers.AIPersonaToProto(persona)
+	}
+
+	return &aichatpb.GetPersonasResponse{
+		Personas: items,
+	}, nil
+}
+
+func (h *AIPersonaHandler) GetPersona(ctx context.Context, req *aichatpb.GetPersonaRequest) (*aichatpb.Persona, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	persona, err := h.personaUseCase.Get(ctx, userId, req.GetPersonaId())
+	if err != nil {
+		return nil, personaStatusError(err)
+	}
+
+	return mappers.AIPersonaToProto(persona), nil
+}
+
+func (h *AIPersonaHandler) UpdatePersona(ctx context.Context, req *aichatpb.UpdatePersonaRequest) (*aichatpb.Persona, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	persona, err := h.personaUseCase.Update(ctx, userId, req.GetPersonaId(),
+		req.GetName(),
+		req.GetSystemPrompt(),
+		req.GetModel(),
+		mappers.GenerationOptionsFromProto(req.GetGenerationOptions()),
+		req.GetShared(),
+	)
+	if err != nil {
+		return nil, personaStatusError(err)
+	}
+
+	return mappers.AIPersonaToProto(persona), nil
+}
+
+func (h *AIPersonaHandler) DeletePersona(ctx context.Context, req *aichatpb.DeletePersonaRequest) (*commonpb.Empty, error) {
+	userId, err := h.getUserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.personaUseCase.Delete(ctx, userId, req.GetPersonaId()); err != nil {
+		return nil, personaStatusError(err)
+	}
+
+	return &commonpb.Empty{}, nil
+}
+
+func personaStatusError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAIPersona), errors.Is(err, domain.ErrInvalidGenerationOptions):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, domain.ErrAIPersonaNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, domain.ErrUnauthorized):
+		return status.Error(codes.PermissionDenied, "изменять персону может только её автор")
+	}
+
+	return error2.ToStatusError(codes.Internal, err)
+}
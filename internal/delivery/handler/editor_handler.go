@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/magomedcoder/legion/api/pb/editorpb"
+	"github.com/magomedcoder/legion/internal/delivery/middleware"
 	"github.com/magomedcoder/legion/internal/usecase"
 	error2 "github.com/magomedcoder/legion/pkg/error"
 	"github.com/magomedcoder/legion/pkg/logger"
@@ -33,10 +34,18 @@ func (e *EditorHandler) Transform(ctx context.Context, req *editorpb.TransformRe
 		return nil, status.Error(codes.InvalidArgument, "текст не предоставлен")
 	}
 
+	session := middleware.GetSession(ctx)
+	if session == nil {
+		return nil, status.Error(codes.Unauthenticated, "сессия не найдена")
+	}
+
 	logger.D("EditorHandler: transform type=%v model=%q", req.Type, req.Model)
 
-	out, err := e.editorUseCase.Transform(ctx, req.GetModel(), req.GetText(), req.GetType(), req.GetPreserveMarkdown())
+	out, err := e.editorUseCase.Transform(ctx, session.Uid, req.GetModel(), req.GetText(), req.GetType(), req.GetPreserveMarkdown())
 	if err != nil {
+		if st, ok := quotaStatusError(err); ok {
+			return nil, st
+		}
 		return nil, error2.ToStatusError(codes.Internal, err)
 	}
 
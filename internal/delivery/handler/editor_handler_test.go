@@ -37,3 +37,12 @@ func TestEditorHandler_Transform_nilRequest_returnsInvalidArgument(t *testing.T)
 		t.Errorf("Transform(nil): код %v, ожидался InvalidArgument", code)
 	}
 }
+
+func TestEditorHandler_Transform_noSession_returnsUnauthenticated(t *testing.T) {
+	h := NewEditorHandler(&usecase.EditorUseCase{}, nil)
+
+	_, err := h.Transform(context.Background(), &editorpb.TransformRequest{Text: "привет"})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("Transform(без сессии): код %v, ожидался Unauthenticated", code)
+	}
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func quotaStatusError(err error) (error, bool) {
+	var exceeded *domain.QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		return nil, false
+	}
+
+	st := status.New(codes.ResourceExhausted, exceeded.Error())
+	detailed, detailsErr := st.WithDetails(
+		&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{
+				Subject:     "user",
+				Description: string(exceeded.Limit),
+			}},
+		},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(exceeded.RetryAfter)},
+	)
+	if detailsErr != nil {
+		return st.Err(), true
+	}
+
+	return detailed.Err(), true
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestQuotaStatusError(t *testing.T) {
+	err := fmt.Errorf("отправка: %w", &domain.QuotaExceededError{Limit: domain.AIQuotaLimitRequestsPerMinute, RetryAfter: 42 * time.Second})
+
+	st, ok := quotaStatusError(err)
+	if !ok {
+		t.Fatal("ожидалась ошибка квоты")
+	}
+
+	s := status.Convert(st)
+	if s.Code() != codes.ResourceExhausted {
+		t.Errorf("код %v, ожидался ResourceExhausted", s.Code())
+	}
+
+	var retry *errdetails.RetryInfo
+	for _, d := range s.Details() {
+		if r, ok := d.(*errdetails.RetryInfo); ok {
+			retry = r
+		}
+	}
+
+	if retry == nil || retry.GetRetryDelay().AsDuration() != 42*time.Second {
+		t.Errorf("RetryInfo: %v", retry)
+	}
+
+	if _, ok := quotaStatusError(errors.New("другая ошибка")); ok {
+		t.Error("обычная ошибка не должна считаться превышением квоты")
+	}
+}
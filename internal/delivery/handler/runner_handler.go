@@ -2,23 +2,33 @@ package handler
 
 import (
 	"context"
+	"errors"
+
 	"github.com/magomedcoder/legion/api/pb/commonpb"
 	"github.com/magomedcoder/legion/api/pb/runnerpb"
+	"github.com/magomedcoder/legion/internal/delivery/mappers"
+	"github.com/magomedcoder/legion/internal/delivery/middleware"
+	"github.com/magomedcoder/legion/internal/domain"
 	"github.com/magomedcoder/legion/internal/usecase"
+	error2 "github.com/magomedcoder/legion/pkg/error"
 	"github.com/magomedcoder/legion/pkg/logger"
 	"github.com/magomedcoder/legion/runner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type RunnerHandler struct {
 	runnerpb.UnimplementedRunnerAdminServiceServer
-	pool        *runner.Pool
-	authUseCase usecase.TokenValidator
+	pool              *runner.Pool
+	authUseCase       usecase.TokenValidator
+	credentialUseCase *usecase.RunnerCredentialUseCase
 }
 
-func NewRunnerHandler(pool *runner.Pool, authUseCase usecase.TokenValidator) *RunnerHandler {
+func NewRunnerHandler(pool *runner.Pool, authUseCase usecase.TokenValidator, credentialUseCase *usecase.RunnerCredentialUseCase) *RunnerHandler {
 	return &RunnerHandler{
-		pool:        pool,
-		authUseCase: authUseCase,
+		pool:              pool,
+		authUseCase:       authUseCase,
+		credentialUseCase: credentialUseCase,
 	}
 }
 
@@ -28,9 +38,17 @@ func (r *RunnerHandler) GetRunners(ctx context.Context, _ *commonpb.Empty) (*run
 	runners := make([]*runnerpb.RunnerInfo, len(items))
 	for i := range items {
 		ri := &runnerpb.RunnerInfo{
-			Address:   items[i].Address,
-			Enabled:   items[i].Enabled,
-			Connected: items[i].Connected,
+			Address:          items[i].Address,
+			Enabled:          items[i].Enabled,
+			Connected:        items[i].Connected,
+			Healthy:          items[i].Healthy,
+			InFlight:         int32(items[i].InFlight),
+			MaxConcurrency:   int32(items[i].MaxConcurrency),
+			ReportedInFlight: int32(items[i].ReportedInFlight),
+			Labels:           items[i].Labels,
+		}
+		if !items[i].LastSeenAt.IsZero() {
+			ri.LastSeenAt = items[i].LastSeenAt.Unix()
 		}
 		if items[i].Connected {
 			if gpuResp := r.pool.GetGpuInfo(ctx, items[i].Address); gpuResp != nil && len(gpuResp.Gpus) > 0 {
@@ -58,8 +76,132 @@ func (r *RunnerHandler) SetRunnerEnabled(ctx context.Context, req *runnerpb.SetR
 	return &commonpb.Empty{}, nil
 }
 
+func (r *RunnerHandler) SetRunnerLabels(ctx context.Context, req *runnerpb.SetRunnerLabelsRequest) (*commonpb.Empty, error) {
+	if req == nil || req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "адрес раннера не указан")
+	}
+
+	logger.I("RunnerHandler: setRunnerLabels %s labels=%v", req.Address, req.Labels)
+	if !r.pool.SetRunnerLabels(req.Address, req.Labels) {
+		return nil, status.Error(codes.NotFound, "раннер не найден")
+	}
+
+	return &commonpb.Empty{}, nil
+}
+
+func (r *RunnerHandler) DeleteRunner(ctx context.Context, req *runnerpb.DeleteRunnerRequest) (*commonpb.Empty, error) {
+	if req == nil || req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "адрес раннера не указан")
+	}
+
+	logger.I("RunnerHandler: deleteRunner %s", req.Address)
+	if err := r.pool.Forget(ctx, req.Address); err != nil {
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	return &commonpb.Empty{}, nil
+}
+
 func (r *RunnerHandler) GetRunnersStatus(ctx context.Context, _ *commonpb.Empty) (*runnerpb.GetRunnersStatusResponse, error) {
-	return &runnerpb.GetRunnersStatusResponse{
+	resp := &runnerpb.GetRunnersStatusResponse{
 		HasActiveRunners: r.pool.HasActiveRunners(),
+	}
+
+	if !r.isAdmin(ctx) {
+		return resp, nil
+	}
+
+	for _, h := range r.pool.GetHealth() {
+		item := &runnerpb.RunnerHealth{
+			Address:             h.Address,
+			Healthy:             h.Healthy,
+			ConsecutiveFailures: int32(h.ConsecutiveFailures),
+			LatencyMs:           h.Latency.Milliseconds(),
+			LastError:           h.LastError,
+		}
+		if !h.LastCheckAt.IsZero() {
+			item.LastCheckAt = h.LastCheckAt.Unix()
+		}
+		resp.Runners = append(resp.Runners, item)
+	}
+
+	return resp, nil
+}
+
+func (r *RunnerHandler) isAdmin(ctx context.Context) bool {
+	if r.authUseCase == nil {
+		return false
+	}
+
+	user, err := middleware.GetUserFromContext(ctx, r.authUseCase)
+	if err != nil {
+		return false
+	}
+
+	return user.Role >= domain.UserRoleAdmin
+}
+
+func (r *RunnerHandler) CreateRunnerCredential(ctx context.Context, req *runnerpb.CreateRunnerCredentialRequest) (*runnerpb.CreateRunnerCredentialResponse, error) {
+	if r.credentialUseCase == nil {
+		return nil, status.Error(codes.Unimplemented, "учётные данные раннеров не настроены")
+	}
+
+	if req == nil || req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "имя раннера обязательно")
+	}
+
+	session := middleware.GetSession(ctx)
+	if session == nil {
+		return nil, error2.ToStatusError(codes.Unauthenticated, nil)
+	}
+
+	logger.I("RunnerHandler: выдача учётных данных раннера %q пользователем %d", req.Name, session.Uid)
+	credential, token, err := r.credentialUseCase.Create(ctx, session.Uid, req.Name, req.Address)
+	if err != nil {
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	return &runnerpb.CreateRunnerCredentialResponse{
+		Credential: mappers.RunnerCredentialToProto(credential),
+		Token:      token,
 	}, nil
 }
+
+func (r *RunnerHandler) ListRunnerCredentials(ctx context.Context, _ *commonpb.Empty) (*runnerpb.ListRunnerCredentialsResponse, error) {
+	if r.credentialUseCase == nil {
+		return nil, status.Error(codes.Unimplemented, "учётные данные раннеров не настроены")
+	}
+
+	credentials, err := r.credentialUseCase.List(ctx)
+	if err != nil {
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	resp := &runnerpb.ListRunnerCredentialsResponse{
+		Credentials: make([]*runnerpb.RunnerCredential, 0, len(credentials)),
+	}
+	for _, c := range credentials {
+		resp.Credentials = append(resp.Credentials, mappers.RunnerCredentialToProto(c))
+	}
+
+	return resp, nil
+}
+
+func (r *RunnerHandler) RevokeRunnerCredential(ctx context.Context, req *runnerpb.RevokeRunnerCredentialRequest) (*commonpb.Empty, error) {
+	if r.credentialUseCase == nil {
+		return nil, status.Error(codes.Unimplemented, "учётные данные раннеров не настроены")
+	}
+
+	if req == nil || req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "идентификатор учётных данных обязателен")
+	}
+
+	if err := r.credentialUseCase.Revoke(ctx, req.Id); err != nil {
+		if errors.Is(err, domain.ErrRunnerCredentialNotFound) {
+			return nil, error2.ToStatusError(codes.NotFound, err)
+		}
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	return &commonpb.Empty{}, nil
+}
@@ -11,7 +11,7 @@ import (
 
 func TestRunnerHandler_GetRunners_returnsEmptyList(t *testing.T) {
 	pool := runner.NewPool(nil)
-	h := NewRunnerHandler(pool, nil)
+	h := NewRunnerHandler(pool, nil, nil)
 	ctx := context.Background()
 
 	resp, err := h.GetRunners(ctx, &commonpb.Empty{})
@@ -25,7 +25,7 @@ func TestRunnerHandler_GetRunners_returnsEmptyList(t *testing.T) {
 
 func TestRunnerHandler_GetRunnersStatus_returnsResponse(t *testing.T) {
 	pool := runner.NewPool(nil)
-	h := NewRunnerHandler(pool, nil)
+	h := NewRunnerHandler(pool, nil, nil)
 	ctx := context.Background()
 
 	resp, err := h.GetRunnersStatus(ctx, &commonpb.Empty{})
@@ -42,9 +42,27 @@ func TestRunnerHandler_GetRunnersStatus_returnsResponse(t *testing.T) {
 	}
 }
 
+func TestRunnerHandler_GetRunnersStatus_hidesRunnersFromNonAdmin(t *testing.T) {
+	pool := runner.NewPool([]string{"a:1"})
+	h := NewRunnerHandler(pool, nil, nil)
+
+	resp, err := h.GetRunnersStatus(context.Background(), &commonpb.Empty{})
+	if err != nil {
+		t.Fatalf("GetRunnersStatus: %v", err)
+	}
+
+	if !resp.HasActiveRunners {
+		t.Error("GetRunnersStatus: ожидалось HasActiveRunners=true")
+	}
+
+	if len(resp.Runners) != 0 {
+		t.Errorf("GetRunnersStatus: состояние раннеров не должно отдаваться без прав администратора: %v", resp.Runners)
+	}
+}
+
 func TestRunnerHandler_SetRunnerEnabled_emptyAddress_noError(t *testing.T) {
 	pool := runner.NewPool(nil)
-	h := NewRunnerHandler(pool, nil)
+	h := NewRunnerHandler(pool, nil, nil)
 	ctx := context.Background()
 
 	_, err := h.SetRunnerEnabled(ctx, &runnerpb.SetRunnerEnabledRequest{
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/magomedcoder/legion/api/pb/usagepb"
+	"github.com/magomedcoder/legion/internal/delivery/mappers"
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/internal/usecase"
+	error2 "github.com/magomedcoder/legion/pkg/error"
+	"github.com/magomedcoder/legion/pkg/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type UsageHandler struct {
+	usagepb.UnimplementedUsageServiceServer
+	usageUseCase *usecase.UsageUseCase
+}
+
+func NewUsageHandler(usageUseCase *usecase.UsageUseCase) *UsageHandler {
+	return &UsageHandler{
+		usageUseCase: usageUseCase,
+	}
+}
+
+func (h *UsageHandler) GetTokenUsage(ctx context.Context, req *usagepb.GetTokenUsageRequest) (*usagepb.GetTokenUsageResponse, error) {
+	filter := domain.UsageFilter{
+		GroupBy: mappers.UsageGroupByFromProto(req.GetGroupBy()),
+		Model:   req.GetModel(),
+	}
+	if req.GetFrom() > 0 {
+		filter.From = time.Unix(req.GetFrom(), 0)
+	}
+	if req.GetTo() > 0 {
+		filter.To = time.Unix(req.GetTo(), 0)
+	}
+	if req.GetUserId() != "" {
+		userId, err := strconv.Atoi(req.GetUserId())
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, "неверный id пользователя")
+		}
+		filter.UserId = userId
+	}
+
+	logger.D("UsageHandler: статистика токенов по %s", filter.GroupBy)
+	stats, err := h.usageUseCase.GetTokenUsage(ctx, filter)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidUsageFilter) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, error2.ToStatusError(codes.Internal, err)
+	}
+
+	resp := &usagepb.GetTokenUsageResponse{
+		Stats: make([]*usagepb.TokenUsageStat, 0, len(stats)),
+	}
+	for _, stat := range stats {
+		resp.Stats = append(resp.Stats, mappers.UsageStatToProto(stat))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/api/pb/usagepb"
+	"github.com/magomedcoder/legion/internal/usecase"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUsageHandler_GetTokenUsage_invalidUserId(t *testing.T) {
+	h := NewUsageHandler(usecase.NewUsageUseCase(nil))
+
+	_, err := h.GetTokenUsage(context.Background(), &usagepb.GetTokenUsageRequest{UserId: "abc"})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("GetTokenUsage(неверный id): код %v, ожидался InvalidArgument", code)
+	}
+}
+
+func TestUsageHandler_GetTokenUsage_invalidPeriod(t *testing.T) {
+	h := NewUsageHandler(usecase.NewUsageUseCase(nil))
+	now := time.Now().Unix()
+
+	_, err := h.GetTokenUsage(context.Background(), &usagepb.GetTokenUsageRequest{From: now, To: now - 3600})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("GetTokenUsage(перевёрнутый период): код %v, ожидался InvalidArgument", code)
+	}
+}
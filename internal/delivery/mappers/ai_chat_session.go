@@ -11,10 +11,14 @@ func AIChatSessionToProto(session *domain.AIChatSession) *aichatpb.ChatSession {
 	}
 
 	return &aichatpb.ChatSession{
-		Id:        session.Id,
-		Title:     session.Title,
-		Model:     session.Model,
-		CreatedAt: session.CreatedAt.Unix(),
-		UpdatedAt: session.UpdatedAt.Unix(),
+		Id:                session.Id,
+		Title:             session.Title,
+		Model:             session.Model,
+		GenerationOptions: GenerationOptionsToProto(session.GenerationOptions),
+		ActiveMessageId:   session.ActiveMessageId,
+		PersonaId:         session.PersonaId,
+		KnowledgeBaseId:   session.KnowledgeBaseId,
+		CreatedAt:         session.CreatedAt.Unix(),
+		UpdatedAt:         session.UpdatedAt.Unix(),
 	}
 }
@@ -12,10 +12,20 @@ func AIMessageToProto(msg *domain.AIChatMessage) *aichatpb.ChatMessage {
 	}
 
 	p := &aichatpb.ChatMessage{
-		Id:        msg.Id,
-		Content:   msg.Content,
-		Role:      domain.AIToProtoRole(msg.Role),
-		CreatedAt: msg.CreatedAt.Unix(),
+		Id:           msg.Id,
+		Content:      msg.Content,
+		Role:         domain.AIToProtoRole(msg.Role),
+		CreatedAt:    msg.CreatedAt.Unix(),
+		Model:        msg.Model,
+		Usage:        TokenUsageToProto(msg.Usage),
+		ParentId:     msg.ParentId,
+		SiblingCount: msg.SiblingCount,
+		SiblingIndex: msg.SiblingIndex,
+		Stopped:      msg.Stopped,
+		FinishReason: string(msg.FinishReason),
+		ToolCalls:    AIToolCallsToProto(msg.ToolCalls),
+		ToolCallId:   msg.ToolCallId,
+		ToolName:     msg.ToolName,
 	}
 	if msg.AttachmentName != "" {
 		p.AttachmentName = &msg.AttachmentName
@@ -30,12 +40,15 @@ func AIMessageFromProto(proto *aichatpb.ChatMessage, sessionID string) *domain.A
 	}
 
 	msg := &domain.AIChatMessage{
-		Id:        proto.Id,
-		SessionId: sessionID,
-		Content:   proto.Content,
-		Role:      domain.AIFromProtoRole(proto.Role),
-		CreatedAt: time.Unix(proto.CreatedAt, 0),
-		UpdatedAt: time.Unix(proto.CreatedAt, 0),
+		Id:         proto.Id,
+		SessionId:  sessionID,
+		Content:    proto.Content,
+		Role:       domain.AIFromProtoRole(proto.Role),
+		ToolCalls:  AIToolCallsFromProto(proto.ToolCalls),
+		ToolCallId: proto.ToolCallId,
+		ToolName:   proto.ToolName,
+		CreatedAt:  time.Unix(proto.CreatedAt, 0),
+		UpdatedAt:  time.Unix(proto.CreatedAt, 0),
 	}
 	if proto.AttachmentName != nil {
 		msg.AttachmentName = *proto.AttachmentName
@@ -94,3 +94,28 @@ func TestMessagesFromProto(t *testing.T) {
 		t.Errorf("MessagesFromProto: неверный результат %+v", got)
 	}
 }
+
+func TestMessageToProto_branch(t *testing.T) {
+	got := AIMessageToProto(&domain.AIChatMessage{
+		Id:           "m",
+		Role:         domain.AIChatMessageRoleAssistant,
+		ParentId:     "p",
+		SiblingCount: 3,
+		SiblingIndex: 1,
+	})
+	if got.ParentId != "p" || got.SiblingCount != 3 || got.SiblingIndex != 1 {
+		t.Errorf("поля ветки неверны: %+v", got)
+	}
+}
+
+func TestMessageToProto_finishReason(t *testing.T) {
+	got := AIMessageToProto(&domain.AIChatMessage{
+		Id:           "m",
+		Role:         domain.AIChatMessageRoleAssistant,
+		Stopped:      true,
+		FinishReason: domain.AIFinishReasonStopped,
+	})
+	if !got.Stopped || got.FinishReason != "stopped" {
+		t.Errorf("признак остановки неверен: %+v", got)
+	}
+}
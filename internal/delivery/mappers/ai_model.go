@@ -0,0 +1,17 @@
+package mappers
+
+import (
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func AIModelToProto(model *domain.AIModel) *aichatpb.ModelInfo {
+	if model == nil {
+		return nil
+	}
+
+	return &aichatpb.ModelInfo{
+		Name:    model.Name,
+		Runners: model.Runners,
+	}
+}
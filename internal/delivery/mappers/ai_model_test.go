@@ -0,0 +1,27 @@
+package mappers
+
+import (
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestAIModelToProto_nil(t *testing.T) {
+	if got := AIModelToProto(nil); got != nil {
+		t.Errorf("AIModelToProto(nil) = %v, ожидалось nil", got)
+	}
+}
+
+func TestAIModelToProto(t *testing.T) {
+	got := AIModelToProto(&domain.AIModel{
+		Name:    "m",
+		Runners: []string{"a:1", "b:2"},
+	})
+	if got == nil {
+		t.Fatal("ожидался непустой результат")
+	}
+
+	if got.Name != "m" || len(got.Runners) != 2 || got.Runners[0] != "a:1" {
+		t.Errorf("AIModelToProto: неверные поля %+v", got)
+	}
+}
@@ -0,0 +1,24 @@
+package mappers
+
+import (
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func AIPersonaToProto(persona *domain.AIPersona) *aichatpb.Persona {
+	if persona == nil {
+		return nil
+	}
+
+	return &aichatpb.Persona{
+		Id:                persona.Id,
+		UserId:            int64(persona.UserId),
+		Name:              persona.Name,
+		SystemPrompt:      persona.SystemPrompt,
+		Model:             persona.Model,
+		GenerationOptions: GenerationOptionsToProto(persona.GenerationOptions),
+		Shared:            persona.Shared,
+		CreatedAt:         persona.CreatedAt.Unix(),
+		UpdatedAt:         persona.UpdatedAt.Unix(),
+	}
+}
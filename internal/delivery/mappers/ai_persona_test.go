@@ -0,0 +1,35 @@
+package mappers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestAIPersonaToProto(t *testing.T) {
+	if got := AIPersonaToProto(nil); got != nil {
+		t.Errorf("AIPersonaToProto(nil) = %v, ожидалось nil", got)
+	}
+
+	ts := time.Now()
+	temperature := float32(0.2)
+	got := AIPersonaToProto(&domain.AIPersona{
+		Id:                "p",
+		UserId:            3,
+		Name:              "Юрист",
+		SystemPrompt:      "Отвечай как юрист",
+		Model:             "m",
+		GenerationOptions: &domain.GenerationOptions{Temperature: &temperature},
+		Shared:            true,
+		CreatedAt:         ts,
+		UpdatedAt:         ts,
+	})
+	if got.Id != "p" || got.UserId != 3 || got.Name != "Юрист" || got.SystemPrompt != "Отвечай как юрист" || !got.Shared || got.CreatedAt != ts.Unix() {
+		t.Errorf("AIPersonaToProto: неверные поля %+v", got)
+	}
+
+	if got.GenerationOptions == nil || got.GenerationOptions.GetTemperature() != 0.2 {
+		t.Errorf("параметры генерации не переданы: %+v", got.GenerationOptions)
+	}
+}
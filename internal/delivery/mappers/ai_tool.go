@@ -0,0 +1,76 @@
+package mappers
+
+import (
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func AIToolsToProto(tools []*domain.AITool) []*aichatpb.Tool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	out := make([]*aichatpb.Tool, len(tools))
+	for i, t := range tools {
+		out[i] = &aichatpb.Tool{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  string(t.Parameters),
+		}
+	}
+
+	return out
+}
+
+func AIToolsFromProto(tools []*aichatpb.Tool) []*domain.AITool {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	out := make([]*domain.AITool, len(tools))
+	for i, t := range tools {
+		out[i] = &domain.AITool{
+			Name:        t.GetName(),
+			Description: t.GetDescription(),
+		}
+		if t.GetParameters() != "" {
+			out[i].Parameters = []byte(t.GetParameters())
+		}
+	}
+
+	return out
+}
+
+func AIToolCallsToProto(calls []*domain.AIToolCall) []*aichatpb.ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	out := make([]*aichatpb.ToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = &aichatpb.ToolCall{
+			Id:        c.Id,
+			Name:      c.Name,
+			Arguments: c.Arguments,
+		}
+	}
+
+	return out
+}
+
+func AIToolCallsFromProto(calls []*aichatpb.ToolCall) []*domain.AIToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	out := make([]*domain.AIToolCall, len(calls))
+	for i, c := range calls {
+		out[i] = &domain.AIToolCall{
+			Id:        c.GetId(),
+			Name:      c.GetName(),
+			Arguments: c.GetArguments(),
+		}
+	}
+
+	return out
+}
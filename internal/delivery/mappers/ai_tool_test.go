@@ -0,0 +1,43 @@
+package mappers
+
+import (
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestAIToolsRoundTrip(t *testing.T) {
+	if AIToolsToProto(nil) != nil || AIToolsFromProto(nil) != nil {
+		t.Error("пустой список инструментов должен давать nil")
+	}
+
+	tools := AIToolsFromProto(AIToolsToProto([]*domain.AITool{
+		{Name: "get_task", Description: "d", Parameters: []byte(`{"type":"object"}`)},
+		{Name: "ping"},
+	}))
+	if len(tools) != 2 || tools[0].Name != "get_task" || string(tools[0].Parameters) != `{"type":"object"}` {
+		t.Fatalf("инструменты: %+v", tools)
+	}
+
+	if tools[1].Parameters != nil {
+		t.Errorf("пустая схема должна оставаться пустой: %q", tools[1].Parameters)
+	}
+}
+
+func TestAIMessage_toolCallsRoundTrip(t *testing.T) {
+	msg := &domain.AIChatMessage{
+		Id:        "m",
+		Role:      domain.AIChatMessageRoleAssistant,
+		ToolCalls: []*domain.AIToolCall{{Id: "c1", Name: "get_task", Arguments: `{"task_id":"t"}`}},
+	}
+	got := AIMessageFromProto(AIMessageToProto(msg), "s")
+	if len(got.ToolCalls) != 1 || *got.ToolCalls[0] != *msg.ToolCalls[0] {
+		t.Errorf("вызовы инструментов: %+v", got.ToolCalls)
+	}
+
+	result := domain.NewAIToolResultMessage("s", msg.ToolCalls[0], "{}")
+	got = AIMessageFromProto(AIMessageToProto(result), "s")
+	if got.Role != domain.AIChatMessageRoleTool || got.ToolCallId != "c1" || got.ToolName != "get_task" {
+		t.Errorf("результат инструмента: %+v", got)
+	}
+}
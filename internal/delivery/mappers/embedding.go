@@ -0,0 +1,23 @@
+package mappers
+
+import "github.com/magomedcoder/legion/api/pb/aichatpb"
+
+func EmbeddingsToProto(vectors [][]float32) []*aichatpb.Embedding {
+	out := make([]*aichatpb.Embedding, len(vectors))
+	for i, v := range vectors {
+		out[i] = &aichatpb.Embedding{
+			Values: v,
+		}
+	}
+
+	return out
+}
+
+func EmbeddingsFromProto(embeddings []*aichatpb.Embedding) [][]float32 {
+	out := make([][]float32, len(embeddings))
+	for i, e := range embeddings {
+		out[i] = e.GetValues()
+	}
+
+	return out
+}
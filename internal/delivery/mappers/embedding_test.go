@@ -0,0 +1,16 @@
+package mappers
+
+import "testing"
+
+func TestEmbeddings_roundTrip(t *testing.T) {
+	vectors := [][]float32{{0.1, 0.2}, {0.3}}
+
+	got := EmbeddingsFromProto(EmbeddingsToProto(vectors))
+	if len(got) != 2 || len(got[0]) != 2 || got[0][1] != 0.2 || got[1][0] != 0.3 {
+		t.Errorf("EmbeddingsFromProto(EmbeddingsToProto) = %v", got)
+	}
+
+	if got := EmbeddingsFromProto(nil); len(got) != 0 {
+		t.Errorf("EmbeddingsFromProto(nil) = %v", got)
+	}
+}
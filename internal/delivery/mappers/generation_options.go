@@ -0,0 +1,41 @@
+package mappers
+
+import (
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func GenerationOptionsToProto(opts *domain.GenerationOptions) *aichatpb.GenerationOptions {
+	if opts.IsEmpty() {
+		return nil
+	}
+
+	return &aichatpb.GenerationOptions{
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		TopK:        opts.TopK,
+		MaxTokens:   opts.MaxTokens,
+		Stop:        append([]string(nil), opts.Stop...),
+		Seed:        opts.Seed,
+	}
+}
+
+func GenerationOptionsFromProto(proto *aichatpb.GenerationOptions) *domain.GenerationOptions {
+	if proto == nil {
+		return nil
+	}
+
+	opts := &domain.GenerationOptions{
+		Temperature: proto.Temperature,
+		TopP:        proto.TopP,
+		TopK:        proto.TopK,
+		MaxTokens:   proto.MaxTokens,
+		Stop:        append([]string(nil), proto.Stop...),
+		Seed:        proto.Seed,
+	}
+	if opts.IsEmpty() {
+		return nil
+	}
+
+	return opts
+}
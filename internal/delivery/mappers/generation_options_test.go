@@ -0,0 +1,47 @@
+package mappers
+
+import (
+	"testing"
+
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestGenerationOptionsToProto_empty(t *testing.T) {
+	if got := GenerationOptionsToProto(nil); got != nil {
+		t.Errorf("GenerationOptionsToProto(nil) = %v, ожидалось nil", got)
+	}
+
+	if got := GenerationOptionsToProto(&domain.GenerationOptions{}); got != nil {
+		t.Errorf("GenerationOptionsToProto(пустые) = %v, ожидалось nil", got)
+	}
+}
+
+func TestGenerationOptions_roundTrip(t *testing.T) {
+	temperature := float32(0.2)
+	maxTokens := int32(256)
+	seed := int64(42)
+	got := GenerationOptionsFromProto(GenerationOptionsToProto(&domain.GenerationOptions{
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+		Stop:        []string{"###"},
+		Seed:        &seed,
+	}))
+	if got == nil {
+		t.Fatal("ожидался непустой результат")
+	}
+
+	if *got.Temperature != 0.2 || *got.MaxTokens != 256 || *got.Seed != 42 || len(got.Stop) != 1 || got.Stop[0] != "###" {
+		t.Errorf("неверные параметры после преобразования: %+v", got)
+	}
+
+	if got.TopP != nil || got.TopK != nil {
+		t.Errorf("незаданные параметры должны остаться nil: %+v", got)
+	}
+}
+
+func TestGenerationOptionsFromProto_empty(t *testing.T) {
+	if got := GenerationOptionsFromProto(&aichatpb.GenerationOptions{}); got != nil {
+		t.Errorf("пустое сообщение должно давать nil, получено %+v", got)
+	}
+}
@@ -0,0 +1,50 @@
+package mappers
+
+import (
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func KnowledgeBaseToProto(kb *domain.KnowledgeBase) *aichatpb.KnowledgeBase {
+	if kb == nil {
+		return nil
+	}
+
+	return &aichatpb.KnowledgeBase{
+		Id:             kb.Id,
+		Name:           kb.Name,
+		EmbeddingModel: kb.EmbeddingModel,
+		CreatedAt:      kb.CreatedAt.Unix(),
+		UpdatedAt:      kb.UpdatedAt.Unix(),
+	}
+}
+
+func KnowledgeDocumentToProto(doc *domain.KnowledgeDocument) *aichatpb.KnowledgeDocument {
+	if doc == nil {
+		return nil
+	}
+
+	return &aichatpb.KnowledgeDocument{
+		Id:              doc.Id,
+		KnowledgeBaseId: doc.KnowledgeBaseId,
+		FileId:          doc.FileId,
+		Name:            doc.Name,
+		Chunks:          int32(doc.Chunks),
+		CreatedAt:       doc.CreatedAt.Unix(),
+	}
+}
+
+func KnowledgeMatchToProto(match *domain.KnowledgeMatch) *aichatpb.KnowledgeMatch {
+	if match == nil || match.Chunk == nil {
+		return nil
+	}
+
+	return &aichatpb.KnowledgeMatch{
+		DocumentId:   match.Chunk.DocumentId,
+		DocumentName: match.Chunk.DocumentName,
+		Start:        int32(match.Chunk.Start),
+		End:          int32(match.Chunk.End),
+		Content:      match.Chunk.Content,
+		Score:        match.Score,
+	}
+}
@@ -0,0 +1,45 @@
+package mappers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestKnowledgeBaseToProto(t *testing.T) {
+	if got := KnowledgeBaseToProto(nil); got != nil {
+		t.Errorf("KnowledgeBaseToProto(nil) = %v, ожидалось nil", got)
+	}
+
+	ts := time.Now()
+	got := KnowledgeBaseToProto(&domain.KnowledgeBase{Id: "kb", Name: "Договоры", EmbeddingModel: "nomic", CreatedAt: ts, UpdatedAt: ts})
+	if got.Id != "kb" || got.Name != "Договоры" || got.EmbeddingModel != "nomic" || got.CreatedAt != ts.Unix() {
+		t.Errorf("KnowledgeBaseToProto: неверные поля %+v", got)
+	}
+}
+
+func TestKnowledgeDocumentToProto(t *testing.T) {
+	if got := KnowledgeDocumentToProto(nil); got != nil {
+		t.Errorf("KnowledgeDocumentToProto(nil) = %v, ожидалось nil", got)
+	}
+
+	got := KnowledgeDocumentToProto(&domain.KnowledgeDocument{Id: "d", KnowledgeBaseId: "kb", FileId: "f", Name: "a.pdf", Chunks: 7})
+	if got.Id != "d" || got.FileId != "f" || got.Name != "a.pdf" || got.Chunks != 7 {
+		t.Errorf("KnowledgeDocumentToProto: неверные поля %+v", got)
+	}
+}
+
+func TestKnowledgeMatchToProto(t *testing.T) {
+	if got := KnowledgeMatchToProto(&domain.KnowledgeMatch{}); got != nil {
+		t.Errorf("совпадение без фрагмента: %v, ожидалось nil", got)
+	}
+
+	got := KnowledgeMatchToProto(&domain.KnowledgeMatch{
+		Chunk: &domain.KnowledgeChunk{DocumentId: "d", DocumentName: "a.pdf", Start: 10, End: 20, Content: "текст"},
+		Score: 0.75,
+	})
+	if got.DocumentName != "a.pdf" || got.Start != 10 || got.End != 20 || got.Content != "текст" || got.Score != 0.75 {
+		t.Errorf("KnowledgeMatchToProto: неверные поля %+v", got)
+	}
+}
@@ -0,0 +1,26 @@
+package mappers
+
+import (
+	"github.com/magomedcoder/legion/api/pb/runnerpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func RunnerCredentialToProto(credential *domain.RunnerCredential) *runnerpb.RunnerCredential {
+	if credential == nil {
+		return nil
+	}
+
+	out := &runnerpb.RunnerCredential{
+		Id:        credential.Id,
+		Name:      credential.Name,
+		Address:   credential.Address,
+		CreatedBy: int32(credential.CreatedBy),
+		CreatedAt: credential.CreatedAt.Unix(),
+		Revoked:   credential.IsRevoked(),
+	}
+	if credential.LastUsedAt != nil {
+		out.LastUsedAt = credential.LastUsedAt.Unix()
+	}
+
+	return out
+}
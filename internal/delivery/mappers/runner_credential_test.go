@@ -0,0 +1,40 @@
+package mappers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestRunnerCredentialToProto_nil(t *testing.T) {
+	if got := RunnerCredentialToProto(nil); got != nil {
+		t.Errorf("RunnerCredentialToProto(nil) = %v, ожидалось nil", got)
+	}
+}
+
+func TestRunnerCredentialToProto(t *testing.T) {
+	used := time.Unix(1700000100, 0)
+	revoked := time.Unix(1700000200, 0)
+	got := RunnerCredentialToProto(&domain.RunnerCredential{
+		Id:         "id-1",
+		Name:       "gpu-1",
+		Address:    "10.0.0.1:50052",
+		TokenHash:  "secret",
+		CreatedBy:  7,
+		CreatedAt:  time.Unix(1700000000, 0),
+		LastUsedAt: &used,
+		RevokedAt:  &revoked,
+	})
+	if got == nil {
+		t.Fatal("ожидался непустой результат")
+	}
+
+	if got.Id != "id-1" || got.Name != "gpu-1" || got.Address != "10.0.0.1:50052" || got.CreatedBy != 7 {
+		t.Errorf("RunnerCredentialToProto: неверные поля %+v", got)
+	}
+
+	if got.CreatedAt != 1700000000 || got.LastUsedAt != 1700000100 || !got.Revoked {
+		t.Errorf("RunnerCredentialToProto: неверные даты или статус %+v", got)
+	}
+}
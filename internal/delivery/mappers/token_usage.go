@@ -0,0 +1,28 @@
+package mappers
+
+import (
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TokenUsageToProto(usage *domain.TokenUsage) *aichatpb.TokenUsage {
+	if usage.IsEmpty() {
+		return nil
+	}
+
+	return &aichatpb.TokenUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+	}
+}
+
+func TokenUsageFromProto(proto *aichatpb.TokenUsage) *domain.TokenUsage {
+	if proto == nil {
+		return nil
+	}
+
+	return &domain.TokenUsage{
+		PromptTokens:     proto.PromptTokens,
+		CompletionTokens: proto.CompletionTokens,
+	}
+}
@@ -0,0 +1,18 @@
+package mappers
+
+import (
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestTokenUsage_roundTrip(t *testing.T) {
+	got := TokenUsageFromProto(TokenUsageToProto(&domain.TokenUsage{PromptTokens: 5, CompletionTokens: 8}))
+	if got == nil || got.PromptTokens != 5 || got.CompletionTokens != 8 {
+		t.Errorf("TokenUsageFromProto(TokenUsageToProto) = %+v", got)
+	}
+
+	if TokenUsageToProto(&domain.TokenUsage{}) != nil || TokenUsageFromProto(nil) != nil {
+		t.Error("пустой usage должен отображаться в nil")
+	}
+}
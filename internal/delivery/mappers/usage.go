@@ -0,0 +1,41 @@
+package mappers
+
+import (
+	"strconv"
+
+	"github.com/magomedcoder/legion/api/pb/usagepb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func UsageGroupByFromProto(groupBy usagepb.UsageGroupBy) domain.UsageGroupBy {
+	switch groupBy {
+	case usagepb.UsageGroupBy_USAGE_GROUP_BY_MODEL:
+		return domain.UsageGroupByModel
+	case usagepb.UsageGroupBy_USAGE_GROUP_BY_DAY:
+		return domain.UsageGroupByDay
+	default:
+		return domain.UsageGroupByUser
+	}
+}
+
+func UsageStatToProto(stat *domain.UsageStat) *usagepb.TokenUsageStat {
+	if stat == nil {
+		return nil
+	}
+
+	p := &usagepb.TokenUsageStat{
+		Model:            stat.Model,
+		PromptTokens:     stat.PromptTokens,
+		CompletionTokens: stat.CompletionTokens,
+		TotalTokens:      stat.PromptTokens + stat.CompletionTokens,
+		Messages:         stat.Messages,
+	}
+	if stat.UserId != 0 {
+		p.UserId = strconv.Itoa(stat.UserId)
+	}
+	if !stat.Day.IsZero() {
+		p.Day = stat.Day.Unix()
+	}
+
+	return p
+}
@@ -0,0 +1,36 @@
+package mappers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/api/pb/usagepb"
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestUsageGroupByFromProto(t *testing.T) {
+	tests := map[usagepb.UsageGroupBy]domain.UsageGroupBy{
+		usagepb.UsageGroupBy_USAGE_GROUP_BY_UNSPECIFIED: domain.UsageGroupByUser,
+		usagepb.UsageGroupBy_USAGE_GROUP_BY_USER:        domain.UsageGroupByUser,
+		usagepb.UsageGroupBy_USAGE_GROUP_BY_MODEL:       domain.UsageGroupByModel,
+		usagepb.UsageGroupBy_USAGE_GROUP_BY_DAY:         domain.UsageGroupByDay,
+	}
+
+	for in, want := range tests {
+		if got := UsageGroupByFromProto(in); got != want {
+			t.Errorf("UsageGroupByFromProto(%v) = %q, ожидалось %q", in, got, want)
+		}
+	}
+}
+
+func TestUsageStatToProto(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	got := UsageStatToProto(&domain.UsageStat{UserId: 7, Day: day, PromptTokens: 100, CompletionTokens: 20, Messages: 3})
+	if got.UserId != "7" || got.Day != day.Unix() || got.TotalTokens != 120 || got.Messages != 3 {
+		t.Errorf("UsageStatToProto: %+v", got)
+	}
+
+	if UsageStatToProto(nil) != nil {
+		t.Error("UsageStatToProto(nil) должен вернуть nil")
+	}
+}
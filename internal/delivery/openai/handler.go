@@ -0,0 +1,291 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/internal/usecase"
+	"github.com/magomedcoder/legion/pkg/logger"
+	"github.com/magomedcoder/legion/runner"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxRequestBodyBytes = 4 << 20
+
+type userKey struct{}
+
+type Handler struct {
+	llm          domain.LLMProvider
+	authUseCase  usecase.TokenValidator
+	quotaUseCase *usecase.QuotaUseCase
+	usageUseCase *usecase.UsageUseCase
+	mux          *http.ServeMux
+}
+
+func NewHandler(llm domain.LLMProvider, authUseCase usecase.TokenValidator, quotaUseCase *usecase.QuotaUseCase, usageUseCase *usecase.UsageUseCase) *Handler {
+	h := &Handler{
+		llm:          llm,
+		authUseCase:  authUseCase,
+		quotaUseCase: quotaUseCase,
+		usageUseCase: usageUseCase,
+		mux:          http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET /v1/models", h.authorize(h.listModels))
+	h.mux.HandleFunc("POST /v1/chat/completions", h.authorize(h.chatCompletions))
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || strings.TrimSpace(token) == "" {
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "заголовок авторизации не предоставлен")
+			return
+		}
+
+		user, err := h.authUseCase.ValidateToken(r.Context(), strings.TrimSpace(token))
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "неверный токен доступа")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	}
+}
+
+func userFromContext(ctx context.Context) *domain.User {
+	user, _ := ctx.Value(userKey{}).(*domain.User)
+	return user
+}
+
+func (h *Handler) listModels(w http.ResponseWriter, r *http.Request) {
+	models, err := h.llm.GetModels(r.Context())
+	if err != nil {
+		logger.E("OpenAI API: ошибка получения моделей: %v", err)
+		writeError(w, http.StatusBadGateway, "api_error", "не удалось получить список моделей")
+		return
+	}
+
+	resp := modelList{
+		Object: "list",
+		Data:   make([]model, 0, len(models)),
+	}
+	for _, m := range models {
+		resp.Data = append(resp.Data, model{
+			Id:      m,
+			Object:  "model",
+			OwnedBy: "legion",
+		})
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) chatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatCompletionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "неверное тело запроса")
+		return
+	}
+
+	messages, opts, err := req.toDomain()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	id := "chatcmpl-" + uuid.NewString()
+	var userId int
+	if user := userFromContext(r.Context()); user != nil {
+		userId = user.Id
+		logger.D("OpenAI API: пользователь %d, модель %s, запрос %s", user.Id, req.Model, id)
+	}
+
+	release, err := h.quotaUseCase.Acquire(r.Context(), userId)
+	if err != nil {
+		writeQuotaError(w, err)
+		return
+	}
+
+	ch, usage, err := h.llm.SendMessage(r.Context(), id, req.Model, messages, opts)
+	if err != nil {
+		release()
+		logger.E("OpenAI API: ошибка генерации %s: %v", id, err)
+		code, errType, message := generateErrorStatus(err)
+		writeError(w, code, errType, message)
+		return
+	}
+	defer func() {
+		for range ch {
+		}
+		release()
+		h.quotaUseCase.RecordUsage(context.Background(), userId, usage)
+		h.usageUseCase.Record(context.Background(), userId, req.Model, domain.TokenUsageSourceOpenAIAPI, usage)
+	}()
+
+	if req.Stream {
+		includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+		h.streamCompletion(w, r, id, req.Model, ch, usage, includeUsage)
+		return
+	}
+
+	var content strings.Builder
+	for chunk := range ch {
+		content.WriteString(chunk)
+	}
+
+	if r.Context().Err() != nil {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, chatCompletion{
+		Id:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []chatCompletionChoice{{
+			Index: 0,
+			Message: &chatMessage{
+				Role:    string(domain.AIChatMessageRoleAssistant),
+				Content: content.String(),
+			},
+			FinishReason: finishReasonStop,
+		}},
+		Usage: newCompletionUsage(usage),
+	})
+}
+
+func (h *Handler) streamCompletion(w http.ResponseWriter, r *http.Request, id string, modelName string, ch chan string, usage *domain.TokenUsage, includeUsage bool) {
+	flusher, _ := w.(http.Flusher)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	created := time.Now().Unix()
+	write := func(chunk chatCompletion) error {
+		chunk.Id = id
+		chunk.Object = "chat.completion.chunk"
+		chunk.Created = created
+		chunk.Model = modelName
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return err
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		return nil
+	}
+
+	send := func(delta chatMessage, finishReason *string) error {
+		return write(chatCompletion{
+			Choices: []chatCompletionChoice{{
+				Index:        0,
+				Delta:        &delta,
+				FinishReason: finishReason,
+			}},
+		})
+	}
+
+	if err := send(chatMessage{Role: string(domain.AIChatMessageRoleAssistant)}, nil); err != nil {
+		return
+	}
+
+	for chunk := range ch {
+		if chunk == "" {
+			continue
+		}
+
+		if err := send(chatMessage{Content: chunk}, nil); err != nil {
+			logger.W("OpenAI API: клиент отключился от потока %s: %v", id, err)
+			return
+		}
+	}
+
+	if r.Context().Err() != nil {
+		return
+	}
+
+	if err := send(chatMessage{}, finishReasonStop); err != nil {
+		return
+	}
+
+	if includeUsage {
+		if err := write(chatCompletion{Choices: []chatCompletionChoice{}, Usage: newCompletionUsage(usage)}); err != nil {
+			return
+		}
+	}
+
+	_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func generateErrorStatus(err error) (int, string, string) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidGenerationOptions), status.Code(err) == codes.InvalidArgument:
+		return http.StatusBadRequest, "invalid_request_error", "неверные параметры генерации"
+	case status.Code(err) == codes.NotFound:
+		return http.StatusNotFound, "invalid_request_error", "модель не найдена"
+	case status.Code(err) == codes.DeadlineExceeded, errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "api_error", "превышено время ожидания раннера"
+	case errors.Is(err, runner.ErrRunnersBusy):
+		return http.StatusServiceUnavailable, "api_error", "все раннеры заняты"
+	default:
+		return http.StatusServiceUnavailable, "api_error", "сервис временно недоступен"
+	}
+}
+
+func writeQuotaError(w http.ResponseWriter, err error) {
+	var exceeded *domain.QuotaExceededError
+	if !errors.As(err, &exceeded) {
+		logger.E("OpenAI API: ошибка проверки квоты: %v", err)
+		writeError(w, http.StatusServiceUnavailable, "api_error", "сервис временно недоступен")
+		return
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(exceeded.RetryAfter.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, "rate_limit_error", exceeded.Error())
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.W("OpenAI API: ошибка записи ответа: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, errType string, message string) {
+	writeJSON(w, code, errorResponse{
+		Error: apiError{
+			Message: message,
+			Type:    errType,
+		},
+	})
+}
@@ -0,0 +1,317 @@
+package openai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/internal/usecase"
+	"github.com/magomedcoder/legion/runner"
+)
+
+type fakeTokenValidator struct{}
+
+func (fakeTokenValidator) ValidateToken(_ context.Context, token string) (*domain.User, error) {
+	if token != "good" {
+		return nil, errors.New("invalid token")
+	}
+
+	return &domain.User{Id: 7}, nil
+}
+
+type fakeLLM struct {
+	models   []string
+	chunks   []string
+	err      error
+	model    string
+	messages []*domain.AIChatMessage
+	opts     *domain.GenerationOptions
+}
+
+func (f *fakeLLM) CheckConnection(context.Context) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeLLM) GetModels(context.Context) ([]string, error) {
+	return f.models, nil
+}
+
+func (f *fakeLLM) GetModelsInfo(context.Context) ([]*domain.AIModel, error) {
+	return nil, nil
+}
+
+func (f *fakeLLM) SendMessage(_ context.Context, _ string, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	f.model, f.messages, f.opts = model, messages, opts
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+
+	ch := make(chan string, len(f.chunks))
+	for _, c := range f.chunks {
+		ch <- c
+	}
+	close(ch)
+
+	return ch, &domain.TokenUsage{PromptTokens: 3, CompletionTokens: int32(len(f.chunks))}, nil
+}
+
+func doRequest(h http.Handler, method, path, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestHandler_unauthorized(t *testing.T) {
+	h := NewHandler(&fakeLLM{}, fakeTokenValidator{}, nil, nil)
+
+	for _, token := range []string{"", "bad"} {
+		rec := doRequest(h, http.MethodGet, "/v1/models", token, "")
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("токен %q: код %d, ожидался 401", token, rec.Code)
+		}
+	}
+}
+
+func TestHandler_listModels(t *testing.T) {
+	h := NewHandler(&fakeLLM{models: []string{"qwen", "llama"}}, fakeTokenValidator{}, nil, nil)
+
+	rec := doRequest(h, http.MethodGet, "/v1/models", "good", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp modelList
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if resp.Object != "list" || len(resp.Data) != 2 || resp.Data[0].Id != "qwen" || resp.Data[0].Object != "model" {
+		t.Errorf("неверный список моделей: %+v", resp)
+	}
+}
+
+func TestHandler_chatCompletions(t *testing.T) {
+	llm := &fakeLLM{chunks: []string{"При", "вет"}}
+	h := NewHandler(llm, fakeTokenValidator{}, nil, nil)
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "good", `{
+		"model": "qwen",
+		"messages": [{"role": "developer", "content": "Будь краток"}, {"role": "user", "content": "hi"}],
+		"temperature": 0.3,
+		"max_completion_tokens": 64,
+		"stop": "###"
+	}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", rec.Code, rec.Body)
+	}
+
+	var resp chatCompletion
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if resp.Object != "chat.completion" || len(resp.Choices) != 1 || resp.Choices[0].Message.Content != "Привет" {
+		t.Errorf("неверный ответ: %+v", resp)
+	}
+
+	if resp.Usage == nil || resp.Usage.PromptTokens != 3 || resp.Usage.CompletionTokens != 2 || resp.Usage.TotalTokens != 5 {
+		t.Errorf("неверный usage: %+v", resp.Usage)
+	}
+
+	if llm.model != "qwen" || len(llm.messages) != 2 || llm.messages[0].Role != domain.AIChatMessageRoleSystem {
+		t.Errorf("неверные параметры генерации: model=%q messages=%+v", llm.model, llm.messages)
+	}
+
+	if llm.opts == nil || *llm.opts.MaxTokens != 64 || len(llm.opts.Stop) != 1 || llm.opts.Stop[0] != "###" {
+		t.Errorf("неверные опции генерации: %+v", llm.opts)
+	}
+}
+
+func TestHandler_chatCompletions_stream(t *testing.T) {
+	h := NewHandler(&fakeLLM{chunks: []string{"При", "вет"}}, fakeTokenValidator{}, nil, nil)
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "good", `{"model":"qwen","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", rec.Code, rec.Body)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type %q", ct)
+	}
+
+	var content string
+	var events []string
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		events = append(events, payload)
+		if payload == "[DONE]" {
+			continue
+		}
+
+		var chunk chatCompletion
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			t.Fatalf("Unmarshal %q: %v", payload, err)
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+
+	if content != "Привет" {
+		t.Errorf("содержимое потока %q", content)
+	}
+
+	if len(events) != 5 || events[len(events)-1] != "[DONE]" || !strings.Contains(events[3], `"finish_reason":"stop"`) {
+		t.Errorf("неверная последовательность событий: %v", events)
+	}
+}
+
+func TestHandler_chatCompletions_streamUsage(t *testing.T) {
+	h := NewHandler(&fakeLLM{chunks: []string{"ok"}}, fakeTokenValidator{}, nil, nil)
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "good", `{"model":"qwen","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", rec.Code, rec.Body)
+	}
+
+	var usage *completionUsage
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		payload, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || payload == "[DONE]" {
+			continue
+		}
+
+		var chunk chatCompletion
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			t.Fatalf("Unmarshal %q: %v", payload, err)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if usage == nil || usage.TotalTokens != 4 {
+		t.Errorf("usage в потоке: %+v", usage)
+	}
+}
+
+func TestHandler_chatCompletions_errors(t *testing.T) {
+	tests := []struct {
+		name string
+		llm  *fakeLLM
+		body string
+		code int
+	}{
+		{"без модели", &fakeLLM{}, `{"messages":[{"role":"user","content":"hi"}]}`, http.StatusBadRequest},
+		{"неизвестная роль", &fakeLLM{}, `{"model":"m","messages":[{"role":"tool","content":"hi"}]}`, http.StatusBadRequest},
+		{"неверные опции", &fakeLLM{}, `{"model":"m","temperature":5,"messages":[{"role":"user","content":"hi"}]}`, http.StatusBadRequest},
+		{"раннеры заняты", &fakeLLM{err: runner.ErrRunnersBusy}, `{"model":"m","messages":[{"role":"user","content":"hi"}]}`, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		rec := doRequest(NewHandler(tt.llm, fakeTokenValidator{}, nil, nil), http.MethodPost, "/v1/chat/completions", "good", tt.body)
+		if rec.Code != tt.code {
+			t.Errorf("%s: код %d, ожидался %d: %s", tt.name, rec.Code, tt.code, rec.Body)
+		}
+
+		var resp errorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Error.Message == "" {
+			t.Errorf("%s: ожидалось тело ошибки OpenAI: %v", tt.name, err)
+		}
+	}
+}
+
+type fakeQuotaRepo struct {
+	requests int64
+	tokens   int64
+}
+
+func (f *fakeQuotaRepo) IncrementRequests(context.Context, int, time.Duration) (int64, time.Duration, error) {
+	f.requests++
+	return f.requests, 1500 * time.Millisecond, nil
+}
+
+func (f *fakeQuotaRepo) GetDailyTokens(context.Context, int, time.Time) (int64, error) {
+	return f.tokens, nil
+}
+
+func (f *fakeQuotaRepo) AddDailyTokens(_ context.Context, _ int, _ time.Time, tokens int64) error {
+	f.tokens += tokens
+	return nil
+}
+
+func (f *fakeQuotaRepo) AcquireStream(context.Context, int, int) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeQuotaRepo) ReleaseStream(context.Context, int) error {
+	return nil
+}
+
+func TestHandler_chatCompletions_quota(t *testing.T) {
+	repo := &fakeQuotaRepo{}
+	quota := usecase.NewQuotaUseCase(repo, nil, domain.AIQuotaPolicy{
+		Users: map[int]domain.AIQuota{7: {RequestsPerMinute: 1}},
+	})
+	h := NewHandler(&fakeLLM{chunks: []string{"ok"}}, fakeTokenValidator{}, quota, nil)
+	body := `{"model":"qwen","messages":[{"role":"user","content":"hi"}]}`
+
+	if rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "good", body); rec.Code != http.StatusOK {
+		t.Fatalf("первый запрос: код %d: %s", rec.Code, rec.Body)
+	}
+
+	if repo.tokens != 4 {
+		t.Errorf("расход токенов не учтён: %d", repo.tokens)
+	}
+
+	rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "good", body)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("второй запрос: код %d, ожидался 429", rec.Code)
+	}
+
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After %q, ожидалось 2", got)
+	}
+}
+
+type fakeTokenUsageRepo struct {
+	records []*domain.TokenUsageRecord
+}
+
+func (f *fakeTokenUsageRepo) Aggregate(context.Context, domain.UsageFilter) ([]*domain.UsageStat, error) {
+	return nil, nil
+}
+
+func (f *fakeTokenUsageRepo) Record(_ context.Context, record *domain.TokenUsageRecord) error {
+	f.records = append(f.records, record)
+	return nil
+}
+
+func TestHandler_chatCompletions_recordsUsage(t *testing.T) {
+	repo := &fakeTokenUsageRepo{}
+	h := NewHandler(&fakeLLM{chunks: []string{"ok"}}, fakeTokenValidator{}, nil, usecase.NewUsageUseCase(repo))
+	body := `{"model":"qwen","messages":[{"role":"user","content":"hi"}]}`
+
+	if rec := doRequest(h, http.MethodPost, "/v1/chat/completions", "good", body); rec.Code != http.StatusOK {
+		t.Fatalf("код %d: %s", rec.Code, rec.Body)
+	}
+
+	if len(repo.records) != 1 || repo.records[0].Source != domain.TokenUsageSourceOpenAIAPI || repo.records[0].Model != "qwen" || repo.records[0].UserId != 7 {
+		t.Errorf("расход API не записан в статистику: %+v", repo.records)
+	}
+}
@@ -0,0 +1,170 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+var finishReasonStop = func() *string {
+	s := "stop"
+	return &s
+}()
+
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+type errorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type model struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+type modelList struct {
+	Object string  `json:"object"`
+	Data   []model `json:"data"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content"`
+}
+
+type stopSequences []string
+
+func (s *stopSequences) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		*s = nil
+		return nil
+	}
+
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*s = stopSequences{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("stop: ожидается строка или массив строк")
+	}
+	*s = list
+
+	return nil
+}
+
+type chatCompletionRequest struct {
+	Model               string         `json:"model"`
+	Messages            []chatMessage  `json:"messages"`
+	Stream              bool           `json:"stream"`
+	Temperature         *float32       `json:"temperature"`
+	TopP                *float32       `json:"top_p"`
+	TopK                *int32         `json:"top_k"`
+	MaxTokens           *int32         `json:"max_tokens"`
+	MaxCompletionTokens *int32         `json:"max_completion_tokens"`
+	Stop                stopSequences  `json:"stop"`
+	Seed                *int64         `json:"seed"`
+	StreamOptions       *streamOptions `json:"stream_options"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type completionUsage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+	TotalTokens      int32 `json:"total_tokens"`
+}
+
+func newCompletionUsage(usage *domain.TokenUsage) *completionUsage {
+	if usage == nil {
+		usage = &domain.TokenUsage{}
+	}
+
+	return &completionUsage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.Total(),
+	}
+}
+
+type chatCompletionChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatMessage `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type chatCompletion struct {
+	Id      string                 `json:"id"`
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Model   string                 `json:"model"`
+	Choices []chatCompletionChoice `json:"choices"`
+	Usage   *completionUsage       `json:"usage,omitempty"`
+}
+
+func messageRole(role string) (domain.AIChatMessageRole, bool) {
+	switch role {
+	case "system", "developer":
+		return domain.AIChatMessageRoleSystem, true
+	case "user":
+		return domain.AIChatMessageRoleUser, true
+	case "assistant":
+		return domain.AIChatMessageRoleAssistant, true
+	default:
+		return "", false
+	}
+}
+
+func (r *chatCompletionRequest) toDomain() ([]*domain.AIChatMessage, *domain.GenerationOptions, error) {
+	if r.Model == "" {
+		return nil, nil, fmt.Errorf("модель не указана")
+	}
+
+	if len(r.Messages) == 0 {
+		return nil, nil, fmt.Errorf("сообщения не предоставлены")
+	}
+
+	messages := make([]*domain.AIChatMessage, 0, len(r.Messages))
+	for i, m := range r.Messages {
+		role, ok := messageRole(m.Role)
+		if !ok {
+			return nil, nil, fmt.Errorf("messages[%d]: неподдерживаемая роль %q", i, m.Role)
+		}
+		messages = append(messages, domain.NewAIChatMessage("", m.Content, role))
+	}
+
+	maxTokens := r.MaxTokens
+	if maxTokens == nil {
+		maxTokens = r.MaxCompletionTokens
+	}
+
+	opts := &domain.GenerationOptions{
+		Temperature: r.Temperature,
+		TopP:        r.TopP,
+		TopK:        r.TopK,
+		MaxTokens:   maxTokens,
+		Stop:        r.Stop,
+		Seed:        r.Seed,
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	if opts.IsEmpty() {
+		opts = nil
+	}
+
+	return messages, opts, nil
+}
@@ -1,26 +1,73 @@
 package domain
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/magomedcoder/legion/pkg"
 	"time"
 )
 
+var (
+	ErrAIMessageNotFound    = errors.New("сообщение не найдено")
+	ErrNothingToRegenerate  = errors.New("нет ответа для повторной генерации")
+	ErrAIMessageNotEditable = errors.New("редактировать можно только сообщения пользователя")
+	ErrGenerationNotFound   = errors.New("генерация не найдена")
+	ErrInvalidChunkOffset   = errors.New("номер фрагмента не может быть отрицательным")
+)
+
+type AIFinishReason string
+
+const (
+	AIFinishReasonCompleted    AIFinishReason = "completed"
+	AIFinishReasonStopped      AIFinishReason = "stopped"
+	AIFinishReasonDisconnected AIFinishReason = "disconnected"
+	AIFinishReasonError        AIFinishReason = "error"
+)
+
+type AIReply struct {
+	MessageId    string
+	FinishReason AIFinishReason
+}
+
+type AIStream struct {
+	MessageId    string
+	SessionId    string
+	UserId       int
+	FinishReason AIFinishReason
+}
+
+func (s *AIStream) Done() bool {
+	return s.FinishReason != ""
+}
+
 type AIChatMessageRole string
 
 const (
 	AIChatMessageRoleSystem    AIChatMessageRole = "system"
 	AIChatMessageRoleUser      AIChatMessageRole = "user"
 	AIChatMessageRoleAssistant AIChatMessageRole = "assistant"
+	AIChatMessageRoleTool      AIChatMessageRole = "tool"
 )
 
 type AIChatSession struct {
-	Id        string
-	UserId    int
-	Title     string
-	Model     string
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt *time.Time
+	Id                string
+	UserId            int
+	Title             string
+	TitleManual       bool
+	Model             string
+	GenerationOptions *GenerationOptions
+	PersonaId         string
+	KnowledgeBaseId   string
+	ContextSummary    *AIChatContextSummary
+	ActiveMessageId   string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time
+}
+
+type AIModel struct {
+	Name    string
+	Runners []string
 }
 
 type AIChatMessage struct {
@@ -29,6 +76,16 @@ type AIChatMessage struct {
 	Content        string
 	Role           AIChatMessageRole
 	AttachmentName string
+	Model          string
+	Usage          *TokenUsage
+	ParentId       string
+	SiblingCount   int32
+	SiblingIndex   int32
+	Stopped        bool
+	FinishReason   AIFinishReason
+	ToolCalls      []*AIToolCall
+	ToolCallId     string
+	ToolName       string
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 	DeletedAt      *time.Time
@@ -62,10 +119,35 @@ func NewAIChatMessageWithAttachment(sessionId, content string, role AIChatMessag
 }
 
 func (ai *AIChatMessage) AIToMap() map[string]interface{} {
-	return map[string]interface{}{
+	m := map[string]interface{}{
 		"role":    string(ai.Role),
 		"content": ai.Content,
 	}
+
+	if len(ai.ToolCalls) > 0 {
+		calls := make([]map[string]interface{}, len(ai.ToolCalls))
+		for i, call := range ai.ToolCalls {
+			var args interface{} = map[string]interface{}{}
+			if call.Arguments != "" {
+				if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+					args = map[string]interface{}{}
+				}
+			}
+			calls[i] = map[string]interface{}{
+				"function": map[string]interface{}{
+					"name":      call.Name,
+					"arguments": args,
+				},
+			}
+		}
+		m["tool_calls"] = calls
+	}
+
+	if ai.ToolName != "" {
+		m["tool_name"] = ai.ToolName
+	}
+
+	return m
 }
 
 func AIFromProtoRole(role string) AIChatMessageRole {
@@ -76,6 +158,8 @@ func AIFromProtoRole(role string) AIChatMessageRole {
 		return AIChatMessageRoleUser
 	case "assistant":
 		return AIChatMessageRoleAssistant
+	case "tool":
+		return AIChatMessageRoleTool
 	default:
 		return AIChatMessageRoleUser
 	}
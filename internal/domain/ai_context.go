@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultContextTokens = 4096
+	DefaultReserveTokens = 1024
+	messageTokenOverhead = 4
+)
+
+type ContextWindow struct {
+	DefaultTokens int
+	ReserveTokens int
+	Models        map[string]int
+}
+
+func (w ContextWindow) Tokens(model string) int {
+	if n := w.Models[model]; n > 0 {
+		return n
+	}
+
+	if w.DefaultTokens > 0 {
+		return w.DefaultTokens
+	}
+
+	return DefaultContextTokens
+}
+
+func (w ContextWindow) Budget(model string, opts *GenerationOptions) int {
+	total := w.Tokens(model)
+
+	reserve := w.ReserveTokens
+	if opts != nil && opts.MaxTokens != nil {
+		reserve = int(*opts.MaxTokens)
+	}
+	if reserve <= 0 {
+		reserve = DefaultReserveTokens
+	}
+	if reserve > total/2 {
+		reserve = total / 2
+	}
+
+	return total - reserve
+}
+
+type AIChatContextSummary struct {
+	Content   string
+	MessageId string
+	Until     time.Time
+}
+
+func EstimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+
+	return ascii/4 + other/2 + 1
+}
+
+func EstimateMessageTokens(msg *AIChatMessage) int {
+	if msg == nil {
+		return 0
+	}
+
+	return EstimateTokens(msg.Content) + messageTokenOverhead
+}
@@ -0,0 +1,193 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/magomedcoder/legion/pkg"
+)
+
+const (
+	DefaultKnowledgeChunkSize    = 1000
+	DefaultKnowledgeChunkOverlap = 150
+	DefaultKnowledgeTopK         = 4
+	maxKnowledgeChunkSize        = MaxEmbeddingInputLength / 4
+	maxKnowledgeTopK             = 20
+)
+
+var (
+	ErrKnowledgeBaseNotFound   = errors.New("база знаний не найдена")
+	ErrInvalidKnowledgeBase    = errors.New("некорректная база знаний")
+	ErrEmptyKnowledgeDocument  = errors.New("документ не содержит текста")
+	ErrKnowledgeEmbeddingCount = errors.New("количество эмбеддингов не совпадает с количеством фрагментов")
+)
+
+type KnowledgeBase struct {
+	Id             string
+	UserId         int
+	Name           string
+	EmbeddingModel string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      *time.Time
+}
+
+func NewKnowledgeBase(userId int, name string, embeddingModel string) *KnowledgeBase {
+	return &KnowledgeBase{
+		Id:             pkg.GenerateUUID(),
+		UserId:         userId,
+		Name:           strings.TrimSpace(name),
+		EmbeddingModel: strings.TrimSpace(embeddingModel),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+}
+
+type KnowledgeDocument struct {
+	Id              string
+	KnowledgeBaseId string
+	FileId          string
+	Name            string
+	Chunks          int
+	CreatedAt       time.Time
+}
+
+func NewKnowledgeDocument(knowledgeBaseId string, name string) *KnowledgeDocument {
+	return &KnowledgeDocument{
+		Id:              pkg.GenerateUUID(),
+		KnowledgeBaseId: knowledgeBaseId,
+		Name:            name,
+		CreatedAt:       time.Now(),
+	}
+}
+
+type KnowledgeChunk struct {
+	Id              string
+	KnowledgeBaseId string
+	DocumentId      string
+	DocumentName    string
+	Position        int
+	Start           int
+	End             int
+	Content         string
+	Embedding       []float32
+}
+
+type KnowledgeMatch struct {
+	Chunk *KnowledgeChunk
+	Score float32
+}
+
+type KnowledgeSettings struct {
+	EmbeddingModel string
+	ChunkSize      int
+	ChunkOverlap   int
+	TopK           int
+}
+
+func (s KnowledgeSettings) WithDefaults() KnowledgeSettings {
+	if s.ChunkSize <= 0 {
+		s.ChunkSize = DefaultKnowledgeChunkSize
+	}
+
+	if s.ChunkSize > maxKnowledgeChunkSize {
+		s.ChunkSize = maxKnowledgeChunkSize
+	}
+
+	if s.ChunkOverlap <= 0 || s.ChunkOverlap >= s.ChunkSize {
+		s.ChunkOverlap = min(DefaultKnowledgeChunkOverlap, s.ChunkSize/2)
+	}
+
+	if s.TopK <= 0 {
+		s.TopK = DefaultKnowledgeTopK
+	}
+
+	return s
+}
+
+func ClampKnowledgeTopK(topK int, fallback int) int {
+	if topK <= 0 {
+		return fallback
+	}
+
+	return min(topK, maxKnowledgeTopK)
+}
+
+type TextChunk struct {
+	Content string
+	Start   int
+	End     int
+}
+
+func ChunkText(text string, size int, overlap int) []TextChunk {
+	runes := []rune(text)
+	if size <= 0 {
+		size = DefaultKnowledgeChunkSize
+	}
+
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	var out []TextChunk
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			end = len(runes)
+		} else if cut := lastSpace(runes[start+size*4/5 : end]); cut >= 0 {
+			end = start + size*4/5 + cut + 1
+		}
+
+		if content := strings.TrimSpace(string(runes[start:end])); content != "" {
+			out = append(out, TextChunk{
+				Content: content,
+				Start:   start,
+				End:     end,
+			})
+		}
+
+		if end == len(runes) {
+			break
+		}
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+
+	return out
+}
+
+func lastSpace(runes []rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func CosineSimilarity(a, b []float32) float32 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return float32(dot / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/magomedcoder/legion/pkg"
+)
+
+const (
+	maxAIPersonaNameLength   = 255
+	maxAIPersonaPromptLength = 16000
+)
+
+var (
+	ErrAIPersonaNotFound = errors.New("персона не найдена")
+	ErrInvalidAIPersona  = errors.New("некорректная персона")
+)
+
+type AIPersona struct {
+	Id                string
+	UserId            int
+	Name              string
+	SystemPrompt      string
+	Model             string
+	GenerationOptions *GenerationOptions
+	Shared            bool
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	DeletedAt         *time.Time
+}
+
+func NewAIPersona(userId int, name string, systemPrompt string, model string) *AIPersona {
+	return &AIPersona{
+		Id:           pkg.GenerateUUID(),
+		UserId:       userId,
+		Name:         strings.TrimSpace(name),
+		SystemPrompt: strings.TrimSpace(systemPrompt),
+		Model:        strings.TrimSpace(model),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+}
+
+func (p *AIPersona) Validate() error {
+	if p.Name == "" {
+		return fmt.Errorf("%w: имя не может быть пустым", ErrInvalidAIPersona)
+	}
+
+	if utf8.RuneCountInString(p.Name) > maxAIPersonaNameLength {
+		return fmt.Errorf("%w: имя длиннее %d символов", ErrInvalidAIPersona, maxAIPersonaNameLength)
+	}
+
+	if p.SystemPrompt == "" {
+		return fmt.Errorf("%w: системный промпт не может быть пустым", ErrInvalidAIPersona)
+	}
+
+	if utf8.RuneCountInString(p.SystemPrompt) > maxAIPersonaPromptLength {
+		return fmt.Errorf("%w: системный промпт длиннее %d символов", ErrInvalidAIPersona, maxAIPersonaPromptLength)
+	}
+
+	return p.GenerationOptions.Validate()
+}
+
+func (p *AIPersona) CanUse(userId int) bool {
+	return p.UserId == userId || p.Shared
+}
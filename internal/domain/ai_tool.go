@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+)
+
+const (
+	DefaultAIToolRounds = 5
+	maxAIToolRounds     = 20
+)
+
+var (
+	ErrAIToolNotFound        = errors.New("инструмент не найден")
+	ErrInvalidAIToolArgs     = errors.New("некорректные аргументы инструмента")
+	ErrToolCallsNotSupported = errors.New("вызов инструментов не поддерживается")
+)
+
+var aiToolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type AITool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+func (t *AITool) Validate() error {
+	if t == nil || !aiToolNamePattern.MatchString(t.Name) {
+		return errors.New("имя инструмента должно состоять из латинских букв, цифр, _ и - (до 64 символов)")
+	}
+
+	if len(t.Parameters) > 0 && !json.Valid(t.Parameters) {
+		return errors.New("схема параметров инструмента должна быть корректным JSON")
+	}
+
+	return nil
+}
+
+type AIToolCall struct {
+	Id        string
+	Name      string
+	Arguments string
+}
+
+type AIToolCalls struct {
+	Calls []*AIToolCall
+}
+
+func (c *AIToolCalls) Empty() bool {
+	return c == nil || len(c.Calls) == 0
+}
+
+func ClampAIToolRounds(rounds int) int {
+	if rounds <= 0 {
+		return DefaultAIToolRounds
+	}
+
+	return min(rounds, maxAIToolRounds)
+}
+
+func NewAIToolResultMessage(sessionId string, call *AIToolCall, content string) *AIChatMessage {
+	msg := NewAIChatMessage(sessionId, content, AIChatMessageRoleTool)
+	msg.ToolCallId = call.Id
+	msg.ToolName = call.Name
+
+	return msg
+}
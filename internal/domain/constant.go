@@ -6,10 +6,13 @@ const (
 )
 
 const (
-	SubEventUserStatus   = "sub.user.status"
-	SubEventNewMessage   = "sub.message.new"
-	SubEventNewTask      = "sub.task.new"
-	SubEventTaskChanged  = "sub.task.changed"
+	SubEventUserStatus         = "sub.user.status"
+	SubEventNewMessage         = "sub.message.new"
+	SubEventNewTask            = "sub.task.new"
+	SubEventTaskChanged        = "sub.task.changed"
+	SubEventAISessionTitle     = "sub.ai_session.title"
+	SubEventAIStopGeneration   = "sub.ai_generation.stop"
+	SubEventAIMessageCompleted = "sub.ai_message.completed"
 )
 
 const ChatChannelName = "chat"
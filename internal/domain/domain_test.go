@@ -1,6 +1,8 @@
 package domain
 
 import (
+	"errors"
+	"strings"
 	"testing"
 	"time"
 )
@@ -102,3 +104,327 @@ func TestNewFile(t *testing.T) {
 		t.Errorf("NewFile: неверные поля %+v", f)
 	}
 }
+
+func TestGenerationOptions_Merge(t *testing.T) {
+	var empty *GenerationOptions
+	if got := empty.Merge(nil); got != nil {
+		t.Errorf("Merge(nil, nil) = %+v, ожидалось nil", got)
+	}
+
+	low, high, topP := float32(0.1), float32(0.9), float32(0.5)
+	base := &GenerationOptions{Temperature: &low, TopP: &topP, Stop: []string{"a"}}
+	got := base.Merge(&GenerationOptions{Temperature: &high})
+	if *got.Temperature != 0.9 || *got.TopP != 0.5 || len(got.Stop) != 1 {
+		t.Errorf("Merge: %+v", got)
+	}
+
+	if *base.Temperature != 0.1 {
+		t.Error("Merge не должен изменять исходные параметры")
+	}
+}
+
+func TestGenerationOptions_Validate(t *testing.T) {
+	bad, good := float32(2.5), float32(1)
+	zero := int32(0)
+	tests := []struct {
+		name string
+		opts *GenerationOptions
+		ok   bool
+	}{
+		{"nil", nil, true},
+		{"temperature", &GenerationOptions{Temperature: &good}, true},
+		{"temperature вне диапазона", &GenerationOptions{Temperature: &bad}, false},
+		{"top_p вне диапазона", &GenerationOptions{TopP: &bad}, false},
+		{"max_tokens = 0", &GenerationOptions{MaxTokens: &zero}, false},
+		{"пустой stop", &GenerationOptions{Stop: []string{""}}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+}
+
+func TestValidateEmbeddingInputs(t *testing.T) {
+	tooMany := make([]string, MaxEmbeddingInputs+1)
+	for i := range tooMany {
+		tooMany[i] = "x"
+	}
+
+	tests := []struct {
+		name   string
+		inputs []string
+		ok     bool
+	}{
+		{"пакет текстов", []string{"a", "b"}, true},
+		{"пустой пакет", nil, false},
+		{"пустой текст", []string{"a", "  "}, false},
+		{"слишком много", tooMany, false},
+		{"слишком длинный", []string{strings.Repeat("x", MaxEmbeddingInputLength+1)}, false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateEmbeddingInputs(tt.inputs)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: ValidateEmbeddingInputs() = %v", tt.name, err)
+		}
+
+		if err != nil && !errors.Is(err, ErrInvalidEmbeddingInput) {
+			t.Errorf("%s: ожидалась ErrInvalidEmbeddingInput, получено %v", tt.name, err)
+		}
+	}
+}
+
+func TestUsageFilter_Validate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		filter UsageFilter
+		ok     bool
+	}{
+		{"по пользователям", UsageFilter{GroupBy: UsageGroupByUser}, true},
+		{"по дням за период", UsageFilter{GroupBy: UsageGroupByDay, From: now.Add(-time.Hour), To: now}, true},
+		{"неизвестная группировка", UsageFilter{GroupBy: "week"}, false},
+		{"перевёрнутый период", UsageFilter{GroupBy: UsageGroupByModel, From: now, To: now.Add(-time.Hour)}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.filter.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+
+		if err != nil && !errors.Is(err, ErrInvalidUsageFilter) {
+			t.Errorf("%s: ожидалась ErrInvalidUsageFilter, получено %v", tt.name, err)
+		}
+	}
+}
+
+func TestTokenUsage_Total(t *testing.T) {
+	var empty *TokenUsage
+	if empty.Total() != 0 || !empty.IsEmpty() {
+		t.Error("nil usage должен быть пустым")
+	}
+
+	u := &TokenUsage{PromptTokens: 12, CompletionTokens: 30}
+	if u.Total() != 42 || u.IsEmpty() {
+		t.Errorf("Total() = %d", u.Total())
+	}
+}
+
+func TestAIQuotaPolicy_For(t *testing.T) {
+	policy := AIQuotaPolicy{
+		Roles: map[UserRole]AIQuota{UserRoleUser: {RequestsPerMinute: 10}},
+		Users: map[int]AIQuota{7: {TokensPerDay: 1000}},
+	}
+
+	if q := policy.For(1, UserRoleUser); q.RequestsPerMinute != 10 {
+		t.Errorf("квота роли: %+v", q)
+	}
+
+	if q := policy.For(7, UserRoleUser); q.TokensPerDay != 1000 || q.RequestsPerMinute != 0 {
+		t.Errorf("квота пользователя должна перекрывать квоту роли: %+v", q)
+	}
+
+	if q := policy.For(1, UserRoleAdmin); !q.IsUnlimited() {
+		t.Errorf("роль без квоты должна быть без ограничений: %+v", q)
+	}
+}
+
+func TestQuotaExceededError(t *testing.T) {
+	var err error = &QuotaExceededError{Limit: AIQuotaLimitRequestsPerMinute, RetryAfter: 30 * time.Second}
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("QuotaExceededError должна оборачивать ErrQuotaExceeded: %v", err)
+	}
+}
+
+func TestContextWindow_Budget(t *testing.T) {
+	w := ContextWindow{DefaultTokens: 4096, ReserveTokens: 512, Models: map[string]int{"big": 32768}}
+
+	if got := w.Budget("small", nil); got != 4096-512 {
+		t.Errorf("Budget(small) = %d", got)
+	}
+
+	if got := w.Budget("big", nil); got != 32768-512 {
+		t.Errorf("Budget(big) = %d", got)
+	}
+
+	maxTokens := int32(3000)
+	if got := w.Budget("small", &GenerationOptions{MaxTokens: &maxTokens}); got != 2048 {
+		t.Errorf("резерв не должен превышать половину окна: %d", got)
+	}
+
+	if got := (ContextWindow{}).Budget("m", nil); got != DefaultContextTokens-DefaultReserveTokens {
+		t.Errorf("значения по умолчанию: %d", got)
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	if EstimateTokens("") != 0 {
+		t.Error("пустая строка не должна занимать токены")
+	}
+
+	if en, ru := EstimateTokens(strings.Repeat("a", 400)), EstimateTokens(strings.Repeat("я", 400)); en >= ru {
+		t.Errorf("кириллица должна оцениваться дороже латиницы: en=%d ru=%d", en, ru)
+	}
+}
+
+func TestAIPersona_Validate(t *testing.T) {
+	bad := float32(3)
+	tests := []struct {
+		name    string
+		persona *AIPersona
+		ok      bool
+	}{
+		{"корректная", NewAIPersona(1, " Юрист ", "Отвечай как юрист", ""), true},
+		{"пустое имя", NewAIPersona(1, "  ", "Отвечай как юрист", ""), false},
+		{"пустой промпт", NewAIPersona(1, "Юрист", " ", ""), false},
+		{"длинный промпт", NewAIPersona(1, "Юрист", strings.Repeat("я", maxAIPersonaPromptLength+1), ""), false},
+		{"параметры генерации", &AIPersona{Name: "Юрист", SystemPrompt: "п", GenerationOptions: &GenerationOptions{Temperature: &bad}}, false},
+	}
+	for _, tt := range tests {
+		err := tt.persona.Validate()
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v", tt.name, err)
+		}
+	}
+
+	if p := NewAIPersona(1, " Юрист ", "п", ""); p.Name != "Юрист" {
+		t.Errorf("имя не очищено от пробелов: %q", p.Name)
+	}
+}
+
+func TestAIPersona_CanUse(t *testing.T) {
+	p := &AIPersona{UserId: 1}
+	if !p.CanUse(1) || p.CanUse(2) {
+		t.Error("личная персона доступна только автору")
+	}
+
+	p.Shared = true
+	if !p.CanUse(2) {
+		t.Error("общая персона должна быть доступна всем")
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("слово ", 50)
+	chunks := ChunkText(text, 40, 10)
+	if len(chunks) < 2 {
+		t.Fatalf("ожидалось несколько фрагментов, получено %d", len(chunks))
+	}
+
+	runes := []rune(text)
+	for i, chunk := range chunks {
+		if chunk.End-chunk.Start > 40 {
+			t.Errorf("фрагмент %d длиннее лимита: %d-%d", i, chunk.Start, chunk.End)
+		}
+
+		if chunk.Content != strings.TrimSpace(string(runes[chunk.Start:chunk.End])) {
+			t.Errorf("фрагмент %d не совпадает со смещениями", i)
+		}
+
+		if chunk.End < len(runes) && runes[chunk.End-1] != ' ' {
+			t.Errorf("фрагмент %d разрезает слово: %q", i, chunk.Content)
+		}
+
+		if i > 0 && chunk.Start >= chunks[i-1].End {
+			t.Errorf("фрагменты %d и %d не перекрываются", i-1, i)
+		}
+	}
+
+	if last := chunks[len(chunks)-1]; last.End != len(runes) {
+		t.Errorf("последний фрагмент должен заканчиваться в конце текста: %d", last.End)
+	}
+
+	if got := ChunkText("  \n ", 40, 10); len(got) != 0 {
+		t.Errorf("пустой текст: получено %d фрагментов", len(got))
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := CosineSimilarity([]float32{1, 0}, []float32{2, 0}); got < 0.999 {
+		t.Errorf("сонаправленные векторы: %v", got)
+	}
+
+	if got := CosineSimilarity([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("ортогональные векторы: %v", got)
+	}
+
+	if got := CosineSimilarity([]float32{1}, []float32{1, 0}); got != 0 {
+		t.Errorf("разная размерность: %v", got)
+	}
+}
+
+func TestKnowledgeSettings_WithDefaults(t *testing.T) {
+	s := KnowledgeSettings{}.WithDefaults()
+	if s.ChunkSize != DefaultKnowledgeChunkSize || s.ChunkOverlap != DefaultKnowledgeChunkOverlap || s.TopK != DefaultKnowledgeTopK {
+		t.Errorf("значения по умолчанию: %+v", s)
+	}
+
+	s = KnowledgeSettings{ChunkSize: 100, ChunkOverlap: 100}.WithDefaults()
+	if s.ChunkOverlap >= s.ChunkSize {
+		t.Errorf("перекрытие должно быть меньше размера фрагмента: %+v", s)
+	}
+
+	if got := ClampKnowledgeTopK(100, 4); got != maxKnowledgeTopK {
+		t.Errorf("ClampKnowledgeTopK(100) = %d", got)
+	}
+
+	if got := ClampKnowledgeTopK(0, 4); got != 4 {
+		t.Errorf("ClampKnowledgeTopK(0) = %d", got)
+	}
+}
+
+func TestAITool_Validate(t *testing.T) {
+	if err := (&AITool{Name: "search_users", Parameters: []byte(`{"type":"object"}`)}).Validate(); err != nil {
+		t.Errorf("корректный инструмент: %v", err)
+	}
+
+	if err := (&AITool{Name: "поиск"}).Validate(); err == nil {
+		t.Error("имя не латиницей должно отклоняться")
+	}
+
+	if err := (&AITool{Name: "t", Parameters: []byte(`{`)}).Validate(); err == nil {
+		t.Error("некорректная схема должна отклоняться")
+	}
+
+	if got := ClampAIToolRounds(0); got != DefaultAIToolRounds {
+		t.Errorf("ClampAIToolRounds(0) = %d", got)
+	}
+
+	if got := ClampAIToolRounds(100); got != maxAIToolRounds {
+		t.Errorf("ClampAIToolRounds(100) = %d", got)
+	}
+}
+
+func TestAIChatMessage_AIToMap_toolCalls(t *testing.T) {
+	msg := NewAIChatMessage("s", "", AIChatMessageRoleAssistant)
+	msg.ToolCalls = []*AIToolCall{{Id: "c1", Name: "get_task", Arguments: `{"task_id":"t1"}`}}
+
+	calls, ok := msg.AIToMap()["tool_calls"].([]map[string]interface{})
+	if !ok || len(calls) != 1 {
+		t.Fatalf("tool_calls не переданы: %v", msg.AIToMap())
+	}
+
+	function := calls[0]["function"].(map[string]interface{})
+	args, _ := function["arguments"].(map[string]interface{})
+	if function["name"] != "get_task" || args["task_id"] != "t1" {
+		t.Errorf("неверный вызов: %v", function)
+	}
+
+	result := NewAIToolResultMessage("s", msg.ToolCalls[0], `{"ok":true}`)
+	if m := result.AIToMap(); m["role"] != "tool" || m["tool_name"] != "get_task" || result.ToolCallId != "c1" {
+		t.Errorf("неверный результат инструмента: %v", m)
+	}
+}
+
+func TestTokenUsage_Add(t *testing.T) {
+	u := &TokenUsage{PromptTokens: 1, CompletionTokens: 2}
+	u.Add(&TokenUsage{PromptTokens: 10, CompletionTokens: 20})
+	u.Add(nil)
+	if u.PromptTokens != 11 || u.CompletionTokens != 22 {
+		t.Errorf("Add: %+v", u)
+	}
+}
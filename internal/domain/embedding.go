@@ -0,0 +1,39 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MaxEmbeddingInputs      = 64
+	MaxEmbeddingInputLength = 32 * 1024
+)
+
+var (
+	ErrInvalidEmbeddingInput  = errors.New("некорректный запрос эмбеддингов")
+	ErrEmbeddingsNotSupported = errors.New("эмбеддинги не поддерживаются")
+)
+
+func ValidateEmbeddingInputs(inputs []string) error {
+	if len(inputs) == 0 {
+		return fmt.Errorf("%w: тексты не предоставлены", ErrInvalidEmbeddingInput)
+	}
+
+	if len(inputs) > MaxEmbeddingInputs {
+		return fmt.Errorf("%w: не более %d текстов за запрос", ErrInvalidEmbeddingInput, MaxEmbeddingInputs)
+	}
+
+	for i, in := range inputs {
+		if strings.TrimSpace(in) == "" {
+			return fmt.Errorf("%w: текст %d пуст", ErrInvalidEmbeddingInput, i)
+		}
+
+		if len(in) > MaxEmbeddingInputLength {
+			return fmt.Errorf("%w: текст %d длиннее %d байт", ErrInvalidEmbeddingInput, i, MaxEmbeddingInputLength)
+		}
+	}
+
+	return nil
+}
@@ -21,3 +21,21 @@ type ConsumeTask struct {
 	ProjectId string `json:"projectId"`
 	TaskId    string `json:"taskId"`
 }
+
+type ConsumeAISessionTitle struct {
+	UserId    int    `json:"userId"`
+	SessionId string `json:"sessionId"`
+	Title     string `json:"title"`
+}
+
+type ConsumeAIStopGeneration struct {
+	SessionId string `json:"sessionId"`
+	MessageId string `json:"messageId"`
+}
+
+type ConsumeAIMessageCompleted struct {
+	UserId       int    `json:"userId"`
+	SessionId    string `json:"sessionId"`
+	MessageId    string `json:"messageId"`
+	FinishReason string `json:"finishReason"`
+}
@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+const maxStopSequences = 8
+
+var (
+	ErrInvalidGenerationOptions = errors.New("некорректные параметры генерации")
+	ErrInvalidGenerationRequest = errors.New("некорректный запрос генерации")
+	ErrModelNotFound            = errors.New("модель не найдена")
+	ErrBackendNotReady          = errors.New("движок раннера не готов к генерации")
+	ErrBackendUnavailable       = errors.New("движок раннера недоступен")
+)
+
+type GenerationOptions struct {
+	Temperature *float32
+	TopP        *float32
+	TopK        *int32
+	MaxTokens   *int32
+	Stop        []string
+	Seed        *int64
+}
+
+func (o *GenerationOptions) IsEmpty() bool {
+	return o == nil || (o.Temperature == nil && o.TopP == nil && o.TopK == nil && o.MaxTokens == nil && len(o.Stop) == 0 && o.Seed == nil)
+}
+
+func (o *GenerationOptions) Validate() error {
+	if o == nil {
+		return nil
+	}
+
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("%w: temperature должна быть в диапазоне [0, 2]", ErrInvalidGenerationOptions)
+	}
+
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		return fmt.Errorf("%w: top_p должен быть в диапазоне (0, 1]", ErrInvalidGenerationOptions)
+	}
+
+	if o.TopK != nil && *o.TopK < 0 {
+		return fmt.Errorf("%w: top_k не может быть отрицательным", ErrInvalidGenerationOptions)
+	}
+
+	if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+		return fmt.Errorf("%w: max_tokens должен быть положительным", ErrInvalidGenerationOptions)
+	}
+
+	if len(o.Stop) > maxStopSequences {
+		return fmt.Errorf("%w: не более %d стоп-последовательностей", ErrInvalidGenerationOptions, maxStopSequences)
+	}
+
+	for _, s := range o.Stop {
+		if s == "" {
+			return fmt.Errorf("%w: пустая стоп-последовательность", ErrInvalidGenerationOptions)
+		}
+	}
+
+	return nil
+}
+
+func (o *GenerationOptions) Merge(override *GenerationOptions) *GenerationOptions {
+	if o.IsEmpty() && override.IsEmpty() {
+		return nil
+	}
+
+	out := &GenerationOptions{}
+	for _, src := range []*GenerationOptions{o, override} {
+		if src == nil {
+			continue
+		}
+
+		if src.Temperature != nil {
+			out.Temperature = src.Temperature
+		}
+
+		if src.TopP != nil {
+			out.TopP = src.TopP
+		}
+
+		if src.TopK != nil {
+			out.TopK = src.TopK
+		}
+
+		if src.MaxTokens != nil {
+			out.MaxTokens = src.MaxTokens
+		}
+
+		if len(src.Stop) > 0 {
+			out.Stop = append([]string(nil), src.Stop...)
+		}
+
+		if src.Seed != nil {
+			out.Seed = src.Seed
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrQuotaExceeded = errors.New("превышена квота запросов к ИИ")
+
+type AIQuotaLimit string
+
+const (
+	AIQuotaLimitRequestsPerMinute AIQuotaLimit = "requests_per_minute"
+	AIQuotaLimitTokensPerDay      AIQuotaLimit = "tokens_per_day"
+	AIQuotaLimitConcurrentStreams AIQuotaLimit = "concurrent_streams"
+)
+
+type AIQuota struct {
+	RequestsPerMinute int
+	TokensPerDay      int64
+	ConcurrentStreams int
+}
+
+func (q AIQuota) IsUnlimited() bool {
+	return q.RequestsPerMinute <= 0 && q.TokensPerDay <= 0 && q.ConcurrentStreams <= 0
+}
+
+type AIQuotaPolicy struct {
+	Roles map[UserRole]AIQuota
+	Users map[int]AIQuota
+}
+
+func (p AIQuotaPolicy) HasUserOverride(userId int) bool {
+	_, ok := p.Users[userId]
+	return ok
+}
+
+func (p AIQuotaPolicy) For(userId int, role UserRole) AIQuota {
+	if q, ok := p.Users[userId]; ok {
+		return q
+	}
+
+	return p.Roles[role]
+}
+
+type QuotaExceededError struct {
+	Limit      AIQuotaLimit
+	RetryAfter time.Duration
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: лимит %s, повторите через %s", ErrQuotaExceeded, e.Limit, e.RetryAfter.Round(time.Second))
+}
+
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"time"
 )
 
 type UserRepository interface {
@@ -49,6 +50,14 @@ type AIChatRepository interface {
 
 	Update(ctx context.Context, session *AIChatSession) error
 
+	UpdateContextSummary(ctx context.Context, id string, summary *AIChatContextSummary) error
+
+	UpdateGeneratedTitle(ctx context.Context, id string, title string) (bool, error)
+
+	UpdateActiveMessage(ctx context.Context, id string, messageId string) error
+
+	AdvanceActiveMessage(ctx context.Context, id string, parentId string, messageId string) error
+
 	Delete(ctx context.Context, id string) error
 }
 
@@ -56,6 +65,72 @@ type AIChatMessageRepository interface {
 	Create(ctx context.Context, message *AIChatMessage) error
 
 	GetBySessionId(ctx context.Context, sessionID string, page, pageSize int32) ([]*AIChatMessage, int32, error)
+
+	GetById(ctx context.Context, id string) (*AIChatMessage, error)
+
+	GetBranch(ctx context.Context, sessionID string, leafId string, limit int) ([]*AIChatMessage, error)
+
+	GetChildIds(ctx context.Context, sessionID string, parentIds []string) (map[string][]string, error)
+
+	GetLatestLeaf(ctx context.Context, sessionID string, messageId string) (*AIChatMessage, error)
+}
+
+type TokenUsageRepository interface {
+	Aggregate(ctx context.Context, filter UsageFilter) ([]*UsageStat, error)
+
+	Record(ctx context.Context, record *TokenUsageRecord) error
+}
+
+type AIQuotaRepository interface {
+	IncrementRequests(ctx context.Context, userId int, window time.Duration) (int64, time.Duration, error)
+
+	GetDailyTokens(ctx context.Context, userId int, day time.Time) (int64, error)
+
+	AddDailyTokens(ctx context.Context, userId int, day time.Time, tokens int64) error
+
+	AcquireStream(ctx context.Context, userId int, limit int) (bool, error)
+
+	ReleaseStream(ctx context.Context, userId int) error
+}
+
+type AIPersonaRepository interface {
+	Create(ctx context.Context, persona *AIPersona) error
+
+	GetById(ctx context.Context, id string) (*AIPersona, error)
+
+	ListAvailable(ctx context.Context, userId int) ([]*AIPersona, error)
+
+	Update(ctx context.Context, persona *AIPersona) error
+
+	Delete(ctx context.Context, id string) error
+}
+
+type KnowledgeBaseRepository interface {
+	Create(ctx context.Context, kb *KnowledgeBase) error
+
+	GetById(ctx context.Context, id string) (*KnowledgeBase, error)
+
+	ListByUserId(ctx context.Context, userId int) ([]*KnowledgeBase, error)
+
+	Delete(ctx context.Context, id string) error
+
+	AddDocument(ctx context.Context, doc *KnowledgeDocument, chunks []*KnowledgeChunk) error
+
+	ListDocuments(ctx context.Context, knowledgeBaseId string) ([]*KnowledgeDocument, error)
+
+	GetChunks(ctx context.Context, knowledgeBaseId string) ([]*KnowledgeChunk, error)
+}
+
+type AIStreamRepository interface {
+	Start(ctx context.Context, stream *AIStream) error
+
+	Append(ctx context.Context, messageId string, chunks []string) error
+
+	Finish(ctx context.Context, messageId string, reason AIFinishReason) error
+
+	Get(ctx context.Context, messageId string) (*AIStream, error)
+
+	Chunks(ctx context.Context, messageId string, chunkOffset int64) ([]string, error)
 }
 
 type FileRepository interface {
@@ -64,12 +139,46 @@ type FileRepository interface {
 	GetById(ctx context.Context, id string) (*File, error)
 }
 
+type RunnerCredentialRepository interface {
+	Create(ctx context.Context, credential *RunnerCredential) error
+
+	GetByTokenHash(ctx context.Context, tokenHash string) (*RunnerCredential, error)
+
+	List(ctx context.Context) ([]*RunnerCredential, error)
+
+	BindAddress(ctx context.Context, id string, address string) error
+
+	TouchLastUsed(ctx context.Context, id string) error
+
+	Revoke(ctx context.Context, id string) error
+}
+
+type RunnerRepository interface {
+	List(ctx context.Context) ([]*Runner, error)
+
+	Get(ctx context.Context, address string) (*Runner, error)
+
+	Save(ctx context.Context, runner *Runner) error
+
+	Delete(ctx context.Context, address string) error
+}
+
 type LLMProvider interface {
 	CheckConnection(ctx context.Context) (bool, error)
 
 	GetModels(ctx context.Context) ([]string, error)
 
-	SendMessage(ctx context.Context, sessionID string, model string, messages []*AIChatMessage) (chan string, error)
+	GetModelsInfo(ctx context.Context) ([]*AIModel, error)
+
+	SendMessage(ctx context.Context, sessionID string, model string, messages []*AIChatMessage, opts *GenerationOptions) (chan string, *TokenUsage, error)
+}
+
+type EmbeddingProvider interface {
+	Embed(ctx context.Context, model string, inputs []string) ([][]float32, error)
+}
+
+type ToolCallingProvider interface {
+	SendMessageWithTools(ctx context.Context, sessionID string, model string, messages []*AIChatMessage, tools []*AITool, opts *GenerationOptions) (chan string, *TokenUsage, *AIToolCalls, error)
 }
 
 type ChatRepository interface {
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/magomedcoder/legion/pkg"
+)
+
+var (
+	ErrRunnerCredentialNotFound = errors.New("учётные данные раннера не найдены")
+	ErrRunnerCredentialRevoked  = errors.New("учётные данные раннера отозваны")
+	ErrRunnerAddressMismatch    = errors.New("адрес раннера не совпадает с привязанным к учётным данным")
+	ErrRunnerNotFound           = errors.New("раннер не найден")
+)
+
+type RunnerCredential struct {
+	Id         string
+	Name       string
+	Address    string
+	TokenHash  string
+	CreatedBy  int
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func NewRunnerCredential(name, address, tokenHash string, createdBy int) *RunnerCredential {
+	return &RunnerCredential{
+		Id:        pkg.GenerateUUID(),
+		Name:      name,
+		Address:   address,
+		TokenHash: tokenHash,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (c *RunnerCredential) IsRevoked() bool {
+	return c.RevokedAt != nil
+}
+
+type Runner struct {
+	Address    string
+	Enabled    bool
+	Labels     map[string]string
+	LastSeenAt *time.Time
+	UpdatedAt  time.Time
+}
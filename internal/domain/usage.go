@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var ErrInvalidUsageFilter = errors.New("некорректный фильтр статистики")
+
+type TokenUsage struct {
+	PromptTokens     int32
+	CompletionTokens int32
+}
+
+func (u *TokenUsage) Total() int32 {
+	if u == nil {
+		return 0
+	}
+
+	return u.PromptTokens + u.CompletionTokens
+}
+
+func (u *TokenUsage) Add(other *TokenUsage) {
+	if u == nil || other == nil {
+		return
+	}
+
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+}
+
+func (u *TokenUsage) IsEmpty() bool {
+	return u == nil || (u.PromptTokens == 0 && u.CompletionTokens == 0)
+}
+
+type TokenUsageSource string
+
+const (
+	TokenUsageSourceOpenAIAPI TokenUsageSource = "openai_api"
+	TokenUsageSourceEditor    TokenUsageSource = "editor"
+	TokenUsageSourceTitle     TokenUsageSource = "title"
+	TokenUsageSourceSummary   TokenUsageSource = "summary"
+)
+
+type TokenUsageRecord struct {
+	UserId    int
+	Model     string
+	Source    TokenUsageSource
+	Usage     TokenUsage
+	CreatedAt time.Time
+}
+
+type UsageGroupBy string
+
+const (
+	UsageGroupByUser  UsageGroupBy = "user"
+	UsageGroupByModel UsageGroupBy = "model"
+	UsageGroupByDay   UsageGroupBy = "day"
+)
+
+type UsageFilter struct {
+	GroupBy UsageGroupBy
+	From    time.Time
+	To      time.Time
+	UserId  int
+	Model   string
+}
+
+func (f *UsageFilter) Validate() error {
+	switch f.GroupBy {
+	case UsageGroupByUser, UsageGroupByModel, UsageGroupByDay:
+	default:
+		return fmt.Errorf("%w: неизвестная группировка %q", ErrInvalidUsageFilter, f.GroupBy)
+	}
+
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("%w: начало периода должно быть раньше конца", ErrInvalidUsageFilter)
+	}
+
+	return nil
+}
+
+type UsageStat struct {
+	UserId           int
+	Model            string
+	Day              time.Time
+	PromptTokens     int64
+	CompletionTokens int64
+	Messages         int64
+}
@@ -60,6 +60,22 @@ func (s *SenderContent) SetUpdateTaskChanged(update *accountpb.Update_TaskChange
 	return s
 }
 
+func (s *SenderContent) SetUpdateAISessionTitle(update *accountpb.Update_AiSessionTitle) *SenderContent {
+	s.update = &accountpb.UpdateResponse{
+		Updates: []*accountpb.Update{{UpdateType: update}},
+	}
+
+	return s
+}
+
+func (s *SenderContent) SetUpdateAIMessageCompleted(update *accountpb.Update_AiMessageCompleted) *SenderContent {
+	s.update = &accountpb.UpdateResponse{
+		Updates: []*accountpb.Update{{UpdateType: update}},
+	}
+
+	return s
+}
+
 func (s *SenderContent) SetReceive(cid ...int64) *SenderContent {
 	s.recipientIDs = append(s.recipientIDs, cid...)
 	return s
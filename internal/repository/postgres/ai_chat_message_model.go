@@ -1,9 +1,11 @@
 package postgres
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
 	"gorm.io/gorm"
 )
 
@@ -13,6 +15,15 @@ type aiChatMessageModel struct {
 	Content          string         `gorm:"column:content;type:text;not null"`
 	Role             string         `gorm:"column:role;size:20;not null"`
 	AttachmentFileId *string        `gorm:"column:attachment_file_id;type:uuid"`
+	ParentId         *string        `gorm:"column:parent_id;type:uuid"`
+	Model            *string        `gorm:"column:model;size:255"`
+	PromptTokens     int32          `gorm:"column:prompt_tokens;not null;default:0"`
+	CompletionTokens int32          `gorm:"column:completion_tokens;not null;default:0"`
+	Stopped          bool           `gorm:"column:stopped;not null;default:false"`
+	FinishReason     *string        `gorm:"column:finish_reason;size:32"`
+	ToolCalls        *string        `gorm:"column:tool_calls;type:jsonb"`
+	ToolCallId       *string        `gorm:"column:tool_call_id;size:255"`
+	ToolName         *string        `gorm:"column:tool_name;size:64"`
 	CreatedAt        time.Time      `gorm:"column:created_at;not null"`
 	UpdatedAt        time.Time      `gorm:"column:updated_at;not null"`
 	DeletedAt        gorm.DeletedAt `gorm:"column:deleted_at;index"`
@@ -22,6 +33,58 @@ func (aiChatMessageModel) TableName() string {
 	return "chat_session_messages"
 }
 
+type toolCallJSON struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+func toolCallsToJSON(calls []*domain.AIToolCall) *string {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	items := make([]toolCallJSON, len(calls))
+	for i, c := range calls {
+		items[i] = toolCallJSON{
+			Id:        c.Id,
+			Name:      c.Name,
+			Arguments: c.Arguments,
+		}
+	}
+
+	data, err := json.Marshal(items)
+	if err != nil {
+		return nil
+	}
+	s := string(data)
+
+	return &s
+}
+
+func toolCallsFromJSON(raw *string) []*domain.AIToolCall {
+	if raw == nil || *raw == "" {
+		return nil
+	}
+
+	var items []toolCallJSON
+	if err := json.Unmarshal([]byte(*raw), &items); err != nil {
+		logger.W("aiChatMessageModel: некорректные вызовы инструментов: %v", err)
+		return nil
+	}
+
+	calls := make([]*domain.AIToolCall, len(items))
+	for i, item := range items {
+		calls[i] = &domain.AIToolCall{
+			Id:        item.Id,
+			Name:      item.Name,
+			Arguments: item.Arguments,
+		}
+	}
+
+	return calls
+}
+
 func aiChatMessageModelToDomain(m *aiChatMessageModel) *domain.AIChatMessage {
 	if m == nil {
 		return nil
@@ -32,6 +95,19 @@ func aiChatMessageModelToDomain(m *aiChatMessageModel) *domain.AIChatMessage {
 		attachmentName = *m.AttachmentFileId
 	}
 
+	model := ""
+	if m.Model != nil {
+		model = *m.Model
+	}
+
+	var usage *domain.TokenUsage
+	if m.PromptTokens != 0 || m.CompletionTokens != 0 {
+		usage = &domain.TokenUsage{
+			PromptTokens:     m.PromptTokens,
+			CompletionTokens: m.CompletionTokens,
+		}
+	}
+
 	var deletedAt *time.Time
 	if m.DeletedAt.Valid {
 		t := m.DeletedAt.Time
@@ -44,6 +120,14 @@ func aiChatMessageModelToDomain(m *aiChatMessageModel) *domain.AIChatMessage {
 		Content:        m.Content,
 		Role:           domain.AIChatMessageRole(m.Role),
 		AttachmentName: attachmentName,
+		Model:          model,
+		Usage:          usage,
+		ParentId:       stringFromNullable(m.ParentId),
+		Stopped:        m.Stopped,
+		FinishReason:   domain.AIFinishReason(stringFromNullable(m.FinishReason)),
+		ToolCalls:      toolCallsFromJSON(m.ToolCalls),
+		ToolCallId:     stringFromNullable(m.ToolCallId),
+		ToolName:       stringFromNullable(m.ToolName),
 		CreatedAt:      m.CreatedAt,
 		UpdatedAt:      m.UpdatedAt,
 		DeletedAt:      deletedAt,
@@ -60,19 +144,37 @@ func aiChatMessageDomainToModel(msg *domain.AIChatMessage) *aiChatMessageModel {
 		attachmentFileId = &msg.AttachmentName
 	}
 
+	var model *string
+	if msg.Model != "" {
+		model = &msg.Model
+	}
+
 	var deletedAt gorm.DeletedAt
 	if msg.DeletedAt != nil {
 		deletedAt = gorm.DeletedAt{Time: *msg.DeletedAt, Valid: true}
 	}
 
-	return &aiChatMessageModel{
+	m := &aiChatMessageModel{
 		Id:               msg.Id,
 		SessionId:        msg.SessionId,
 		Content:          msg.Content,
 		Role:             string(msg.Role),
 		AttachmentFileId: attachmentFileId,
+		ParentId:         nullableString(msg.ParentId),
+		Stopped:          msg.Stopped,
+		FinishReason:     nullableString(string(msg.FinishReason)),
+		ToolCalls:        toolCallsToJSON(msg.ToolCalls),
+		ToolCallId:       nullableString(msg.ToolCallId),
+		ToolName:         nullableString(msg.ToolName),
+		Model:            model,
 		CreatedAt:        msg.CreatedAt,
 		UpdatedAt:        msg.UpdatedAt,
 		DeletedAt:        deletedAt,
 	}
+	if msg.Usage != nil {
+		m.PromptTokens = msg.Usage.PromptTokens
+		m.CompletionTokens = msg.Usage.CompletionTokens
+	}
+
+	return m
 }
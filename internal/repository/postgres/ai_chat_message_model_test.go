@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -61,4 +62,97 @@ func Test_aiChatMessageDomainToModel(t *testing.T) {
 			t.Errorf("aiChatMessageDomainToModel: %+v", got)
 		}
 	})
+
+	t.Run("модель и usage ответа", func(t *testing.T) {
+		msg := &domain.AIChatMessage{
+			Id:        "mid",
+			SessionId: "sid",
+			Role:      domain.AIChatMessageRoleAssistant,
+			Model:     "llama3",
+			Usage:     &domain.TokenUsage{PromptTokens: 11, CompletionTokens: 5},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		m := aiChatMessageDomainToModel(msg)
+		if m.Model == nil || *m.Model != "llama3" || m.PromptTokens != 11 || m.CompletionTokens != 5 {
+			t.Fatalf("aiChatMessageDomainToModel: %+v", m)
+		}
+
+		got := aiChatMessageModelToDomain(m)
+		if got.Model != "llama3" || got.Usage == nil || got.Usage.Total() != 16 {
+			t.Errorf("aiChatMessageModelToDomain: %+v", got)
+		}
+	})
+}
+
+func Test_aiChatMessage_parentId(t *testing.T) {
+	m := aiChatMessageDomainToModel(&domain.AIChatMessage{Id: "b", ParentId: "a"})
+	if m.ParentId == nil || *m.ParentId != "a" {
+		t.Fatalf("parent_id не сохранён: %v", m.ParentId)
+	}
+
+	if got := aiChatMessageModelToDomain(m); got.ParentId != "a" {
+		t.Errorf("parent_id не восстановлен: %q", got.ParentId)
+	}
+
+	if m := aiChatMessageDomainToModel(&domain.AIChatMessage{Id: "root"}); m.ParentId != nil {
+		t.Error("у корневого сообщения parent_id должен быть NULL")
+	}
+}
+
+func Test_messageBranchQuery(t *testing.T) {
+	if q := messageBranchQuery(0); strings.Contains(q, "@limit") {
+		t.Error("без лимита глубина ветки не должна ограничиваться")
+	}
+
+	if q := messageBranchQuery(50); !strings.Contains(q, "b.depth < @limit") {
+		t.Error("лимит должен ограничивать глубину ветки")
+	}
+}
+
+func Test_aiChatMessage_finishReason(t *testing.T) {
+	m := aiChatMessageDomainToModel(&domain.AIChatMessage{
+		Id:           "m",
+		Stopped:      true,
+		FinishReason: domain.AIFinishReasonStopped,
+	})
+	if !m.Stopped || m.FinishReason == nil || *m.FinishReason != "stopped" {
+		t.Fatalf("признак остановки не сохранён: %+v", m)
+	}
+
+	if got := aiChatMessageModelToDomain(m); !got.Stopped || got.FinishReason != domain.AIFinishReasonStopped {
+		t.Errorf("признак остановки не восстановлен: %+v", got)
+	}
+
+	if m := aiChatMessageDomainToModel(&domain.AIChatMessage{Id: "u"}); m.FinishReason != nil {
+		t.Error("у сообщений без причины завершения finish_reason должен быть NULL")
+	}
+}
+
+func Test_aiChatMessageModel_toolCalls(t *testing.T) {
+	msg := &domain.AIChatMessage{
+		Id:        "mid",
+		SessionId: "sid",
+		Role:      domain.AIChatMessageRoleAssistant,
+		ToolCalls: []*domain.AIToolCall{{Id: "c1", Name: "get_task", Arguments: `{"task_id":"t"}`}},
+	}
+	m := aiChatMessageDomainToModel(msg)
+	if m.ToolCalls == nil || m.ToolCallId != nil {
+		t.Fatalf("вызовы инструментов не сохранены: %+v", m)
+	}
+
+	got := aiChatMessageModelToDomain(m)
+	if len(got.ToolCalls) != 1 || *got.ToolCalls[0] != *msg.ToolCalls[0] {
+		t.Errorf("вызовы инструментов: %+v", got.ToolCalls)
+	}
+
+	result := aiChatMessageModelToDomain(aiChatMessageDomainToModel(domain.NewAIToolResultMessage("sid", msg.ToolCalls[0], "{}")))
+	if result.ToolCallId != "c1" || result.ToolName != "get_task" || result.ToolCalls != nil {
+		t.Errorf("результат инструмента: %+v", result)
+	}
+
+	bad := "{"
+	if calls := toolCallsFromJSON(&bad); calls != nil {
+		t.Errorf("некорректный JSON: %+v", calls)
+	}
 }
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 
 	"github.com/magomedcoder/legion/internal/domain"
 	"gorm.io/gorm"
@@ -47,3 +48,117 @@ func (r *messageRepository) GetBySessionId(ctx context.Context, sessionID string
 
 	return messages, int32(total), nil
 }
+
+func (r *messageRepository) GetById(ctx context.Context, id string) (*domain.AIChatMessage, error) {
+	var m aiChatMessageModel
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrAIMessageNotFound
+		}
+		return nil, err
+	}
+
+	return aiChatMessageModelToDomain(&m), nil
+}
+
+func messageBranchQuery(limit int) string {
+	depthLimit := ""
+	if limit > 0 {
+		depthLimit = " AND b.depth < @limit"
+	}
+
+	return `WITH RECURSIVE branch AS (
+	SELECT m.*, 1 AS depth FROM chat_session_messages m
+	WHERE m.id = @leaf AND m.session_id = @session AND m.deleted_at IS NULL
+	UNION ALL
+	SELECT m.*, b.depth + 1 FROM chat_session_messages m
+	JOIN branch b ON m.id = b.parent_id
+	WHERE m.deleted_at IS NULL` + depthLimit + `
+)
+SELECT * FROM branch ORDER BY depth DESC`
+}
+
+func (r *messageRepository) GetBranch(ctx context.Context, sessionID string, leafId string, limit int) ([]*domain.AIChatMessage, error) {
+	if leafId == "" {
+		return nil, nil
+	}
+
+	var list []aiChatMessageModel
+	if err := r.db.WithContext(ctx).Raw(messageBranchQuery(limit), map[string]interface{}{
+		"leaf":    leafId,
+		"session": sessionID,
+		"limit":   limit,
+	}).Scan(&list).Error; err != nil {
+		return nil, err
+	}
+
+	messages := make([]*domain.AIChatMessage, 0, len(list))
+	for i := range list {
+		messages = append(messages, aiChatMessageModelToDomain(&list[i]))
+	}
+
+	return messages, nil
+}
+
+func (r *messageRepository) GetChildIds(ctx context.Context, sessionID string, parentIds []string) (map[string][]string, error) {
+	ids := make([]string, 0, len(parentIds))
+	withRoots := false
+	for _, id := range parentIds {
+		if id == "" {
+			withRoots = true
+		} else {
+			ids = append(ids, id)
+		}
+	}
+
+	q := r.db.WithContext(ctx).Model(&aiChatMessageModel{}).
+		Select("id", "parent_id").
+		Where("session_id = ?", sessionID)
+	switch {
+	case withRoots && len(ids) > 0:
+		q = q.Where("(parent_id IN ? OR parent_id IS NULL)", ids)
+	case withRoots:
+		q = q.Where("parent_id IS NULL")
+	case len(ids) > 0:
+		q = q.Where("parent_id IN ?", ids)
+	default:
+		return map[string][]string{}, nil
+	}
+
+	var rows []aiChatMessageModel
+	if err := q.Order("created_at ASC, id ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]string, len(parentIds))
+	for _, row := range rows {
+		parentId := stringFromNullable(row.ParentId)
+		children[parentId] = append(children[parentId], row.Id)
+	}
+
+	return children, nil
+}
+
+func (r *messageRepository) GetLatestLeaf(ctx context.Context, sessionID string, messageId string) (*domain.AIChatMessage, error) {
+	var list []aiChatMessageModel
+	if err := r.db.WithContext(ctx).Raw(`WITH RECURSIVE subtree AS (
+	SELECT m.* FROM chat_session_messages m
+	WHERE m.id = @message AND m.session_id = @session AND m.deleted_at IS NULL
+	UNION ALL
+	SELECT m.* FROM chat_session_messages m
+	JOIN subtree s ON m.parent_id = s.id
+	WHERE m.deleted_at IS NULL
+)
+SELECT * FROM subtree ORDER BY created_at DESC, id DESC LIMIT 1`, map[string]interface{}{
+		"message": messageId,
+		"session": sessionID,
+	}).Scan(&list).Error; err != nil {
+		return nil, err
+	}
+
+	if len(list) == 0 {
+		return nil, domain.ErrAIMessageNotFound
+	}
+
+	return aiChatMessageModelToDomain(&list[0]), nil
+}
@@ -66,12 +66,57 @@ func (ai *aiChatSessionRepository) Update(ctx context.Context, session *domain.A
 	return ai.db.WithContext(ctx).Model(&aiChatSessionModel{}).
 		Where("id = ?", session.Id).
 		Updates(map[string]interface{}{
-			"title":      session.Title,
-			"model":      session.Model,
-			"updated_at": session.UpdatedAt,
+			"title":              session.Title,
+			"title_manual":       session.TitleManual,
+			"model":              session.Model,
+			"generation_options": generationOptionsToJSON(session.GenerationOptions),
+			"knowledge_base_id":  nullableString(session.KnowledgeBaseId),
+			"updated_at":         session.UpdatedAt,
 		}).Error
 }
 
+func (ai *aiChatSessionRepository) UpdateGeneratedTitle(ctx context.Context, id string, title string) (bool, error) {
+	res := ai.db.WithContext(ctx).Model(&aiChatSessionModel{}).
+		Where("id = ? AND title_manual = ?", id, false).
+		Updates(map[string]interface{}{
+			"title":      title,
+			"updated_at": time.Now(),
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (ai *aiChatSessionRepository) UpdateContextSummary(ctx context.Context, id string, summary *domain.AIChatContextSummary) error {
+	content, until, messageId := contextSummaryToColumns(summary)
+	return ai.db.WithContext(ctx).Model(&aiChatSessionModel{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"context_summary":            content,
+			"context_summary_until":      until,
+			"context_summary_message_id": messageId,
+		}).Error
+}
+
+func (ai *aiChatSessionRepository) UpdateActiveMessage(ctx context.Context, id string, messageId string) error {
+	return ai.db.WithContext(ctx).Model(&aiChatSessionModel{}).
+		Where("id = ?", id).
+		Update("active_message_id", nullableString(messageId)).Error
+}
+
+func (ai *aiChatSessionRepository) AdvanceActiveMessage(ctx context.Context, id string, parentId string, messageId string) error {
+	query := ai.db.WithContext(ctx).Model(&aiChatSessionModel{}).Where("id = ?", id)
+	if parentId == "" {
+		query = query.Where("active_message_id IS NULL")
+	} else {
+		query = query.Where("active_message_id = ?", parentId)
+	}
+
+	return query.Update("active_message_id", messageId).Error
+}
+
 func (ai *aiChatSessionRepository) Delete(ctx context.Context, id string) error {
 	return ai.db.WithContext(ctx).Where("id = ?", id).Delete(&aiChatSessionModel{}).Error
 }
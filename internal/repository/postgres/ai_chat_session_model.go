@@ -1,20 +1,108 @@
 package postgres
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
 	"gorm.io/gorm"
 )
 
 type aiChatSessionModel struct {
-	Id        string         `gorm:"column:id;primaryKey;type:uuid"`
-	UserId    int            `gorm:"column:user_id;not null;index"`
-	Title     string         `gorm:"column:title;size:500;not null"`
-	Model     string         `gorm:"column:model;size:255;not null;default:''"`
-	CreatedAt time.Time      `gorm:"column:created_at;not null"`
-	UpdatedAt time.Time      `gorm:"column:updated_at;not null"`
-	DeletedAt gorm.DeletedAt `gorm:"column:deleted_at;index"`
+	Id                      string         `gorm:"column:id;primaryKey;type:uuid"`
+	UserId                  int            `gorm:"column:user_id;not null;index"`
+	Title                   string         `gorm:"column:title;size:500;not null"`
+	TitleManual             bool           `gorm:"column:title_manual;not null;default:false"`
+	Model                   string         `gorm:"column:model;size:255;not null;default:''"`
+	GenerationOptions       *string        `gorm:"column:generation_options;type:jsonb"`
+	PersonaId               *string        `gorm:"column:persona_id;type:uuid"`
+	KnowledgeBaseId         *string        `gorm:"column:knowledge_base_id;type:uuid"`
+	ContextSummary          *string        `gorm:"column:context_summary;type:text"`
+	ContextSummaryUntil     *time.Time     `gorm:"column:context_summary_until"`
+	ContextSummaryMessageId *string        `gorm:"column:context_summary_message_id;type:uuid"`
+	ActiveMessageId         *string        `gorm:"column:active_message_id;type:uuid"`
+	CreatedAt               time.Time      `gorm:"column:created_at;not null"`
+	UpdatedAt               time.Time      `gorm:"column:updated_at;not null"`
+	DeletedAt               gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+type generationOptionsJSON struct {
+	Temperature *float32 `json:"temperature,omitempty"`
+	TopP        *float32 `json:"top_p,omitempty"`
+	TopK        *int32   `json:"top_k,omitempty"`
+	MaxTokens   *int32   `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	Seed        *int64   `json:"seed,omitempty"`
+}
+
+func generationOptionsToJSON(opts *domain.GenerationOptions) *string {
+	if opts.IsEmpty() {
+		return nil
+	}
+
+	data, err := json.Marshal(generationOptionsJSON{
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		TopK:        opts.TopK,
+		MaxTokens:   opts.MaxTokens,
+		Stop:        opts.Stop,
+		Seed:        opts.Seed,
+	})
+	if err != nil {
+		return nil
+	}
+	s := string(data)
+
+	return &s
+}
+
+func generationOptionsFromJSON(raw *string) *domain.GenerationOptions {
+	if raw == nil || *raw == "" {
+		return nil
+	}
+
+	var v generationOptionsJSON
+	if err := json.Unmarshal([]byte(*raw), &v); err != nil {
+		logger.W("aiChatSessionModel: некорректные параметры генерации: %v", err)
+		return nil
+	}
+
+	opts := &domain.GenerationOptions{
+		Temperature: v.Temperature,
+		TopP:        v.TopP,
+		TopK:        v.TopK,
+		MaxTokens:   v.MaxTokens,
+		Stop:        v.Stop,
+		Seed:        v.Seed,
+	}
+	if opts.IsEmpty() {
+		return nil
+	}
+
+	return opts
+}
+
+func contextSummaryFromColumns(content *string, until *time.Time, messageId *string) *domain.AIChatContextSummary {
+	if content == nil || *content == "" || until == nil {
+		return nil
+	}
+
+	return &domain.AIChatContextSummary{
+		Content:   *content,
+		MessageId: stringFromNullable(messageId),
+		Until:     *until,
+	}
+}
+
+func contextSummaryToColumns(summary *domain.AIChatContextSummary) (*string, *time.Time, *string) {
+	if summary == nil || summary.Content == "" {
+		return nil, nil, nil
+	}
+
+	content, until := summary.Content, summary.Until
+
+	return &content, &until, nullableString(summary.MessageId)
 }
 
 func (aiChatSessionModel) TableName() string {
@@ -33,13 +121,19 @@ func aiChatSessionModelToDomain(m *aiChatSessionModel) *domain.AIChatSession {
 	}
 
 	return &domain.AIChatSession{
-		Id:        m.Id,
-		UserId:    m.UserId,
-		Title:     m.Title,
-		Model:     m.Model,
-		CreatedAt: m.CreatedAt,
-		UpdatedAt: m.UpdatedAt,
-		DeletedAt: deletedAt,
+		Id:                m.Id,
+		UserId:            m.UserId,
+		Title:             m.Title,
+		TitleManual:       m.TitleManual,
+		Model:             m.Model,
+		GenerationOptions: generationOptionsFromJSON(m.GenerationOptions),
+		PersonaId:         stringFromNullable(m.PersonaId),
+		KnowledgeBaseId:   stringFromNullable(m.KnowledgeBaseId),
+		ContextSummary:    contextSummaryFromColumns(m.ContextSummary, m.ContextSummaryUntil, m.ContextSummaryMessageId),
+		ActiveMessageId:   stringFromNullable(m.ActiveMessageId),
+		CreatedAt:         m.CreatedAt,
+		UpdatedAt:         m.UpdatedAt,
+		DeletedAt:         deletedAt,
 	}
 }
 
@@ -53,13 +147,23 @@ func aiChatSessionDomainToModel(s *domain.AIChatSession) *aiChatSessionModel {
 		deletedAt = gorm.DeletedAt{Time: *s.DeletedAt, Valid: true}
 	}
 
+	summary, summaryUntil, summaryMessageId := contextSummaryToColumns(s.ContextSummary)
+
 	return &aiChatSessionModel{
-		Id:        s.Id,
-		UserId:    s.UserId,
-		Title:     s.Title,
-		Model:     s.Model,
-		CreatedAt: s.CreatedAt,
-		UpdatedAt: s.UpdatedAt,
-		DeletedAt: deletedAt,
+		Id:                      s.Id,
+		UserId:                  s.UserId,
+		Title:                   s.Title,
+		TitleManual:             s.TitleManual,
+		Model:                   s.Model,
+		GenerationOptions:       generationOptionsToJSON(s.GenerationOptions),
+		PersonaId:               nullableString(s.PersonaId),
+		KnowledgeBaseId:         nullableString(s.KnowledgeBaseId),
+		ContextSummary:          summary,
+		ContextSummaryUntil:     summaryUntil,
+		ContextSummaryMessageId: summaryMessageId,
+		ActiveMessageId:         nullableString(s.ActiveMessageId),
+		CreatedAt:               s.CreatedAt,
+		UpdatedAt:               s.UpdatedAt,
+		DeletedAt:               deletedAt,
 	}
 }
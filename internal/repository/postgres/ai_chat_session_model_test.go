@@ -62,3 +62,78 @@ func Test_aiChatSessionDomainToModel(t *testing.T) {
 		}
 	})
 }
+
+func Test_aiChatSession_generationOptions(t *testing.T) {
+	temperature := float32(0.3)
+	seed := int64(7)
+	m := aiChatSessionDomainToModel(&domain.AIChatSession{
+		Id: "uuid",
+		GenerationOptions: &domain.GenerationOptions{
+			Temperature: &temperature,
+			Stop:        []string{"</s>"},
+			Seed:        &seed,
+		},
+	})
+	if m.GenerationOptions == nil {
+		t.Fatal("параметры генерации должны сохраняться в JSON")
+	}
+
+	got := aiChatSessionModelToDomain(m).GenerationOptions
+	if got == nil || *got.Temperature != 0.3 || *got.Seed != 7 || len(got.Stop) != 1 || got.TopP != nil {
+		t.Errorf("параметры генерации не восстановлены: %+v", got)
+	}
+
+	if m := aiChatSessionDomainToModel(&domain.AIChatSession{Id: "uuid"}); m.GenerationOptions != nil {
+		t.Errorf("пустые параметры должны храниться как NULL, получено %q", *m.GenerationOptions)
+	}
+
+	broken := "not json"
+	if got := aiChatSessionModelToDomain(&aiChatSessionModel{GenerationOptions: &broken}); got.GenerationOptions != nil {
+		t.Errorf("некорректный JSON должен игнорироваться: %+v", got.GenerationOptions)
+	}
+}
+
+func Test_aiChatSession_contextSummary(t *testing.T) {
+	until := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	m := aiChatSessionDomainToModel(&domain.AIChatSession{
+		Id: "uuid",
+		ContextSummary: &domain.AIChatContextSummary{
+			Content: "кратко",
+			Until:   until,
+		},
+	})
+	if m.ContextSummary == nil || m.ContextSummaryUntil == nil {
+		t.Fatal("краткое содержание должно сохраняться")
+	}
+
+	got := aiChatSessionModelToDomain(m).ContextSummary
+	if got == nil || got.Content != "кратко" || !got.Until.Equal(until) {
+		t.Errorf("краткое содержание не восстановлено: %+v", got)
+	}
+
+	if m := aiChatSessionDomainToModel(&domain.AIChatSession{Id: "uuid"}); m.ContextSummary != nil || m.ContextSummaryUntil != nil {
+		t.Error("отсутствующее краткое содержание должно храниться как NULL")
+	}
+}
+
+func Test_aiChatSession_titleManual(t *testing.T) {
+	m := aiChatSessionDomainToModel(&domain.AIChatSession{Id: "uuid", Title: "t", TitleManual: true})
+	if !m.TitleManual {
+		t.Fatal("признак ручного названия должен сохраняться")
+	}
+
+	if got := aiChatSessionModelToDomain(m); !got.TitleManual {
+		t.Error("признак ручного названия не восстановлен")
+	}
+}
+
+func Test_aiChatSession_personaId(t *testing.T) {
+	if m := aiChatSessionDomainToModel(&domain.AIChatSession{Id: "uuid", Title: "t"}); m.PersonaId != nil {
+		t.Errorf("сессия без персоны: persona_id = %v, ожидалось nil", *m.PersonaId)
+	}
+
+	m := aiChatSessionDomainToModel(&domain.AIChatSession{Id: "uuid", Title: "t", PersonaId: "p"})
+	if got := aiChatSessionModelToDomain(m); got.PersonaId != "p" {
+		t.Errorf("PersonaId = %q, ожидалось p", got.PersonaId)
+	}
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+type aiPersonaModel struct {
+	Id                string         `gorm:"column:id;primaryKey;type:uuid"`
+	UserId            int            `gorm:"column:user_id;not null;index"`
+	Name              string         `gorm:"column:name;size:255;not null"`
+	SystemPrompt      string         `gorm:"column:system_prompt;type:text;not null"`
+	Model             string         `gorm:"column:model;size:255;not null;default:''"`
+	GenerationOptions *string        `gorm:"column:generation_options;type:jsonb"`
+	Shared            bool           `gorm:"column:shared;not null;default:false"`
+	CreatedAt         time.Time      `gorm:"column:created_at;not null"`
+	UpdatedAt         time.Time      `gorm:"column:updated_at;not null"`
+	DeletedAt         gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+func (aiPersonaModel) TableName() string {
+	return "ai_personas"
+}
+
+func aiPersonaModelToDomain(m *aiPersonaModel) *domain.AIPersona {
+	if m == nil {
+		return nil
+	}
+
+	var deletedAt *time.Time
+	if m.DeletedAt.Valid {
+		t := m.DeletedAt.Time
+		deletedAt = &t
+	}
+
+	return &domain.AIPersona{
+		Id:                m.Id,
+		UserId:            m.UserId,
+		Name:              m.Name,
+		SystemPrompt:      m.SystemPrompt,
+		Model:             m.Model,
+		GenerationOptions: generationOptionsFromJSON(m.GenerationOptions),
+		Shared:            m.Shared,
+		CreatedAt:         m.CreatedAt,
+		UpdatedAt:         m.UpdatedAt,
+		DeletedAt:         deletedAt,
+	}
+}
+
+func aiPersonaDomainToModel(p *domain.AIPersona) *aiPersonaModel {
+	if p == nil {
+		return nil
+	}
+
+	var deletedAt gorm.DeletedAt
+	if p.DeletedAt != nil {
+		deletedAt = gorm.DeletedAt{Time: *p.DeletedAt, Valid: true}
+	}
+
+	return &aiPersonaModel{
+		Id:                p.Id,
+		UserId:            p.UserId,
+		Name:              p.Name,
+		SystemPrompt:      p.SystemPrompt,
+		Model:             p.Model,
+		GenerationOptions: generationOptionsToJSON(p.GenerationOptions),
+		Shared:            p.Shared,
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+		DeletedAt:         deletedAt,
+	}
+}
@@ -0,0 +1,55 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+func Test_aiPersonaModelToDomain(t *testing.T) {
+	if got := aiPersonaModelToDomain(nil); got != nil {
+		t.Errorf("aiPersonaModelToDomain(nil) = %v, ожидалось nil", got)
+	}
+
+	now := time.Now()
+	options := `{"temperature":0.5}`
+	got := aiPersonaModelToDomain(&aiPersonaModel{
+		Id:                "uuid",
+		UserId:            1,
+		Name:              "Юрист",
+		SystemPrompt:      "Отвечай как юрист",
+		Model:             "m",
+		GenerationOptions: &options,
+		Shared:            true,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+		DeletedAt:         gorm.DeletedAt{Time: now, Valid: true},
+	})
+	if got.Name != "Юрист" || got.SystemPrompt != "Отвечай как юрист" || !got.Shared || got.DeletedAt == nil {
+		t.Errorf("aiPersonaModelToDomain: %+v", got)
+	}
+
+	if got.GenerationOptions == nil || got.GenerationOptions.Temperature == nil || *got.GenerationOptions.Temperature != 0.5 {
+		t.Errorf("параметры генерации не восстановлены: %+v", got.GenerationOptions)
+	}
+}
+
+func Test_aiPersonaDomainToModel(t *testing.T) {
+	if got := aiPersonaDomainToModel(nil); got != nil {
+		t.Errorf("aiPersonaDomainToModel(nil) = %v, ожидалось nil", got)
+	}
+
+	temperature := float32(0.5)
+	m := aiPersonaDomainToModel(&domain.AIPersona{
+		Id:                "uuid",
+		UserId:            1,
+		Name:              "Юрист",
+		SystemPrompt:      "Отвечай как юрист",
+		GenerationOptions: &domain.GenerationOptions{Temperature: &temperature},
+	})
+	if m.GenerationOptions == nil || m.DeletedAt.Valid || m.Shared {
+		t.Errorf("aiPersonaDomainToModel: %+v", m)
+	}
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+type aiPersonaRepository struct {
+	db *gorm.DB
+}
+
+func NewAIPersonaRepository(db *gorm.DB) domain.AIPersonaRepository {
+	return &aiPersonaRepository{db: db}
+}
+
+func (r *aiPersonaRepository) Create(ctx context.Context, persona *domain.AIPersona) error {
+	return r.db.WithContext(ctx).Create(aiPersonaDomainToModel(persona)).Error
+}
+
+func (r *aiPersonaRepository) GetById(ctx context.Context, id string) (*domain.AIPersona, error) {
+	var m aiPersonaModel
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrAIPersonaNotFound
+		}
+		return nil, err
+	}
+
+	return aiPersonaModelToDomain(&m), nil
+}
+
+func (r *aiPersonaRepository) ListAvailable(ctx context.Context, userId int) ([]*domain.AIPersona, error) {
+	var list []aiPersonaModel
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? OR shared = TRUE", userId).
+		Order("name ASC").
+		Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]*domain.AIPersona, 0, len(list))
+	for i := range list {
+		out = append(out, aiPersonaModelToDomain(&list[i]))
+	}
+
+	return out, nil
+}
+
+func (r *aiPersonaRepository) Update(ctx context.Context, persona *domain.AIPersona) error {
+	persona.UpdatedAt = time.Now()
+	return r.db.WithContext(ctx).Model(&aiPersonaModel{}).
+		Where("id = ?", persona.Id).
+		Updates(map[string]interface{}{
+			"name":               persona.Name,
+			"system_prompt":      persona.SystemPrompt,
+			"model":              persona.Model,
+			"generation_options": generationOptionsToJSON(persona.GenerationOptions),
+			"shared":             persona.Shared,
+			"updated_at":         persona.UpdatedAt,
+		}).Error
+}
+
+func (r *aiPersonaRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&aiPersonaModel{}).Error
+}
@@ -16,3 +16,19 @@ func normalizePagination(page, pageSize int32) (int32, int32, int32) {
 	offset := (page - 1) * pageSize
 	return page, pageSize, offset
 }
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func stringFromNullable(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
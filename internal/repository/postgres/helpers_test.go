@@ -22,3 +22,17 @@ func Test_normalizePagination(t *testing.T) {
 		}
 	}
 }
+
+func Test_nullableString(t *testing.T) {
+	if nullableString("") != nil {
+		t.Error("пустая строка должна храниться как NULL")
+	}
+
+	if got := nullableString("id"); got == nil || *got != "id" {
+		t.Errorf("nullableString(id) = %v", got)
+	}
+
+	if stringFromNullable(nil) != "" || stringFromNullable(nullableString("id")) != "id" {
+		t.Error("stringFromNullable вернул неверное значение")
+	}
+}
@@ -0,0 +1,179 @@
+package postgres
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+type knowledgeBaseModel struct {
+	Id             string         `gorm:"column:id;primaryKey;type:uuid"`
+	UserId         int            `gorm:"column:user_id;not null;index"`
+	Name           string         `gorm:"column:name;size:255;not null"`
+	EmbeddingModel string         `gorm:"column:embedding_model;size:255;not null;default:''"`
+	CreatedAt      time.Time      `gorm:"column:created_at;not null"`
+	UpdatedAt      time.Time      `gorm:"column:updated_at;not null"`
+	DeletedAt      gorm.DeletedAt `gorm:"column:deleted_at;index"`
+}
+
+func (knowledgeBaseModel) TableName() string {
+	return "knowledge_bases"
+}
+
+type knowledgeDocumentModel struct {
+	Id              string    `gorm:"column:id;primaryKey;type:uuid"`
+	KnowledgeBaseId string    `gorm:"column:knowledge_base_id;type:uuid;not null;index"`
+	FileId          *string   `gorm:"column:file_id;type:uuid"`
+	Name            string    `gorm:"column:name;size:255;not null"`
+	Chunks          int       `gorm:"column:chunks;not null;default:0"`
+	CreatedAt       time.Time `gorm:"column:created_at;not null"`
+}
+
+func (knowledgeDocumentModel) TableName() string {
+	return "knowledge_documents"
+}
+
+type knowledgeChunkModel struct {
+	Id              string `gorm:"column:id;primaryKey;type:uuid"`
+	KnowledgeBaseId string `gorm:"column:knowledge_base_id;type:uuid;not null;index"`
+	DocumentId      string `gorm:"column:document_id;type:uuid;not null"`
+	DocumentName    string `gorm:"column:document_name;->"`
+	Position        int    `gorm:"column:position;not null"`
+	StartOffset     int    `gorm:"column:start_offset;not null"`
+	EndOffset       int    `gorm:"column:end_offset;not null"`
+	Content         string `gorm:"column:content;type:text;not null"`
+	Embedding       []byte `gorm:"column:embedding;type:bytea;not null"`
+}
+
+func (knowledgeChunkModel) TableName() string {
+	return "knowledge_chunks"
+}
+
+func encodeEmbedding(v []float32) []byte {
+	out := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(f))
+	}
+
+	return out
+}
+
+func decodeEmbedding(b []byte) []float32 {
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+
+	return out
+}
+
+func knowledgeBaseModelToDomain(m *knowledgeBaseModel) *domain.KnowledgeBase {
+	if m == nil {
+		return nil
+	}
+
+	var deletedAt *time.Time
+	if m.DeletedAt.Valid {
+		t := m.DeletedAt.Time
+		deletedAt = &t
+	}
+
+	return &domain.KnowledgeBase{
+		Id:             m.Id,
+		UserId:         m.UserId,
+		Name:           m.Name,
+		EmbeddingModel: m.EmbeddingModel,
+		CreatedAt:      m.CreatedAt,
+		UpdatedAt:      m.UpdatedAt,
+		DeletedAt:      deletedAt,
+	}
+}
+
+func knowledgeBaseDomainToModel(kb *domain.KnowledgeBase) *knowledgeBaseModel {
+	if kb == nil {
+		return nil
+	}
+
+	var deletedAt gorm.DeletedAt
+	if kb.DeletedAt != nil {
+		deletedAt = gorm.DeletedAt{Time: *kb.DeletedAt, Valid: true}
+	}
+
+	return &knowledgeBaseModel{
+		Id:             kb.Id,
+		UserId:         kb.UserId,
+		Name:           kb.Name,
+		EmbeddingModel: kb.EmbeddingModel,
+		CreatedAt:      kb.CreatedAt,
+		UpdatedAt:      kb.UpdatedAt,
+		DeletedAt:      deletedAt,
+	}
+}
+
+func knowledgeDocumentModelToDomain(m *knowledgeDocumentModel) *domain.KnowledgeDocument {
+	if m == nil {
+		return nil
+	}
+
+	return &domain.KnowledgeDocument{
+		Id:              m.Id,
+		KnowledgeBaseId: m.KnowledgeBaseId,
+		FileId:          stringFromNullable(m.FileId),
+		Name:            m.Name,
+		Chunks:          m.Chunks,
+		CreatedAt:       m.CreatedAt,
+	}
+}
+
+func knowledgeDocumentDomainToModel(doc *domain.KnowledgeDocument) *knowledgeDocumentModel {
+	if doc == nil {
+		return nil
+	}
+
+	return &knowledgeDocumentModel{
+		Id:              doc.Id,
+		KnowledgeBaseId: doc.KnowledgeBaseId,
+		FileId:          nullableString(doc.FileId),
+		Name:            doc.Name,
+		Chunks:          doc.Chunks,
+		CreatedAt:       doc.CreatedAt,
+	}
+}
+
+func knowledgeChunkModelToDomain(m *knowledgeChunkModel) *domain.KnowledgeChunk {
+	if m == nil {
+		return nil
+	}
+
+	return &domain.KnowledgeChunk{
+		Id:              m.Id,
+		KnowledgeBaseId: m.KnowledgeBaseId,
+		DocumentId:      m.DocumentId,
+		DocumentName:    m.DocumentName,
+		Position:        m.Position,
+		Start:           m.StartOffset,
+		End:             m.EndOffset,
+		Content:         m.Content,
+		Embedding:       decodeEmbedding(m.Embedding),
+	}
+}
+
+func knowledgeChunkDomainToModel(c *domain.KnowledgeChunk) *knowledgeChunkModel {
+	if c == nil {
+		return nil
+	}
+
+	return &knowledgeChunkModel{
+		Id:              c.Id,
+		KnowledgeBaseId: c.KnowledgeBaseId,
+		DocumentId:      c.DocumentId,
+		Position:        c.Position,
+		StartOffset:     c.Start,
+		EndOffset:       c.End,
+		Content:         c.Content,
+		Embedding:       encodeEmbedding(c.Embedding),
+	}
+}
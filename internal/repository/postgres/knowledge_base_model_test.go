@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+func Test_embeddingEncoding(t *testing.T) {
+	in := []float32{0, 1.5, -2.25, 3e-7}
+	data := encodeEmbedding(in)
+	if len(data) != 4*len(in) {
+		t.Fatalf("длина = %d, ожидалось %d", len(data), 4*len(in))
+	}
+
+	out := decodeEmbedding(data)
+	if len(out) != len(in) {
+		t.Fatalf("decodeEmbedding: %v", out)
+	}
+
+	for i := range in {
+		if out[i] != in[i] {
+			t.Errorf("элемент %d = %v, ожидалось %v", i, out[i], in[i])
+		}
+	}
+}
+
+func Test_knowledgeBaseModel(t *testing.T) {
+	if knowledgeBaseModelToDomain(nil) != nil || knowledgeBaseDomainToModel(nil) != nil {
+		t.Fatal("nil должен преобразовываться в nil")
+	}
+
+	now := time.Now()
+	got := knowledgeBaseModelToDomain(&knowledgeBaseModel{
+		Id:             "kb",
+		UserId:         1,
+		Name:           "Договоры",
+		EmbeddingModel: "nomic",
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		DeletedAt:      gorm.DeletedAt{Time: now, Valid: true},
+	})
+	if got.Name != "Договоры" || got.EmbeddingModel != "nomic" || got.DeletedAt == nil {
+		t.Errorf("knowledgeBaseModelToDomain: %+v", got)
+	}
+
+	if m := knowledgeBaseDomainToModel(&domain.KnowledgeBase{Id: "kb", Name: "n"}); m.DeletedAt.Valid {
+		t.Errorf("knowledgeBaseDomainToModel: %+v", m)
+	}
+}
+
+func Test_knowledgeChunkModel(t *testing.T) {
+	chunk := &domain.KnowledgeChunk{
+		Id:              "c",
+		KnowledgeBaseId: "kb",
+		DocumentId:      "d",
+		Position:        2,
+		Start:           100,
+		End:             200,
+		Content:         "текст",
+		Embedding:       []float32{0.5, -0.5},
+	}
+	m := knowledgeChunkDomainToModel(chunk)
+	m.DocumentName = "договор.pdf"
+
+	got := knowledgeChunkModelToDomain(m)
+	if got.Start != 100 || got.End != 200 || got.Position != 2 || got.DocumentName != "договор.pdf" || len(got.Embedding) != 2 || got.Embedding[1] != -0.5 {
+		t.Errorf("knowledgeChunkModelToDomain: %+v", got)
+	}
+}
+
+func Test_knowledgeDocumentModel(t *testing.T) {
+	m := knowledgeDocumentDomainToModel(&domain.KnowledgeDocument{Id: "d", KnowledgeBaseId: "kb", Name: "a.txt", Chunks: 3})
+	if m.FileId != nil {
+		t.Errorf("документ без файла: file_id = %v", *m.FileId)
+	}
+
+	m.FileId = nullableString("f")
+	if got := knowledgeDocumentModelToDomain(m); got.FileId != "f" || got.Chunks != 3 {
+		t.Errorf("knowledgeDocumentModelToDomain: %+v", got)
+	}
+}
@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+const knowledgeChunkBatchSize = 100
+
+type knowledgeBaseRepository struct {
+	db *gorm.DB
+}
+
+func NewKnowledgeBaseRepository(db *gorm.DB) domain.KnowledgeBaseRepository {
+	return &knowledgeBaseRepository{db: db}
+}
+
+func (r *knowledgeBaseRepository) Create(ctx context.Context, kb *domain.KnowledgeBase) error {
+	return r.db.WithContext(ctx).Create(knowledgeBaseDomainToModel(kb)).Error
+}
+
+func (r *knowledgeBaseRepository) GetById(ctx context.Context, id string) (*domain.KnowledgeBase, error) {
+	var m knowledgeBaseModel
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrKnowledgeBaseNotFound
+		}
+		return nil, err
+	}
+
+	return knowledgeBaseModelToDomain(&m), nil
+}
+
+func (r *knowledgeBaseRepository) ListByUserId(ctx context.Context, userId int) ([]*domain.KnowledgeBase, error) {
+	var list []knowledgeBaseModel
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]*domain.KnowledgeBase, 0, len(list))
+	for i := range list {
+		out = append(out, knowledgeBaseModelToDomain(&list[i]))
+	}
+
+	return out, nil
+}
+
+func (r *knowledgeBaseRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Where("id = ?", id).Delete(&knowledgeBaseModel{}).Error
+}
+
+func (r *knowledgeBaseRepository) AddDocument(ctx context.Context, doc *domain.KnowledgeDocument, chunks []*domain.KnowledgeChunk) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(knowledgeDocumentDomainToModel(doc)).Error; err != nil {
+			return err
+		}
+
+		if len(chunks) == 0 {
+			return nil
+		}
+
+		models := make([]*knowledgeChunkModel, len(chunks))
+		for i, c := range chunks {
+			models[i] = knowledgeChunkDomainToModel(c)
+		}
+
+		return tx.Omit("document_name").CreateInBatches(models, knowledgeChunkBatchSize).Error
+	})
+}
+
+func (r *knowledgeBaseRepository) ListDocuments(ctx context.Context, knowledgeBaseId string) ([]*domain.KnowledgeDocument, error) {
+	var list []knowledgeDocumentModel
+	if err := r.db.WithContext(ctx).Where("knowledge_base_id = ?", knowledgeBaseId).Order("created_at ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]*domain.KnowledgeDocument, 0, len(list))
+	for i := range list {
+		out = append(out, knowledgeDocumentModelToDomain(&list[i]))
+	}
+
+	return out, nil
+}
+
+func (r *knowledgeBaseRepository) GetChunks(ctx context.Context, knowledgeBaseId string) ([]*domain.KnowledgeChunk, error) {
+	var list []knowledgeChunkModel
+	if err := r.db.WithContext(ctx).
+		Table("knowledge_chunks AS c").
+		Select("c.*, d.name AS document_name").
+		Joins("JOIN knowledge_documents AS d ON d.id = c.document_id").
+		Where("c.knowledge_base_id = ?", knowledgeBaseId).
+		Order("c.document_id, c.position").
+		Scan(&list).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]*domain.KnowledgeChunk, 0, len(list))
+	for i := range list {
+		out = append(out, knowledgeChunkModelToDomain(&list[i]))
+	}
+
+	return out, nil
+}
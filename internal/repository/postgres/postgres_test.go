@@ -77,3 +77,30 @@ func TestNewFileRepository_returnsImplementation(t *testing.T) {
 
 	var _ domain.FileRepository = repo
 }
+
+func TestNewTokenUsageRepository_returnsImplementation(t *testing.T) {
+	repo := NewTokenUsageRepository(nil)
+	if repo == nil {
+		t.Fatal("NewTokenUsageRepository не должен возвращать nil")
+	}
+
+	var _ domain.TokenUsageRepository = repo
+}
+
+func TestNewAIPersonaRepository_returnsImplementation(t *testing.T) {
+	repo := NewAIPersonaRepository(nil)
+	if repo == nil {
+		t.Fatal("NewAIPersonaRepository не должен возвращать nil")
+	}
+
+	var _ domain.AIPersonaRepository = repo
+}
+
+func TestNewKnowledgeBaseRepository_returnsImplementation(t *testing.T) {
+	repo := NewKnowledgeBaseRepository(nil)
+	if repo == nil {
+		t.Fatal("NewKnowledgeBaseRepository не должен возвращать nil")
+	}
+
+	var _ domain.KnowledgeBaseRepository = repo
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type runnerCredentialModel struct {
+	Id         string     `gorm:"column:id;primaryKey;type:uuid"`
+	Name       string     `gorm:"column:name;size:255;not null"`
+	Address    string     `gorm:"column:address;size:255;not null;default:''"`
+	TokenHash  string     `gorm:"column:token_hash;size:64;not null;uniqueIndex"`
+	CreatedBy  int        `gorm:"column:created_by;not null"`
+	CreatedAt  time.Time  `gorm:"column:created_at;not null"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at"`
+	RevokedAt  *time.Time `gorm:"column:revoked_at"`
+}
+
+func (runnerCredentialModel) TableName() string {
+	return "runner_credentials"
+}
+
+func runnerCredentialModelToDomain(m *runnerCredentialModel) *domain.RunnerCredential {
+	if m == nil {
+		return nil
+	}
+
+	return &domain.RunnerCredential{
+		Id:         m.Id,
+		Name:       m.Name,
+		Address:    m.Address,
+		TokenHash:  m.TokenHash,
+		CreatedBy:  m.CreatedBy,
+		CreatedAt:  m.CreatedAt,
+		LastUsedAt: m.LastUsedAt,
+		RevokedAt:  m.RevokedAt,
+	}
+}
+
+func runnerCredentialDomainToModel(c *domain.RunnerCredential) *runnerCredentialModel {
+	if c == nil {
+		return nil
+	}
+
+	return &runnerCredentialModel{
+		Id:         c.Id,
+		Name:       c.Name,
+		Address:    c.Address,
+		TokenHash:  c.TokenHash,
+		CreatedBy:  c.CreatedBy,
+		CreatedAt:  c.CreatedAt,
+		LastUsedAt: c.LastUsedAt,
+		RevokedAt:  c.RevokedAt,
+	}
+}
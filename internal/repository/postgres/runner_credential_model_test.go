@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func Test_runnerCredentialModelToDomain(t *testing.T) {
+	now := time.Now()
+
+	t.Run("nil возвращает nil", func(t *testing.T) {
+		if got := runnerCredentialModelToDomain(nil); got != nil {
+			t.Errorf("runnerCredentialModelToDomain(nil) = %v, ожидалось nil", got)
+		}
+	})
+
+	t.Run("отозванные учётные данные", func(t *testing.T) {
+		m := &runnerCredentialModel{
+			Id:        "c1",
+			Name:      "gpu-1",
+			Address:   "10.0.0.1:50052",
+			TokenHash: "hash",
+			CreatedBy: 1,
+			CreatedAt: now,
+			RevokedAt: &now,
+		}
+		got := runnerCredentialModelToDomain(m)
+		if got == nil || got.Id != "c1" || got.Address != "10.0.0.1:50052" || !got.IsRevoked() {
+			t.Errorf("runnerCredentialModelToDomain: %+v", got)
+		}
+	})
+}
+
+func Test_runnerCredentialDomainToModel(t *testing.T) {
+	if got := runnerCredentialDomainToModel(nil); got != nil {
+		t.Errorf("runnerCredentialDomainToModel(nil) = %v, ожидалось nil", got)
+	}
+
+	c := domain.NewRunnerCredential("gpu-1", "", "hash", 1)
+	m := runnerCredentialDomainToModel(c)
+	if m == nil || m.Id != c.Id || m.TokenHash != "hash" || m.RevokedAt != nil {
+		t.Errorf("runnerCredentialDomainToModel: %+v", m)
+	}
+}
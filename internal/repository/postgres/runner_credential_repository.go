@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+type runnerCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewRunnerCredentialRepository(db *gorm.DB) domain.RunnerCredentialRepository {
+	return &runnerCredentialRepository{db: db}
+}
+
+func (r *runnerCredentialRepository) Create(ctx context.Context, credential *domain.RunnerCredential) error {
+	m := runnerCredentialDomainToModel(credential)
+	return r.db.WithContext(ctx).Create(m).Error
+}
+
+func (r *runnerCredentialRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*domain.RunnerCredential, error) {
+	var m runnerCredentialModel
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrRunnerCredentialNotFound
+		}
+		return nil, err
+	}
+
+	return runnerCredentialModelToDomain(&m), nil
+}
+
+func (r *runnerCredentialRepository) List(ctx context.Context) ([]*domain.RunnerCredential, error) {
+	var list []runnerCredentialModel
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]*domain.RunnerCredential, 0, len(list))
+	for i := range list {
+		out = append(out, runnerCredentialModelToDomain(&list[i]))
+	}
+
+	return out, nil
+}
+
+func (r *runnerCredentialRepository) BindAddress(ctx context.Context, id string, address string) error {
+	res := r.db.WithContext(ctx).Model(&runnerCredentialModel{}).
+		Where("id = ? AND address = ''", id).
+		Update("address", address)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return domain.ErrRunnerAddressMismatch
+	}
+
+	return nil
+}
+
+func (r *runnerCredentialRepository) TouchLastUsed(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Model(&runnerCredentialModel{}).
+		Where("id = ?", id).
+		Update("last_used_at", time.Now()).Error
+}
+
+func (r *runnerCredentialRepository) Revoke(ctx context.Context, id string) error {
+	res := r.db.WithContext(ctx).Model(&runnerCredentialModel{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return domain.ErrRunnerCredentialNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+type runnerModel struct {
+	Address    string     `gorm:"column:address;primaryKey;size:255"`
+	Enabled    bool       `gorm:"column:enabled;not null"`
+	Labels     string     `gorm:"column:labels;type:jsonb;not null"`
+	LastSeenAt *time.Time `gorm:"column:last_seen_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at;not null"`
+	UpdatedAt  time.Time  `gorm:"column:updated_at;not null"`
+}
+
+func (runnerModel) TableName() string {
+	return "runners"
+}
+
+func runnerModelToDomain(m *runnerModel) *domain.Runner {
+	if m == nil {
+		return nil
+	}
+
+	labels := make(map[string]string)
+	if m.Labels != "" {
+		if err := json.Unmarshal([]byte(m.Labels), &labels); err != nil {
+			logger.W("runnerModel: некорректные метки раннера %s: %v", m.Address, err)
+		}
+	}
+
+	return &domain.Runner{
+		Address:    m.Address,
+		Enabled:    m.Enabled,
+		Labels:     labels,
+		LastSeenAt: m.LastSeenAt,
+		UpdatedAt:  m.UpdatedAt,
+	}
+}
+
+func runnerDomainToModel(r *domain.Runner) *runnerModel {
+	if r == nil {
+		return nil
+	}
+
+	labels := "{}"
+	if len(r.Labels) > 0 {
+		if data, err := json.Marshal(r.Labels); err == nil {
+			labels = string(data)
+		}
+	}
+
+	return &runnerModel{
+		Address:    r.Address,
+		Enabled:    r.Enabled,
+		Labels:     labels,
+		LastSeenAt: r.LastSeenAt,
+		UpdatedAt:  r.UpdatedAt,
+	}
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func Test_runnerModelToDomain(t *testing.T) {
+	if got := runnerModelToDomain(nil); got != nil {
+		t.Errorf("runnerModelToDomain(nil) = %v, ожидалось nil", got)
+	}
+
+	now := time.Now()
+	got := runnerModelToDomain(&runnerModel{
+		Address:    "10.0.0.1:50052",
+		Enabled:    false,
+		Labels:     `{"gpu":"a100","zone":"eu"}`,
+		LastSeenAt: &now,
+	})
+	if got == nil || got.Address != "10.0.0.1:50052" || got.Enabled {
+		t.Fatalf("runnerModelToDomain: неверные поля %+v", got)
+	}
+
+	if got.Labels["gpu"] != "a100" || got.Labels["zone"] != "eu" || got.LastSeenAt == nil {
+		t.Errorf("runnerModelToDomain: метки или last_seen не восстановлены %+v", got)
+	}
+
+	broken := runnerModelToDomain(&runnerModel{Address: "a:1", Labels: "not json"})
+	if broken == nil || broken.Labels == nil || len(broken.Labels) != 0 {
+		t.Errorf("некорректные метки должны давать пустую карту, получено %+v", broken)
+	}
+}
+
+func Test_runnerDomainToModel(t *testing.T) {
+	if got := runnerDomainToModel(nil); got != nil {
+		t.Errorf("runnerDomainToModel(nil) = %v, ожидалось nil", got)
+	}
+
+	if got := runnerDomainToModel(&domain.Runner{Address: "a:1", Enabled: true}); got.Labels != "{}" {
+		t.Errorf("пустые метки: %q, ожидалось {}", got.Labels)
+	}
+
+	got := runnerDomainToModel(&domain.Runner{
+		Address: "a:1",
+		Enabled: true,
+		Labels:  map[string]string{"gpu": "a100"},
+	})
+	back := runnerModelToDomain(got)
+	if !back.Enabled || back.Labels["gpu"] != "a100" {
+		t.Errorf("круговое преобразование: %+v", back)
+	}
+}
@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type runnerRepository struct {
+	db *gorm.DB
+}
+
+func NewRunnerRepository(db *gorm.DB) domain.RunnerRepository {
+	return &runnerRepository{db: db}
+}
+
+func (r *runnerRepository) List(ctx context.Context) ([]*domain.Runner, error) {
+	var list []runnerModel
+	if err := r.db.WithContext(ctx).Order("address").Find(&list).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]*domain.Runner, 0, len(list))
+	for i := range list {
+		out = append(out, runnerModelToDomain(&list[i]))
+	}
+
+	return out, nil
+}
+
+func (r *runnerRepository) Get(ctx context.Context, address string) (*domain.Runner, error) {
+	var m runnerModel
+	if err := r.db.WithContext(ctx).Where("address = ?", address).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, domain.ErrRunnerNotFound
+		}
+		return nil, err
+	}
+
+	return runnerModelToDomain(&m), nil
+}
+
+func (r *runnerRepository) Save(ctx context.Context, runner *domain.Runner) error {
+	m := runnerDomainToModel(runner)
+	m.CreatedAt = time.Now()
+	if m.UpdatedAt.IsZero() {
+		m.UpdatedAt = m.CreatedAt
+	}
+
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "labels", "last_seen_at", "updated_at"}),
+	}).Create(m).Error
+}
+
+func (r *runnerRepository) Delete(ctx context.Context, address string) error {
+	return r.db.WithContext(ctx).Where("address = ?", address).Delete(&runnerModel{}).Error
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type tokenUsageModel struct {
+	Id               int64     `gorm:"column:id;primaryKey;autoIncrement"`
+	UserId           int       `gorm:"column:user_id;not null"`
+	Model            string    `gorm:"column:model;size:255;not null"`
+	Source           string    `gorm:"column:source;size:32;not null"`
+	PromptTokens     int32     `gorm:"column:prompt_tokens;not null"`
+	CompletionTokens int32     `gorm:"column:completion_tokens;not null"`
+	CreatedAt        time.Time `gorm:"column:created_at;not null"`
+}
+
+func (tokenUsageModel) TableName() string {
+	return "ai_token_usage"
+}
+
+func tokenUsageRecordToModel(r *domain.TokenUsageRecord) *tokenUsageModel {
+	if r == nil {
+		return nil
+	}
+
+	createdAt := r.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+
+	return &tokenUsageModel{
+		UserId:           r.UserId,
+		Model:            r.Model,
+		Source:           string(r.Source),
+		PromptTokens:     r.Usage.PromptTokens,
+		CompletionTokens: r.Usage.CompletionTokens,
+		CreatedAt:        createdAt,
+	}
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"gorm.io/gorm"
+)
+
+type tokenUsageRow struct {
+	UserId           int       `gorm:"column:user_id"`
+	Model            string    `gorm:"column:model"`
+	Day              time.Time `gorm:"column:day"`
+	PromptTokens     int64     `gorm:"column:prompt_tokens"`
+	CompletionTokens int64     `gorm:"column:completion_tokens"`
+	Messages         int64     `gorm:"column:messages"`
+}
+
+type tokenUsageRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenUsageRepository(db *gorm.DB) domain.TokenUsageRepository {
+	return &tokenUsageRepository{db: db}
+}
+
+const tokenUsageSource = `(
+	SELECT s.user_id, m.model, m.created_at, m.prompt_tokens, m.completion_tokens
+	FROM chat_session_messages AS m
+	JOIN chat_sessions AS s ON s.id = m.session_id
+	WHERE m.role = ?
+	UNION ALL
+	SELECT user_id, model, created_at, prompt_tokens, completion_tokens
+	FROM ai_token_usage
+) AS u`
+
+func usageGroupColumn(groupBy domain.UsageGroupBy) (string, string, error) {
+	switch groupBy {
+	case domain.UsageGroupByUser:
+		return "u.user_id AS user_id", "u.user_id", nil
+	case domain.UsageGroupByModel:
+		return "COALESCE(u.model, '') AS model", "COALESCE(u.model, '')", nil
+	case domain.UsageGroupByDay:
+		return "date_trunc('day', u.created_at) AS day", "date_trunc('day', u.created_at)", nil
+	default:
+		return "", "", fmt.Errorf("%w: неизвестная группировка %q", domain.ErrInvalidUsageFilter, groupBy)
+	}
+}
+
+func (r *tokenUsageRepository) Aggregate(ctx context.Context, filter domain.UsageFilter) ([]*domain.UsageStat, error) {
+	column, group, err := usageGroupColumn(filter.GroupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	q := r.db.WithContext(ctx).
+		Table(tokenUsageSource, string(domain.AIChatMessageRoleAssistant)).
+		Select(column + ", SUM(u.prompt_tokens) AS prompt_tokens, SUM(u.completion_tokens) AS completion_tokens, COUNT(*) AS messages")
+
+	if !filter.From.IsZero() {
+		q = q.Where("u.created_at >= ?", filter.From)
+	}
+
+	if !filter.To.IsZero() {
+		q = q.Where("u.created_at < ?", filter.To)
+	}
+
+	if filter.UserId != 0 {
+		q = q.Where("u.user_id = ?", filter.UserId)
+	}
+
+	if filter.Model != "" {
+		q = q.Where("u.model = ?", filter.Model)
+	}
+
+	var rows []tokenUsageRow
+	if err := q.Group(group).Order(group).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	out := make([]*domain.UsageStat, 0, len(rows))
+	for i := range rows {
+		out = append(out, &domain.UsageStat{
+			UserId:           rows[i].UserId,
+			Model:            rows[i].Model,
+			Day:              rows[i].Day,
+			PromptTokens:     rows[i].PromptTokens,
+			CompletionTokens: rows[i].CompletionTokens,
+			Messages:         rows[i].Messages,
+		})
+	}
+
+	return out, nil
+}
+
+func (r *tokenUsageRepository) Record(ctx context.Context, record *domain.TokenUsageRecord) error {
+	return r.db.WithContext(ctx).Create(tokenUsageRecordToModel(record)).Error
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func Test_usageGroupColumn(t *testing.T) {
+	for _, groupBy := range []domain.UsageGroupBy{domain.UsageGroupByUser, domain.UsageGroupByModel, domain.UsageGroupByDay} {
+		column, group, err := usageGroupColumn(groupBy)
+		if err != nil || column == "" || group == "" {
+			t.Errorf("usageGroupColumn(%q) = %q, %q, %v", groupBy, column, group, err)
+		}
+	}
+
+	if _, _, err := usageGroupColumn("week"); !errors.Is(err, domain.ErrInvalidUsageFilter) {
+		t.Errorf("ожидалась ErrInvalidUsageFilter, получено %v", err)
+	}
+}
+
+func Test_tokenUsageRecordToModel(t *testing.T) {
+	m := tokenUsageRecordToModel(&domain.TokenUsageRecord{
+		UserId: 3,
+		Model:  "llama3",
+		Source: domain.TokenUsageSourceOpenAIAPI,
+		Usage:  domain.TokenUsage{PromptTokens: 12, CompletionTokens: 5},
+	})
+
+	if m.UserId != 3 || m.Model != "llama3" || m.Source != "openai_api" || m.PromptTokens != 12 || m.CompletionTokens != 5 {
+		t.Errorf("неверная модель расхода: %+v", m)
+	}
+
+	if m.CreatedAt.IsZero() {
+		t.Error("время записи должно заполняться")
+	}
+
+	if tokenUsageRecordToModel(nil) != nil {
+		t.Error("nil должен оставаться nil")
+	}
+}
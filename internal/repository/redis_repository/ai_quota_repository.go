@@ -0,0 +1,110 @@
+package redis_repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	aiQuotaTokensTTL  = 48 * time.Hour
+	aiQuotaStreamsTTL = time.Hour
+)
+
+var acquireStreamScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[1])
+	return 0
+end
+redis.call("EXPIRE", KEYS[1], ARGV[2])
+return 1
+`)
+
+type AIQuotaRepository struct {
+	Rds *redis.Client
+}
+
+func NewAIQuotaRepository(rds *redis.Client) domain.AIQuotaRepository {
+	return &AIQuotaRepository{
+		Rds: rds,
+	}
+}
+
+func (r *AIQuotaRepository) IncrementRequests(ctx context.Context, userId int, window time.Duration) (int64, time.Duration, error) {
+	now := time.Now()
+	start := now.Truncate(window)
+	key := r.requestsKey(userId, start)
+
+	var incr *redis.IntCmd
+	_, err := r.Rds.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return incr.Val(), start.Add(window).Sub(now), nil
+}
+
+func (r *AIQuotaRepository) GetDailyTokens(ctx context.Context, userId int, day time.Time) (int64, error) {
+	n, err := r.Rds.Get(ctx, r.tokensKey(userId, day)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return n, err
+}
+
+func (r *AIQuotaRepository) AddDailyTokens(ctx context.Context, userId int, day time.Time, tokens int64) error {
+	key := r.tokensKey(userId, day)
+	_, err := r.Rds.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.IncrBy(ctx, key, tokens)
+		pipe.Expire(ctx, key, aiQuotaTokensTTL)
+		return nil
+	})
+
+	return err
+}
+
+func (r *AIQuotaRepository) AcquireStream(ctx context.Context, userId int, limit int) (bool, error) {
+	ttl := int64(aiQuotaStreamsTTL / time.Second)
+	acquired, err := acquireStreamScript.Run(ctx, r.Rds, []string{r.streamsKey(userId)}, limit, ttl).Int64()
+	if err != nil {
+		return false, err
+	}
+
+	return acquired == 1, nil
+}
+
+func (r *AIQuotaRepository) ReleaseStream(ctx context.Context, userId int) error {
+	key := r.streamsKey(userId)
+	n, err := r.Rds.Decr(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	if n <= 0 {
+		return r.Rds.Del(ctx, key).Err()
+	}
+
+	return nil
+}
+
+func (r *AIQuotaRepository) requestsKey(userId int, start time.Time) string {
+	return fmt.Sprintf("ai_quota:requests:%d:%d", userId, start.Unix())
+}
+
+func (r *AIQuotaRepository) tokensKey(userId int, day time.Time) string {
+	return fmt.Sprintf("ai_quota:tokens:%d:%s", userId, day.UTC().Format(time.DateOnly))
+}
+
+func (r *AIQuotaRepository) streamsKey(userId int) string {
+	return fmt.Sprintf("ai_quota:streams:%d", userId)
+}
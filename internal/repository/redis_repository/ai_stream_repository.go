@@ -0,0 +1,106 @@
+package redis_repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+const aiStreamTTL = 10 * time.Minute
+
+type AIStreamRepository struct {
+	Rds *redis.Client
+}
+
+func NewAIStreamRepository(rds *redis.Client) domain.AIStreamRepository {
+	return &AIStreamRepository{
+		Rds: rds,
+	}
+}
+
+func (r *AIStreamRepository) Start(ctx context.Context, stream *domain.AIStream) error {
+	key := r.metaKey(stream.MessageId)
+	_, err := r.Rds.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key,
+			"session_id", stream.SessionId,
+			"user_id", stream.UserId,
+			"finish_reason", string(stream.FinishReason),
+		)
+		pipe.Expire(ctx, key, aiStreamTTL)
+		return nil
+	})
+
+	return err
+}
+
+func (r *AIStreamRepository) Append(ctx context.Context, messageId string, chunks []string) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+
+	values := make([]any, len(chunks))
+	for i, chunk := range chunks {
+		values[i] = chunk
+	}
+
+	key := r.chunksKey(messageId)
+	_, err := r.Rds.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, key, values...)
+		pipe.Expire(ctx, key, aiStreamTTL)
+		pipe.Expire(ctx, r.metaKey(messageId), aiStreamTTL)
+		return nil
+	})
+
+	return err
+}
+
+func (r *AIStreamRepository) Finish(ctx context.Context, messageId string, reason domain.AIFinishReason) error {
+	key := r.metaKey(messageId)
+	_, err := r.Rds.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "finish_reason", string(reason))
+		pipe.Expire(ctx, key, aiStreamTTL)
+		pipe.Expire(ctx, r.chunksKey(messageId), aiStreamTTL)
+		return nil
+	})
+
+	return err
+}
+
+func (r *AIStreamRepository) Get(ctx context.Context, messageId string) (*domain.AIStream, error) {
+	fields, err := r.Rds.HGetAll(ctx, r.metaKey(messageId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 0 {
+		return nil, domain.ErrGenerationNotFound
+	}
+
+	userId, err := strconv.Atoi(fields["user_id"])
+	if err != nil {
+		return nil, fmt.Errorf("некорректный поток генерации %s: %w", messageId, err)
+	}
+
+	return &domain.AIStream{
+		MessageId:    messageId,
+		SessionId:    fields["session_id"],
+		UserId:       userId,
+		FinishReason: domain.AIFinishReason(fields["finish_reason"]),
+	}, nil
+}
+
+func (r *AIStreamRepository) Chunks(ctx context.Context, messageId string, chunkOffset int64) ([]string, error) {
+	return r.Rds.LRange(ctx, r.chunksKey(messageId), chunkOffset, -1).Result()
+}
+
+func (r *AIStreamRepository) metaKey(messageId string) string {
+	return fmt.Sprintf("ai_stream:%s", messageId)
+}
+
+func (r *AIStreamRepository) chunksKey(messageId string) string {
+	return fmt.Sprintf("ai_stream:%s:chunks", messageId)
+}
@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+func paginateMessages(messages []*domain.AIChatMessage, page, pageSize int32) []*domain.AIChatMessage {
+	if pageSize <= 0 {
+		return messages
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+
+	from := int(page-1) * int(pageSize)
+	if from >= len(messages) {
+		return []*domain.AIChatMessage{}
+	}
+
+	to := from + int(pageSize)
+	if to > len(messages) {
+		to = len(messages)
+	}
+
+	return messages[from:to]
+}
+
+func (ai *AIChatUseCase) annotateSiblings(ctx context.Context, sessionId string, messages []*domain.AIChatMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(messages))
+	parentIds := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		if !seen[msg.ParentId] {
+			seen[msg.ParentId] = true
+			parentIds = append(parentIds, msg.ParentId)
+		}
+	}
+
+	children, err := ai.aiChatMessageRepo.GetChildIds(ctx, sessionId, parentIds)
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		siblings := children[msg.ParentId]
+		msg.SiblingCount = int32(len(siblings))
+		for i, id := range siblings {
+			if id == msg.Id {
+				msg.SiblingIndex = int32(i)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+func (ai *AIChatUseCase) getSessionMessage(ctx context.Context, sessionId string, messageId string) (*domain.AIChatMessage, error) {
+	msg, err := ai.aiChatMessageRepo.GetById(ctx, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.SessionId != sessionId {
+		return nil, domain.ErrAIMessageNotFound
+	}
+
+	return msg, nil
+}
+
+func (ai *AIChatUseCase) RegenerateMessage(ctx context.Context, userId int, sessionId string, model string, opts *domain.GenerationOptions) (chan string, *domain.AIReply, error) {
+	logger.D("ChatUseCase: повторная генерация ответа в сессии %s", sessionId)
+	session, err := ai.verifySessionOwnership(ctx, userId, sessionId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	generationOptions := session.GenerationOptions.Merge(opts)
+	if err := generationOptions.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	branch, err := ai.aiChatMessageRepo.GetBranch(ctx, sessionId, session.ActiveMessageId, ai.historyLimit+2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	n := len(branch)
+	for n > 0 && (branch[n-1].Role == domain.AIChatMessageRoleAssistant || branch[n-1].Role == domain.AIChatMessageRoleTool) {
+		n--
+	}
+
+	if n == 0 || branch[n-1].Role != domain.AIChatMessageRoleUser {
+		return nil, nil, domain.ErrNothingToRegenerate
+	}
+	userMsg, history := branch[n-1], branch[:n-1]
+
+	release, err := ai.quotaUseCase.Acquire(ctx, userId)
+	if err != nil {
+		logger.W("ChatUseCase: запрос пользователя %d отклонён: %v", userId, err)
+		return nil, nil, err
+	}
+
+	if err := ai.aiChatRepo.UpdateActiveMessage(ctx, sessionId, userMsg.Id); err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	return ai.streamReply(ctx, userId, session, model, generationOptions, history, userMsg, release, "")
+}
+
+func (ai *AIChatUseCase) EditMessage(ctx context.Context, userId int, sessionId string, messageId string, content string, model string, opts *domain.GenerationOptions) (chan string, *domain.AIReply, error) {
+	logger.D("ChatUseCase: редактирование сообщения %s в сессии %s", messageId, sessionId)
+	session, err := ai.verifySessionOwnership(ctx, userId, sessionId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	generationOptions := session.GenerationOptions.Merge(opts)
+	if err := generationOptions.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	target, err := ai.getSessionMessage(ctx, sessionId, messageId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if target.Role != domain.AIChatMessageRoleUser {
+		return nil, nil, domain.ErrAIMessageNotEditable
+	}
+
+	history, err := ai.aiChatMessageRepo.GetBranch(ctx, sessionId, target.ParentId, ai.historyLimit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release, err := ai.quotaUseCase.Acquire(ctx, userId)
+	if err != nil {
+		logger.W("ChatUseCase: запрос пользователя %d отклонён: %v", userId, err)
+		return nil, nil, err
+	}
+
+	edited := domain.NewAIChatMessageWithAttachment(sessionId, content, domain.AIChatMessageRoleUser, target.AttachmentName)
+	edited.ParentId = target.ParentId
+	if err := ai.appendMessage(ctx, edited); err != nil {
+		release()
+		return nil, nil, err
+	}
+
+	var titleFrom string
+	if len(history) == 0 {
+		titleFrom = content
+	}
+
+	return ai.streamReply(ctx, userId, session, model, generationOptions, history, edited, release, titleFrom)
+}
+
+func (ai *AIChatUseCase) SelectBranch(ctx context.Context, userId int, sessionId string, messageId string) (*domain.AIChatSession, error) {
+	session, err := ai.verifySessionOwnership(ctx, userId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := ai.getSessionMessage(ctx, sessionId, messageId); err != nil {
+		return nil, err
+	}
+
+	leaf, err := ai.aiChatMessageRepo.GetLatestLeaf(ctx, sessionId, messageId)
+	if err != nil {
+		return nil, fmt.Errorf("поиск конца ветки: %w", err)
+	}
+
+	if err := ai.aiChatRepo.UpdateActiveMessage(ctx, sessionId, leaf.Id); err != nil {
+		return nil, err
+	}
+	session.ActiveMessageId = leaf.Id
+
+	return session, nil
+}
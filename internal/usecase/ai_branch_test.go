@@ -0,0 +1,173 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func waitActiveMessage(t *testing.T, repo *mockAIChatRepo, sessionId string, messageId string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stored, _ := repo.GetById(context.Background(), sessionId); stored.ActiveMessageId == messageId {
+			return
+		}
+	}
+	t.Fatalf("ответ %s не стал активным", messageId)
+}
+
+func drainReply(t *testing.T, repo *mockAIChatRepo, sessionId string, ch chan string, reply *domain.AIReply, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("генерация: %v", err)
+	}
+	drain(ch)
+	waitActiveMessage(t, repo, sessionId, reply.MessageId)
+}
+
+func TestAIChatUseCase_RegenerateMessage(t *testing.T) {
+	uc, repo := newAIChatUseCaseForTest(&mockReplyLLMProvider{reply: "ответ"})
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if _, _, err := uc.RegenerateMessage(ctx, 1, session.Id, "", nil); !errors.Is(err, domain.ErrNothingToRegenerate) {
+		t.Fatalf("пустая сессия: ожидалась ErrNothingToRegenerate, получено %v", err)
+	}
+
+	ch, first, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	drainReply(t, repo, session.Id, ch, first, err)
+
+	ch, second, err := uc.RegenerateMessage(ctx, 1, session.Id, "", nil)
+	drainReply(t, repo, session.Id, ch, second, err)
+
+	messages, total, err := uc.GetSessionMessages(ctx, 1, session.Id, 1, 50)
+	if err != nil {
+		t.Fatalf("GetSessionMessages: %v", err)
+	}
+
+	if total != 2 || len(messages) != 2 || messages[1].Id != second.MessageId {
+		t.Fatalf("ожидалась активная ветка из вопроса и нового ответа: total=%d %+v", total, messages)
+	}
+
+	if messages[1].SiblingCount != 2 || messages[1].SiblingIndex != 1 || messages[0].SiblingCount != 1 {
+		t.Errorf("неверные счётчики ветвей: ответ %d/%d, вопрос %d", messages[1].SiblingIndex, messages[1].SiblingCount, messages[0].SiblingCount)
+	}
+
+	if _, err := uc.SelectBranch(ctx, 1, session.Id, first.MessageId); err != nil {
+		t.Fatalf("SelectBranch: %v", err)
+	}
+
+	messages, _, _ = uc.GetSessionMessages(ctx, 1, session.Id, 1, 50)
+	if len(messages) != 2 || messages[1].Id != first.MessageId || messages[1].SiblingIndex != 0 {
+		t.Errorf("после переключения должна быть активна первая ветка: %+v", messages)
+	}
+}
+
+func TestAIChatUseCase_EditMessage(t *testing.T) {
+	uc, repo := newAIChatUseCaseForTest(&mockReplyLLMProvider{reply: "ответ"})
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "первый", "", nil, nil)
+	drainReply(t, repo, session.Id, ch, reply, err)
+
+	ch, reply, err = uc.SendMessage(ctx, 1, session.Id, "", "второй", "", nil, nil)
+	drainReply(t, repo, session.Id, ch, reply, err)
+
+	messages, _, _ := uc.GetSessionMessages(ctx, 1, session.Id, 1, 50)
+	if len(messages) != 4 {
+		t.Fatalf("ожидалось 4 сообщения, получено %d", len(messages))
+	}
+
+	if _, _, err := uc.EditMessage(ctx, 1, session.Id, messages[1].Id, "x", "", nil); !errors.Is(err, domain.ErrAIMessageNotEditable) {
+		t.Errorf("ответ ассистента: ожидалась ErrAIMessageNotEditable, получено %v", err)
+	}
+
+	if _, _, err := uc.EditMessage(ctx, 1, session.Id, "missing", "x", "", nil); !errors.Is(err, domain.ErrAIMessageNotFound) {
+		t.Errorf("несуществующее сообщение: ожидалась ErrAIMessageNotFound, получено %v", err)
+	}
+
+	ch, reply, err = uc.EditMessage(ctx, 1, session.Id, messages[2].Id, "второй, исправленный", "", nil)
+	drainReply(t, repo, session.Id, ch, reply, err)
+
+	edited, total, _ := uc.GetSessionMessages(ctx, 1, session.Id, 1, 50)
+	if total != 4 || edited[0].Id != messages[0].Id || edited[2].Content != "второй, исправленный" || edited[3].Id != reply.MessageId {
+		t.Fatalf("ветка после редактирования: %+v", edited)
+	}
+
+	if edited[2].SiblingCount != 2 || edited[2].SiblingIndex != 1 {
+		t.Errorf("отредактированное сообщение должно быть второй ветвью: %d/%d", edited[2].SiblingIndex, edited[2].SiblingCount)
+	}
+
+	if page, total, _ := uc.GetSessionMessages(ctx, 1, session.Id, 2, 3); total != 4 || len(page) != 1 || page[0].Id != reply.MessageId {
+		t.Errorf("пагинация активной ветки: total=%d %+v", total, page)
+	}
+}
+
+type mockBranchGateLLMProvider struct {
+	mockLLMProvider
+	gates map[string]chan struct{}
+}
+
+func (m *mockBranchGateLLMProvider) SendMessage(_ context.Context, _ string, _ string, messages []*domain.AIChatMessage, _ *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	content := messages[len(messages)-1].Content
+	ch := make(chan string, 1)
+	go func() {
+		defer close(ch)
+		if gate := m.gates[content]; gate != nil {
+			<-gate
+		}
+		ch <- "ответ на " + content
+	}()
+
+	return ch, nil, nil
+}
+
+func TestAIChatUseCase_EditMessage_lateReplyKeepsActiveBranch(t *testing.T) {
+	gate := make(chan struct{})
+	llm := &mockBranchGateLLMProvider{gates: map[string]chan struct{}{"вопрос": gate}}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil)
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	slow, late, err := uc.SendMessage(ctx, 1, session.Id, "", "вопрос", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	messages, _, _ := uc.GetSessionMessages(ctx, 1, session.Id, 1, 50)
+	if len(messages) != 1 {
+		t.Fatalf("ожидалось сообщение пользователя без ответа, получено %+v", messages)
+	}
+
+	ch, edited, err := uc.EditMessage(ctx, 1, session.Id, messages[0].Id, "исправленный вопрос", "", nil)
+	drainReply(t, repo, session.Id, ch, edited, err)
+
+	close(gate)
+	drain(slow)
+	waitGenerationFinished(t, uc, late.MessageId)
+
+	if _, err := uc.aiChatMessageRepo.GetById(ctx, late.MessageId); err != nil {
+		t.Fatalf("запоздавший ответ должен быть сохранён: %v", err)
+	}
+
+	if stored, _ := repo.GetById(ctx, session.Id); stored.ActiveMessageId != edited.MessageId {
+		t.Errorf("запоздавший ответ не должен переключать активную ветку: активно %s, ожидалось %s", stored.ActiveMessageId, edited.MessageId)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/magomedcoder/legion/internal/domain"
+	redisRepo "github.com/magomedcoder/legion/internal/repository/redis_repository"
 	"github.com/magomedcoder/legion/pkg/document"
 	"github.com/magomedcoder/legion/pkg/logger"
+	"github.com/redis/go-redis/v9"
 )
 
 type AIChatUseCase struct {
@@ -16,6 +19,21 @@ type AIChatUseCase struct {
 	fileRepo          domain.FileRepository
 	llmProvider       domain.LLMProvider
 	storageUseCase    *StorageUseCase
+	quotaUseCase      *QuotaUseCase
+	usageUseCase      *UsageUseCase
+	contextWindow     domain.ContextWindow
+	historyLimit      int
+	summarize         bool
+	summarizing       sync.Map
+	generations       sync.Map
+	streams           domain.AIStreamRepository
+	personas          domain.AIPersonaRepository
+	knowledge         *KnowledgeUseCase
+	tools             *AIToolRegistry
+	toolRounds        int
+	redis             *redis.Client
+	serverCache       *redisRepo.ServerCacheRepository
+	clientCache       *redisRepo.ClientCacheRepository
 }
 
 func NewAIChatUseCase(
@@ -24,14 +42,78 @@ func NewAIChatUseCase(
 	fileRepo domain.FileRepository,
 	llmProvider domain.LLMProvider,
 	storageUseCase *StorageUseCase,
+	quotaUseCase *QuotaUseCase,
+	opts ...AIChatUseCaseOption,
 ) *AIChatUseCase {
-	return &AIChatUseCase{
+	ai := &AIChatUseCase{
 		aiChatRepo:        aiChatRepo,
 		aiChatMessageRepo: aiChatMessageRepo,
 		fileRepo:          fileRepo,
 		llmProvider:       llmProvider,
 		storageUseCase:    storageUseCase,
+		quotaUseCase:      quotaUseCase,
+		historyLimit:      defaultAIHistoryLimit,
+		toolRounds:        domain.DefaultAIToolRounds,
 	}
+	for _, opt := range opts {
+		opt(ai)
+	}
+
+	return ai
+}
+
+type AIChatUseCaseOption func(*AIChatUseCase)
+
+func WithAIContextWindow(w domain.ContextWindow) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.contextWindow = w }
+}
+
+func WithAIHistoryLimit(limit int) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) {
+		if limit > 0 {
+			ai.historyLimit = limit
+		}
+	}
+}
+
+func WithAISummaries(enabled bool) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.summarize = enabled }
+}
+
+func WithAIStreams(repo domain.AIStreamRepository) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.streams = repo }
+}
+
+func WithAIPersonas(repo domain.AIPersonaRepository) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.personas = repo }
+}
+
+func WithAIKnowledge(k *KnowledgeUseCase) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.knowledge = k }
+}
+
+func WithAITools(registry *AIToolRegistry) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.tools = registry }
+}
+
+func WithAIToolRounds(rounds int) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.toolRounds = domain.ClampAIToolRounds(rounds) }
+}
+
+func WithAIUsage(u *UsageUseCase) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.usageUseCase = u }
+}
+
+func WithAIRedis(rds *redis.Client) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.redis = rds }
+}
+
+func WithAIServerCache(s *redisRepo.ServerCacheRepository) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.serverCache = s }
+}
+
+func WithAIClientCache(cl *redisRepo.ClientCacheRepository) AIChatUseCaseOption {
+	return func(ai *AIChatUseCase) { ai.clientCache = cl }
 }
 
 func (ai *AIChatUseCase) verifySessionOwnership(ctx context.Context, userId int, sessionID string) (*domain.AIChatSession, error) {
@@ -49,18 +131,47 @@ func (ai *AIChatUseCase) GetModels(ctx context.Context) ([]string, error) {
 	return ai.llmProvider.GetModels(ctx)
 }
 
-func (ai *AIChatUseCase) SendMessage(ctx context.Context, userId int, sessionId string, model string, userMessage string, attachmentName string, attachmentContent []byte) (chan string, string, error) {
+func (ai *AIChatUseCase) GetModelsInfo(ctx context.Context) ([]*domain.AIModel, error) {
+	return ai.llmProvider.GetModelsInfo(ctx)
+}
+
+func (ai *AIChatUseCase) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	if err := domain.ValidateEmbeddingInputs(inputs); err != nil {
+		return nil, err
+	}
+
+	embedder, ok := ai.llmProvider.(domain.EmbeddingProvider)
+	if !ok {
+		return nil, domain.ErrEmbeddingsNotSupported
+	}
+
+	return embedder.Embed(ctx, model, inputs)
+}
+
+func (ai *AIChatUseCase) SendMessage(ctx context.Context, userId int, sessionId string, model string, userMessage string, attachmentName string, attachmentContent []byte, opts *domain.GenerationOptions) (chan string, *domain.AIReply, error) {
 	logger.D("ChatUseCase: отправка сообщения в сессию %s", sessionId)
-	_, err := ai.verifySessionOwnership(ctx, userId, sessionId)
+	session, err := ai.verifySessionOwnership(ctx, userId, sessionId)
 	if err != nil {
 		logger.W("ChatUseCase: ошибка проверки сессии: %v", err)
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	messages, _, err := ai.aiChatMessageRepo.GetBySessionId(ctx, sessionId, 1, 100)
+	generationOptions := session.GenerationOptions.Merge(opts)
+	if err := generationOptions.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	release, err := ai.quotaUseCase.Acquire(ctx, userId)
+	if err != nil {
+		logger.W("ChatUseCase: запрос пользователя %d отклонён: %v", userId, err)
+		return nil, nil, err
+	}
+
+	history, err := ai.aiChatMessageRepo.GetBranch(ctx, sessionId, session.ActiveMessageId, ai.historyLimit)
 	if err != nil {
+		release()
 		logger.E("ChatUseCase: ошибка получения сообщений: %v", err)
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	var attachmentFileID string
@@ -76,55 +187,211 @@ func (ai *AIChatUseCase) SendMessage(ctx context.Context, userId int, sessionId
 	}
 
 	userMsg := domain.NewAIChatMessageWithAttachment(sessionId, userMessage, domain.AIChatMessageRoleUser, attachmentFileID)
-	if err := ai.aiChatMessageRepo.Create(ctx, userMsg); err != nil {
-		return nil, "", err
+	userMsg.ParentId = session.ActiveMessageId
+	if err := ai.appendMessage(ctx, userMsg); err != nil {
+		release()
+		return nil, nil, err
 	}
 
-	messagesForLLM := make([]*domain.AIChatMessage, 0, len(messages)+1)
-	messagesForLLM = append(messagesForLLM, messages...)
+	currentForLLM := userMsg
 	if len(attachmentContent) > 0 && attachmentName != "" {
 		fullContent := buildMessageWithFile(attachmentName, attachmentContent, userMessage)
 		userMsgForLLM := *userMsg
 		userMsgForLLM.Content = fullContent
-		messagesForLLM = append(messagesForLLM, &userMsgForLLM)
-	} else {
-		messagesForLLM = append(messagesForLLM, userMsg)
+		currentForLLM = &userMsgForLLM
+	}
+
+	var titleFrom string
+	if len(history) == 0 {
+		titleFrom = userMessage
+		if titleFrom == "" {
+			titleFrom = attachmentName
+		}
+	}
+
+	return ai.streamReply(ctx, userId, session, model, generationOptions, history, currentForLLM, release, titleFrom)
+}
+
+func (ai *AIChatUseCase) appendMessage(ctx context.Context, msg *domain.AIChatMessage) error {
+	if err := ai.aiChatMessageRepo.Create(ctx, msg); err != nil {
+		return err
+	}
+
+	return ai.aiChatRepo.UpdateActiveMessage(ctx, msg.SessionId, msg.Id)
+}
+
+func (ai *AIChatUseCase) appendReply(ctx context.Context, msg *domain.AIChatMessage) error {
+	if err := ai.aiChatMessageRepo.Create(ctx, msg); err != nil {
+		return err
+	}
+
+	return ai.aiChatRepo.AdvanceActiveMessage(ctx, msg.SessionId, msg.ParentId, msg.Id)
+}
+
+func (ai *AIChatUseCase) streamReply(ctx context.Context, userId int, session *domain.AIChatSession, model string, generationOptions *domain.GenerationOptions, history []*domain.AIChatMessage, current *domain.AIChatMessage, release func(), titleFrom string) (chan string, *domain.AIReply, error) {
+	sessionModel := model
+	if sessionModel == "" {
+		sessionModel = session.Model
+	}
+	budget := ai.contextWindow.Budget(sessionModel, generationOptions)
+	history = ai.withPersonaPrompt(ctx, session, history)
+	history = ai.withKnowledgeContext(ctx, session, current, history)
+	aiCtx := buildAIContext(session.Id, history, current, session.ContextSummary, budget)
+	if len(aiCtx.messages) < len(history)+1 {
+		logger.D("ChatUseCase: в контекст сессии %s вошло %d из %d сообщений", session.Id, len(aiCtx.messages), len(history)+1)
 	}
 
-	responseChan, err := ai.llmProvider.SendMessage(ctx, sessionId, model, messagesForLLM)
+	assistantMsg := domain.NewAIChatMessage(session.Id, "", domain.AIChatMessageRoleAssistant)
+	assistantMsg.Model = sessionModel
+	assistantMsg.ParentId = current.Id
+	reply := &domain.AIReply{MessageId: assistantMsg.Id}
+
+	genCtx, gen := ai.startGeneration(ctx, userId, session.Id, assistantMsg.Id)
+	tools := ai.availableTools()
+	responseChan, roundUsage, toolCalls, err := ai.generate(genCtx, session.Id, model, aiCtx.messages, tools, generationOptions)
 	if err != nil {
+		ai.finishGeneration(assistantMsg.Id, gen)
+		release()
 		logger.E("ChatUseCase: ошибка LLM: %v", err)
-		return nil, "", err
+		return nil, nil, err
 	}
 	logger.V("ChatUseCase: поток ответа запущен")
 
-	assistantMsg := domain.NewAIChatMessage(sessionId, "", domain.AIChatMessageRoleAssistant)
-	messageId := assistantMsg.Id
 	var fullResponse strings.Builder
-
 	clientChan := make(chan string, 100)
 	go func() {
-		defer func() {
-			assistantMsg.Content = fullResponse.String()
-			ai.aiChatMessageRepo.Create(context.Background(), assistantMsg)
-		}()
-		defer close(clientChan)
-
-		for chunk := range responseChan {
-			fullResponse.WriteString(chunk)
-			select {
-			case <-ctx.Done():
-				return
-			case clientChan <- chunk:
+		defer ai.finishGeneration(assistantMsg.Id, gen)
+
+		client := clientChan
+		forward := func(ch chan string) {
+			for chunk := range ch {
+				fullResponse.WriteString(chunk)
+				ai.bufferChunk(gen, chunk)
+				if client == nil {
+					if genCtx.Err() != nil {
+						return
+					}
+					continue
+				}
+
+				select {
+				case <-genCtx.Done():
+					return
+				case <-ctx.Done():
+					logger.D("ChatUseCase: клиент отключился, генерация %s продолжается в фоне", assistantMsg.Id)
+					close(clientChan)
+					client = nil
+				case client <- chunk:
+				}
+			}
+		}
+
+		usage := &domain.TokenUsage{}
+		messages := aiCtx.messages
+		failed := false
+		for round := 1; ; round++ {
+			roundStart := fullResponse.Len()
+			forward(responseChan)
+			for range responseChan {
+			}
+			usage.Add(roundUsage)
+
+			if genCtx.Err() != nil || toolCalls.Empty() {
+				break
+			}
+
+			messages = append(messages, ai.runTools(genCtx, userId, assistantMsg, fullResponse.String()[roundStart:], toolCalls.Calls)...)
+			if genCtx.Err() != nil {
+				break
+			}
+
+			roundTools := tools
+			if round >= ai.toolRounds {
+				logger.W("ChatUseCase: превышено число вызовов инструментов в сессии %s, запрошен ответ без инструментов", session.Id)
+				roundTools = nil
+			}
+
+			responseChan, roundUsage, toolCalls, err = ai.generate(genCtx, session.Id, model, messages, roundTools, generationOptions)
+			if err != nil {
+				logger.E("ChatUseCase: ошибка LLM после вызова инструментов: %v", err)
+				failed = true
+				break
 			}
 		}
+
+		switch {
+		case gen.stopped.Load():
+			reply.FinishReason = domain.AIFinishReasonStopped
+		case genCtx.Err() != nil:
+			reply.FinishReason = domain.AIFinishReasonDisconnected
+		case failed, fullResponse.Len() == 0:
+			reply.FinishReason = domain.AIFinishReasonError
+		default:
+			reply.FinishReason = domain.AIFinishReasonCompleted
+		}
+		if client != nil {
+			close(clientChan)
+		}
+
+		release()
+		ai.quotaUseCase.RecordUsage(context.Background(), userId, usage)
+
+		assistantMsg.Content = fullResponse.String()
+		saved := ai.saveReply(session.Id, assistantMsg, reply.FinishReason, usage)
+		ai.completeGeneration(userId, gen, reply.FinishReason)
+		if !saved {
+			return
+		}
+
+		if ai.summarize && len(aiCtx.uncovered) > 0 {
+			go ai.summarizeContext(userId, session.Id, model, aiCtx.summary, aiCtx.uncovered, budget)
+		}
+		if titleFrom != "" && !session.TitleManual && reply.FinishReason == domain.AIFinishReasonCompleted {
+			go ai.generateTitle(userId, session.Id, model, titleFrom, assistantMsg.Content)
+		}
 	}()
 
-	return clientChan, messageId, nil
+	return clientChan, reply, nil
 }
 
-func (ai *AIChatUseCase) CreateSession(ctx context.Context, userId int, title string, model string) (*domain.AIChatSession, error) {
+func (ai *AIChatUseCase) saveReply(sessionId string, msg *domain.AIChatMessage, reason domain.AIFinishReason, usage *domain.TokenUsage) bool {
+	if msg.Content == "" {
+		logger.W("ChatUseCase: пустой ответ в сессии %s не сохранён (%s)", sessionId, reason)
+		return false
+	}
+
+	msg.FinishReason = reason
+	msg.Stopped = reason == domain.AIFinishReasonStopped || reason == domain.AIFinishReasonDisconnected
+	if !usage.IsEmpty() {
+		msg.Usage = usage
+	}
+	if err := ai.appendReply(context.Background(), msg); err != nil {
+		logger.E("ChatUseCase: не удалось сохранить ответ в сессии %s: %v", sessionId, err)
+		return false
+	}
+
+	return true
+}
+
+func (ai *AIChatUseCase) CreateSession(ctx context.Context, userId int, title string, model string, personaId string) (*domain.AIChatSession, error) {
 	session := domain.NewAIChatSession(userId, title, model)
+	if personaId != "" {
+		persona, err := ai.getPersona(ctx, personaId)
+		if err != nil {
+			return nil, err
+		}
+
+		if !persona.CanUse(userId) {
+			return nil, domain.ErrAIPersonaNotFound
+		}
+
+		session.PersonaId = persona.Id
+		if session.Model == "" {
+			session.Model = persona.Model
+		}
+		session.GenerationOptions = persona.GenerationOptions
+	}
+
 	if err := ai.aiChatRepo.Create(ctx, session); err != nil {
 		return nil, err
 	}
@@ -141,12 +408,22 @@ func (ai *AIChatUseCase) GetSessions(ctx context.Context, userId int, page, page
 }
 
 func (ai *AIChatUseCase) GetSessionMessages(ctx context.Context, userId int, sessionId string, page, pageSize int32) ([]*domain.AIChatMessage, int32, error) {
-	_, err := ai.verifySessionOwnership(ctx, userId, sessionId)
+	session, err := ai.verifySessionOwnership(ctx, userId, sessionId)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	return ai.aiChatMessageRepo.GetBySessionId(ctx, sessionId, page, pageSize)
+	branch, err := ai.aiChatMessageRepo.GetBranch(ctx, sessionId, session.ActiveMessageId, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	messages := paginateMessages(branch, page, pageSize)
+	if err := ai.annotateSiblings(ctx, sessionId, messages); err != nil {
+		return nil, 0, err
+	}
+
+	return messages, int32(len(branch)), nil
 }
 
 func (ai *AIChatUseCase) DeleteSession(ctx context.Context, userId int, sessionID string) error {
@@ -165,6 +442,7 @@ func (ai *AIChatUseCase) UpdateSessionTitle(ctx context.Context, userId int, ses
 	}
 
 	session.Title = title
+	session.TitleManual = true
 	if err := ai.aiChatRepo.Update(ctx, session); err != nil {
 		return nil, err
 	}
@@ -186,13 +464,34 @@ func (ai *AIChatUseCase) UpdateSessionModel(ctx context.Context, userId int, ses
 	return session, nil
 }
 
+func (ai *AIChatUseCase) UpdateSessionGenerationOptions(ctx context.Context, userId int, sessionId string, opts *domain.GenerationOptions) (*domain.AIChatSession, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	session, err := ai.verifySessionOwnership(ctx, userId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	session.GenerationOptions = nil
+	if !opts.IsEmpty() {
+		session.GenerationOptions = opts
+	}
+	if err := ai.aiChatRepo.Update(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
 func buildMessageWithFile(attachmentName string, attachmentContent []byte, userMessage string) string {
 	fileContent, err := document.ExtractText(attachmentName, attachmentContent)
 	if err != nil {
 		logger.W("ChatUseCase: извлечение текста из вложения %q: %v, используем сырое содержимое", attachmentName, err)
 		fileContent = string(attachmentContent)
 	}
-	
+
 	s := fmt.Sprintf("Файл «%s»:\n\n```\n%s\n```", attachmentName, fileContent)
 	if userMessage != "" {
 		s += "\n\n---\n\n" + userMessage
@@ -2,13 +2,19 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/magomedcoder/legion/internal/domain"
 )
 
 type mockLLMProvider struct {
-	getModels func(context.Context) ([]string, error)
+	getModels     func(context.Context) ([]string, error)
+	getModelsInfo func(context.Context) ([]*domain.AIModel, error)
+	lastOptions   *domain.GenerationOptions
 }
 
 func (m *mockLLMProvider) GetModels(ctx context.Context) ([]string, error) {
@@ -19,15 +25,24 @@ func (m *mockLLMProvider) GetModels(ctx context.Context) ([]string, error) {
 	return nil, nil
 }
 
+func (m *mockLLMProvider) GetModelsInfo(ctx context.Context) ([]*domain.AIModel, error) {
+	if m.getModelsInfo != nil {
+		return m.getModelsInfo(ctx)
+	}
+
+	return nil, nil
+}
+
 func (m *mockLLMProvider) CheckConnection(context.Context) (bool, error) {
 	return true, nil
 }
 
-func (m *mockLLMProvider) SendMessage(context.Context, string, string, []*domain.AIChatMessage) (chan string, error) {
+func (m *mockLLMProvider) SendMessage(_ context.Context, _ string, _ string, _ []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	m.lastOptions = opts
 	ch := make(chan string, 1)
 	ch <- ""
 	close(ch)
-	return ch, nil
+	return ch, &domain.TokenUsage{PromptTokens: 10, CompletionTokens: 4}, nil
 }
 
 func TestAIChatUseCase_GetModels(t *testing.T) {
@@ -36,7 +51,7 @@ func TestAIChatUseCase_GetModels(t *testing.T) {
 		getModels: func(context.Context) ([]string, error) { return want, nil },
 	}
 
-	uc := NewAIChatUseCase(nil, nil, nil, llm, nil)
+	uc := NewAIChatUseCase(nil, nil, nil, llm, nil, nil)
 	got, err := uc.GetModels(context.Background())
 	if err != nil {
 		t.Fatalf("GetModels: %v", err)
@@ -46,3 +61,544 @@ func TestAIChatUseCase_GetModels(t *testing.T) {
 		t.Errorf("GetModels: получено %v", got)
 	}
 }
+
+func TestAIChatUseCase_GetModelsInfo(t *testing.T) {
+	llm := &mockLLMProvider{
+		getModelsInfo: func(context.Context) ([]*domain.AIModel, error) {
+			return []*domain.AIModel{{Name: "model1", Runners: []string{"a:1", "b:2"}}}, nil
+		},
+	}
+
+	uc := NewAIChatUseCase(nil, nil, nil, llm, nil, nil)
+	got, err := uc.GetModelsInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetModelsInfo: %v", err)
+	}
+
+	if len(got) != 1 || got[0].Name != "model1" || len(got[0].Runners) != 2 {
+		t.Errorf("GetModelsInfo: получено %+v", got)
+	}
+}
+
+type mockAIChatRepo struct {
+	mu       sync.Mutex
+	sessions map[string]*domain.AIChatSession
+	updates  int
+}
+
+func (m *mockAIChatRepo) Create(_ context.Context, session *domain.AIChatSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[session.Id] = session
+	return nil
+}
+
+func (m *mockAIChatRepo) GetById(_ context.Context, id string) (*domain.AIChatSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, errors.New("сессия не найдена")
+	}
+	copied := *s
+	return &copied, nil
+}
+
+func (m *mockAIChatRepo) GetByUserId(context.Context, int, int32, int32) ([]*domain.AIChatSession, int32, error) {
+	return nil, 0, nil
+}
+
+func (m *mockAIChatRepo) Update(_ context.Context, session *domain.AIChatSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.updates++
+	copied := *session
+	if stored, ok := m.sessions[session.Id]; ok {
+		copied.ActiveMessageId = stored.ActiveMessageId
+		copied.ContextSummary = stored.ContextSummary
+	}
+	m.sessions[session.Id] = &copied
+	return nil
+}
+
+func (m *mockAIChatRepo) UpdateGeneratedTitle(_ context.Context, id string, title string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return false, errors.New("сессия не найдена")
+	}
+	if s.TitleManual {
+		return false, nil
+	}
+	copied := *s
+	copied.Title = title
+	m.sessions[id] = &copied
+	return true, nil
+}
+
+func (m *mockAIChatRepo) UpdateContextSummary(_ context.Context, id string, summary *domain.AIChatContextSummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return errors.New("сессия не найдена")
+	}
+	copied := *s
+	copied.ContextSummary = summary
+	m.sessions[id] = &copied
+	return nil
+}
+
+func (m *mockAIChatRepo) UpdateActiveMessage(_ context.Context, id string, messageId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return errors.New("сессия не найдена")
+	}
+	copied := *s
+	copied.ActiveMessageId = messageId
+	m.sessions[id] = &copied
+	return nil
+}
+
+func (m *mockAIChatRepo) AdvanceActiveMessage(_ context.Context, id string, parentId string, messageId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok {
+		return errors.New("сессия не найдена")
+	}
+	if s.ActiveMessageId != parentId {
+		return nil
+	}
+	copied := *s
+	copied.ActiveMessageId = messageId
+	m.sessions[id] = &copied
+	return nil
+}
+
+func (m *mockAIChatRepo) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+type mockAIChatMessageRepo struct {
+	mu       sync.Mutex
+	messages []*domain.AIChatMessage
+}
+
+func (m *mockAIChatMessageRepo) Create(_ context.Context, message *domain.AIChatMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func (m *mockAIChatMessageRepo) GetBySessionId(_ context.Context, sessionID string, _, _ int32) ([]*domain.AIChatMessage, int32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*domain.AIChatMessage
+	for _, msg := range m.messages {
+		if msg.SessionId == sessionID {
+			out = append(out, msg)
+		}
+	}
+	return out, int32(len(out)), nil
+}
+
+func (m *mockAIChatMessageRepo) GetById(_ context.Context, id string) (*domain.AIChatMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range m.messages {
+		if msg.Id == id {
+			copied := *msg
+			return &copied, nil
+		}
+	}
+	return nil, domain.ErrAIMessageNotFound
+}
+
+func (m *mockAIChatMessageRepo) GetBranch(ctx context.Context, sessionID string, leafId string, limit int) ([]*domain.AIChatMessage, error) {
+	var out []*domain.AIChatMessage
+	for id := leafId; id != "" && (limit <= 0 || len(out) < limit); {
+		msg, err := m.GetById(ctx, id)
+		if err != nil || msg.SessionId != sessionID {
+			break
+		}
+		out = append([]*domain.AIChatMessage{msg}, out...)
+		id = msg.ParentId
+	}
+	return out, nil
+}
+
+func (m *mockAIChatMessageRepo) GetChildIds(_ context.Context, sessionID string, parentIds []string) (map[string][]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wanted := make(map[string]bool, len(parentIds))
+	for _, id := range parentIds {
+		wanted[id] = true
+	}
+	out := make(map[string][]string)
+	for _, msg := range m.messages {
+		if msg.SessionId == sessionID && wanted[msg.ParentId] {
+			out[msg.ParentId] = append(out[msg.ParentId], msg.Id)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockAIChatMessageRepo) GetLatestLeaf(ctx context.Context, sessionID string, messageId string) (*domain.AIChatMessage, error) {
+	leaf, err := m.GetById(ctx, messageId)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		children, _ := m.GetChildIds(ctx, sessionID, []string{leaf.Id})
+		ids := children[leaf.Id]
+		if len(ids) == 0 {
+			return leaf, nil
+		}
+		if leaf, err = m.GetById(ctx, ids[len(ids)-1]); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newAIChatUseCaseForTest(llm domain.LLMProvider) (*AIChatUseCase, *mockAIChatRepo) {
+	repo := &mockAIChatRepo{
+		sessions: make(map[string]*domain.AIChatSession),
+	}
+	return NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil), repo
+}
+
+func drain(ch chan string) {
+	for range ch {
+	}
+}
+
+func TestAIChatUseCase_SendMessage_mergesSessionOptions(t *testing.T) {
+	llm := &mockLLMProvider{}
+	uc, _ := newAIChatUseCaseForTest(llm)
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	temperature, topP := float32(0.1), float32(0.9)
+	if _, err := uc.UpdateSessionGenerationOptions(ctx, 1, session.Id, &domain.GenerationOptions{
+		Temperature: &temperature,
+		TopP:        &topP,
+	}); err != nil {
+		t.Fatalf("UpdateSessionGenerationOptions: %v", err)
+	}
+
+	override := float32(1.2)
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "m", "привет", "", nil, &domain.GenerationOptions{
+		Temperature: &override,
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	got := llm.lastOptions
+	if got == nil || *got.Temperature != 1.2 || got.TopP == nil || *got.TopP != 0.9 {
+		t.Errorf("параметры запроса должны перекрывать параметры сессии: %+v", got)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_invalidOptions(t *testing.T) {
+	uc, _ := newAIChatUseCaseForTest(&mockLLMProvider{})
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	temperature := float32(5)
+	_, _, err = uc.SendMessage(ctx, 1, session.Id, "m", "привет", "", nil, &domain.GenerationOptions{
+		Temperature: &temperature,
+	})
+	if !errors.Is(err, domain.ErrInvalidGenerationOptions) {
+		t.Errorf("ожидалась ErrInvalidGenerationOptions, получено %v", err)
+	}
+
+	if _, err := uc.UpdateSessionGenerationOptions(ctx, 2, session.Id, nil); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("чужая сессия: ожидалась ErrUnauthorized, получено %v", err)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_recordsUsage(t *testing.T) {
+	messages := &mockAIChatMessageRepo{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	llm := &mockReplyLLMProvider{
+		reply: "ответ",
+		usage: &domain.TokenUsage{PromptTokens: 10, CompletionTokens: 4},
+	}
+	uc := NewAIChatUseCase(repo, messages, nil, llm, nil, nil)
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "llama3", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	var assistant *domain.AIChatMessage
+	for deadline := time.Now().Add(time.Second); assistant == nil && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		stored, _, _ := messages.GetBySessionId(ctx, session.Id, 1, 100)
+		for _, m := range stored {
+			if m.Id == reply.MessageId {
+				assistant = m
+			}
+		}
+	}
+
+	if assistant == nil {
+		t.Fatal("ответ ассистента не сохранён")
+	}
+
+	if assistant.Model != "llama3" || assistant.Usage == nil || assistant.Usage.PromptTokens != 10 || assistant.Usage.CompletionTokens != 4 {
+		t.Errorf("неверные модель или usage ответа: model=%q usage=%+v", assistant.Model, assistant.Usage)
+	}
+}
+
+type mockEmbeddingLLMProvider struct {
+	mockLLMProvider
+	model  string
+	inputs []string
+}
+
+func (m *mockEmbeddingLLMProvider) Embed(_ context.Context, model string, inputs []string) ([][]float32, error) {
+	m.model, m.inputs = model, inputs
+	out := make([][]float32, len(inputs))
+	for i := range inputs {
+		out[i] = []float32{float32(i)}
+	}
+
+	return out, nil
+}
+
+func TestAIChatUseCase_Embed(t *testing.T) {
+	llm := &mockEmbeddingLLMProvider{}
+	uc, _ := newAIChatUseCaseForTest(llm)
+
+	vectors, err := uc.Embed(context.Background(), "nomic", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	if len(vectors) != 2 || vectors[1][0] != 1 || llm.model != "nomic" || len(llm.inputs) != 2 {
+		t.Errorf("Embed: vectors=%v model=%q inputs=%v", vectors, llm.model, llm.inputs)
+	}
+
+	if _, err := uc.Embed(context.Background(), "nomic", nil); !errors.Is(err, domain.ErrInvalidEmbeddingInput) {
+		t.Errorf("пустой пакет: ожидалась ErrInvalidEmbeddingInput, получено %v", err)
+	}
+}
+
+func TestAIChatUseCase_Embed_notSupported(t *testing.T) {
+	uc, _ := newAIChatUseCaseForTest(&mockLLMProvider{})
+
+	if _, err := uc.Embed(context.Background(), "m", []string{"a"}); !errors.Is(err, domain.ErrEmbeddingsNotSupported) {
+		t.Errorf("ожидалась ErrEmbeddingsNotSupported, получено %v", err)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_quotaExceeded(t *testing.T) {
+	messages := &mockAIChatMessageRepo{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	quota := NewQuotaUseCase(newMockAIQuotaRepo(), nil, domain.AIQuotaPolicy{
+		Users: map[int]domain.AIQuota{1: {RequestsPerMinute: 1}},
+	})
+	uc := NewAIChatUseCase(repo, messages, nil, &mockLLMProvider{}, nil, quota)
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "m", "первый", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	if _, _, err := uc.SendMessage(ctx, 1, session.Id, "m", "второй", "", nil, nil); !errors.Is(err, domain.ErrQuotaExceeded) {
+		t.Fatalf("ожидалась ErrQuotaExceeded, получено %v", err)
+	}
+
+	stored, _, _ := messages.GetBySessionId(ctx, session.Id, 1, 100)
+	for _, m := range stored {
+		if m.Content == "второй" {
+			t.Error("сообщение сверх квоты не должно сохраняться")
+		}
+	}
+}
+
+type mockSummaryLLMProvider struct {
+	mockLLMProvider
+	mu    sync.Mutex
+	calls [][]*domain.AIChatMessage
+}
+
+func (m *mockSummaryLLMProvider) SendMessage(_ context.Context, _ string, _ string, messages []*domain.AIChatMessage, _ *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	m.mu.Lock()
+	m.calls = append(m.calls, messages)
+	m.mu.Unlock()
+	ch := make(chan string, 1)
+	ch <- "сводка"
+	close(ch)
+	return ch, nil, nil
+}
+
+func (m *mockSummaryLLMProvider) callFor(content string) []*domain.AIChatMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, call := range m.calls {
+		if call[len(call)-1].Content == content {
+			return call
+		}
+	}
+	return nil
+}
+
+func TestAIChatUseCase_SendMessage_summarizesDroppedHistory(t *testing.T) {
+	messages := &mockAIChatMessageRepo{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	llm := &mockSummaryLLMProvider{}
+	uc := NewAIChatUseCase(repo, messages, nil, llm, nil, nil,
+		WithAIContextWindow(domain.ContextWindow{DefaultTokens: 200, ReserveTokens: 50}),
+		WithAISummaries(true),
+	)
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	var parentId string
+	for i := 0; i < 10; i++ {
+		msg := domain.NewAIChatMessage(session.Id, strings.Repeat("x", 100), domain.AIChatMessageRoleUser)
+		msg.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		msg.ParentId = parentId
+		messages.Create(ctx, msg)
+		parentId = msg.Id
+	}
+	repo.UpdateActiveMessage(ctx, session.Id, parentId)
+
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	if sent := llm.callFor("привет"); len(sent) == 0 || len(sent) >= 11 {
+		t.Errorf("история должна быть обрезана по бюджету, отправлено %d сообщений", len(sent))
+	}
+
+	var summary *domain.AIChatContextSummary
+	for deadline := time.Now().Add(time.Second); summary == nil && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		stored, _ := repo.GetById(ctx, session.Id)
+		summary = stored.ContextSummary
+	}
+
+	if summary == nil || summary.Content != "сводка" || summary.MessageId == "" || summary.Until.Before(start) {
+		t.Fatalf("краткое содержание не сохранено: %+v", summary)
+	}
+
+	ch, _, err = uc.SendMessage(ctx, 1, session.Id, "", "ещё", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	found := false
+	for _, msg := range llm.callFor("ещё") {
+		if msg.Role == domain.AIChatMessageRoleSystem && strings.Contains(msg.Content, "сводка") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("краткое содержание должно передаваться модели вместо отброшенных сообщений")
+	}
+}
+
+type mockReplyLLMProvider struct {
+	mockLLMProvider
+	reply string
+	usage *domain.TokenUsage
+}
+
+func (m *mockReplyLLMProvider) SendMessage(context.Context, string, string, []*domain.AIChatMessage, *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch := make(chan string, 1)
+	ch <- m.reply
+	close(ch)
+	return ch, m.usage, nil
+}
+
+func TestAIChatUseCase_SendMessage_generatesTitle(t *testing.T) {
+	uc, repo := newAIChatUseCaseForTest(&mockReplyLLMProvider{reply: "«Погода»"})
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "Новый чат", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "", "какая погода?", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	var title string
+	for deadline := time.Now().Add(time.Second); title != "Погода" && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		stored, _ := repo.GetById(ctx, session.Id)
+		title = stored.Title
+	}
+
+	if title != "Погода" {
+		t.Errorf("название сессии не сгенерировано: %q", title)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_keepsManualTitle(t *testing.T) {
+	uc, repo := newAIChatUseCaseForTest(&mockReplyLLMProvider{reply: "Погода"})
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "Новый чат", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if _, err := uc.UpdateSessionTitle(ctx, 1, session.Id, "Мой чат"); err != nil {
+		t.Fatalf("UpdateSessionTitle: %v", err)
+	}
+
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "", "какая погода?", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+	time.Sleep(50 * time.Millisecond)
+
+	if stored, _ := repo.GetById(ctx, session.Id); stored.Title != "Мой чат" || !stored.TitleManual {
+		t.Errorf("ручное название не должно перезаписываться: %+v", stored)
+	}
+}
@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	defaultAIHistoryLimit = 200
+	aiSummaryTimeout      = 2 * time.Minute
+	aiSummaryPrompt       = "Ты сжимаешь историю диалога. Составь краткое содержание разговора на языке собеседников: сохрани факты, договорённости, имена, числа и открытые вопросы. Не добавляй ничего от себя и не обращайся к пользователю."
+)
+
+func summaryMessage(sessionId string, summary *domain.AIChatContextSummary) *domain.AIChatMessage {
+	return domain.NewAIChatMessage(sessionId, "Краткое содержание предыдущей части разговора:\n"+summary.Content, domain.AIChatMessageRoleSystem)
+}
+
+func fitRecent(messages []*domain.AIChatMessage, budget int) int {
+	used, start := 0, len(messages)
+	for start > 0 {
+		tokens := domain.EstimateMessageTokens(messages[start-1])
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		start--
+	}
+
+	return start
+}
+
+type aiContext struct {
+	messages  []*domain.AIChatMessage
+	uncovered []*domain.AIChatMessage
+	summary   *domain.AIChatContextSummary
+}
+
+func buildAIContext(sessionId string, history []*domain.AIChatMessage, current *domain.AIChatMessage, summary *domain.AIChatContextSummary, budget int) aiContext {
+	var system, turns []*domain.AIChatMessage
+	for _, msg := range history {
+		if msg.Role == domain.AIChatMessageRoleSystem {
+			system = append(system, msg)
+		} else {
+			turns = append(turns, msg)
+		}
+	}
+
+	covered := -1
+	if summary != nil {
+		for i, msg := range turns {
+			if msg.Id == summary.MessageId {
+				covered = i
+				break
+			}
+		}
+		if covered < 0 {
+			summary = nil
+		}
+	}
+
+	available := budget - domain.EstimateMessageTokens(current)
+	for _, msg := range system {
+		available -= domain.EstimateMessageTokens(msg)
+	}
+
+	start := fitRecent(turns, available)
+	var summaryMsg *domain.AIChatMessage
+	if start > 0 && summary != nil {
+		summaryMsg = summaryMessage(sessionId, summary)
+		start = fitRecent(turns, available-domain.EstimateMessageTokens(summaryMsg))
+	}
+
+	for start < len(turns) && turns[start].Role == domain.AIChatMessageRoleTool {
+		start++
+	}
+
+	out := make([]*domain.AIChatMessage, 0, len(system)+len(turns)-start+2)
+	out = append(out, system...)
+	if summaryMsg != nil {
+		out = append(out, summaryMsg)
+	}
+	out = append(out, turns[start:]...)
+	out = append(out, current)
+
+	var uncovered []*domain.AIChatMessage
+	if covered+1 < start {
+		uncovered = turns[covered+1 : start]
+	}
+
+	return aiContext{
+		messages:  out,
+		uncovered: uncovered,
+		summary:   summary,
+	}
+}
+
+func (ai *AIChatUseCase) summarizeContext(userId int, sessionId string, model string, previous *domain.AIChatContextSummary, uncovered []*domain.AIChatMessage, budget int) {
+	if _, busy := ai.summarizing.LoadOrStore(sessionId, struct{}{}); busy {
+		return
+	}
+	defer ai.summarizing.Delete(sessionId)
+
+	var transcript strings.Builder
+	if previous != nil {
+		transcript.WriteString("Ранее:\n")
+		transcript.WriteString(previous.Content)
+		transcript.WriteString("\n\n")
+	}
+
+	limit := budget / 2
+	used := domain.EstimateTokens(transcript.String())
+	var last *domain.AIChatMessage
+	for _, msg := range uncovered {
+		line := fmt.Sprintf("%s: %s\n", msg.Role, msg.Content)
+		tokens := domain.EstimateTokens(line)
+		if last != nil && used+tokens > limit {
+			break
+		}
+		transcript.WriteString(line)
+		used += tokens
+		last = msg
+	}
+
+	if last == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), aiSummaryTimeout)
+	defer cancel()
+
+	responseChan, usage, err := ai.llmProvider.SendMessage(ctx, sessionId, model, []*domain.AIChatMessage{
+		domain.NewAIChatMessage(sessionId, aiSummaryPrompt, domain.AIChatMessageRoleSystem),
+		domain.NewAIChatMessage(sessionId, transcript.String(), domain.AIChatMessageRoleUser),
+	}, nil)
+	if err != nil {
+		logger.W("ChatUseCase: не удалось сжать историю сессии %s: %v", sessionId, err)
+		return
+	}
+
+	var content strings.Builder
+	for chunk := range responseChan {
+		content.WriteString(chunk)
+	}
+	ai.quotaUseCase.RecordUsage(ctx, userId, usage)
+	ai.usageUseCase.Record(ctx, userId, model, domain.TokenUsageSourceSummary, usage)
+
+	summary := strings.TrimSpace(content.String())
+	if summary == "" {
+		logger.W("ChatUseCase: пустое краткое содержание для сессии %s", sessionId)
+		return
+	}
+
+	if err := ai.aiChatRepo.UpdateContextSummary(ctx, sessionId, &domain.AIChatContextSummary{
+		Content:   summary,
+		MessageId: last.Id,
+		Until:     last.CreatedAt,
+	}); err != nil {
+		logger.W("ChatUseCase: не удалось сохранить краткое содержание сессии %s: %v", sessionId, err)
+		return
+	}
+	logger.D("ChatUseCase: история сессии %s сжата до сообщения %s", sessionId, last.Id)
+}
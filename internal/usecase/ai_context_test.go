@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func contextMessages(n int, role domain.AIChatMessageRole, start time.Time) []*domain.AIChatMessage {
+	out := make([]*domain.AIChatMessage, 0, n)
+	for i := 0; i < n; i++ {
+		msg := domain.NewAIChatMessage("s", strings.Repeat("a", 40), role)
+		msg.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		out = append(out, msg)
+	}
+
+	return out
+}
+
+func TestBuildAIContext_fitsEverything(t *testing.T) {
+	history := contextMessages(3, domain.AIChatMessageRoleUser, time.Now())
+	current := domain.NewAIChatMessage("s", "вопрос", domain.AIChatMessageRoleUser)
+
+	got := buildAIContext("s", history, current, nil, 1000)
+	if len(got.messages) != 4 || got.messages[3] != current || len(got.uncovered) != 0 {
+		t.Errorf("вся история должна поместиться: %d сообщений, %d отброшено", len(got.messages), len(got.uncovered))
+	}
+}
+
+func TestBuildAIContext_keepsSystemAndRecent(t *testing.T) {
+	start := time.Now()
+	system := domain.NewAIChatMessage("s", "ты помощник", domain.AIChatMessageRoleSystem)
+	turns := contextMessages(10, domain.AIChatMessageRoleUser, start)
+	history := append([]*domain.AIChatMessage{system}, turns...)
+	current := domain.NewAIChatMessage("s", "вопрос", domain.AIChatMessageRoleUser)
+
+	budget := domain.EstimateMessageTokens(system) + domain.EstimateMessageTokens(current) + 3*domain.EstimateMessageTokens(turns[0])
+	got := buildAIContext("s", history, current, nil, budget)
+
+	if len(got.messages) != 5 || got.messages[0] != system || got.messages[1] != turns[7] || got.messages[4] != current {
+		t.Fatalf("ожидались system, три последних сообщения и текущее: %d", len(got.messages))
+	}
+
+	if len(got.uncovered) != 7 || got.uncovered[6] != turns[6] {
+		t.Errorf("отброшенные сообщения: %d", len(got.uncovered))
+	}
+}
+
+func TestBuildAIContext_summary(t *testing.T) {
+	start := time.Now()
+	turns := contextMessages(10, domain.AIChatMessageRoleUser, start)
+	current := domain.NewAIChatMessage("s", "вопрос", domain.AIChatMessageRoleUser)
+	summary := &domain.AIChatContextSummary{Content: "кратко", MessageId: turns[3].Id, Until: turns[3].CreatedAt}
+
+	budget := domain.EstimateMessageTokens(current) + domain.EstimateMessageTokens(summaryMessage("s", summary)) + 2*domain.EstimateMessageTokens(turns[0])
+	got := buildAIContext("s", turns, current, summary, budget)
+
+	if len(got.messages) != 4 || got.messages[0].Role != domain.AIChatMessageRoleSystem || !strings.Contains(got.messages[0].Content, "кратко") || got.messages[1] != turns[8] {
+		t.Fatalf("ожидались краткое содержание, два последних сообщения и текущее: %d", len(got.messages))
+	}
+
+	if len(got.uncovered) != 4 || got.uncovered[0] != turns[4] || got.summary != summary {
+		t.Errorf("сообщения, уже вошедшие в краткое содержание, не должны возвращаться: %d", len(got.uncovered))
+	}
+
+	if got = buildAIContext("s", turns, current, summary, 10000); len(got.messages) != 11 || got.messages[0] != turns[0] {
+		t.Errorf("краткое содержание не нужно, если история помещается целиком: %d", len(got.messages))
+	}
+
+	foreign := &domain.AIChatContextSummary{Content: "другая ветка", MessageId: "other"}
+	if got = buildAIContext("s", turns, current, foreign, budget); got.summary != nil || len(got.uncovered) != 7 {
+		t.Errorf("краткое содержание другой ветки не должно использоваться: %+v", got.summary)
+	}
+}
+
+func TestBuildAIContext_currentOverBudget(t *testing.T) {
+	history := contextMessages(2, domain.AIChatMessageRoleUser, time.Now())
+	current := domain.NewAIChatMessage("s", strings.Repeat("a", 400), domain.AIChatMessageRoleUser)
+
+	got := buildAIContext("s", history, current, nil, 10)
+	if len(got.messages) != 1 || got.messages[0] != current || len(got.uncovered) != 2 {
+		t.Errorf("текущее сообщение должно отправляться всегда: %d", len(got.messages))
+	}
+}
@@ -0,0 +1,269 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/jsonutil"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	aiStreamPollInterval  = 200 * time.Millisecond
+	aiStreamFlushInterval = 100 * time.Millisecond
+	aiStreamFlushChunks   = 32
+)
+
+type aiGeneration struct {
+	sessionId string
+	messageId string
+	cancel    context.CancelFunc
+	stopped   atomic.Bool
+	mu        sync.Mutex
+	pending   []string
+	flushMu   sync.Mutex
+	flush     chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+func (ai *AIChatUseCase) startGeneration(ctx context.Context, userId int, sessionId string, messageId string) (context.Context, *aiGeneration) {
+	buffered := false
+	if ai.streams != nil {
+		err := ai.streams.Start(ctx, &domain.AIStream{
+			MessageId: messageId,
+			SessionId: sessionId,
+			UserId:    userId,
+		})
+		if err != nil {
+			logger.W("ChatUseCase: не удалось создать буфер генерации %s: %v", messageId, err)
+		} else {
+			ctx = context.WithoutCancel(ctx)
+			buffered = true
+		}
+	}
+
+	genCtx, cancel := context.WithCancel(ctx)
+	gen := &aiGeneration{
+		sessionId: sessionId,
+		messageId: messageId,
+		cancel:    cancel,
+	}
+	if buffered {
+		gen.flush = make(chan struct{}, 1)
+		gen.done = make(chan struct{})
+		go ai.flushLoop(gen)
+	}
+	ai.generations.Store(messageId, gen)
+
+	return genCtx, gen
+}
+
+func (ai *AIChatUseCase) bufferChunk(gen *aiGeneration, chunk string) {
+	if gen.flush == nil {
+		return
+	}
+
+	gen.mu.Lock()
+	gen.pending = append(gen.pending, chunk)
+	full := len(gen.pending) >= aiStreamFlushChunks
+	gen.mu.Unlock()
+
+	if full {
+		select {
+		case gen.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (ai *AIChatUseCase) flushLoop(gen *aiGeneration) {
+	ticker := time.NewTicker(aiStreamFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gen.done:
+			return
+		case <-gen.flush:
+		case <-ticker.C:
+		}
+		ai.flushChunks(gen)
+	}
+}
+
+func (ai *AIChatUseCase) flushChunks(gen *aiGeneration) {
+	gen.flushMu.Lock()
+	defer gen.flushMu.Unlock()
+
+	gen.mu.Lock()
+	chunks := gen.pending
+	gen.pending = nil
+	gen.mu.Unlock()
+
+	if len(chunks) == 0 {
+		return
+	}
+
+	if err := ai.streams.Append(context.Background(), gen.messageId, chunks); err != nil {
+		logger.W("ChatUseCase: не удалось сохранить фрагменты генерации %s: %v", gen.messageId, err)
+	}
+}
+
+func (gen *aiGeneration) stopFlushing() {
+	gen.stopOnce.Do(func() {
+		if gen.done != nil {
+			close(gen.done)
+		}
+	})
+}
+
+func (ai *AIChatUseCase) completeGeneration(userId int, gen *aiGeneration, reason domain.AIFinishReason) {
+	ctx := context.Background()
+	if gen.done != nil {
+		gen.stopFlushing()
+		ai.flushChunks(gen)
+		if err := ai.streams.Finish(ctx, gen.messageId, reason); err != nil {
+			logger.W("ChatUseCase: не удалось завершить буфер генерации %s: %v", gen.messageId, err)
+		}
+	}
+
+	if err := ai.PublishMessageCompleted(ctx, userId, gen.sessionId, gen.messageId, reason); err != nil {
+		logger.W("ChatUseCase: не удалось отправить завершение генерации %s: %v", gen.messageId, err)
+	}
+}
+
+func (ai *AIChatUseCase) finishGeneration(messageId string, gen *aiGeneration) {
+	ai.generations.CompareAndDelete(messageId, gen)
+	gen.stopFlushing()
+	gen.cancel()
+}
+
+func (ai *AIChatUseCase) StopLocalGeneration(sessionId string, messageId string) bool {
+	v, ok := ai.generations.Load(messageId)
+	if !ok {
+		return false
+	}
+
+	gen := v.(*aiGeneration)
+	if gen.sessionId != sessionId {
+		return false
+	}
+
+	gen.stopped.Store(true)
+	gen.cancel()
+	logger.I("ChatUseCase: генерация %s в сессии %s остановлена", messageId, sessionId)
+
+	return true
+}
+
+func (ai *AIChatUseCase) PublishMessageCompleted(ctx context.Context, userId int, sessionId string, messageId string, reason domain.AIFinishReason) error {
+	return ai.publishToUser(ctx, userId, domain.SubEventAIMessageCompleted, map[string]any{
+		"userId":       userId,
+		"sessionId":    sessionId,
+		"messageId":    messageId,
+		"finishReason": string(reason),
+	})
+}
+
+func (ai *AIChatUseCase) ResumeGeneration(ctx context.Context, userId int, sessionId string, messageId string, chunkOffset int64) (chan string, *domain.AIReply, error) {
+	if chunkOffset < 0 {
+		return nil, nil, domain.ErrInvalidChunkOffset
+	}
+
+	if _, err := ai.verifySessionOwnership(ctx, userId, sessionId); err != nil {
+		return nil, nil, err
+	}
+
+	if ai.streams == nil {
+		return nil, nil, domain.ErrGenerationNotFound
+	}
+
+	stream, err := ai.streams.Get(ctx, messageId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if stream.SessionId != sessionId || stream.UserId != userId {
+		return nil, nil, domain.ErrGenerationNotFound
+	}
+
+	reply := &domain.AIReply{MessageId: messageId}
+	clientChan := make(chan string, 100)
+	go func() {
+		defer close(clientChan)
+
+		for {
+			chunks, err := ai.streams.Chunks(ctx, messageId, chunkOffset)
+			if err != nil {
+				logger.W("ChatUseCase: не удалось прочитать буфер генерации %s: %v", messageId, err)
+				reply.FinishReason = domain.AIFinishReasonError
+				return
+			}
+
+			for _, chunk := range chunks {
+				select {
+				case <-ctx.Done():
+					return
+				case clientChan <- chunk:
+				}
+			}
+			chunkOffset += int64(len(chunks))
+
+			if stream.Done() {
+				reply.FinishReason = stream.FinishReason
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(aiStreamPollInterval):
+			}
+
+			if stream, err = ai.streams.Get(ctx, messageId); err != nil {
+				logger.W("ChatUseCase: буфер генерации %s недоступен: %v", messageId, err)
+				reply.FinishReason = domain.AIFinishReasonError
+				return
+			}
+		}
+	}()
+
+	return clientChan, reply, nil
+}
+
+func (ai *AIChatUseCase) StopGeneration(ctx context.Context, userId int, sessionId string, messageId string) error {
+	if _, err := ai.verifySessionOwnership(ctx, userId, sessionId); err != nil {
+		return err
+	}
+
+	if ai.StopLocalGeneration(sessionId, messageId) {
+		return nil
+	}
+
+	if ai.redis == nil || ai.streams == nil {
+		return domain.ErrGenerationNotFound
+	}
+
+	stream, err := ai.streams.Get(ctx, messageId)
+	if err != nil {
+		return err
+	}
+
+	if stream.SessionId != sessionId || stream.Done() {
+		return domain.ErrGenerationNotFound
+	}
+
+	content := jsonutil.Encode(map[string]any{
+		"event": domain.SubEventAIStopGeneration,
+		"data": jsonutil.Encode(map[string]any{
+			"sessionId": sessionId,
+			"messageId": messageId,
+		}),
+	})
+
+	return ai.redis.Publish(ctx, domain.LegionTopicAll, content).Err()
+}
@@ -0,0 +1,375 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+type mockBlockingLLMProvider struct {
+	mockLLMProvider
+	first string
+}
+
+func (m *mockBlockingLLMProvider) SendMessage(ctx context.Context, _ string, _ string, _ []*domain.AIChatMessage, _ *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		select {
+		case ch <- m.first:
+		case <-ctx.Done():
+			return
+		}
+		<-ctx.Done()
+	}()
+
+	return ch, nil, nil
+}
+
+func waitGenerationFinished(t *testing.T, uc *AIChatUseCase, messageId string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if _, ok := uc.generations.Load(messageId); !ok {
+			return
+		}
+	}
+	t.Fatalf("генерация %s не завершилась", messageId)
+}
+
+func TestAIChatUseCase_StopGeneration(t *testing.T) {
+	uc, repo := newAIChatUseCaseForTest(&mockBlockingLLMProvider{first: "частичный"})
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	if chunk := <-ch; chunk != "частичный" {
+		t.Fatalf("первый фрагмент: %q", chunk)
+	}
+
+	if err := uc.StopGeneration(ctx, 2, session.Id, reply.MessageId); err == nil {
+		t.Fatal("чужой пользователь не должен останавливать генерацию")
+	}
+
+	if err := uc.StopGeneration(ctx, 1, session.Id, reply.MessageId); err != nil {
+		t.Fatalf("StopGeneration: %v", err)
+	}
+	drain(ch)
+
+	if reply.FinishReason != domain.AIFinishReasonStopped {
+		t.Errorf("FinishReason = %q, ожидалось stopped", reply.FinishReason)
+	}
+
+	waitActiveMessage(t, repo, session.Id, reply.MessageId)
+	stored, err := uc.aiChatMessageRepo.GetById(ctx, reply.MessageId)
+	if err != nil {
+		t.Fatalf("GetById: %v", err)
+	}
+
+	if stored.Content != "частичный" || !stored.Stopped || stored.FinishReason != domain.AIFinishReasonStopped {
+		t.Errorf("частичный ответ сохранён неверно: %+v", stored)
+	}
+
+	waitGenerationFinished(t, uc, reply.MessageId)
+	if err := uc.StopGeneration(ctx, 1, session.Id, reply.MessageId); !errors.Is(err, domain.ErrGenerationNotFound) {
+		t.Errorf("повторная остановка: ожидалась ErrGenerationNotFound, получено %v", err)
+	}
+}
+
+func TestAIChatUseCase_StopGeneration_withoutStreams(t *testing.T) {
+	uc, _ := newAIChatUseCaseForTest(&mockLLMProvider{})
+	uc.redis = redis.NewClient(&redis.Options{Addr: "127.0.0.1:0"})
+	t.Cleanup(func() { _ = uc.redis.Close() })
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if err := uc.StopGeneration(ctx, 1, session.Id, "missing"); !errors.Is(err, domain.ErrGenerationNotFound) {
+		t.Errorf("без буфера потоков: ожидалась ErrGenerationNotFound, получено %v", err)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_skipsEmptyReply(t *testing.T) {
+	uc, repo := newAIChatUseCaseForTest(&mockLLMProvider{})
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+	waitGenerationFinished(t, uc, reply.MessageId)
+
+	if reply.FinishReason != domain.AIFinishReasonError {
+		t.Errorf("FinishReason = %q, ожидалось error", reply.FinishReason)
+	}
+
+	if _, err := uc.aiChatMessageRepo.GetById(ctx, reply.MessageId); err == nil {
+		t.Error("пустой ответ не должен сохраняться")
+	}
+
+	if stored, _ := repo.GetById(ctx, session.Id); stored.ActiveMessageId == reply.MessageId {
+		t.Error("пустой ответ не должен становиться активным")
+	}
+}
+
+type mockAIStreamRepo struct {
+	mu      sync.Mutex
+	streams map[string]*domain.AIStream
+	chunks  map[string][]string
+	appends int
+}
+
+func newMockAIStreamRepo() *mockAIStreamRepo {
+	return &mockAIStreamRepo{
+		streams: make(map[string]*domain.AIStream),
+		chunks:  make(map[string][]string),
+	}
+}
+
+func (m *mockAIStreamRepo) Start(_ context.Context, stream *domain.AIStream) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *stream
+	m.streams[stream.MessageId] = &cp
+	return nil
+}
+
+func (m *mockAIStreamRepo) Append(_ context.Context, messageId string, chunks []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.appends++
+	m.chunks[messageId] = append(m.chunks[messageId], chunks...)
+	return nil
+}
+
+func (m *mockAIStreamRepo) Finish(_ context.Context, messageId string, reason domain.AIFinishReason) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.streams[messageId]; ok {
+		s.FinishReason = reason
+	}
+	return nil
+}
+
+func (m *mockAIStreamRepo) Get(_ context.Context, messageId string) (*domain.AIStream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.streams[messageId]
+	if !ok {
+		return nil, domain.ErrGenerationNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+func (m *mockAIStreamRepo) Chunks(_ context.Context, messageId string, offset int64) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	all := m.chunks[messageId]
+	if offset >= int64(len(all)) {
+		return nil, nil
+	}
+	return append([]string(nil), all[offset:]...), nil
+}
+
+type mockGatedLLMProvider struct {
+	mockLLMProvider
+	chunks []string
+	gate   chan struct{}
+}
+
+func (m *mockGatedLLMProvider) SendMessage(context.Context, string, string, []*domain.AIChatMessage, *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch := make(chan string, len(m.chunks))
+	go func() {
+		defer close(ch)
+		for i, chunk := range m.chunks {
+			if i == 1 {
+				<-m.gate
+			}
+			ch <- chunk
+		}
+	}()
+
+	return ch, nil, nil
+}
+
+func TestAIChatUseCase_SendMessage_continuesAfterDisconnect(t *testing.T) {
+	llm := &mockGatedLLMProvider{
+		chunks: []string{"один ", "два ", "три"},
+		gate:   make(chan struct{}),
+	}
+	streams := newMockAIStreamRepo()
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil, WithAIStreams(streams))
+
+	session, err := uc.CreateSession(context.Background(), 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	if chunk := <-ch; chunk != "один " {
+		t.Fatalf("первый фрагмент: %q", chunk)
+	}
+	cancel()
+	close(llm.gate)
+	drain(ch)
+
+	waitGenerationFinished(t, uc, reply.MessageId)
+	stored, err := uc.aiChatMessageRepo.GetById(context.Background(), reply.MessageId)
+	if err != nil {
+		t.Fatalf("ответ не сохранён после отключения клиента: %v", err)
+	}
+
+	if stored.Content != "один два три" || stored.Stopped || stored.FinishReason != domain.AIFinishReasonCompleted {
+		t.Errorf("фоновая генерация сохранена неверно: %+v", stored)
+	}
+
+	if _, _, err := uc.ResumeGeneration(context.Background(), 2, session.Id, reply.MessageId, 0); err == nil {
+		t.Error("чужой пользователь не должен подключаться к генерации")
+	}
+
+	if _, _, err := uc.ResumeGeneration(context.Background(), 1, session.Id, "missing", 0); !errors.Is(err, domain.ErrGenerationNotFound) {
+		t.Errorf("неизвестная генерация: ожидалась ErrGenerationNotFound, получено %v", err)
+	}
+
+	resumed, resumedReply, err := uc.ResumeGeneration(context.Background(), 1, session.Id, reply.MessageId, 1)
+	if err != nil {
+		t.Fatalf("ResumeGeneration: %v", err)
+	}
+
+	var rest strings.Builder
+	for chunk := range resumed {
+		rest.WriteString(chunk)
+	}
+
+	if rest.String() != "два три" || resumedReply.FinishReason != domain.AIFinishReasonCompleted {
+		t.Errorf("продолжение потока: %q (%s)", rest.String(), resumedReply.FinishReason)
+	}
+}
+
+func TestAIChatUseCase_ResumeGeneration_followsLiveStream(t *testing.T) {
+	llm := &mockGatedLLMProvider{
+		chunks: []string{"один ", "два"},
+		gate:   make(chan struct{}),
+	}
+	streams := newMockAIStreamRepo()
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil, WithAIStreams(streams))
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	<-ch
+
+	resumed, resumedReply, err := uc.ResumeGeneration(ctx, 1, session.Id, reply.MessageId, 0)
+	if err != nil {
+		t.Fatalf("ResumeGeneration: %v", err)
+	}
+
+	if chunk := <-resumed; chunk != "один " {
+		t.Fatalf("буферизованный фрагмент: %q", chunk)
+	}
+	close(llm.gate)
+	drain(ch)
+
+	var rest strings.Builder
+	for chunk := range resumed {
+		rest.WriteString(chunk)
+	}
+
+	if rest.String() != "два" || resumedReply.FinishReason != domain.AIFinishReasonCompleted {
+		t.Errorf("живое продолжение потока: %q (%s)", rest.String(), resumedReply.FinishReason)
+	}
+}
+
+type mockChunksLLMProvider struct {
+	mockLLMProvider
+	chunks []string
+}
+
+func (m *mockChunksLLMProvider) SendMessage(context.Context, string, string, []*domain.AIChatMessage, *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch := make(chan string, len(m.chunks))
+	for _, chunk := range m.chunks {
+		ch <- chunk
+	}
+	close(ch)
+
+	return ch, nil, nil
+}
+
+func TestAIChatUseCase_SendMessage_batchesStreamChunks(t *testing.T) {
+	var chunks []string
+	for i := 0; i < 3*aiStreamFlushChunks; i++ {
+		chunks = append(chunks, strconv.Itoa(i)+" ")
+	}
+	streams := newMockAIStreamRepo()
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, &mockChunksLLMProvider{chunks: chunks}, nil, nil, WithAIStreams(streams))
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+	waitGenerationFinished(t, uc, reply.MessageId)
+
+	if _, _, err := uc.ResumeGeneration(ctx, 1, session.Id, reply.MessageId, -1); !errors.Is(err, domain.ErrInvalidChunkOffset) {
+		t.Errorf("отрицательный номер фрагмента: ожидалась ErrInvalidChunkOffset, получено %v", err)
+	}
+
+	streams.mu.Lock()
+	defer streams.mu.Unlock()
+	if strings.Join(streams.chunks[reply.MessageId], "") != strings.Join(chunks, "") {
+		t.Errorf("буфер генерации повреждён: %q", streams.chunks[reply.MessageId])
+	}
+
+	if streams.appends == 0 || streams.appends >= len(chunks) {
+		t.Errorf("фрагменты должны записываться пачками: %d записей на %d фрагментов", streams.appends, len(chunks))
+	}
+
+	if streams.streams[reply.MessageId].FinishReason != domain.AIFinishReasonCompleted {
+		t.Errorf("буфер не завершён: %+v", streams.streams[reply.MessageId])
+	}
+}
@@ -0,0 +1,77 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	aiKnowledgeQueryLimit = 2000
+	aiKnowledgePrompt     = "Ниже приведены фрагменты из базы знаний «%s». Используй их, если они относятся к вопросу, и ссылайся на источники в формате [n]. Если во фрагментах нет ответа, так и скажи."
+)
+
+func knowledgeMessage(sessionId string, kb *domain.KnowledgeBase, matches []*domain.KnowledgeMatch) *domain.AIChatMessage {
+	var b strings.Builder
+	fmt.Fprintf(&b, aiKnowledgePrompt, kb.Name)
+	for i, match := range matches {
+		fmt.Fprintf(&b, "\n\n[%d] %s, символы %d-%d:\n%s", i+1, match.Chunk.DocumentName, match.Chunk.Start, match.Chunk.End, match.Chunk.Content)
+	}
+
+	return domain.NewAIChatMessage(sessionId, b.String(), domain.AIChatMessageRoleSystem)
+}
+
+func (ai *AIChatUseCase) UpdateSessionKnowledgeBase(ctx context.Context, userId int, sessionId string, knowledgeBaseId string) (*domain.AIChatSession, error) {
+	session, err := ai.verifySessionOwnership(ctx, userId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	if knowledgeBaseId != "" {
+		if ai.knowledge == nil {
+			return nil, domain.ErrKnowledgeBaseNotFound
+		}
+
+		if _, err := ai.knowledge.Get(ctx, userId, knowledgeBaseId); err != nil {
+			return nil, err
+		}
+	}
+
+	session.KnowledgeBaseId = knowledgeBaseId
+	if err := ai.aiChatRepo.Update(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (ai *AIChatUseCase) withKnowledgeContext(ctx context.Context, session *domain.AIChatSession, current *domain.AIChatMessage, history []*domain.AIChatMessage) []*domain.AIChatMessage {
+	if session.KnowledgeBaseId == "" || ai.knowledge == nil || strings.TrimSpace(current.Content) == "" {
+		return history
+	}
+
+	kb, err := ai.knowledge.Get(ctx, session.UserId, session.KnowledgeBaseId)
+	if err != nil {
+		logger.W("ChatUseCase: база знаний %s сессии %s недоступна: %v", session.KnowledgeBaseId, session.Id, err)
+		return history
+	}
+
+	matches, err := ai.knowledge.search(ctx, kb, truncateRunes(current.Content, aiKnowledgeQueryLimit), ai.knowledge.settings.TopK)
+	if err != nil {
+		logger.W("ChatUseCase: поиск по базе знаний %s не выполнен: %v", kb.Id, err)
+		return history
+	}
+
+	if len(matches) == 0 {
+		return history
+	}
+	logger.D("ChatUseCase: в контекст сессии %s добавлено %d фрагментов базы знаний", session.Id, len(matches))
+
+	out := make([]*domain.AIChatMessage, 0, len(history)+1)
+	out = append(out, history...)
+
+	return append(out, knowledgeMessage(session.Id, kb, matches))
+}
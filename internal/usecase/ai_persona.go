@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+func (ai *AIChatUseCase) getPersona(ctx context.Context, personaId string) (*domain.AIPersona, error) {
+	if ai.personas == nil {
+		return nil, domain.ErrAIPersonaNotFound
+	}
+
+	return ai.personas.GetById(ctx, personaId)
+}
+
+func (ai *AIChatUseCase) withPersonaPrompt(ctx context.Context, session *domain.AIChatSession, history []*domain.AIChatMessage) []*domain.AIChatMessage {
+	if session.PersonaId == "" {
+		return history
+	}
+
+	persona, err := ai.getPersona(ctx, session.PersonaId)
+	if err != nil {
+		logger.W("ChatUseCase: персона %s сессии %s недоступна: %v", session.PersonaId, session.Id, err)
+		return history
+	}
+
+	if !persona.CanUse(session.UserId) {
+		logger.W("ChatUseCase: персона %s больше недоступна владельцу сессии %s", session.PersonaId, session.Id)
+		return history
+	}
+
+	prompt := domain.NewAIChatMessage(session.Id, persona.SystemPrompt, domain.AIChatMessageRoleSystem)
+	out := make([]*domain.AIChatMessage, 0, len(history)+1)
+	out = append(out, prompt)
+
+	return append(out, history...)
+}
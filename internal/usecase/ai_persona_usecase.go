@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+type AIPersonaUseCase struct {
+	repo domain.AIPersonaRepository
+}
+
+func NewAIPersonaUseCase(repo domain.AIPersonaRepository) *AIPersonaUseCase {
+	return &AIPersonaUseCase{
+		repo: repo,
+	}
+}
+
+func (u *AIPersonaUseCase) Create(ctx context.Context, userId int, name string, systemPrompt string, model string, opts *domain.GenerationOptions, shared bool) (*domain.AIPersona, error) {
+	persona := domain.NewAIPersona(userId, name, systemPrompt, model)
+	persona.GenerationOptions = opts
+	persona.Shared = shared
+	if err := persona.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := u.repo.Create(ctx, persona); err != nil {
+		return nil, err
+	}
+	logger.I("AIPersonaUseCase: пользователь %d создал персону %q (%s)", userId, persona.Name, persona.Id)
+
+	return persona, nil
+}
+
+func (u *AIPersonaUseCase) List(ctx context.Context, userId int) ([]*domain.AIPersona, error) {
+	return u.repo.ListAvailable(ctx, userId)
+}
+
+func (u *AIPersonaUseCase) Get(ctx context.Context, userId int, id string) (*domain.AIPersona, error) {
+	persona, err := u.repo.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !persona.CanUse(userId) {
+		return nil, domain.ErrAIPersonaNotFound
+	}
+
+	return persona, nil
+}
+
+func (u *AIPersonaUseCase) Update(ctx context.Context, userId int, id string, name string, systemPrompt string, model string, opts *domain.GenerationOptions, shared bool) (*domain.AIPersona, error) {
+	persona, err := u.getOwned(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	persona.Name = strings.TrimSpace(name)
+	persona.SystemPrompt = strings.TrimSpace(systemPrompt)
+	persona.Model = strings.TrimSpace(model)
+	persona.GenerationOptions = opts
+	persona.Shared = shared
+	if err := persona.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := u.repo.Update(ctx, persona); err != nil {
+		return nil, err
+	}
+
+	return persona, nil
+}
+
+func (u *AIPersonaUseCase) Delete(ctx context.Context, userId int, id string) error {
+	if _, err := u.getOwned(ctx, userId, id); err != nil {
+		return err
+	}
+
+	if err := u.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	logger.I("AIPersonaUseCase: пользователь %d удалил персону %s", userId, id)
+
+	return nil
+}
+
+func (u *AIPersonaUseCase) getOwned(ctx context.Context, userId int, id string) (*domain.AIPersona, error) {
+	persona, err := u.Get(ctx, userId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if persona.UserId != userId {
+		return nil, domain.ErrUnauthorized
+	}
+
+	return persona, nil
+}
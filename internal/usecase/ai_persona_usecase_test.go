@@ -0,0 +1,191 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type mockAIPersonaRepo struct {
+	mu       sync.Mutex
+	personas map[string]*domain.AIPersona
+}
+
+func newMockAIPersonaRepo() *mockAIPersonaRepo {
+	return &mockAIPersonaRepo{personas: make(map[string]*domain.AIPersona)}
+}
+
+func (m *mockAIPersonaRepo) Create(_ context.Context, persona *domain.AIPersona) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *persona
+	m.personas[persona.Id] = &cp
+	return nil
+}
+
+func (m *mockAIPersonaRepo) GetById(_ context.Context, id string) (*domain.AIPersona, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.personas[id]
+	if !ok {
+		return nil, domain.ErrAIPersonaNotFound
+	}
+	cp := *p
+	return &cp, nil
+}
+
+func (m *mockAIPersonaRepo) ListAvailable(_ context.Context, userId int) ([]*domain.AIPersona, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*domain.AIPersona
+	for _, p := range m.personas {
+		if p.CanUse(userId) {
+			cp := *p
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func (m *mockAIPersonaRepo) Update(_ context.Context, persona *domain.AIPersona) error {
+	return m.Create(context.Background(), persona)
+}
+
+func (m *mockAIPersonaRepo) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.personas, id)
+	return nil
+}
+
+func TestAIPersonaUseCase_visibility(t *testing.T) {
+	uc := NewAIPersonaUseCase(newMockAIPersonaRepo())
+	ctx := context.Background()
+
+	if _, err := uc.Create(ctx, 1, "", "промпт", "", nil, false); !errors.Is(err, domain.ErrInvalidAIPersona) {
+		t.Fatalf("пустое имя: ожидалась ErrInvalidAIPersona, получено %v", err)
+	}
+
+	private, err := uc.Create(ctx, 1, "Личная", "Отвечай кратко", "", nil, false)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	shared, err := uc.Create(ctx, 1, "Общая", "Отвечай подробно", "m", nil, true)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if list, _ := uc.List(ctx, 2); len(list) != 1 || list[0].Id != shared.Id {
+		t.Errorf("другому пользователю должна быть видна только общая персона: %+v", list)
+	}
+
+	if list, _ := uc.List(ctx, 1); len(list) != 2 {
+		t.Errorf("автору должны быть видны обе персоны: %+v", list)
+	}
+
+	if _, err := uc.Get(ctx, 2, private.Id); !errors.Is(err, domain.ErrAIPersonaNotFound) {
+		t.Errorf("чужая личная персона: ожидалась ErrAIPersonaNotFound, получено %v", err)
+	}
+
+	if _, err := uc.Update(ctx, 2, shared.Id, "Чужая", "п", "", nil, true); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("изменение чужой персоны: ожидалась ErrUnauthorized, получено %v", err)
+	}
+
+	if err := uc.Delete(ctx, 2, shared.Id); !errors.Is(err, domain.ErrUnauthorized) {
+		t.Errorf("удаление чужой персоны: ожидалась ErrUnauthorized, получено %v", err)
+	}
+
+	updated, err := uc.Update(ctx, 1, private.Id, " Личная 2 ", "Отвечай очень кратко", "", nil, true)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	if updated.Name != "Личная 2" || !updated.Shared {
+		t.Errorf("Update: %+v", updated)
+	}
+
+	if err := uc.Delete(ctx, 1, private.Id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := uc.Get(ctx, 1, private.Id); !errors.Is(err, domain.ErrAIPersonaNotFound) {
+		t.Errorf("удалённая персона: ожидалась ErrAIPersonaNotFound, получено %v", err)
+	}
+}
+
+func TestAIChatUseCase_CreateSession_withPersona(t *testing.T) {
+	personas := newMockAIPersonaRepo()
+	temperature := float32(0.3)
+	persona := domain.NewAIPersona(1, "Юрист", "Отвечай как юрист", "law-model")
+	persona.GenerationOptions = &domain.GenerationOptions{Temperature: &temperature}
+	_ = personas.Create(context.Background(), persona)
+	private := domain.NewAIPersona(2, "Чужая", "п", "")
+	_ = personas.Create(context.Background(), private)
+
+	llm := &mockSummaryLLMProvider{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil, WithAIPersonas(personas))
+	ctx := context.Background()
+
+	if _, err := uc.CreateSession(ctx, 1, "t", "", private.Id); !errors.Is(err, domain.ErrAIPersonaNotFound) {
+		t.Fatalf("чужая персона: ожидалась ErrAIPersonaNotFound, получено %v", err)
+	}
+
+	session, err := uc.CreateSession(ctx, 1, "t", "", persona.Id)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if session.PersonaId != persona.Id || session.Model != "law-model" || session.GenerationOptions == nil || *session.GenerationOptions.Temperature != 0.3 {
+		t.Errorf("настройки персоны не применены: %+v", session)
+	}
+
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "", "вопрос", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	call := llm.callFor("вопрос")
+	if len(call) != 2 || call[0].Role != domain.AIChatMessageRoleSystem || call[0].Content != "Отвечай как юрист" {
+		t.Errorf("системный промпт персоны не добавлен: %+v", call)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_personaNoLongerShared(t *testing.T) {
+	personas := newMockAIPersonaRepo()
+	persona := domain.NewAIPersona(2, "Общая", "Секретный промпт", "")
+	persona.Shared = true
+	_ = personas.Create(context.Background(), persona)
+
+	llm := &mockSummaryLLMProvider{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil, WithAIPersonas(personas))
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", persona.Id)
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	persona.Shared = false
+	_ = personas.Update(ctx, persona)
+
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "", "вопрос", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	for _, msg := range llm.callFor("вопрос") {
+		if msg.Role == domain.AIChatMessageRoleSystem && msg.Content == "Секретный промпт" {
+			t.Fatal("промпт персоны, ставшей личной, не должен передаваться чужой сессии")
+		}
+	}
+}
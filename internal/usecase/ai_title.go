@@ -0,0 +1,95 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	aiTitleTimeout     = time.Minute
+	aiTitleMaxRunes    = 80
+	aiTitleSourceRunes = 2000
+	aiTitleQuotes      = "\"'«»“”`*# "
+	aiTitlePrompt      = "Придумай короткое название для диалога: не больше шести слов, на языке пользователя, без кавычек и точки в конце. Ответь только названием."
+)
+
+func truncateRunes(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+
+	return string(r[:limit])
+}
+
+func normalizeAITitle(raw string) string {
+	var title string
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			title = line
+			break
+		}
+	}
+
+	title = strings.TrimRight(strings.Trim(title, aiTitleQuotes), ".")
+	title = strings.Trim(title, aiTitleQuotes)
+	title = strings.TrimSpace(truncateRunes(title, aiTitleMaxRunes))
+
+	return title
+}
+
+func (ai *AIChatUseCase) generateTitle(userId int, sessionId string, model string, question string, answer string) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiTitleTimeout)
+	defer cancel()
+
+	transcript := fmt.Sprintf("Пользователь: %s\n\nАссистент: %s", truncateRunes(question, aiTitleSourceRunes), truncateRunes(answer, aiTitleSourceRunes))
+	responseChan, usage, err := ai.llmProvider.SendMessage(ctx, sessionId, model, []*domain.AIChatMessage{
+		domain.NewAIChatMessage(sessionId, aiTitlePrompt, domain.AIChatMessageRoleSystem),
+		domain.NewAIChatMessage(sessionId, transcript, domain.AIChatMessageRoleUser),
+	}, nil)
+	if err != nil {
+		logger.W("ChatUseCase: не удалось сгенерировать название сессии %s: %v", sessionId, err)
+		return
+	}
+
+	var raw strings.Builder
+	for chunk := range responseChan {
+		raw.WriteString(chunk)
+	}
+	ai.quotaUseCase.RecordUsage(ctx, userId, usage)
+	ai.usageUseCase.Record(ctx, userId, model, domain.TokenUsageSourceTitle, usage)
+
+	title := normalizeAITitle(raw.String())
+	if title == "" {
+		logger.W("ChatUseCase: пустое название для сессии %s", sessionId)
+		return
+	}
+
+	updated, err := ai.aiChatRepo.UpdateGeneratedTitle(ctx, sessionId, title)
+	if err != nil {
+		logger.W("ChatUseCase: не удалось сохранить название сессии %s: %v", sessionId, err)
+		return
+	}
+
+	if !updated {
+		return
+	}
+	logger.D("ChatUseCase: сессия %s получила название %q", sessionId, title)
+
+	if err := ai.PublishSessionTitle(ctx, userId, sessionId, title); err != nil {
+		logger.W("ChatUseCase: не удалось отправить название сессии %s: %v", sessionId, err)
+	}
+}
+
+func (ai *AIChatUseCase) PublishSessionTitle(ctx context.Context, userId int, sessionId string, title string) error {
+	return ai.publishToUser(ctx, userId, domain.SubEventAISessionTitle, map[string]any{
+		"userId":    userId,
+		"sessionId": sessionId,
+		"title":     title,
+	})
+}
@@ -0,0 +1,81 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestNormalizeAITitle(t *testing.T) {
+	cases := map[string]string{
+		"«Погода в Москве».":           "Погода в Москве",
+		"\n\n\"Рецепт борща\"\nлишнее": "Рецепт борща",
+		"**Go generics**":              "Go generics",
+		"   ":                          "",
+	}
+	for in, want := range cases {
+		if got := normalizeAITitle(in); got != want {
+			t.Errorf("normalizeAITitle(%q) = %q, ожидалось %q", in, got, want)
+		}
+	}
+
+	if got := normalizeAITitle(strings.Repeat("я", 200)); len([]rune(got)) != aiTitleMaxRunes {
+		t.Errorf("название должно обрезаться до %d символов, получено %d", aiTitleMaxRunes, len([]rune(got)))
+	}
+}
+
+type mockTitleHookLLMProvider struct {
+	mockLLMProvider
+	onCall func()
+}
+
+func (m *mockTitleHookLLMProvider) SendMessage(context.Context, string, string, []*domain.AIChatMessage, *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	m.onCall()
+	ch := make(chan string, 1)
+	ch <- "Погода"
+	close(ch)
+	return ch, nil, nil
+}
+
+func TestAIChatUseCase_generateTitle_keepsConcurrentChanges(t *testing.T) {
+	llm := &mockTitleHookLLMProvider{}
+	uc, repo := newAIChatUseCaseForTest(llm)
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "Новый чат", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	llm.onCall = func() {
+		if _, err := uc.UpdateSessionModel(ctx, 1, session.Id, "other"); err != nil {
+			t.Errorf("UpdateSessionModel: %v", err)
+		}
+	}
+	uc.generateTitle(1, session.Id, "m", "какая погода?", "солнечно")
+
+	stored, _ := repo.GetById(ctx, session.Id)
+	if stored.Title != "Погода" || stored.Model != "other" {
+		t.Errorf("название должно сохраниться без отката модели: %+v", stored)
+	}
+
+	repo.mu.Lock()
+	updates := repo.updates
+	repo.mu.Unlock()
+	if updates != 1 {
+		t.Errorf("генерация названия должна обновлять только название, а не всю сессию: обновлений %d", updates)
+	}
+
+	llm.onCall = func() {
+		if _, err := uc.UpdateSessionTitle(ctx, 1, session.Id, "Мой чат"); err != nil {
+			t.Errorf("UpdateSessionTitle: %v", err)
+		}
+	}
+	uc.generateTitle(1, session.Id, "m", "какая погода?", "солнечно")
+
+	if stored, _ := repo.GetById(ctx, session.Id); stored.Title != "Мой чат" || !stored.TitleManual {
+		t.Errorf("переименование во время генерации названия не должно перезаписываться: %+v", stored)
+	}
+}
@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg"
+	"github.com/magomedcoder/legion/pkg/jsonutil"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const aiToolResultLimit = 16000
+
+type AIToolHandler func(ctx context.Context, userId int, args json.RawMessage) (any, error)
+
+type AIToolRegistry struct {
+	tools    []*domain.AITool
+	handlers map[string]AIToolHandler
+}
+
+func NewAIToolRegistry() *AIToolRegistry {
+	return &AIToolRegistry{
+		handlers: make(map[string]AIToolHandler),
+	}
+}
+
+func (r *AIToolRegistry) Register(tool *domain.AITool, handler AIToolHandler) error {
+	if err := tool.Validate(); err != nil {
+		return err
+	}
+
+	if _, ok := r.handlers[tool.Name]; ok {
+		return fmt.Errorf("инструмент %q уже зарегистрирован", tool.Name)
+	}
+
+	r.tools = append(r.tools, tool)
+	r.handlers[tool.Name] = handler
+
+	return nil
+}
+
+func (r *AIToolRegistry) Tools() []*domain.AITool {
+	if r == nil {
+		return nil
+	}
+
+	return r.tools
+}
+
+func (r *AIToolRegistry) Execute(ctx context.Context, userId int, call *domain.AIToolCall) (string, error) {
+	handler, ok := r.handlers[call.Name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", domain.ErrAIToolNotFound, call.Name)
+	}
+
+	args := strings.TrimSpace(call.Arguments)
+	if args == "" {
+		args = "{}"
+	}
+
+	if !json.Valid([]byte(args)) {
+		return "", domain.ErrInvalidAIToolArgs
+	}
+
+	result, err := handler(ctx, userId, json.RawMessage(args))
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+
+	return truncateRunes(string(data), aiToolResultLimit), nil
+}
+
+func decodeAIToolArgs(args json.RawMessage, v any) error {
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("%w: %v", domain.ErrInvalidAIToolArgs, err)
+	}
+
+	return nil
+}
+
+func (ai *AIChatUseCase) availableTools() []*domain.AITool {
+	if _, ok := ai.llmProvider.(domain.ToolCallingProvider); !ok {
+		return nil
+	}
+
+	return ai.tools.Tools()
+}
+
+func (ai *AIChatUseCase) generate(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	if len(tools) > 0 {
+		return ai.llmProvider.(domain.ToolCallingProvider).SendMessageWithTools(ctx, sessionId, model, messages, tools, opts)
+	}
+
+	ch, usage, err := ai.llmProvider.SendMessage(ctx, sessionId, model, messages, opts)
+
+	return ch, usage, &domain.AIToolCalls{}, err
+}
+
+func (ai *AIChatUseCase) runTools(ctx context.Context, userId int, reply *domain.AIChatMessage, content string, calls []*domain.AIToolCall) []*domain.AIChatMessage {
+	request := domain.NewAIChatMessage(reply.SessionId, content, domain.AIChatMessageRoleAssistant)
+	request.Model = reply.Model
+	request.ParentId = reply.ParentId
+	request.ToolCalls = calls
+	for _, call := range calls {
+		if call.Id == "" {
+			call.Id = pkg.GenerateUUID()
+		}
+	}
+	saved := *request
+	saved.Content = ""
+	ai.saveToolMessage(&saved)
+
+	out := []*domain.AIChatMessage{request}
+	parentId := request.Id
+	for _, call := range calls {
+		result, err := ai.tools.Execute(ctx, userId, call)
+		if err != nil {
+			logger.W("ChatUseCase: инструмент %s в сессии %s завершился ошибкой: %v", call.Name, reply.SessionId, err)
+			result = jsonutil.Encode(map[string]any{"error": err.Error()})
+		} else {
+			logger.D("ChatUseCase: инструмент %s выполнен в сессии %s", call.Name, reply.SessionId)
+		}
+
+		msg := domain.NewAIToolResultMessage(reply.SessionId, call, result)
+		msg.ParentId = parentId
+		ai.saveToolMessage(msg)
+		out = append(out, msg)
+		parentId = msg.Id
+	}
+	reply.ParentId = parentId
+
+	return out
+}
+
+func (ai *AIChatUseCase) saveToolMessage(msg *domain.AIChatMessage) {
+	if err := ai.appendReply(context.Background(), msg); err != nil {
+		logger.E("ChatUseCase: не удалось сохранить сообщение инструмента в сессии %s: %v", msg.SessionId, err)
+	}
+}
@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	aiToolUsersLimit    = 20
+	aiToolTasksLimit    = 50
+	aiToolProjectsLimit = 100
+)
+
+type legionAITools struct {
+	search   *SearchUseCase
+	projects *ProjectUseCase
+}
+
+func NewLegionAIToolRegistry(search *SearchUseCase, projects *ProjectUseCase) *AIToolRegistry {
+	t := &legionAITools{
+		search:   search,
+		projects: projects,
+	}
+
+	registry := NewAIToolRegistry()
+	tools := []struct {
+		tool    *domain.AITool
+		handler AIToolHandler
+	}{
+		{&domain.AITool{
+			Name:        "search_users",
+			Description: "Ищет пользователей Legion по имени, фамилии или логину.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"query":{"type":"string","description":"Строка поиска"},"limit":{"type":"integer","description":"Сколько пользователей вернуть (до 20)"}},"required":["query"]}`),
+		}, t.searchUsers},
+		{&domain.AITool{
+			Name:        "list_my_tasks",
+			Description: "Возвращает задачи текущего пользователя (он исполнитель или постановщик) во всех его проектах или в одном проекте.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"project_id":{"type":"string","description":"Идентификатор проекта; если не задан - все проекты"}}}`),
+		}, t.listMyTasks},
+		{&domain.AITool{
+			Name:        "get_task",
+			Description: "Возвращает задачу по идентификатору, включая описание.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"task_id":{"type":"string","description":"Идентификатор задачи"}},"required":["task_id"]}`),
+		}, t.getTask},
+		{&domain.AITool{
+			Name:        "create_task",
+			Description: "Создаёт задачу в проекте, участником которого является текущий пользователь.",
+			Parameters:  json.RawMessage(`{"type":"object","properties":{"project_id":{"type":"string","description":"Идентификатор проекта"},"name":{"type":"string","description":"Название задачи"},"description":{"type":"string","description":"Описание задачи"},"executor_id":{"type":"integer","description":"Идентификатор исполнителя; по умолчанию текущий пользователь"}},"required":["project_id","name"]}`),
+		}, t.createTask},
+	}
+	for _, item := range tools {
+		if err := registry.Register(item.tool, item.handler); err != nil {
+			logger.E("AIToolRegistry: инструмент %s не зарегистрирован: %v", item.tool.Name, err)
+		}
+	}
+
+	return registry
+}
+
+type aiToolUser struct {
+	Id       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Surname  string `json:"surname"`
+}
+
+type aiToolTask struct {
+	Id          string `json:"id"`
+	ProjectId   string `json:"project_id"`
+	ProjectName string `json:"project_name,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Status      string `json:"status,omitempty"`
+	CreatedBy   int    `json:"created_by"`
+	AssignerId  int    `json:"assigner_id"`
+	ExecutorId  int    `json:"executor_id"`
+	CreatedAt   int64  `json:"created_at,omitempty"`
+}
+
+func newAIToolTask(task *domain.Task) aiToolTask {
+	return aiToolTask{
+		Id:         task.Id,
+		ProjectId:  task.ProjectId,
+		Name:       task.Name,
+		CreatedBy:  task.CreatedBy,
+		AssignerId: task.Assigner,
+		ExecutorId: task.Executor,
+		CreatedAt:  task.CreatedAt,
+	}
+}
+
+func (t *legionAITools) searchUsers(ctx context.Context, _ int, raw json.RawMessage) (any, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := decodeAIToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(args.Query) == "" {
+		return nil, errors.New("query не может быть пустым")
+	}
+
+	if args.Limit <= 0 || args.Limit > aiToolUsersLimit {
+		args.Limit = aiToolUsersLimit
+	}
+
+	users, _, err := t.search.SearchUsers(ctx, args.Query, 1, int32(args.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]aiToolUser, len(users))
+	for i, u := range users {
+		out[i] = aiToolUser{
+			Id:       u.Id,
+			Username: u.Username,
+			Name:     u.Name,
+			Surname:  u.Surname,
+		}
+	}
+
+	return map[string]any{"users": out}, nil
+}
+
+func (t *legionAITools) listMyTasks(ctx context.Context, userId int, raw json.RawMessage) (any, error) {
+	var args struct {
+		ProjectId string `json:"project_id"`
+	}
+	if err := decodeAIToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+
+	var projects []*domain.Project
+	if args.ProjectId != "" {
+		project, err := t.projects.GetProject(ctx, args.ProjectId, userId)
+		if err != nil {
+			return nil, err
+		}
+		projects = []*domain.Project{project}
+	} else {
+		list, _, err := t.projects.GetProjects(ctx, userId, 1, aiToolProjectsLimit)
+		if err != nil {
+			return nil, err
+		}
+		projects = list
+	}
+
+	out := make([]aiToolTask, 0)
+	for _, project := range projects {
+		tasks, err := t.projects.GetTasks(ctx, project.Id, userId)
+		if err != nil {
+			return nil, err
+		}
+
+		statuses := make(map[string]string)
+		if columns, err := t.projects.GetProjectColumns(ctx, project.Id, userId); err == nil {
+			for _, col := range columns {
+				statuses[col.Id] = col.Title
+			}
+		}
+
+		for _, task := range tasks {
+			if task.Executor != userId && task.Assigner != userId {
+				continue
+			}
+
+			item := newAIToolTask(task)
+			item.ProjectName = project.Name
+			item.Status = statuses[task.ColumnId]
+			out = append(out, item)
+			if len(out) >= aiToolTasksLimit {
+				return map[string]any{"tasks": out, "truncated": true}, nil
+			}
+		}
+	}
+
+	return map[string]any{"tasks": out}, nil
+}
+
+func (t *legionAITools) getTask(ctx context.Context, userId int, raw json.RawMessage) (any, error) {
+	var args struct {
+		TaskId string `json:"task_id"`
+	}
+	if err := decodeAIToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+
+	if args.TaskId == "" {
+		return nil, errors.New("task_id не задан")
+	}
+
+	task, err := t.projects.GetTask(ctx, args.TaskId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	item := newAIToolTask(task)
+	item.Description = task.Description
+
+	return item, nil
+}
+
+func (t *legionAITools) createTask(ctx context.Context, userId int, raw json.RawMessage) (any, error) {
+	var args struct {
+		ProjectId   string `json:"project_id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		ExecutorId  int    `json:"executor_id"`
+	}
+	if err := decodeAIToolArgs(raw, &args); err != nil {
+		return nil, err
+	}
+
+	if args.ProjectId == "" {
+		return nil, errors.New("project_id не задан")
+	}
+
+	if args.ExecutorId <= 0 {
+		args.ExecutorId = userId
+	}
+
+	task, err := t.projects.CreateTask(ctx, args.ProjectId, strings.TrimSpace(args.Name), args.Description, userId, args.ExecutorId)
+	if err != nil {
+		return nil, err
+	}
+
+	return newAIToolTask(task), nil
+}
@@ -0,0 +1,286 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type mockToolLLMProvider struct {
+	mockLLMProvider
+	mu        sync.Mutex
+	messages  [][]*domain.AIChatMessage
+	tools     []int
+	preamble  string
+	failRound int
+}
+
+func (m *mockToolLLMProvider) SendMessageWithTools(_ context.Context, _ string, _ string, messages []*domain.AIChatMessage, tools []*domain.AITool, _ *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	m.mu.Lock()
+	m.messages = append(m.messages, messages)
+	m.tools = append(m.tools, len(tools))
+	round := len(m.messages)
+	m.mu.Unlock()
+
+	if round == m.failRound {
+		return nil, nil, nil, errors.New("раннер недоступен")
+	}
+
+	calls := &domain.AIToolCalls{}
+	ch := make(chan string, 1)
+	if round == 1 {
+		if m.preamble != "" {
+			ch <- m.preamble
+		}
+		calls.Calls = []*domain.AIToolCall{{Id: "call_1", Name: "echo", Arguments: `{"text":"пинг"}`}}
+	} else {
+		ch <- "готово"
+	}
+	close(ch)
+
+	return ch, &domain.TokenUsage{PromptTokens: 5, CompletionTokens: 2}, calls, nil
+}
+
+func (m *mockToolLLMProvider) SendMessage(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch, usage, _, err := m.SendMessageWithTools(ctx, sessionId, model, messages, nil, opts)
+	return ch, usage, err
+}
+
+func newEchoAIToolRegistry(t *testing.T, userIds *[]int) *AIToolRegistry {
+	t.Helper()
+	registry := NewAIToolRegistry()
+	err := registry.Register(&domain.AITool{
+		Name:       "echo",
+		Parameters: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`),
+	}, func(_ context.Context, userId int, raw json.RawMessage) (any, error) {
+		var args struct {
+			Text string `json:"text"`
+		}
+		if err := decodeAIToolArgs(raw, &args); err != nil {
+			return nil, err
+		}
+		if userIds != nil {
+			*userIds = append(*userIds, userId)
+		}
+		return map[string]string{"text": args.Text}, nil
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	return registry
+}
+
+func TestAIToolRegistry_Execute(t *testing.T) {
+	registry := newEchoAIToolRegistry(t, nil)
+	ctx := context.Background()
+
+	if err := registry.Register(&domain.AITool{Name: "echo"}, nil); err == nil {
+		t.Error("повторная регистрация должна завершаться ошибкой")
+	}
+
+	if _, err := registry.Execute(ctx, 1, &domain.AIToolCall{Name: "missing"}); !errors.Is(err, domain.ErrAIToolNotFound) {
+		t.Errorf("ожидалась ErrAIToolNotFound, получено %v", err)
+	}
+
+	if _, err := registry.Execute(ctx, 1, &domain.AIToolCall{Name: "echo", Arguments: "{"}); !errors.Is(err, domain.ErrInvalidAIToolArgs) {
+		t.Errorf("ожидалась ErrInvalidAIToolArgs, получено %v", err)
+	}
+
+	got, err := registry.Execute(ctx, 1, &domain.AIToolCall{Name: "echo", Arguments: `{"text":"привет"}`})
+	if err != nil || got != `{"text":"привет"}` {
+		t.Errorf("Execute: получено %q, %v", got, err)
+	}
+
+	got, err = registry.Execute(ctx, 1, &domain.AIToolCall{Name: "echo"})
+	if err != nil || got != `{"text":""}` {
+		t.Errorf("пустые аргументы: получено %q, %v", got, err)
+	}
+}
+
+func TestNewLegionAIToolRegistry(t *testing.T) {
+	registry := NewLegionAIToolRegistry(nil, nil)
+
+	var names []string
+	for _, tool := range registry.Tools() {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "search_users,list_my_tasks,get_task,create_task" {
+		t.Fatalf("неверный набор инструментов: %v", names)
+	}
+
+	if _, err := registry.Execute(context.Background(), 1, &domain.AIToolCall{Name: "create_task", Arguments: `{"name":"x"}`}); err == nil {
+		t.Error("create_task без project_id должен завершаться ошибкой")
+	}
+}
+
+func TestAIChatUseCase_SendMessage_runsTools(t *testing.T) {
+	var userIds []int
+	llm := &mockToolLLMProvider{}
+	messages := &mockAIChatMessageRepo{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, messages, nil, llm, nil, nil, WithAITools(newEchoAIToolRegistry(t, &userIds)))
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 7, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 7, session.Id, "", "повтори пинг", "", nil, nil)
+	drainReply(t, repo, session.Id, ch, reply, err)
+
+	if len(userIds) != 1 || userIds[0] != 7 {
+		t.Fatalf("инструмент должен выполняться от имени пользователя: %v", userIds)
+	}
+
+	branch, _ := messages.GetBranch(ctx, session.Id, reply.MessageId, 0)
+	if len(branch) != 4 {
+		t.Fatalf("ожидалась ветка вопрос -> вызов -> результат -> ответ, получено %d сообщений", len(branch))
+	}
+
+	call, result, answer := branch[1], branch[2], branch[3]
+	if call.Role != domain.AIChatMessageRoleAssistant || len(call.ToolCalls) != 1 || call.ToolCalls[0].Name != "echo" {
+		t.Errorf("неверное сообщение с вызовом инструмента: %+v", call)
+	}
+
+	if result.Role != domain.AIChatMessageRoleTool || result.ToolCallId != "call_1" || result.Content != `{"text":"пинг"}` {
+		t.Errorf("неверный результат инструмента: %+v", result)
+	}
+
+	if answer.Content != "готово" || answer.Usage == nil || answer.Usage.PromptTokens != 10 || answer.Usage.CompletionTokens != 4 {
+		t.Errorf("неверный итоговый ответ: %q usage=%+v", answer.Content, answer.Usage)
+	}
+
+	llm.mu.Lock()
+	defer llm.mu.Unlock()
+	if len(llm.messages) < 2 {
+		t.Fatalf("ожидалось не меньше двух раундов генерации, получено %d", len(llm.messages))
+	}
+
+	last := llm.messages[1]
+	if n := len(last); n < 2 || last[n-1].Role != domain.AIChatMessageRoleTool || len(last[n-2].ToolCalls) != 1 {
+		t.Errorf("второй раунд должен получить вызов и результат инструмента: %+v", last)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_toolRoundsLimit(t *testing.T) {
+	llm := &mockToolLLMProvider{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil,
+		WithAITools(newEchoAIToolRegistry(t, nil)),
+		WithAIToolRounds(1),
+	)
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	drainReply(t, repo, session.Id, ch, reply, err)
+
+	llm.mu.Lock()
+	defer llm.mu.Unlock()
+	if len(llm.tools) < 2 || llm.tools[0] != 1 || llm.tools[1] != 0 {
+		t.Errorf("после исчерпания раундов инструменты не должны передаваться: %v", llm.tools)
+	}
+}
+
+func TestAIChatUseCase_RegenerateMessage_afterTools(t *testing.T) {
+	llm := &mockToolLLMProvider{}
+	messages := &mockAIChatMessageRepo{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, messages, nil, llm, nil, nil, WithAITools(newEchoAIToolRegistry(t, nil)))
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, first, err := uc.SendMessage(ctx, 1, session.Id, "", "привет", "", nil, nil)
+	drainReply(t, repo, session.Id, ch, first, err)
+
+	ch, second, err := uc.RegenerateMessage(ctx, 1, session.Id, "", nil)
+	drainReply(t, repo, session.Id, ch, second, err)
+
+	branch, _ := messages.GetBranch(ctx, session.Id, second.MessageId, 0)
+	if len(branch) != 2 || branch[0].Role != domain.AIChatMessageRoleUser || branch[1].Content != "готово" {
+		t.Errorf("перегенерация должна отвечать на вопрос пользователя: %+v", branch)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_keepsTextStreamedBeforeTools(t *testing.T) {
+	llm := &mockToolLLMProvider{preamble: "Сейчас проверю. "}
+	messages := &mockAIChatMessageRepo{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, messages, nil, llm, nil, nil, WithAITools(newEchoAIToolRegistry(t, nil)))
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "повтори пинг", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	var streamed strings.Builder
+	for chunk := range ch {
+		streamed.WriteString(chunk)
+	}
+	waitActiveMessage(t, repo, session.Id, reply.MessageId)
+
+	branch, _ := messages.GetBranch(ctx, session.Id, reply.MessageId, 0)
+	if len(branch) != 4 {
+		t.Fatalf("ожидалась ветка вопрос -> вызов -> результат -> ответ, получено %d сообщений", len(branch))
+	}
+
+	if branch[3].Content != streamed.String() || streamed.String() != "Сейчас проверю. готово" {
+		t.Errorf("сохранённый ответ должен совпадать с отправленным клиенту: сохранено %q, отправлено %q", branch[3].Content, streamed.String())
+	}
+
+	if branch[1].Content != "" {
+		t.Errorf("текст до вызова инструмента не должен дублироваться в сообщении с вызовом: %q", branch[1].Content)
+	}
+
+	llm.mu.Lock()
+	defer llm.mu.Unlock()
+	if call := llm.messages[1][len(llm.messages[1])-2]; call.Content != "Сейчас проверю. " {
+		t.Errorf("модель должна получить свой текст перед вызовом инструмента: %+v", call)
+	}
+}
+
+func TestAIChatUseCase_SendMessage_keepsPartialReplyOnToolRoundError(t *testing.T) {
+	llm := &mockToolLLMProvider{preamble: "Сейчас проверю. ", failRound: 2}
+	messages := &mockAIChatMessageRepo{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, messages, nil, llm, nil, nil, WithAITools(newEchoAIToolRegistry(t, nil)))
+	ctx := context.Background()
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	ch, reply, err := uc.SendMessage(ctx, 1, session.Id, "", "повтори пинг", "", nil, nil)
+	drainReply(t, repo, session.Id, ch, reply, err)
+
+	stored, err := messages.GetById(ctx, reply.MessageId)
+	if err != nil {
+		t.Fatalf("частичный ответ должен быть сохранён: %v", err)
+	}
+
+	if stored.Content != "Сейчас проверю. " || stored.FinishReason != domain.AIFinishReasonError {
+		t.Errorf("неверный частичный ответ: %q (%s)", stored.Content, stored.FinishReason)
+	}
+}
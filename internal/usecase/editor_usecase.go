@@ -11,16 +11,20 @@ import (
 )
 
 type EditorUseCase struct {
-	llmProvider domain.LLMProvider
+	llmProvider  domain.LLMProvider
+	quotaUseCase *QuotaUseCase
+	usageUseCase *UsageUseCase
 }
 
-func NewEditorUseCase(llmProvider domain.LLMProvider) *EditorUseCase {
+func NewEditorUseCase(llmProvider domain.LLMProvider, quotaUseCase *QuotaUseCase, usageUseCase *UsageUseCase) *EditorUseCase {
 	return &EditorUseCase{
-		llmProvider: llmProvider,
+		llmProvider:  llmProvider,
+		quotaUseCase: quotaUseCase,
+		usageUseCase: usageUseCase,
 	}
 }
 
-func (e *EditorUseCase) Transform(ctx context.Context, model string, text string, t editorpb.TransformType, preserveMarkdown bool) (string, error) {
+func (e *EditorUseCase) Transform(ctx context.Context, userId int, model string, text string, t editorpb.TransformType, preserveMarkdown bool) (string, error) {
 	if strings.TrimSpace(text) == "" {
 		return "", fmt.Errorf("пустой текст")
 	}
@@ -33,7 +37,13 @@ func (e *EditorUseCase) Transform(ctx context.Context, model string, text string
 		domain.NewAIChatMessage(sessionId, wrapUserText(text), domain.AIChatMessageRoleUser),
 	}
 
-	ch, err := e.llmProvider.SendMessage(ctx, sessionId, model, messages)
+	release, err := e.quotaUseCase.Acquire(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	ch, usage, err := e.llmProvider.SendMessage(ctx, sessionId, model, messages, nil)
 	if err != nil {
 		return "", err
 	}
@@ -42,6 +52,8 @@ func (e *EditorUseCase) Transform(ctx context.Context, model string, text string
 	for chunk := range ch {
 		b.WriteString(chunk)
 	}
+	e.quotaUseCase.RecordUsage(ctx, userId, usage)
+	e.usageUseCase.Record(ctx, userId, model, domain.TokenUsageSourceEditor, usage)
 
 	return strings.TrimSpace(b.String()), nil
 }
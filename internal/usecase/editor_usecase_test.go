@@ -16,24 +16,29 @@ func (m *mockEditorLLM) GetModels(context.Context) ([]string, error) {
 	return nil, nil
 }
 
+func (m *mockEditorLLM) GetModelsInfo(context.Context) ([]*domain.AIModel, error) {
+	return nil, nil
+}
+
 func (m *mockEditorLLM) CheckConnection(context.Context) (bool, error) {
 	return true, nil
 }
 
-func (m *mockEditorLLM) SendMessage(ctx context.Context, sessionID string, model string, messages []*domain.AIChatMessage) (chan string, error) {
+func (m *mockEditorLLM) SendMessage(ctx context.Context, sessionID string, model string, messages []*domain.AIChatMessage, _ *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
 	if m.sendMessage != nil {
-		return m.sendMessage(ctx, sessionID, model, messages)
+		ch, err := m.sendMessage(ctx, sessionID, model, messages)
+		return ch, nil, err
 	}
 
 	ch := make(chan string, 1)
 	ch <- "transformed"
 	close(ch)
-	return ch, nil
+	return ch, nil, nil
 }
 
 func TestEditorUseCase_Transform_emptyText(t *testing.T) {
-	uc := NewEditorUseCase(&mockEditorLLM{})
-	_, err := uc.Transform(context.Background(), "m", "", editorpb.TransformType_TRANSFORM_TYPE_IMPROVE, false)
+	uc := NewEditorUseCase(&mockEditorLLM{}, nil, nil)
+	_, err := uc.Transform(context.Background(), 1, "m", "", editorpb.TransformType_TRANSFORM_TYPE_IMPROVE, false)
 	if err == nil {
 		t.Fatal("ожидалась ошибка для пустого текста")
 	}
@@ -44,8 +49,8 @@ func TestEditorUseCase_Transform_emptyText(t *testing.T) {
 }
 
 func TestEditorUseCase_Transform_success(t *testing.T) {
-	uc := NewEditorUseCase(&mockEditorLLM{})
-	out, err := uc.Transform(context.Background(), "m", "привет", editorpb.TransformType_TRANSFORM_TYPE_IMPROVE, false)
+	uc := NewEditorUseCase(&mockEditorLLM{}, nil, nil)
+	out, err := uc.Transform(context.Background(), 1, "m", "привет", editorpb.TransformType_TRANSFORM_TYPE_IMPROVE, false)
 	if err != nil {
 		t.Fatalf("Transform: %v", err)
 	}
@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg"
+	"github.com/magomedcoder/legion/pkg/document"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+type KnowledgeUseCase struct {
+	repo           domain.KnowledgeBaseRepository
+	fileRepo       domain.FileRepository
+	embedder       domain.EmbeddingProvider
+	storageUseCase *StorageUseCase
+	settings       domain.KnowledgeSettings
+}
+
+func NewKnowledgeUseCase(
+	repo domain.KnowledgeBaseRepository,
+	fileRepo domain.FileRepository,
+	embedder domain.EmbeddingProvider,
+	storageUseCase *StorageUseCase,
+	settings domain.KnowledgeSettings,
+) *KnowledgeUseCase {
+	return &KnowledgeUseCase{
+		repo:           repo,
+		fileRepo:       fileRepo,
+		embedder:       embedder,
+		storageUseCase: storageUseCase,
+		settings:       settings.WithDefaults(),
+	}
+}
+
+func (k *KnowledgeUseCase) Create(ctx context.Context, userId int, name string, embeddingModel string) (*domain.KnowledgeBase, error) {
+	kb := domain.NewKnowledgeBase(userId, name, embeddingModel)
+	if kb.Name == "" {
+		return nil, fmt.Errorf("%w: имя не может быть пустым", domain.ErrInvalidKnowledgeBase)
+	}
+
+	if kb.EmbeddingModel == "" {
+		kb.EmbeddingModel = k.settings.EmbeddingModel
+	}
+
+	if err := k.repo.Create(ctx, kb); err != nil {
+		return nil, err
+	}
+	logger.I("KnowledgeUseCase: пользователь %d создал базу знаний %q (%s)", userId, kb.Name, kb.Id)
+
+	return kb, nil
+}
+
+func (k *KnowledgeUseCase) List(ctx context.Context, userId int) ([]*domain.KnowledgeBase, error) {
+	return k.repo.ListByUserId(ctx, userId)
+}
+
+func (k *KnowledgeUseCase) Get(ctx context.Context, userId int, id string) (*domain.KnowledgeBase, error) {
+	kb, err := k.repo.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if kb.UserId != userId {
+		return nil, domain.ErrKnowledgeBaseNotFound
+	}
+
+	return kb, nil
+}
+
+func (k *KnowledgeUseCase) Delete(ctx context.Context, userId int, id string) error {
+	if _, err := k.Get(ctx, userId, id); err != nil {
+		return err
+	}
+
+	return k.repo.Delete(ctx, id)
+}
+
+func (k *KnowledgeUseCase) AddDocument(ctx context.Context, userId int, knowledgeBaseId string, fileName string, content []byte) (*domain.KnowledgeDocument, error) {
+	kb, err := k.Get(ctx, userId, knowledgeBaseId)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := document.ExtractText(fileName, content)
+	if err != nil {
+		return nil, fmt.Errorf("извлечение текста из %s: %w", fileName, err)
+	}
+
+	parts := domain.ChunkText(text, k.settings.ChunkSize, k.settings.ChunkOverlap)
+	if len(parts) == 0 {
+		return nil, domain.ErrEmptyKnowledgeDocument
+	}
+
+	inputs := make([]string, len(parts))
+	for i, part := range parts {
+		inputs[i] = part.Content
+	}
+
+	embeddings, err := k.embed(ctx, kb.EmbeddingModel, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := domain.NewKnowledgeDocument(kb.Id, fileName)
+	doc.Chunks = len(parts)
+	if k.storageUseCase != nil {
+		file, err := k.storageUseCase.SaveAttachment(ctx, "knowledge", kb.Id, fileName, content)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := k.fileRepo.Create(ctx, file); err != nil {
+			return nil, err
+		}
+		doc.FileId = file.Id
+		doc.Name = file.Filename
+	}
+
+	chunks := make([]*domain.KnowledgeChunk, len(parts))
+	for i, part := range parts {
+		chunks[i] = &domain.KnowledgeChunk{
+			Id:              pkg.GenerateUUID(),
+			KnowledgeBaseId: kb.Id,
+			DocumentId:      doc.Id,
+			DocumentName:    doc.Name,
+			Position:        i,
+			Start:           part.Start,
+			End:             part.End,
+			Content:         part.Content,
+			Embedding:       embeddings[i],
+		}
+	}
+
+	if err := k.repo.AddDocument(ctx, doc, chunks); err != nil {
+		return nil, err
+	}
+	logger.I("KnowledgeUseCase: документ %q добавлен в базу знаний %s (%d фрагментов)", doc.Name, kb.Id, doc.Chunks)
+
+	return doc, nil
+}
+
+func (k *KnowledgeUseCase) ListDocuments(ctx context.Context, userId int, knowledgeBaseId string) ([]*domain.KnowledgeDocument, error) {
+	if _, err := k.Get(ctx, userId, knowledgeBaseId); err != nil {
+		return nil, err
+	}
+
+	return k.repo.ListDocuments(ctx, knowledgeBaseId)
+}
+
+func (k *KnowledgeUseCase) Search(ctx context.Context, userId int, knowledgeBaseId string, query string, topK int) ([]*domain.KnowledgeMatch, error) {
+	kb, err := k.Get(ctx, userId, knowledgeBaseId)
+	if err != nil {
+		return nil, err
+	}
+
+	return k.search(ctx, kb, query, domain.ClampKnowledgeTopK(topK, k.settings.TopK))
+}
+
+func (k *KnowledgeUseCase) search(ctx context.Context, kb *domain.KnowledgeBase, query string, topK int) ([]*domain.KnowledgeMatch, error) {
+	if err := domain.ValidateEmbeddingInputs([]string{query}); err != nil {
+		return nil, err
+	}
+
+	chunks, err := k.repo.GetChunks(ctx, kb.Id)
+	if err != nil || len(chunks) == 0 {
+		return nil, err
+	}
+
+	embeddings, err := k.embed(ctx, kb.EmbeddingModel, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]*domain.KnowledgeMatch, 0, len(chunks))
+	for _, chunk := range chunks {
+		matches = append(matches, &domain.KnowledgeMatch{
+			Chunk: chunk,
+			Score: domain.CosineSimilarity(embeddings[0], chunk.Embedding),
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+
+	if len(matches) > topK {
+		matches = matches[:topK]
+	}
+
+	return matches, nil
+}
+
+func (k *KnowledgeUseCase) embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	if k.embedder == nil {
+		return nil, domain.ErrEmbeddingsNotSupported
+	}
+
+	out := make([][]float32, 0, len(inputs))
+	for start := 0; start < len(inputs); start += domain.MaxEmbeddingInputs {
+		batch := inputs[start:min(start+domain.MaxEmbeddingInputs, len(inputs))]
+		vectors, err := k.embedder.Embed(ctx, model, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(vectors) != len(batch) {
+			return nil, domain.ErrKnowledgeEmbeddingCount
+		}
+		out = append(out, vectors...)
+	}
+
+	return out, nil
+}
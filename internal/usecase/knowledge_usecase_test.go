@@ -0,0 +1,236 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type mockKnowledgeBaseRepo struct {
+	mu     sync.Mutex
+	bases  map[string]*domain.KnowledgeBase
+	docs   []*domain.KnowledgeDocument
+	chunks []*domain.KnowledgeChunk
+}
+
+func newMockKnowledgeBaseRepo() *mockKnowledgeBaseRepo {
+	return &mockKnowledgeBaseRepo{bases: make(map[string]*domain.KnowledgeBase)}
+}
+
+func (m *mockKnowledgeBaseRepo) Create(_ context.Context, kb *domain.KnowledgeBase) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp := *kb
+	m.bases[kb.Id] = &cp
+	return nil
+}
+
+func (m *mockKnowledgeBaseRepo) GetById(_ context.Context, id string) (*domain.KnowledgeBase, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kb, ok := m.bases[id]
+	if !ok {
+		return nil, domain.ErrKnowledgeBaseNotFound
+	}
+	cp := *kb
+	return &cp, nil
+}
+
+func (m *mockKnowledgeBaseRepo) ListByUserId(_ context.Context, userId int) ([]*domain.KnowledgeBase, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*domain.KnowledgeBase
+	for _, kb := range m.bases {
+		if kb.UserId == userId {
+			out = append(out, kb)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockKnowledgeBaseRepo) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.bases, id)
+	return nil
+}
+
+func (m *mockKnowledgeBaseRepo) AddDocument(_ context.Context, doc *domain.KnowledgeDocument, chunks []*domain.KnowledgeChunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs = append(m.docs, doc)
+	m.chunks = append(m.chunks, chunks...)
+	return nil
+}
+
+func (m *mockKnowledgeBaseRepo) ListDocuments(_ context.Context, knowledgeBaseId string) ([]*domain.KnowledgeDocument, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*domain.KnowledgeDocument
+	for _, doc := range m.docs {
+		if doc.KnowledgeBaseId == knowledgeBaseId {
+			out = append(out, doc)
+		}
+	}
+	return out, nil
+}
+
+func (m *mockKnowledgeBaseRepo) GetChunks(_ context.Context, knowledgeBaseId string) ([]*domain.KnowledgeChunk, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*domain.KnowledgeChunk
+	for _, chunk := range m.chunks {
+		if chunk.KnowledgeBaseId == knowledgeBaseId {
+			out = append(out, chunk)
+		}
+	}
+	return out, nil
+}
+
+type mockKeywordEmbedder struct {
+	keywords []string
+}
+
+func (m *mockKeywordEmbedder) Embed(_ context.Context, _ string, inputs []string) ([][]float32, error) {
+	out := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		vec := make([]float32, len(m.keywords))
+		for j, keyword := range m.keywords {
+			vec[j] = float32(strings.Count(strings.ToLower(input), keyword))
+		}
+		out[i] = vec
+	}
+	return out, nil
+}
+
+func newKnowledgeUseCaseForTest() (*KnowledgeUseCase, *mockKnowledgeBaseRepo) {
+	repo := newMockKnowledgeBaseRepo()
+	embedder := &mockKeywordEmbedder{keywords: []string{"отпуск", "зарплата"}}
+	settings := domain.KnowledgeSettings{ChunkSize: 60, ChunkOverlap: 10, TopK: 1}
+	return NewKnowledgeUseCase(repo, nil, embedder, nil, settings), repo
+}
+
+const knowledgeTestDocument = "Отпуск составляет двадцать восемь дней в году. " +
+	"Заявление на отпуск подают за две недели. " +
+	"Зарплата выплачивается дважды в месяц, зарплата приходит на карту."
+
+func TestKnowledgeUseCase_AddDocumentAndSearch(t *testing.T) {
+	uc, repo := newKnowledgeUseCaseForTest()
+	ctx := context.Background()
+
+	if _, err := uc.Create(ctx, 1, " ", ""); !errors.Is(err, domain.ErrInvalidKnowledgeBase) {
+		t.Errorf("пустое имя: ожидалась ErrInvalidKnowledgeBase, получено %v", err)
+	}
+
+	kb, err := uc.Create(ctx, 1, "Кадры", "nomic")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := uc.AddDocument(ctx, 1, kb.Id, "empty.txt", []byte("  ")); !errors.Is(err, domain.ErrEmptyKnowledgeDocument) {
+		t.Errorf("пустой документ: ожидалась ErrEmptyKnowledgeDocument, получено %v", err)
+	}
+
+	doc, err := uc.AddDocument(ctx, 1, kb.Id, "hr.txt", []byte(knowledgeTestDocument))
+	if err != nil {
+		t.Fatalf("AddDocument: %v", err)
+	}
+
+	if doc.Chunks < 2 || len(repo.chunks) != doc.Chunks {
+		t.Fatalf("документ должен быть разбит на фрагменты: %d, сохранено %d", doc.Chunks, len(repo.chunks))
+	}
+
+	matches, err := uc.Search(ctx, 1, kb.Id, "Когда придёт зарплата?", 0)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+
+	if len(matches) != 1 || !strings.Contains(strings.ToLower(matches[0].Chunk.Content), "зарплата") {
+		t.Fatalf("ожидался фрагмент про зарплату: %+v", matches)
+	}
+
+	if matches[0].Chunk.DocumentName != "hr.txt" || matches[0].Chunk.End <= matches[0].Chunk.Start {
+		t.Errorf("у фрагмента нет источника: %+v", matches[0].Chunk)
+	}
+}
+
+func TestKnowledgeUseCase_ownerOnly(t *testing.T) {
+	uc, _ := newKnowledgeUseCaseForTest()
+	ctx := context.Background()
+
+	kb, err := uc.Create(ctx, 1, "Кадры", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := uc.AddDocument(ctx, 2, kb.Id, "hr.txt", []byte(knowledgeTestDocument)); !errors.Is(err, domain.ErrKnowledgeBaseNotFound) {
+		t.Errorf("AddDocument чужим пользователем: %v", err)
+	}
+
+	if _, err := uc.Search(ctx, 2, kb.Id, "отпуск", 0); !errors.Is(err, domain.ErrKnowledgeBaseNotFound) {
+		t.Errorf("Search чужим пользователем: %v", err)
+	}
+
+	if err := uc.Delete(ctx, 2, kb.Id); !errors.Is(err, domain.ErrKnowledgeBaseNotFound) {
+		t.Errorf("Delete чужим пользователем: %v", err)
+	}
+
+	if list, _ := uc.List(ctx, 2); len(list) != 0 {
+		t.Errorf("чужие базы знаний не должны попадать в список: %d", len(list))
+	}
+}
+
+func TestAIChatUseCase_SendMessage_withKnowledgeBase(t *testing.T) {
+	knowledge, _ := newKnowledgeUseCaseForTest()
+	ctx := context.Background()
+
+	kb, err := knowledge.Create(ctx, 1, "Кадры", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := knowledge.AddDocument(ctx, 1, kb.Id, "hr.txt", []byte(knowledgeTestDocument)); err != nil {
+		t.Fatalf("AddDocument: %v", err)
+	}
+
+	llm := &mockSummaryLLMProvider{}
+	repo := &mockAIChatRepo{sessions: make(map[string]*domain.AIChatSession)}
+	uc := NewAIChatUseCase(repo, &mockAIChatMessageRepo{}, nil, llm, nil, nil, WithAIKnowledge(knowledge))
+
+	session, err := uc.CreateSession(ctx, 1, "t", "m", "")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	if _, err := uc.UpdateSessionKnowledgeBase(ctx, 2, session.Id, kb.Id); err == nil {
+		t.Error("чужой пользователь не должен подключать базу знаний")
+	}
+
+	if _, err := uc.UpdateSessionKnowledgeBase(ctx, 1, session.Id, "missing"); !errors.Is(err, domain.ErrKnowledgeBaseNotFound) {
+		t.Errorf("неизвестная база знаний: %v", err)
+	}
+
+	if _, err := uc.UpdateSessionKnowledgeBase(ctx, 1, session.Id, kb.Id); err != nil {
+		t.Fatalf("UpdateSessionKnowledgeBase: %v", err)
+	}
+
+	ch, _, err := uc.SendMessage(ctx, 1, session.Id, "", "Сколько дней отпуск?", "", nil, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	drain(ch)
+
+	found := false
+	for _, msg := range llm.callFor("Сколько дней отпуск?") {
+		if msg.Role == domain.AIChatMessageRoleSystem && strings.Contains(msg.Content, "[1] hr.txt") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("фрагменты базы знаний со ссылками на источник должны передаваться модели")
+	}
+}
@@ -3,14 +3,12 @@ package usecase
 import (
 	"context"
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
 	"github.com/magomedcoder/legion/internal/config"
 	"github.com/magomedcoder/legion/internal/domain"
 	redisRepo "github.com/magomedcoder/legion/internal/repository/redis_repository"
-	"github.com/magomedcoder/legion/pkg/jsonutil"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -324,40 +322,12 @@ func (p *ProjectUseCase) publishTaskEvent(ctx context.Context, eventName, projec
 		return nil
 	}
 
-	dataStr := jsonutil.Encode(map[string]any{
+	memberIds, _ := p.ProjectMemberRepo.GetByProjectId(ctx, projectId)
+
+	return publishToUsers(ctx, p.redis, p.serverCache, p.clientCache, eventName, map[string]any{
 		"projectId": projectId,
 		"taskId":    taskId,
-	})
-
-	content := jsonutil.Encode(map[string]any{
-		"event": eventName,
-		"data":  dataStr,
-	})
-
-	sids := p.serverCache.All(ctx, 1)
-	if len(sids) == 0 {
-		return nil
-	}
-
-	pipe := p.redis.Pipeline()
-	for _, sid := range sids {
-		memberIds, _ := p.ProjectMemberRepo.GetByProjectId(ctx, projectId)
-		anyOnline := false
-		for _, uid := range memberIds {
-			if p.clientCache.IsCurrentServerOnline(ctx, sid, domain.ChatChannelName, fmt.Sprint(uid)) {
-				anyOnline = true
-				break
-			}
-		}
-		
-		if anyOnline {
-			pipe.Publish(ctx, fmt.Sprintf(domain.LegionTopicByServer, sid), content)
-		}
-	}
-
-	_, err := pipe.Exec(ctx)
-
-	return err
+	}, memberIds...)
 }
 
 func (p *ProjectUseCase) PublishNewTask(ctx context.Context, projectId, taskId string) error {
@@ -0,0 +1,46 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	redisRepo "github.com/magomedcoder/legion/internal/repository/redis_repository"
+	"github.com/magomedcoder/legion/pkg/jsonutil"
+	"github.com/redis/go-redis/v9"
+)
+
+func publishToUsers(ctx context.Context, rds *redis.Client, serverCache *redisRepo.ServerCacheRepository, clientCache *redisRepo.ClientCacheRepository, event string, data map[string]any, userIds ...int) error {
+	if rds == nil || serverCache == nil || clientCache == nil || len(userIds) == 0 {
+		return nil
+	}
+
+	content := jsonutil.Encode(map[string]any{
+		"event": event,
+		"data":  jsonutil.Encode(data),
+	})
+
+	sids := serverCache.All(ctx, 1)
+	if len(sids) == 0 {
+		return nil
+	}
+
+	pipe := rds.Pipeline()
+	for _, sid := range sids {
+		for _, uid := range userIds {
+			if clientCache.IsCurrentServerOnline(ctx, sid, domain.ChatChannelName, strconv.Itoa(uid)) {
+				pipe.Publish(ctx, fmt.Sprintf(domain.LegionTopicByServer, sid), content)
+				break
+			}
+		}
+	}
+
+	_, err := pipe.Exec(ctx)
+
+	return err
+}
+
+func (ai *AIChatUseCase) publishToUser(ctx context.Context, userId int, event string, data map[string]any) error {
+	return publishToUsers(ctx, ai.redis, ai.serverCache, ai.clientCache, event, data, userId)
+}
@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	quotaRequestsWindow = time.Minute
+	quotaStreamRetry    = 5 * time.Second
+)
+
+type QuotaUseCase struct {
+	repo     domain.AIQuotaRepository
+	userRepo domain.UserRepository
+	policy   domain.AIQuotaPolicy
+	now      func() time.Time
+}
+
+func NewQuotaUseCase(repo domain.AIQuotaRepository, userRepo domain.UserRepository, policy domain.AIQuotaPolicy) *QuotaUseCase {
+	return &QuotaUseCase{
+		repo:     repo,
+		userRepo: userRepo,
+		policy:   policy,
+		now:      time.Now,
+	}
+}
+
+func (q *QuotaUseCase) Acquire(ctx context.Context, userId int) (func(), error) {
+	noop := func() {}
+	if q == nil {
+		return noop, nil
+	}
+
+	quota, err := q.quotaFor(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if quota.IsUnlimited() {
+		return noop, nil
+	}
+
+	if quota.TokensPerDay > 0 {
+		now := q.now().UTC()
+		used, err := q.repo.GetDailyTokens(ctx, userId, now)
+		if err != nil {
+			logger.W("QuotaUseCase: не удалось прочитать расход токенов пользователя %d: %v", userId, err)
+		} else if used >= quota.TokensPerDay {
+			tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+			return nil, &domain.QuotaExceededError{Limit: domain.AIQuotaLimitTokensPerDay, RetryAfter: tomorrow.Sub(now)}
+		}
+	}
+
+	if quota.RequestsPerMinute > 0 {
+		count, retryAfter, err := q.repo.IncrementRequests(ctx, userId, quotaRequestsWindow)
+		if err != nil {
+			logger.W("QuotaUseCase: не удалось учесть запрос пользователя %d: %v", userId, err)
+		} else if count > int64(quota.RequestsPerMinute) {
+			return nil, &domain.QuotaExceededError{Limit: domain.AIQuotaLimitRequestsPerMinute, RetryAfter: retryAfter}
+		}
+	}
+
+	if quota.ConcurrentStreams <= 0 {
+		return noop, nil
+	}
+
+	ok, err := q.repo.AcquireStream(ctx, userId, quota.ConcurrentStreams)
+	if err != nil {
+		logger.W("QuotaUseCase: не удалось занять поток пользователя %d: %v", userId, err)
+		return noop, nil
+	}
+
+	if !ok {
+		return nil, &domain.QuotaExceededError{Limit: domain.AIQuotaLimitConcurrentStreams, RetryAfter: quotaStreamRetry}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if err := q.repo.ReleaseStream(context.Background(), userId); err != nil {
+				logger.W("QuotaUseCase: не удалось освободить поток пользователя %d: %v", userId, err)
+			}
+		})
+	}, nil
+}
+
+func (q *QuotaUseCase) RecordUsage(ctx context.Context, userId int, usage *domain.TokenUsage) {
+	if q == nil || usage.IsEmpty() {
+		return
+	}
+
+	if err := q.repo.AddDailyTokens(ctx, userId, q.now().UTC(), int64(usage.Total())); err != nil {
+		logger.W("QuotaUseCase: не удалось учесть токены пользователя %d: %v", userId, err)
+	}
+}
+
+func (q *QuotaUseCase) quotaFor(ctx context.Context, userId int) (domain.AIQuota, error) {
+	if q.policy.HasUserOverride(userId) || len(q.policy.Roles) == 0 {
+		return q.policy.For(userId, domain.UserRoleUser), nil
+	}
+
+	user, err := q.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return domain.AIQuota{}, err
+	}
+
+	return q.policy.For(userId, user.Role), nil
+}
@@ -0,0 +1,175 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type mockAIQuotaRepo struct {
+	mu       sync.Mutex
+	requests map[int]int64
+	tokens   map[int]int64
+	streams  map[int]int
+}
+
+func newMockAIQuotaRepo() *mockAIQuotaRepo {
+	return &mockAIQuotaRepo{
+		requests: make(map[int]int64),
+		tokens:   make(map[int]int64),
+		streams:  make(map[int]int),
+	}
+}
+
+func (m *mockAIQuotaRepo) IncrementRequests(_ context.Context, userId int, _ time.Duration) (int64, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[userId]++
+	return m.requests[userId], 30 * time.Second, nil
+}
+
+func (m *mockAIQuotaRepo) GetDailyTokens(_ context.Context, userId int, _ time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens[userId], nil
+}
+
+func (m *mockAIQuotaRepo) AddDailyTokens(_ context.Context, userId int, _ time.Time, tokens int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[userId] += tokens
+	return nil
+}
+
+func (m *mockAIQuotaRepo) AcquireStream(_ context.Context, userId int, limit int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.streams[userId] >= limit {
+		return false, nil
+	}
+	m.streams[userId]++
+	return true, nil
+}
+
+func (m *mockAIQuotaRepo) ReleaseStream(_ context.Context, userId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streams[userId]--
+	return nil
+}
+
+type mockQuotaUserRepo struct {
+	mockUserRepo
+	role domain.UserRole
+}
+
+func (m *mockQuotaUserRepo) GetById(_ context.Context, id int) (*domain.User, error) {
+	return &domain.User{Id: id, Role: m.role}, nil
+}
+
+func quotaLimit(err error) domain.AIQuotaLimit {
+	var exceeded *domain.QuotaExceededError
+	if errors.As(err, &exceeded) {
+		return exceeded.Limit
+	}
+
+	return ""
+}
+
+func TestQuotaUseCase_nilAllowsEverything(t *testing.T) {
+	var q *QuotaUseCase
+	release, err := q.Acquire(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	release()
+	q.RecordUsage(context.Background(), 1, &domain.TokenUsage{PromptTokens: 1})
+}
+
+func TestQuotaUseCase_requestsPerMinute(t *testing.T) {
+	q := NewQuotaUseCase(newMockAIQuotaRepo(), &mockQuotaUserRepo{}, domain.AIQuotaPolicy{
+		Roles: map[domain.UserRole]domain.AIQuota{domain.UserRoleUser: {RequestsPerMinute: 2}},
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := q.Acquire(context.Background(), 1); err != nil {
+			t.Fatalf("запрос %d: %v", i+1, err)
+		}
+	}
+
+	_, err := q.Acquire(context.Background(), 1)
+	if quotaLimit(err) != domain.AIQuotaLimitRequestsPerMinute {
+		t.Fatalf("ожидалось превышение requests_per_minute, получено %v", err)
+	}
+
+	var exceeded *domain.QuotaExceededError
+	if errors.As(err, &exceeded); exceeded.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %v", exceeded.RetryAfter)
+	}
+}
+
+func TestQuotaUseCase_roleWithoutQuota(t *testing.T) {
+	q := NewQuotaUseCase(newMockAIQuotaRepo(), &mockQuotaUserRepo{role: domain.UserRoleAdmin}, domain.AIQuotaPolicy{
+		Roles: map[domain.UserRole]domain.AIQuota{domain.UserRoleUser: {RequestsPerMinute: 1}},
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := q.Acquire(context.Background(), 1); err != nil {
+			t.Fatalf("администратор без квоты: %v", err)
+		}
+	}
+}
+
+func TestQuotaUseCase_tokensPerDay(t *testing.T) {
+	repo := newMockAIQuotaRepo()
+	q := NewQuotaUseCase(repo, nil, domain.AIQuotaPolicy{
+		Users: map[int]domain.AIQuota{7: {TokensPerDay: 100}},
+	})
+	now := time.Date(2025, 5, 10, 18, 0, 0, 0, time.UTC)
+	q.now = func() time.Time { return now }
+
+	if _, err := q.Acquire(context.Background(), 7); err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	q.RecordUsage(context.Background(), 7, &domain.TokenUsage{PromptTokens: 80, CompletionTokens: 30})
+
+	_, err := q.Acquire(context.Background(), 7)
+	if quotaLimit(err) != domain.AIQuotaLimitTokensPerDay {
+		t.Fatalf("ожидалось превышение tokens_per_day, получено %v", err)
+	}
+
+	var exceeded *domain.QuotaExceededError
+	if errors.As(err, &exceeded); exceeded.RetryAfter != 6*time.Hour {
+		t.Errorf("RetryAfter должен указывать на начало следующих суток: %v", exceeded.RetryAfter)
+	}
+}
+
+func TestQuotaUseCase_concurrentStreams(t *testing.T) {
+	repo := newMockAIQuotaRepo()
+	q := NewQuotaUseCase(repo, nil, domain.AIQuotaPolicy{
+		Users: map[int]domain.AIQuota{3: {ConcurrentStreams: 1}},
+	})
+
+	release, err := q.Acquire(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if _, err := q.Acquire(context.Background(), 3); quotaLimit(err) != domain.AIQuotaLimitConcurrentStreams {
+		t.Fatalf("ожидалось превышение concurrent_streams, получено %v", err)
+	}
+
+	release()
+	release()
+	if repo.streams[3] != 0 {
+		t.Errorf("повторный release не должен освобождать поток дважды: %d", repo.streams[3])
+	}
+
+	if _, err := q.Acquire(context.Background(), 3); err != nil {
+		t.Errorf("после освобождения поток должен быть доступен: %v", err)
+	}
+}
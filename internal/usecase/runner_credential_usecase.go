@@ -0,0 +1,117 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/encrypt"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const runnerTokenPrefix = "lrt_"
+
+type RunnerCredentialUseCase struct {
+	repo domain.RunnerCredentialRepository
+}
+
+func NewRunnerCredentialUseCase(repo domain.RunnerCredentialRepository) *RunnerCredentialUseCase {
+	return &RunnerCredentialUseCase{
+		repo: repo,
+	}
+}
+
+func (u *RunnerCredentialUseCase) Create(ctx context.Context, createdBy int, name string, address string) (*domain.RunnerCredential, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("имя раннера не может быть пустым")
+	}
+
+	secret, err := encrypt.RandomHex(32)
+	if err != nil {
+		return nil, "", fmt.Errorf("генерация токена раннера: %w", err)
+	}
+	token := runnerTokenPrefix + secret
+
+	credential := domain.NewRunnerCredential(name, strings.TrimSpace(address), encrypt.Sha256(token), createdBy)
+	if err := u.repo.Create(ctx, credential); err != nil {
+		return nil, "", err
+	}
+	logger.I("RunnerCredentialUseCase: выданы учётные данные раннера %q (%s)", credential.Name, credential.Id)
+
+	return credential, token, nil
+}
+
+func (u *RunnerCredentialUseCase) List(ctx context.Context) ([]*domain.RunnerCredential, error) {
+	return u.repo.List(ctx)
+}
+
+func (u *RunnerCredentialUseCase) Revoke(ctx context.Context, id string) error {
+	if err := u.repo.Revoke(ctx, id); err != nil {
+		return err
+	}
+	logger.I("RunnerCredentialUseCase: учётные данные раннера %s отозваны", id)
+
+	return nil
+}
+
+func (u *RunnerCredentialUseCase) Authenticate(ctx context.Context, token string, address string) (*domain.RunnerCredential, error) {
+	if !strings.HasPrefix(token, runnerTokenPrefix) {
+		return nil, domain.ErrRunnerCredentialNotFound
+	}
+
+	credential, err := u.repo.GetByTokenHash(ctx, encrypt.Sha256(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if credential.IsRevoked() {
+		return nil, domain.ErrRunnerCredentialRevoked
+	}
+
+	switch {
+	case credential.Address == "":
+		if credential, err = u.bindAddress(ctx, credential, token, address); err != nil {
+			return nil, err
+		}
+	case credential.Address != address:
+		return nil, domain.ErrRunnerAddressMismatch
+	}
+
+	if err := u.repo.TouchLastUsed(ctx, credential.Id); err != nil {
+		logger.W("RunnerCredentialUseCase: не удалось обновить last_used_at: %v", err)
+	}
+
+	return credential, nil
+}
+
+func (u *RunnerCredentialUseCase) bindAddress(ctx context.Context, credential *domain.RunnerCredential, token string, address string) (*domain.RunnerCredential, error) {
+	err := u.repo.BindAddress(ctx, credential.Id, address)
+	if err == nil {
+		credential.Address = address
+		logger.I("RunnerCredentialUseCase: учётные данные %q привязаны к адресу %s", credential.Name, address)
+		return credential, nil
+	}
+
+	if !errors.Is(err, domain.ErrRunnerAddressMismatch) {
+		return nil, err
+	}
+
+	reloaded, err := u.repo.GetByTokenHash(ctx, encrypt.Sha256(token))
+	if err != nil {
+		return nil, err
+	}
+
+	if reloaded.IsRevoked() {
+		return nil, domain.ErrRunnerCredentialRevoked
+	}
+
+	if reloaded.Address != address {
+		logger.W("RunnerCredentialUseCase: учётные данные %q уже привязаны к адресу %s, отклонён %s", reloaded.Name, reloaded.Address, address)
+		return nil, domain.ErrRunnerAddressMismatch
+	}
+
+	return reloaded, nil
+}
@@ -0,0 +1,177 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type mockRunnerCredentialRepo struct {
+	byHash  map[string]*domain.RunnerCredential
+	touched int
+	raced   string
+}
+
+func newMockRunnerCredentialRepo() *mockRunnerCredentialRepo {
+	return &mockRunnerCredentialRepo{
+		byHash: make(map[string]*domain.RunnerCredential),
+	}
+}
+
+func (m *mockRunnerCredentialRepo) Create(_ context.Context, credential *domain.RunnerCredential) error {
+	m.byHash[credential.TokenHash] = credential
+	return nil
+}
+
+func (m *mockRunnerCredentialRepo) GetByTokenHash(_ context.Context, tokenHash string) (*domain.RunnerCredential, error) {
+	c, ok := m.byHash[tokenHash]
+	if !ok {
+		return nil, domain.ErrRunnerCredentialNotFound
+	}
+	copied := *c
+	return &copied, nil
+}
+
+func (m *mockRunnerCredentialRepo) List(context.Context) ([]*domain.RunnerCredential, error) {
+	out := make([]*domain.RunnerCredential, 0, len(m.byHash))
+	for _, c := range m.byHash {
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func (m *mockRunnerCredentialRepo) BindAddress(_ context.Context, id string, address string) error {
+	for _, c := range m.byHash {
+		if c.Id == id {
+			if m.raced != "" {
+				c.Address = m.raced
+			}
+			if c.Address != "" {
+				return domain.ErrRunnerAddressMismatch
+			}
+			c.Address = address
+			return nil
+		}
+	}
+	return domain.ErrRunnerCredentialNotFound
+}
+
+func (m *mockRunnerCredentialRepo) TouchLastUsed(context.Context, string) error {
+	m.touched++
+	return nil
+}
+
+func (m *mockRunnerCredentialRepo) Revoke(_ context.Context, id string) error {
+	for _, c := range m.byHash {
+		if c.Id == id {
+			now := time.Now()
+			c.RevokedAt = &now
+			return nil
+		}
+	}
+	return domain.ErrRunnerCredentialNotFound
+}
+
+func TestRunnerCredentialUseCase_Create(t *testing.T) {
+	repo := newMockRunnerCredentialRepo()
+	uc := NewRunnerCredentialUseCase(repo)
+
+	credential, token, err := uc.Create(context.Background(), 1, " gpu-1 ", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if !strings.HasPrefix(token, runnerTokenPrefix) {
+		t.Errorf("токен должен начинаться с %q: %q", runnerTokenPrefix, token)
+	}
+
+	if credential.Name != "gpu-1" || credential.TokenHash == token || credential.TokenHash == "" {
+		t.Errorf("неверные учётные данные: %+v", credential)
+	}
+
+	if _, _, err := uc.Create(context.Background(), 1, "  ", ""); err == nil {
+		t.Error("ожидалась ошибка для пустого имени")
+	}
+}
+
+func TestRunnerCredentialUseCase_Authenticate_bindsAddress(t *testing.T) {
+	repo := newMockRunnerCredentialRepo()
+	uc := NewRunnerCredentialUseCase(repo)
+	ctx := context.Background()
+
+	_, token, err := uc.Create(ctx, 1, "gpu-1", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	credential, err := uc.Authenticate(ctx, token, "10.0.0.1:50052")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	if credential.Address != "10.0.0.1:50052" || repo.touched != 1 {
+		t.Errorf("адрес не привязан или last_used не обновлён: %+v, touched=%d", credential, repo.touched)
+	}
+
+	if _, err := uc.Authenticate(ctx, token, "10.0.0.2:50052"); !errors.Is(err, domain.ErrRunnerAddressMismatch) {
+		t.Errorf("ожидалась ErrRunnerAddressMismatch, получено %v", err)
+	}
+}
+
+func TestRunnerCredentialUseCase_Authenticate_concurrentBind(t *testing.T) {
+	repo := newMockRunnerCredentialRepo()
+	uc := NewRunnerCredentialUseCase(repo)
+	ctx := context.Background()
+
+	_, token, err := uc.Create(ctx, 1, "gpu-1", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	repo.raced = "10.0.0.1:50052"
+	if _, err := uc.Authenticate(ctx, token, "10.0.0.2:50052"); !errors.Is(err, domain.ErrRunnerAddressMismatch) {
+		t.Errorf("проигравший гонку раннер: ожидалась ErrRunnerAddressMismatch, получено %v", err)
+	}
+
+	_, token, err = uc.Create(ctx, 1, "gpu-2", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	repo.raced = "10.0.0.3:50052"
+	credential, err := uc.Authenticate(ctx, token, "10.0.0.3:50052")
+	if err != nil || credential.Address != "10.0.0.3:50052" {
+		t.Errorf("тот же адрес после гонки должен проходить: %+v, %v", credential, err)
+	}
+}
+
+func TestRunnerCredentialUseCase_Authenticate_rejected(t *testing.T) {
+	repo := newMockRunnerCredentialRepo()
+	uc := NewRunnerCredentialUseCase(repo)
+	ctx := context.Background()
+
+	credential, token, err := uc.Create(ctx, 1, "gpu-1", "a:1")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := uc.Authenticate(ctx, "shared-token", "a:1"); !errors.Is(err, domain.ErrRunnerCredentialNotFound) {
+		t.Errorf("токен без префикса: ожидалась ErrRunnerCredentialNotFound, получено %v", err)
+	}
+
+	if _, err := uc.Authenticate(ctx, token+"0", "a:1"); !errors.Is(err, domain.ErrRunnerCredentialNotFound) {
+		t.Errorf("неизвестный токен: ожидалась ErrRunnerCredentialNotFound, получено %v", err)
+	}
+
+	if err := uc.Revoke(ctx, credential.Id); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+
+	if _, err := uc.Authenticate(ctx, token, "a:1"); !errors.Is(err, domain.ErrRunnerCredentialRevoked) {
+		t.Errorf("ожидалась ErrRunnerCredentialRevoked, получено %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+type UsageUseCase struct {
+	repo domain.TokenUsageRepository
+}
+
+func NewUsageUseCase(repo domain.TokenUsageRepository) *UsageUseCase {
+	return &UsageUseCase{
+		repo: repo,
+	}
+}
+
+func (u *UsageUseCase) GetTokenUsage(ctx context.Context, filter domain.UsageFilter) ([]*domain.UsageStat, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	stats, err := u.repo.Aggregate(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	logger.V("UsageUseCase: статистика по %s: строк %d", filter.GroupBy, len(stats))
+
+	return stats, nil
+}
+
+func (u *UsageUseCase) Record(ctx context.Context, userId int, model string, source domain.TokenUsageSource, usage *domain.TokenUsage) {
+	if u == nil || usage.IsEmpty() {
+		return
+	}
+
+	record := &domain.TokenUsageRecord{
+		UserId: userId,
+		Model:  model,
+		Source: source,
+		Usage:  *usage,
+	}
+	if err := u.repo.Record(ctx, record); err != nil {
+		logger.W("UsageUseCase: не удалось записать расход токенов пользователя %d (%s): %v", userId, source, err)
+	}
+}
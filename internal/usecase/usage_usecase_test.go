@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type mockTokenUsageRepo struct {
+	filter  *domain.UsageFilter
+	records []*domain.TokenUsageRecord
+}
+
+func (m *mockTokenUsageRepo) Aggregate(_ context.Context, filter domain.UsageFilter) ([]*domain.UsageStat, error) {
+	m.filter = &filter
+	return []*domain.UsageStat{{Model: filter.Model, PromptTokens: 10, CompletionTokens: 2, Messages: 1}}, nil
+}
+
+func (m *mockTokenUsageRepo) Record(_ context.Context, record *domain.TokenUsageRecord) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func TestUsageUseCase_GetTokenUsage(t *testing.T) {
+	repo := &mockTokenUsageRepo{}
+	uc := NewUsageUseCase(repo)
+
+	stats, err := uc.GetTokenUsage(context.Background(), domain.UsageFilter{GroupBy: domain.UsageGroupByModel, Model: "llama3"})
+	if err != nil {
+		t.Fatalf("GetTokenUsage: %v", err)
+	}
+
+	if len(stats) != 1 || stats[0].Model != "llama3" || repo.filter == nil || repo.filter.GroupBy != domain.UsageGroupByModel {
+		t.Errorf("GetTokenUsage: stats=%+v filter=%+v", stats, repo.filter)
+	}
+}
+
+func TestUsageUseCase_GetTokenUsage_invalidFilter(t *testing.T) {
+	repo := &mockTokenUsageRepo{}
+	uc := NewUsageUseCase(repo)
+
+	if _, err := uc.GetTokenUsage(context.Background(), domain.UsageFilter{GroupBy: "week"}); !errors.Is(err, domain.ErrInvalidUsageFilter) {
+		t.Errorf("ожидалась ErrInvalidUsageFilter, получено %v", err)
+	}
+
+	if repo.filter != nil {
+		t.Error("некорректный фильтр не должен доходить до репозитория")
+	}
+}
+
+func TestUsageUseCase_Record(t *testing.T) {
+	repo := &mockTokenUsageRepo{}
+	uc := NewUsageUseCase(repo)
+	ctx := context.Background()
+
+	uc.Record(ctx, 1, "llama3", domain.TokenUsageSourceEditor, nil)
+	uc.Record(ctx, 1, "llama3", domain.TokenUsageSourceEditor, &domain.TokenUsage{})
+	uc.Record(ctx, 1, "llama3", domain.TokenUsageSourceEditor, &domain.TokenUsage{PromptTokens: 7, CompletionTokens: 3})
+
+	if len(repo.records) != 1 {
+		t.Fatalf("пустой расход не должен записываться: %d записей", len(repo.records))
+	}
+
+	got := repo.records[0]
+	if got.UserId != 1 || got.Model != "llama3" || got.Source != domain.TokenUsageSourceEditor || got.Usage.Total() != 10 {
+		t.Errorf("неверная запись расхода: %+v", got)
+	}
+
+	var disabled *UsageUseCase
+	disabled.Record(ctx, 1, "llama3", domain.TokenUsageSourceEditor, &domain.TokenUsage{PromptTokens: 1})
+}
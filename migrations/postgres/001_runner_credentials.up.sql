@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS runner_credentials
+(
+    id           UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    name         VARCHAR(255) NOT NULL,
+    address      VARCHAR(255) NOT NULL DEFAULT '',
+    token_hash   VARCHAR(64)  NOT NULL UNIQUE,
+    created_by   INTEGER      NOT NULL REFERENCES users (id),
+    created_at   TIMESTAMP    NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP    NULL,
+    revoked_at   TIMESTAMP    NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_runner_credentials_token_hash ON runner_credentials (token_hash);
+CREATE INDEX IF NOT EXISTS idx_runner_credentials_created_at ON runner_credentials (created_at);
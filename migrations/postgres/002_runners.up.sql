@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS runners
+(
+    address      VARCHAR(255) PRIMARY KEY,
+    enabled      BOOLEAN      NOT NULL DEFAULT TRUE,
+    labels       JSONB        NOT NULL DEFAULT '{}',
+    last_seen_at TIMESTAMP    NULL,
+    created_at   TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP    NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,2 @@
+ALTER TABLE chat_sessions
+    ADD COLUMN IF NOT EXISTS generation_options JSONB NULL;
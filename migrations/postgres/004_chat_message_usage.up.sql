@@ -0,0 +1,4 @@
+ALTER TABLE chat_session_messages
+    ADD COLUMN IF NOT EXISTS model             VARCHAR(255) NULL,
+    ADD COLUMN IF NOT EXISTS prompt_tokens     INTEGER      NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS completion_tokens INTEGER      NOT NULL DEFAULT 0;
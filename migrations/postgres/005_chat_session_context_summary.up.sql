@@ -0,0 +1,3 @@
+ALTER TABLE chat_sessions
+    ADD COLUMN IF NOT EXISTS context_summary       TEXT      NULL,
+    ADD COLUMN IF NOT EXISTS context_summary_until TIMESTAMP NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE chat_sessions
+    ADD COLUMN IF NOT EXISTS title_manual BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,26 @@
+ALTER TABLE chat_session_messages
+    ADD COLUMN IF NOT EXISTS parent_id UUID NULL REFERENCES chat_session_messages (id);
+
+ALTER TABLE chat_sessions
+    ADD COLUMN IF NOT EXISTS active_message_id          UUID NULL,
+    ADD COLUMN IF NOT EXISTS context_summary_message_id UUID NULL;
+
+CREATE INDEX IF NOT EXISTS idx_chat_session_messages_parent_id ON chat_session_messages (session_id, parent_id);
+
+UPDATE chat_session_messages m
+SET parent_id = p.prev_id
+FROM (SELECT id, LAG(id) OVER (PARTITION BY session_id ORDER BY created_at, id) AS prev_id
+      FROM chat_session_messages
+      WHERE deleted_at IS NULL) p
+WHERE m.id = p.id
+  AND m.parent_id IS NULL
+  AND p.prev_id IS NOT NULL;
+
+UPDATE chat_sessions s
+SET active_message_id = (SELECT m.id
+                         FROM chat_session_messages m
+                         WHERE m.session_id = s.id
+                           AND m.deleted_at IS NULL
+                         ORDER BY m.created_at DESC, m.id DESC
+                         LIMIT 1)
+WHERE s.active_message_id IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE chat_session_messages
+    ADD COLUMN IF NOT EXISTS stopped       BOOLEAN     NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS finish_reason VARCHAR(32) NULL;
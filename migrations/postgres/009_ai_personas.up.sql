@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS ai_personas
+(
+    id                 UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    user_id            INTEGER      NOT NULL REFERENCES users (id),
+    name               VARCHAR(255) NOT NULL,
+    system_prompt      TEXT         NOT NULL,
+    model              VARCHAR(255) NOT NULL DEFAULT '',
+    generation_options JSONB        NULL,
+    shared             BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at         TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMP    NOT NULL DEFAULT NOW(),
+    deleted_at         TIMESTAMP    NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_personas_user_id ON ai_personas (user_id);
+CREATE INDEX IF NOT EXISTS idx_ai_personas_shared ON ai_personas (shared) WHERE deleted_at IS NULL;
+
+ALTER TABLE chat_sessions
+    ADD COLUMN IF NOT EXISTS persona_id UUID NULL REFERENCES ai_personas (id) ON DELETE SET NULL;
@@ -0,0 +1,41 @@
+CREATE TABLE IF NOT EXISTS knowledge_bases
+(
+    id              UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    user_id         INTEGER      NOT NULL REFERENCES users (id),
+    name            VARCHAR(255) NOT NULL,
+    embedding_model VARCHAR(255) NOT NULL DEFAULT '',
+    created_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+    deleted_at      TIMESTAMP    NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_bases_user_id ON knowledge_bases (user_id);
+
+CREATE TABLE IF NOT EXISTS knowledge_documents
+(
+    id                UUID PRIMARY KEY      DEFAULT gen_random_uuid(),
+    knowledge_base_id UUID         NOT NULL REFERENCES knowledge_bases (id) ON DELETE CASCADE,
+    file_id           UUID         NULL REFERENCES files (id) ON DELETE SET NULL,
+    name              VARCHAR(255) NOT NULL,
+    chunks            INTEGER      NOT NULL DEFAULT 0,
+    created_at        TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_documents_knowledge_base_id ON knowledge_documents (knowledge_base_id);
+
+CREATE TABLE IF NOT EXISTS knowledge_chunks
+(
+    id                UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    knowledge_base_id UUID    NOT NULL REFERENCES knowledge_bases (id) ON DELETE CASCADE,
+    document_id       UUID    NOT NULL REFERENCES knowledge_documents (id) ON DELETE CASCADE,
+    position          INTEGER NOT NULL,
+    start_offset      INTEGER NOT NULL,
+    end_offset        INTEGER NOT NULL,
+    content           TEXT    NOT NULL,
+    embedding         BYTEA   NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_knowledge_chunks_knowledge_base_id ON knowledge_chunks (knowledge_base_id);
+
+ALTER TABLE chat_sessions
+    ADD COLUMN IF NOT EXISTS knowledge_base_id UUID NULL REFERENCES knowledge_bases (id) ON DELETE SET NULL;
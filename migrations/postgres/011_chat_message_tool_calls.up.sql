@@ -0,0 +1,4 @@
+ALTER TABLE chat_session_messages
+    ADD COLUMN IF NOT EXISTS tool_calls   JSONB        NULL,
+    ADD COLUMN IF NOT EXISTS tool_call_id VARCHAR(255) NULL,
+    ADD COLUMN IF NOT EXISTS tool_name    VARCHAR(64)  NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS ai_token_usage
+(
+    id                BIGSERIAL PRIMARY KEY,
+    user_id           INTEGER      NOT NULL,
+    model             VARCHAR(255) NOT NULL DEFAULT '',
+    source            VARCHAR(32)  NOT NULL,
+    prompt_tokens     INTEGER      NOT NULL DEFAULT 0,
+    completion_tokens INTEGER      NOT NULL DEFAULT 0,
+    created_at        TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_token_usage_created_at ON ai_token_usage (created_at);
+CREATE INDEX IF NOT EXISTS idx_ai_token_usage_user_id ON ai_token_usage (user_id, created_at);
@@ -2,6 +2,8 @@ package encrypt
 
 import (
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 )
 
@@ -11,3 +13,18 @@ func Md5(str string) string {
 
 	return hex.EncodeToString(h.Sum(nil))
 }
+
+func Sha256(str string) string {
+	sum := sha256.Sum256([]byte(str))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func RandomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -42,3 +42,27 @@ func TestMd5_deterministic(t *testing.T) {
 		t.Errorf("Md5 должен быть детерминированным: %q != %q", a, b)
 	}
 }
+
+func TestSha256(t *testing.T) {
+	got := Sha256("hello")
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got != want {
+		t.Errorf("Sha256(%q) = %q, ожидалось %q", "hello", got, want)
+	}
+}
+
+func TestRandomHex(t *testing.T) {
+	a, err := RandomHex(16)
+	if err != nil {
+		t.Fatalf("RandomHex: %v", err)
+	}
+
+	if len(a) != 32 {
+		t.Errorf("RandomHex(16) длина = %d, ожидалось 32", len(a))
+	}
+
+	b, _ := RandomHex(16)
+	if a == b {
+		t.Error("RandomHex должен возвращать разные значения")
+	}
+}
@@ -10,14 +10,39 @@ import (
 const (
 	EngineOllama = "ollama"
 	EngineLlama  = "llama"
+	EngineOpenAI = "openai"
 )
 
 type Ollama struct {
 	BaseURL string `yaml:"base_url"`
 }
 
+type OpenAI struct {
+	BaseURL string   `yaml:"base_url"`
+	APIKey  string   `yaml:"api_key"`
+	Models  []string `yaml:"models"`
+}
+
 type Llama struct {
-	ModelPath string `yaml:"model_path"`
+	ModelPath       string            `yaml:"model_path"`
+	ChatTemplates   map[string]string `yaml:"chat_templates"`
+	MemoryBudgetMB  int64             `yaml:"memory_budget_mb"`
+	EmbeddingModels []string          `yaml:"embedding_models"`
+}
+
+type TLS struct {
+	Enabled      bool   `yaml:"enabled"`
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+}
+
+type CoreTLS struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"ca_file"`
+	CertFile   string `yaml:"cert_file"`
+	KeyFile    string `yaml:"key_file"`
+	ServerName string `yaml:"server_name"`
 }
 
 type LogConfig struct {
@@ -32,6 +57,9 @@ type Config struct {
 	Engine             string    `yaml:"engine"`
 	Ollama             Ollama    `yaml:"ollama"`
 	Llama              Llama     `yaml:"llama"`
+	OpenAI             OpenAI    `yaml:"openai"`
+	TLS                TLS       `yaml:"tls"`
+	CoreTLS            CoreTLS   `yaml:"core_tls"`
 }
 
 func Load() (*Config, error) {
@@ -0,0 +1,59 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"github.com/magomedcoder/legion/internal/delivery/mappers"
+	"github.com/magomedcoder/legion/pkg/logger"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (p *Pool) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	req := &aichatpb.EmbedRequest{
+		Model:  model,
+		Inputs: inputs,
+	}
+
+	tried := make(map[string]bool)
+	var lastErr error
+	for attempt := 1; attempt <= p.generateAttempts; attempt++ {
+		addr, err := p.acquireRunner(ctx, model, tried)
+		if err != nil {
+			if lastErr == nil {
+				logger.W("Pool: нет раннера для эмбеддингов (модель %q): %v", model, err)
+				return nil, err
+			}
+			break
+		}
+		tried[addr] = true
+
+		resp, err := p.embedOn(ctx, addr, req)
+		p.releaseRunner(addr)
+		if err == nil {
+			if len(resp.Embeddings) != len(inputs) {
+				return nil, fmt.Errorf("runner %s: получено %d эмбеддингов вместо %d", addr, len(resp.Embeddings), len(inputs))
+			}
+			return mappers.EmbeddingsFromProto(resp.Embeddings), nil
+		}
+
+		lastErr = fmt.Errorf("runner %s: %w", addr, err)
+		if ctx.Err() != nil || !(isRetryableGenerateError(err) || status.Code(err) == codes.Unimplemented) {
+			return nil, lastErr
+		}
+		logger.W("Pool: раннер %s не вернул эмбеддинги: %v", addr, err)
+	}
+
+	return nil, lastErr
+}
+
+func (p *Pool) embedOn(ctx context.Context, address string, req *aichatpb.EmbedRequest) (*aichatpb.EmbedResponse, error) {
+	client, err := p.getConn(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Embed(ctx, req)
+}
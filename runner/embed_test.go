@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPool_Embed(t *testing.T) {
+	tp := &fakeTextProvider{models: []string{"nomic"}}
+	p := NewPool([]string{startFakeRunner(t, tp)})
+
+	vectors, err := p.Embed(context.Background(), "nomic", []string{"ab", "abcd"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	if len(vectors) != 2 || vectors[0][0] != 2 || vectors[1][0] != 4 {
+		t.Errorf("неверные эмбеддинги: %v", vectors)
+	}
+}
+
+func TestPool_Embed_failoverOnUnsupported(t *testing.T) {
+	unsupported := &fakeTextProvider{models: []string{"nomic"}, noEmbed: true}
+	supported := &fakeTextProvider{models: []string{"nomic"}}
+	p := NewPool([]string{startFakeRunner(t, unsupported), startFakeRunner(t, supported)}, WithGenerateAttempts(2))
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Embed(context.Background(), "nomic", []string{"a"}); err != nil {
+			t.Fatalf("Embed: %v", err)
+		}
+	}
+
+	if supported.embeds.Load() != 2 {
+		t.Errorf("запросы должны обслуживаться раннером с эмбеддингами: %d", supported.embeds.Load())
+	}
+}
+
+func TestServer_Embed_invalidInput(t *testing.T) {
+	s := NewServer(&fakeTextProvider{}, nil)
+
+	_, err := s.Embed(context.Background(), &aichatpb.EmbedRequest{Model: "nomic"})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("код %v, ожидался InvalidArgument", code)
+	}
+}
@@ -0,0 +1,178 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/magomedcoder/legion/api/pb/commonpb"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	defaultHealthCheckInterval = 10 * time.Second
+	defaultHealthCheckTimeout  = 3 * time.Second
+	defaultHealthCheckFailures = 3
+)
+
+type runnerHealth struct {
+	healthy   bool
+	failures  int
+	latency   time.Duration
+	lastCheck time.Time
+	lastError string
+}
+
+type RunnerHealth struct {
+	Address             string
+	Healthy             bool
+	ConsecutiveFailures int
+	Latency             time.Duration
+	LastCheckAt         time.Time
+	LastError           string
+}
+
+func WithHealthCheck(interval, timeout time.Duration, failures int) PoolOption {
+	return func(p *Pool) {
+		if interval > 0 {
+			p.healthInterval = interval
+		}
+
+		if timeout > 0 {
+			p.healthTimeout = timeout
+		}
+
+		if failures > 0 {
+			p.healthFailures = failures
+		}
+	}
+}
+
+func (p *Pool) StartHealthCheck(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(p.healthInterval)
+		defer ticker.Stop()
+
+		p.CheckHealth(ctx)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.CheckHealth(ctx)
+			}
+		}
+	}()
+}
+
+func (p *Pool) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, addr := range p.enabledAddresses() {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := p.ping(ctx, addr)
+			p.recordProbe(ctx, addr, time.Since(start), err)
+			if err == nil && p.scheduling == SchedulingLeastLoaded {
+				p.GetGpuInfo(ctx, addr)
+			}
+		}(addr)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) ping(ctx context.Context, address string) error {
+	client, err := p.getConn(ctx, address)
+	if err != nil {
+		return err
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, p.healthTimeout)
+	defer cancel()
+
+	resp, err := client.Ping(reqCtx, &commonpb.Empty{})
+	if err != nil {
+		return err
+	}
+
+	if resp == nil || !resp.Ok {
+		return fmt.Errorf("движок раннера не отвечает")
+	}
+
+	return nil
+}
+
+func (p *Pool) recordProbe(ctx context.Context, address string, latency time.Duration, probeErr error) {
+	p.mu.Lock()
+	if !p.hasAddressLocked(address) {
+		p.mu.Unlock()
+		return
+	}
+
+	h, ok := p.health[address]
+	if !ok {
+		h = &runnerHealth{healthy: true}
+		p.health[address] = h
+	}
+
+	h.lastCheck = time.Now()
+	recovered, evicted := false, false
+	if probeErr == nil {
+		recovered = !h.healthy
+		h.healthy = true
+		h.failures = 0
+		h.latency = latency
+		h.lastError = ""
+	} else {
+		h.failures++
+		h.lastError = probeErr.Error()
+		if h.healthy && h.failures >= p.healthFailures {
+			h.healthy = false
+			evicted = true
+		}
+	}
+	failures := h.failures
+	p.mu.Unlock()
+
+	switch {
+	case evicted:
+		logger.W("Pool: раннер %s исключён после %d неудачных проверок: %v", address, failures, probeErr)
+		p.closeConn(address)
+	case recovered:
+		logger.I("Pool: раннер %s снова доступен (%s)", address, latency)
+		p.refreshModels(ctx, []string{address})
+	case probeErr != nil:
+		logger.D("Pool: проверка раннера %s не удалась (%d): %v", address, failures, probeErr)
+	}
+}
+
+func (p *Pool) isHealthyLocked(address string) bool {
+	h, ok := p.health[address]
+	return !ok || h.healthy
+}
+
+func (p *Pool) GetHealth() []RunnerHealth {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]RunnerHealth, 0, len(p.addresses))
+	for _, a := range p.addresses {
+		item := RunnerHealth{
+			Address: a,
+			Healthy: true,
+		}
+		if h, ok := p.health[a]; ok {
+			item.Healthy = h.healthy
+			item.ConsecutiveFailures = h.failures
+			item.Latency = h.latency
+			item.LastCheckAt = h.lastCheck
+			item.LastError = h.lastError
+		}
+		out = append(out, item)
+	}
+
+	return out
+}
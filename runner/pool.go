@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/magomedcoder/legion/api/pb/aichatpb"
 	"github.com/magomedcoder/legion/api/pb/commonpb"
@@ -10,25 +11,81 @@ import (
 	"github.com/magomedcoder/legion/internal/domain"
 	"github.com/magomedcoder/legion/pkg/logger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"io"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
+)
+
+const (
+	modelsRefreshTimeout    = 5 * time.Second
+	defaultGenerateAttempts = 3
 )
 
 type Pool struct {
-	addresses []string
-	disabled  map[string]bool
-	mu        sync.RWMutex
-	index     atomic.Uint32
-	conns     map[string]*grpc.ClientConn
-	connMu    sync.Mutex
+	addresses        []string
+	disabled         map[string]bool
+	models           map[string][]string
+	health           map[string]*runnerHealth
+	mu               sync.RWMutex
+	index            atomic.Uint32
+	conns            map[string]*grpc.ClientConn
+	connMu           sync.Mutex
+	transportCreds   credentials.TransportCredentials
+	healthInterval   time.Duration
+	healthTimeout    time.Duration
+	healthFailures   int
+	generateAttempts int
+	scheduling       string
+	maxConcurrency   int
+	concurrency      map[string]int
+	queueTimeout     time.Duration
+	inflight         map[string]int
+	gpuUtilization   map[string]uint32
+	reportedLoad     map[string]int
+	lastSeen         map[string]time.Time
+	labels           map[string]map[string]string
+	repo             domain.RunnerRepository
+	released         chan struct{}
+}
+
+type PoolOption func(*Pool)
+
+func WithGenerateAttempts(n int) PoolOption {
+	return func(p *Pool) {
+		if n > 0 {
+			p.generateAttempts = n
+		}
+	}
 }
 
-func NewPool(addresses []string) *Pool {
+func NewPool(addresses []string, opts ...PoolOption) *Pool {
 	p := &Pool{
-		addresses: make([]string, 0, len(addresses)),
-		disabled:  make(map[string]bool),
-		conns:     make(map[string]*grpc.ClientConn),
+		addresses:        make([]string, 0, len(addresses)),
+		disabled:         make(map[string]bool),
+		models:           make(map[string][]string),
+		health:           make(map[string]*runnerHealth),
+		conns:            make(map[string]*grpc.ClientConn),
+		transportCreds:   insecure.NewCredentials(),
+		healthInterval:   defaultHealthCheckInterval,
+		healthTimeout:    defaultHealthCheckTimeout,
+		healthFailures:   defaultHealthCheckFailures,
+		generateAttempts: defaultGenerateAttempts,
+		scheduling:       SchedulingRoundRobin,
+		concurrency:      make(map[string]int),
+		queueTimeout:     defaultQueueTimeout,
+		inflight:         make(map[string]int),
+		gpuUtilization:   make(map[string]uint32),
+		reportedLoad:     make(map[string]int),
+		lastSeen:         make(map[string]time.Time),
+		labels:           make(map[string]map[string]string),
+		released:         make(chan struct{}),
 	}
 
 	for _, a := range addresses {
@@ -37,6 +94,10 @@ func NewPool(addresses []string) *Pool {
 		}
 	}
 
+	for _, opt := range opts {
+		opt(p)
+	}
+
 	return p
 }
 
@@ -45,30 +106,47 @@ func (p *Pool) Add(address string) {
 		return
 	}
 
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	for _, a := range p.addresses {
-		if a == address {
-			return
-		}
+	p.mu.RLock()
+	known := p.hasAddressLocked(address)
+	p.mu.RUnlock()
+	if known {
+		return
 	}
 
+	stored := p.lookup(address)
+
+	p.mu.Lock()
+	if p.hasAddressLocked(address) {
+		p.mu.Unlock()
+		return
+	}
 	p.addresses = append(p.addresses, address)
+	if stored != nil {
+		p.applyStoredLocked(stored)
+	}
+	p.mu.Unlock()
+
+	p.persist(address)
 }
 
 func (p *Pool) Remove(address string) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	for i, a := range p.addresses {
 		if a == address {
 			p.addresses = append(p.addresses[:i], p.addresses[i+1:]...)
 			break
 		}
 	}
-
+	delete(p.disabled, address)
+	delete(p.models, address)
+	delete(p.health, address)
+	delete(p.inflight, address)
+	delete(p.gpuUtilization, address)
+	delete(p.reportedLoad, address)
+	delete(p.lastSeen, address)
+	delete(p.labels, address)
 	p.closeConn(address)
+	p.mu.Unlock()
 }
 
 func (p *Pool) closeConn(address string) {
@@ -89,7 +167,7 @@ func (p *Pool) getConn(ctx context.Context, address string) (runnerpb.RunnerServ
 		return runnerpb.NewRunnerServiceClient(conn), nil
 	}
 
-	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(p.transportCreds))
 	if err != nil {
 		logger.W("Pool: ошибка подключения к раннеру %s: %v", address, err)
 		return nil, fmt.Errorf("подключение к раннеру %s: %w", address, err)
@@ -115,14 +193,144 @@ func (p *Pool) enabledAddresses() []string {
 	return out
 }
 
-func (p *Pool) pickRunner() (string, bool) {
-	addrs := p.enabledAddresses()
+func (p *Pool) hasAddressLocked(address string) bool {
+	for _, a := range p.addresses {
+		if a == address {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Pool) availableAddresses() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]string, 0, len(p.addresses))
+	for _, a := range p.addresses {
+		if !p.disabled[a] && p.isHealthyLocked(a) {
+			out = append(out, a)
+		}
+	}
+
+	return out
+}
+
+func (p *Pool) setModels(address string, models []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.hasAddressLocked(address) {
+		return
+	}
+
+	list := make([]string, len(models))
+	copy(list, models)
+	p.models[address] = list
+}
+
+func (p *Pool) ApplyHeartbeat(address string, models []string, inFlight int, gpus []*runnerpb.GpuInfo) {
+	if address == "" {
+		return
+	}
+
+	p.Add(address)
+	if len(models) > 0 {
+		p.setModels(address, models)
+	}
+	p.setGpuUtilization(address, gpus)
+
+	p.mu.Lock()
+	known := p.hasAddressLocked(address)
+	if known {
+		p.reportedLoad[address] = inFlight
+		p.lastSeen[address] = time.Now()
+	}
+	p.mu.Unlock()
+
+	if known {
+		p.persist(address)
+	}
+}
+
+func (p *Pool) refreshModels(ctx context.Context, addrs []string) {
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+
+			client, err := p.getConn(ctx, addr)
+			if err != nil {
+				return
+			}
+
+			reqCtx, cancel := context.WithTimeout(ctx, modelsRefreshTimeout)
+			defer cancel()
+
+			resp, err := client.GetModels(reqCtx, &commonpb.Empty{})
+			if err != nil || resp == nil {
+				logger.W("Pool: не удалось получить модели раннера %s: %v", addr, err)
+				return
+			}
+
+			p.setModels(addr, resp.Models)
+		}(addr)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) RefreshModels(ctx context.Context) {
+	p.refreshModels(ctx, p.availableAddresses())
+}
+
+func (p *Pool) runnersForModel(model string, exclude map[string]bool) []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]string, 0, len(p.addresses))
+	for _, a := range p.addresses {
+		if p.disabled[a] || exclude[a] || !p.isHealthyLocked(a) {
+			continue
+		}
+
+		if model == "" {
+			out = append(out, a)
+			continue
+		}
+
+		for _, m := range p.models[a] {
+			if m == model {
+				out = append(out, a)
+				break
+			}
+		}
+	}
+
+	return out
+}
+
+func (p *Pool) eligibleRunners(ctx context.Context, model string, exclude map[string]bool) ([]string, error) {
+	if !p.HasActiveRunners() {
+		return nil, fmt.Errorf("нет доступных раннеров")
+	}
+
+	addrs := p.runnersForModel(model, exclude)
+	if len(addrs) == 0 && len(exclude) == 0 {
+		p.RefreshModels(ctx)
+		addrs = p.runnersForModel(model, exclude)
+	}
+
+	if len(addrs) == 0 && len(exclude) > 0 {
+		return nil, fmt.Errorf("нет других раннеров с моделью %q", model)
+	}
+
 	if len(addrs) == 0 {
-		return "", false
+		return nil, fmt.Errorf("модель %q не найдена ни на одном раннере", model)
 	}
 
-	i := p.index.Add(1) % uint32(len(addrs))
-	return addrs[i], true
+	return addrs, nil
 }
 
 func (p *Pool) GetRunners() []RunnerInfo {
@@ -133,6 +341,24 @@ func (p *Pool) GetRunners() []RunnerInfo {
 	for k, v := range p.disabled {
 		disabledCopy[k] = v
 	}
+	modelsCopy := make(map[string][]string, len(p.models))
+	for k, v := range p.models {
+		modelsCopy[k] = append([]string(nil), v...)
+	}
+	healthyCopy := make(map[string]bool, len(addrs))
+	inflightCopy := make(map[string]int, len(addrs))
+	limitCopy := make(map[string]int, len(addrs))
+	reportedCopy := make(map[string]int, len(addrs))
+	lastSeenCopy := make(map[string]time.Time, len(addrs))
+	labelsCopy := make(map[string]map[string]string, len(addrs))
+	for _, a := range addrs {
+		labelsCopy[a] = copyLabels(p.labels[a])
+		healthyCopy[a] = p.isHealthyLocked(a)
+		inflightCopy[a] = p.inflight[a]
+		limitCopy[a] = p.limitLocked(a)
+		reportedCopy[a] = p.reportedLoad[a]
+		lastSeenCopy[a] = p.lastSeen[a]
+	}
 	p.mu.RUnlock()
 
 	p.connMu.Lock()
@@ -146,9 +372,16 @@ func (p *Pool) GetRunners() []RunnerInfo {
 	for i, a := range addrs {
 		enabled := !disabledCopy[a]
 		out[i] = RunnerInfo{
-			Address:   a,
-			Enabled:   enabled,
-			Connected: connStatus[a] && enabled,
+			Address:          a,
+			Enabled:          enabled,
+			Healthy:          healthyCopy[a],
+			Connected:        connStatus[a] && enabled && healthyCopy[a],
+			Models:           modelsCopy[a],
+			InFlight:         inflightCopy[a],
+			MaxConcurrency:   limitCopy[a],
+			ReportedInFlight: reportedCopy[a],
+			LastSeenAt:       lastSeenCopy[a],
+			Labels:           labelsCopy[a],
 		}
 	}
 
@@ -157,33 +390,42 @@ func (p *Pool) GetRunners() []RunnerInfo {
 
 func (p *Pool) SetRunnerEnabled(address string, enabled bool) {
 	p.mu.Lock()
-	defer p.mu.Unlock()
+	if !p.hasAddressLocked(address) {
+		p.mu.Unlock()
+		return
+	}
 
-	for _, a := range p.addresses {
-		if a == address {
-			if enabled {
-				delete(p.disabled, address)
-			} else {
-				p.disabled[address] = true
-				p.closeConn(address)
-			}
-			return
-		}
+	if enabled {
+		delete(p.disabled, address)
+		delete(p.health, address)
+	} else {
+		p.disabled[address] = true
+		p.closeConn(address)
 	}
+	p.mu.Unlock()
+
+	p.persist(address)
 }
 
 func (p *Pool) HasActiveRunners() bool {
-	return len(p.enabledAddresses()) > 0
+	return len(p.availableAddresses()) > 0
 }
 
 type RunnerInfo struct {
-	Address   string
-	Enabled   bool
-	Connected bool
+	Address          string
+	Enabled          bool
+	Healthy          bool
+	Connected        bool
+	Models           []string
+	InFlight         int
+	MaxConcurrency   int
+	ReportedInFlight int
+	LastSeenAt       time.Time
+	Labels           map[string]string
 }
 
 func (p *Pool) CheckConnection(ctx context.Context) (bool, error) {
-	addrs := p.enabledAddresses()
+	addrs := p.availableAddresses()
 	if len(addrs) == 0 {
 		return false, fmt.Errorf("нет активных раннеров")
 	}
@@ -204,24 +446,61 @@ func (p *Pool) CheckConnection(ctx context.Context) (bool, error) {
 }
 
 func (p *Pool) GetModels(ctx context.Context) ([]string, error) {
-	addrs := p.enabledAddresses()
+	infos, err := p.GetModelsInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name
+	}
+
+	return names, nil
+}
+
+func (p *Pool) GetModelsInfo(ctx context.Context) ([]*domain.AIModel, error) {
+	addrs := p.availableAddresses()
 	if len(addrs) == 0 {
 		return nil, fmt.Errorf("нет активных раннеров")
 	}
 
+	p.refreshModels(ctx, addrs)
+
+	p.mu.RLock()
+	byName := make(map[string]*domain.AIModel)
+	known := false
 	for _, addr := range addrs {
-		client, err := p.getConn(ctx, addr)
-		if err != nil {
+		list, ok := p.models[addr]
+		if !ok {
 			continue
 		}
-
-		resp, err := client.GetModels(ctx, &commonpb.Empty{})
-		if err == nil && resp != nil {
-			return resp.Models, nil
+		known = true
+		for _, name := range list {
+			m, ok := byName[name]
+			if !ok {
+				m = &domain.AIModel{Name: name}
+				byName[name] = m
+			}
+			m.Runners = append(m.Runners, addr)
 		}
 	}
+	p.mu.RUnlock()
 
-	return nil, fmt.Errorf("ни один раннер не вернул список моделей")
+	if !known {
+		return nil, fmt.Errorf("ни один раннер не вернул список моделей")
+	}
+
+	out := make([]*domain.AIModel, 0, len(byName))
+	for _, m := range byName {
+		sort.Strings(m.Runners)
+		out = append(out, m)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+
+	return out, nil
 }
 
 func (p *Pool) GetGpuInfo(ctx context.Context, address string) *runnerpb.GetGpuInfoResponse {
@@ -234,6 +513,7 @@ func (p *Pool) GetGpuInfo(ctx context.Context, address string) *runnerpb.GetGpuI
 	if err != nil || resp == nil {
 		return nil
 	}
+	p.setGpuUtilization(address, resp.Gpus)
 
 	return resp
 }
@@ -248,47 +528,102 @@ func (p *Pool) GetServerInfo(ctx context.Context, address string) *runnerpb.Serv
 	if err != nil || resp == nil {
 		return nil
 	}
+	p.setModels(address, resp.Models)
 
 	return resp
 }
 
-func (p *Pool) SendMessage(ctx context.Context, sessionID string, model string, messages []*domain.AIChatMessage) (chan string, error) {
-	addr, ok := p.pickRunner()
-	if !ok {
-		logger.W("Pool: нет доступных раннеров для сессии %s", sessionID)
-		return nil, fmt.Errorf("нет доступных раннеров")
-	}
+func (p *Pool) SendMessage(ctx context.Context, sessionID string, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch, usage, _, err := p.generate(ctx, sessionID, model, messages, nil, opts)
+	return ch, usage, err
+}
 
-	logger.V("Pool: выбран раннер %s для сессии %s", addr, sessionID)
-	client, err := p.getConn(ctx, addr)
-	if err != nil {
-		return nil, err
-	}
+func (p *Pool) SendMessageWithTools(ctx context.Context, sessionID string, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	return p.generate(ctx, sessionID, model, messages, tools, opts)
+}
 
+func (p *Pool) generate(ctx context.Context, sessionID string, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
 	protoMessages := make([]*aichatpb.ChatMessage, len(messages))
 	for i, m := range messages {
 		protoMessages[i] = mappers.AIMessageToProto(m)
 	}
 	req := &runnerpb.GenerateRequest{
-		SessionId: sessionID,
-		Messages:  protoMessages,
-		Model:     model,
+		SessionId:         sessionID,
+		Messages:          protoMessages,
+		Model:             model,
+		GenerationOptions: mappers.GenerationOptionsToProto(opts),
+		Tools:             mappers.AIToolsToProto(tools),
+	}
+
+	tried := make(map[string]bool)
+	attempted := make([]string, 0, p.generateAttempts)
+	var lastErr error
+	for attempt := 1; attempt <= p.generateAttempts; attempt++ {
+		addr, err := p.acquireRunner(ctx, model, tried)
+		if err != nil {
+			if lastErr == nil {
+				logger.W("Pool: нет раннера для сессии %s (модель %q): %v", sessionID, model, err)
+				return nil, nil, nil, err
+			}
+			break
+		}
+		tried[addr] = true
+		attempted = append(attempted, addr)
+
+		logger.V("Pool: выбран раннер %s для сессии %s (попытка %d)", addr, sessionID, attempt)
+		stream, first, err := p.openGenerate(ctx, addr, req)
+		if err == nil {
+			if len(attempted) > 1 {
+				logger.I("Pool: сессия %s обслужена раннером %s, опробованы: %s", sessionID, addr, strings.Join(attempted, ", "))
+			}
+			ch, usage, toolCalls := p.forwardGenerate(ctx, addr, stream, first)
+			return ch, usage, toolCalls, nil
+		}
+		p.releaseRunner(addr)
+
+		lastErr = fmt.Errorf("runner %s: %w", addr, err)
+		if ctx.Err() != nil || !isRetryableGenerateError(err) {
+			return nil, nil, nil, lastErr
+		}
+		logger.W("Pool: раннер %s не начал генерацию для сессии %s: %v", addr, sessionID, err)
+	}
+
+	logger.E("Pool: генерация для сессии %s не удалась, опробованы раннеры: %s", sessionID, strings.Join(attempted, ", "))
+	return nil, nil, nil, lastErr
+}
+
+func (p *Pool) openGenerate(ctx context.Context, address string, req *runnerpb.GenerateRequest) (runnerpb.RunnerService_GenerateClient, *runnerpb.GenerateResponse, error) {
+	client, err := p.getConn(ctx, address)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	stream, err := client.Generate(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("runner %s: %w", addr, err)
+		return nil, nil, err
 	}
 
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, status.Error(codes.Unavailable, "раннер закрыл поток без ответа")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stream, first, nil
+}
+
+func (p *Pool) forwardGenerate(ctx context.Context, address string, stream runnerpb.RunnerService_GenerateClient, first *runnerpb.GenerateResponse) (chan string, *domain.TokenUsage, *domain.AIToolCalls) {
 	out := make(chan string, 100)
+	usage := &domain.TokenUsage{}
+	toolCalls := &domain.AIToolCalls{}
 	go func() {
 		defer close(out)
-		for {
-			resp, err := stream.Recv()
-			if err != nil {
-				return
-			}
+		defer p.releaseRunner(address)
 
+		resp := first
+		for {
 			if resp.Content != "" {
 				select {
 				case <-ctx.Done():
@@ -297,10 +632,24 @@ func (p *Pool) SendMessage(ctx context.Context, sessionID string, model string,
 				}
 			}
 			if resp.Done {
+				if u := mappers.TokenUsageFromProto(resp.Usage); u != nil {
+					*usage = *u
+				}
+				toolCalls.Calls = mappers.AIToolCallsFromProto(resp.ToolCalls)
+				return
+			}
+
+			var err error
+			resp, err = stream.Recv()
+			if err != nil {
 				return
 			}
 		}
 	}()
 
-	return out, nil
+	return out, usage, toolCalls
+}
+
+func isRetryableGenerateError(err error) bool {
+	return status.Code(err) == codes.Unavailable
 }
@@ -2,9 +2,113 @@ package runner
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/api/pb/runnerpb"
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/runner/provider"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+type fakeTextProvider struct {
+	models  []string
+	reply   string
+	fail    bool
+	err     error
+	block   chan struct{}
+	down    atomic.Bool
+	calls   atomic.Int32
+	opts    atomic.Pointer[domain.GenerationOptions]
+	embeds  atomic.Int32
+	noEmbed bool
+}
+
+func (f *fakeTextProvider) Embed(_ context.Context, _ string, inputs []string) ([][]float32, error) {
+	f.embeds.Add(1)
+	if f.noEmbed {
+		return nil, domain.ErrEmbeddingsNotSupported
+	}
+
+	out := make([][]float32, len(inputs))
+	for i, in := range inputs {
+		out[i] = []float32{float32(len(in))}
+	}
+
+	return out, nil
+}
+
+func (f *fakeTextProvider) CheckConnection(context.Context) (bool, error) {
+	return !f.down.Load(), nil
+}
+
+func (f *fakeTextProvider) GetModels(context.Context) ([]string, error) {
+	return f.models, nil
+}
+
+func (f *fakeTextProvider) SendMessage(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	f.calls.Add(1)
+	f.opts.Store(opts)
+	if f.fail {
+		return nil, nil, fmt.Errorf("%w: ollama вернул статус 500", domain.ErrBackendUnavailable)
+	}
+
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+
+	usage := &domain.TokenUsage{PromptTokens: int32(len(messages)), CompletionTokens: 1}
+
+	ch := make(chan string, 1)
+	if f.block != nil {
+		go func() {
+			defer close(ch)
+			ch <- f.reply
+			select {
+			case <-f.block:
+			case <-ctx.Done():
+			}
+		}()
+		return ch, usage, nil
+	}
+
+	ch <- f.reply
+	close(ch)
+	return ch, usage, nil
+}
+
+func startFakeRunner(t *testing.T, tp provider.TextProvider) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	srv := grpc.NewServer()
+	runnerpb.RegisterRunnerServiceServer(srv, NewServer(tp, nil))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func collect(ch chan string) string {
+	var out string
+	for chunk := range ch {
+		out += chunk
+	}
+	return out
+}
+
 func TestNewPool(t *testing.T) {
 	p := NewPool(nil)
 	if p == nil {
@@ -57,7 +161,7 @@ func TestPool_Add_Remove(t *testing.T) {
 func TestPool_Add_emptyIgnored(t *testing.T) {
 	p := NewPool(nil)
 	p.Add("")
-	
+
 	if len(p.GetRunners()) != 0 {
 		t.Error("Add с пустым адресом не должен добавлять")
 	}
@@ -96,3 +200,362 @@ func TestPool_CheckConnection_noRunners(t *testing.T) {
 		t.Error("ожидалось false")
 	}
 }
+
+func TestPool_GetModelsInfo_unionAcrossRunners(t *testing.T) {
+	a := startFakeRunner(t, &fakeTextProvider{models: []string{"llama3", "qwen"}})
+	b := startFakeRunner(t, &fakeTextProvider{models: []string{"qwen", "gemma"}})
+	p := NewPool([]string{a, b})
+
+	infos, err := p.GetModelsInfo(context.Background())
+	if err != nil {
+		t.Fatalf("GetModelsInfo: %v", err)
+	}
+
+	if len(infos) != 3 {
+		t.Fatalf("ожидалось 3 модели, получено %d", len(infos))
+	}
+
+	byName := make(map[string][]string)
+	for _, m := range infos {
+		byName[m.Name] = m.Runners
+	}
+
+	if len(byName["qwen"]) != 2 {
+		t.Errorf("qwen должна быть на двух раннерах: %v", byName["qwen"])
+	}
+
+	if len(byName["llama3"]) != 1 || byName["llama3"][0] != a {
+		t.Errorf("llama3 должна быть только на %s: %v", a, byName["llama3"])
+	}
+
+	models, err := p.GetModels(context.Background())
+	if err != nil {
+		t.Fatalf("GetModels: %v", err)
+	}
+
+	if len(models) != 3 || models[0] != "gemma" {
+		t.Errorf("GetModels: получено %v", models)
+	}
+}
+
+func TestPool_SendMessage_routesByModel(t *testing.T) {
+	a := startFakeRunner(t, &fakeTextProvider{models: []string{"llama3"}, reply: "from-a"})
+	b := startFakeRunner(t, &fakeTextProvider{models: []string{"gemma"}, reply: "from-b"})
+	p := NewPool([]string{a, b})
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+	for i := 0; i < 4; i++ {
+		ch, _, err := p.SendMessage(context.Background(), "s", "gemma", msgs, nil)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+
+		if got := collect(ch); got != "from-b" {
+			t.Errorf("запрос к gemma ушёл не на тот раннер: %q", got)
+		}
+	}
+}
+
+func TestPool_SendMessage_forwardsGenerationOptions(t *testing.T) {
+	tp := &fakeTextProvider{models: []string{"llama3"}, reply: "ok"}
+	p := NewPool([]string{startFakeRunner(t, tp)})
+
+	temperature, maxTokens := float32(0.4), int32(64)
+	ch, usage, err := p.SendMessage(context.Background(), "s", "llama3", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser),
+	}, &domain.GenerationOptions{
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+		Stop:        []string{"\n\n"},
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	collect(ch)
+
+	if usage.PromptTokens != 1 || usage.CompletionTokens != 1 {
+		t.Errorf("usage раннера не передан: %+v", usage)
+	}
+
+	got := tp.opts.Load()
+	if got == nil || got.Temperature == nil || *got.Temperature != 0.4 || *got.MaxTokens != 64 || len(got.Stop) != 1 {
+		t.Errorf("параметры генерации не дошли до раннера: %+v", got)
+	}
+
+	if got.TopP != nil || got.Seed != nil {
+		t.Errorf("незаданные параметры должны остаться nil: %+v", got)
+	}
+}
+
+func TestPool_SendMessage_invalidOptionsNotRetried(t *testing.T) {
+	tp1 := &fakeTextProvider{models: []string{"llama3"}, reply: "a"}
+	tp2 := &fakeTextProvider{models: []string{"llama3"}, reply: "b"}
+	p := NewPool([]string{startFakeRunner(t, tp1), startFakeRunner(t, tp2)})
+
+	topP := float32(3)
+	_, _, err := p.SendMessage(context.Background(), "s", "llama3", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser),
+	}, &domain.GenerationOptions{TopP: &topP})
+	if status.Code(errors.Unwrap(err)) != codes.InvalidArgument {
+		t.Fatalf("ожидалась InvalidArgument, получено %v", err)
+	}
+
+	if tp1.calls.Load()+tp2.calls.Load() != 0 {
+		t.Error("некорректные параметры не должны доходить до движка")
+	}
+}
+
+func TestPool_SendMessage_unknownModel(t *testing.T) {
+	a := startFakeRunner(t, &fakeTextProvider{models: []string{"llama3"}})
+	p := NewPool([]string{a})
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+	if _, _, err := p.SendMessage(context.Background(), "s", "missing", msgs, nil); err == nil {
+		t.Fatal("ожидалась ошибка для модели, которой нет ни на одном раннере")
+	}
+}
+
+func TestPool_CheckHealth_evictsAndRecovers(t *testing.T) {
+	tp := &fakeTextProvider{models: []string{"llama3"}}
+	a := startFakeRunner(t, tp)
+	p := NewPool([]string{a}, WithHealthCheck(time.Minute, time.Second, 2))
+	ctx := context.Background()
+
+	tp.down.Store(true)
+	p.CheckHealth(ctx)
+	if !p.HasActiveRunners() {
+		t.Fatal("раннер не должен исключаться после одной неудачной проверки")
+	}
+
+	p.CheckHealth(ctx)
+	if p.HasActiveRunners() {
+		t.Fatal("раннер должен быть исключён после двух неудачных проверок")
+	}
+
+	health := p.GetHealth()
+	if len(health) != 1 || health[0].Healthy || health[0].ConsecutiveFailures != 2 || health[0].LastError == "" {
+		t.Errorf("неверное состояние здоровья: %+v", health)
+	}
+
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+	if _, _, err := p.SendMessage(ctx, "s", "llama3", msgs, nil); err == nil {
+		t.Error("исключённый раннер не должен выбираться")
+	}
+
+	tp.down.Store(false)
+	p.CheckHealth(ctx)
+	if !p.HasActiveRunners() {
+		t.Fatal("раннер должен вернуться после успешной проверки")
+	}
+
+	runners := p.GetRunners()
+	if len(runners) != 1 || !runners[0].Healthy {
+		t.Errorf("раннер должен быть здоров: %+v", runners)
+	}
+}
+
+func TestPool_CheckHealth_unreachableRunner(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+
+	p := NewPool([]string{addr}, WithHealthCheck(time.Minute, 200*time.Millisecond, 1))
+	p.CheckHealth(context.Background())
+
+	if p.HasActiveRunners() {
+		t.Error("недоступный раннер должен быть исключён")
+	}
+}
+
+func TestPool_SendMessage_failsOverBeforeFirstToken(t *testing.T) {
+	broken := &fakeTextProvider{models: []string{"llama3"}, fail: true}
+	a := startFakeRunner(t, broken)
+	b := startFakeRunner(t, &fakeTextProvider{models: []string{"llama3"}, reply: "from-b"})
+	p := NewPool([]string{a, b})
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+	for i := 0; i < 4; i++ {
+		ch, _, err := p.SendMessage(context.Background(), "s", "llama3", msgs, nil)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+
+		if got := collect(ch); got != "from-b" {
+			t.Errorf("ожидался ответ резервного раннера, получено %q", got)
+		}
+	}
+
+	if broken.calls.Load() == 0 {
+		t.Error("сбойный раннер должен был быть опробован")
+	}
+}
+
+func TestPool_SendMessage_backendErrorsNotRetried(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"unknown model", fmt.Errorf("%w: ollama вернул статус 404", domain.ErrModelNotFound), codes.NotFound},
+		{"bad request", fmt.Errorf("%w: openai вернул статус 400: prompt is too long", domain.ErrInvalidGenerationRequest), codes.InvalidArgument},
+		{"load failure", fmt.Errorf("%w: llama: не удалось загрузить модель", domain.ErrBackendNotReady), codes.FailedPrecondition},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			failing := &fakeTextProvider{models: []string{"llama3"}, err: tc.err}
+			other := &fakeTextProvider{models: []string{"llama3"}, reply: "b"}
+			p := NewPool([]string{startFakeRunner(t, failing), startFakeRunner(t, other)}, WithHealthCheck(time.Minute, time.Second, 1))
+			msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+			for i := 0; i < 4; i++ {
+				ch, _, err := p.SendMessage(context.Background(), "s", "llama3", msgs, nil)
+				if err == nil {
+					collect(ch)
+					continue
+				}
+
+				if code := status.Code(err); code != tc.code {
+					t.Fatalf("код %v, ожидался %v: %v", code, tc.code, err)
+				}
+			}
+
+			if failing.calls.Load() == 0 {
+				t.Fatal("сбойный раннер должен был быть опробован")
+			}
+
+			if other.calls.Load() != 4-failing.calls.Load() {
+				t.Errorf("ошибка запроса не должна повторяться на другом раннере: %d/%d", failing.calls.Load(), other.calls.Load())
+			}
+
+			for _, h := range p.GetHealth() {
+				if !h.Healthy || h.ConsecutiveFailures != 0 {
+					t.Errorf("ошибка запроса не должна считаться неудачной проверкой раннера: %+v", h)
+				}
+			}
+		})
+	}
+}
+
+func TestPool_SendMessage_unreachableRunnerFailover(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	dead := lis.Addr().String()
+	_ = lis.Close()
+
+	b := startFakeRunner(t, &fakeTextProvider{reply: "from-b"})
+	p := NewPool([]string{dead, b})
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+	for i := 0; i < 2; i++ {
+		ch, _, err := p.SendMessage(context.Background(), "s", "", msgs, nil)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+
+		if got := collect(ch); got != "from-b" {
+			t.Errorf("ожидался ответ живого раннера, получено %q", got)
+		}
+	}
+}
+
+func TestPool_SendMessage_retryBudget(t *testing.T) {
+	first := &fakeTextProvider{models: []string{"llama3"}, fail: true}
+	second := &fakeTextProvider{models: []string{"llama3"}, fail: true}
+	third := &fakeTextProvider{models: []string{"llama3"}, fail: true}
+	p := NewPool([]string{
+		startFakeRunner(t, first),
+		startFakeRunner(t, second),
+		startFakeRunner(t, third),
+	}, WithGenerateAttempts(2))
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+	if _, _, err := p.SendMessage(context.Background(), "s", "llama3", msgs, nil); err == nil {
+		t.Fatal("ожидалась ошибка, если все раннеры отказали")
+	}
+
+	if got := first.calls.Load() + second.calls.Load() + third.calls.Load(); got != 2 {
+		t.Errorf("ожидалось 2 попытки, получено %d", got)
+	}
+}
+
+func TestPool_SendMessage_concurrencyLimitQueues(t *testing.T) {
+	tp := &fakeTextProvider{reply: "busy", block: make(chan struct{})}
+	a := startFakeRunner(t, tp)
+	p := NewPool([]string{a}, WithConcurrencyLimits(1, nil), WithQueueTimeout(100*time.Millisecond))
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+	first, _, err := p.SendMessage(context.Background(), "s", "", msgs, nil)
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	runners := p.GetRunners()
+	if runners[0].InFlight != 1 || runners[0].MaxConcurrency != 1 {
+		t.Errorf("ожидалась одна активная генерация при лимите 1: %+v", runners[0])
+	}
+
+	if _, _, err := p.SendMessage(context.Background(), "s", "", msgs, nil); !errors.Is(err, ErrRunnersBusy) {
+		t.Fatalf("ожидалась ErrRunnersBusy, получено %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		ch, _, err := p.SendMessage(context.Background(), "s", "", msgs, nil)
+		if err == nil {
+			collect(ch)
+		}
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(tp.block)
+	collect(first)
+
+	if err := <-done; err != nil {
+		t.Fatalf("запрос из очереди должен получить раннер после освобождения: %v", err)
+	}
+
+	if got := p.GetRunners()[0].InFlight; got != 0 {
+		t.Errorf("после завершения генераций ожидалось 0 активных, получено %d", got)
+	}
+}
+
+func TestPool_SendMessage_leastLoaded(t *testing.T) {
+	busy := &fakeTextProvider{reply: "from-a", block: make(chan struct{})}
+	defer close(busy.block)
+	a := startFakeRunner(t, busy)
+	b := startFakeRunner(t, &fakeTextProvider{reply: "from-b"})
+	p := NewPool([]string{a, b}, WithScheduling(SchedulingLeastLoaded))
+	msgs := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser)}
+
+	var held chan string
+	for held == nil {
+		ch, _, err := p.SendMessage(context.Background(), "s", "", msgs, nil)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+
+		if first := <-ch; first == "from-a" {
+			held = ch
+		} else {
+			collect(ch)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		ch, _, err := p.SendMessage(context.Background(), "s", "", msgs, nil)
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+
+		if got := collect(ch); got != "from-b" {
+			t.Errorf("ожидался наименее загруженный раннер, получено %q", got)
+		}
+	}
+}
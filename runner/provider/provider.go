@@ -13,7 +13,21 @@ type TextBackend interface {
 
 	GetModels(ctx context.Context) ([]string, error)
 
-	SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage) (chan string, error)
+	SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error)
+}
+
+type EmbeddingBackend interface {
+	Embed(ctx context.Context, model string, inputs []string) ([][]float32, error)
+}
+
+type ToolBackend interface {
+	SendMessageWithTools(ctx context.Context, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error)
+}
+
+type ModelResidency interface {
+	ResidentModels() []service2.ResidentModel
+
+	ModelEvents() []service2.ModelEvent
 }
 
 type TextProvider interface {
@@ -21,7 +35,11 @@ type TextProvider interface {
 
 	GetModels(ctx context.Context) ([]string, error)
 
-	SendMessage(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage) (chan string, error)
+	SendMessage(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error)
+}
+
+type ToolProvider interface {
+	SendMessageWithTools(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error)
 }
 
 func NewTextProvider(cfg *config.Config) (TextProvider, error) {
@@ -30,12 +48,23 @@ func NewTextProvider(cfg *config.Config) (TextProvider, error) {
 		if cfg.Llama.ModelPath == "" {
 			return nil, fmt.Errorf("движок %q: задайте llama.model_path", config.EngineLlama)
 		}
-		svc := service2.NewLlamaService(cfg.Llama.ModelPath)
+		svc := service2.NewLlamaService(
+			cfg.Llama.ModelPath,
+			service2.WithChatTemplates(cfg.Llama.ChatTemplates),
+			service2.WithMemoryBudget(cfg.Llama.MemoryBudgetMB*1024*1024),
+			service2.WithEmbeddingModels(cfg.Llama.EmbeddingModels),
+		)
 		return NewText(svc), nil
 	case config.EngineOllama:
 		svc := service2.NewOllamaService(cfg.Ollama)
 		return NewText(svc), nil
+	case config.EngineOpenAI:
+		if cfg.OpenAI.BaseURL == "" {
+			return nil, fmt.Errorf("движок %q: задайте openai.base_url", config.EngineOpenAI)
+		}
+		svc := service2.NewOpenAIService(cfg.OpenAI)
+		return NewText(svc), nil
 	default:
-		return nil, fmt.Errorf("движок не задан или неизвестен %q (ожидается %q или %q)", cfg.Engine, config.EngineOllama, config.EngineLlama)
+		return nil, fmt.Errorf("движок не задан или неизвестен %q (ожидается %q, %q или %q)", cfg.Engine, config.EngineOllama, config.EngineLlama, config.EngineOpenAI)
 	}
 }
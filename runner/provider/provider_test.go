@@ -63,3 +63,26 @@ func TestNewTextProvider_unknownEngine(t *testing.T) {
 		t.Fatal("ожидалась ошибка для неизвестного движка")
 	}
 }
+
+func TestNewTextProvider_openai(t *testing.T) {
+	cfg := &config.Config{
+		Engine: config.EngineOpenAI,
+		OpenAI: config.OpenAI{
+			BaseURL: "http://localhost:8000/v1",
+		},
+	}
+
+	tp, err := NewTextProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewTextProvider(openai): %v", err)
+	}
+
+	if tp == nil {
+		t.Fatal("ожидался непустой провайдер")
+	}
+
+	cfg.OpenAI.BaseURL = ""
+	if _, err := NewTextProvider(cfg); err == nil {
+		t.Fatal("ожидалась ошибка при пустом base_url")
+	}
+}
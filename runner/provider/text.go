@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/runner/service"
 )
 
 type Text struct {
@@ -23,6 +24,40 @@ func (t *Text) GetModels(ctx context.Context) ([]string, error) {
 	return t.backend.GetModels(ctx)
 }
 
-func (t *Text) SendMessage(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage) (chan string, error) {
-	return t.backend.SendMessage(ctx, model, messages)
+func (t *Text) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	e, ok := t.backend.(EmbeddingBackend)
+	if !ok {
+		return nil, domain.ErrEmbeddingsNotSupported
+	}
+
+	return e.Embed(ctx, model, inputs)
+}
+
+func (t *Text) ResidentModels() []service.ResidentModel {
+	if r, ok := t.backend.(ModelResidency); ok {
+		return r.ResidentModels()
+	}
+
+	return nil
+}
+
+func (t *Text) ModelEvents() []service.ModelEvent {
+	if r, ok := t.backend.(ModelResidency); ok {
+		return r.ModelEvents()
+	}
+
+	return nil
+}
+
+func (t *Text) SendMessage(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	return t.backend.SendMessage(ctx, model, messages, opts)
+}
+
+func (t *Text) SendMessageWithTools(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	b, ok := t.backend.(ToolBackend)
+	if !ok {
+		return nil, nil, nil, domain.ErrToolCallsNotSupported
+	}
+
+	return b.SendMessageWithTools(ctx, model, messages, tools, opts)
 }
@@ -3,74 +3,255 @@ package runner
 import (
 	"context"
 	"crypto/subtle"
+	"errors"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/magomedcoder/legion/api/pb/commonpb"
 	"github.com/magomedcoder/legion/api/pb/runnerpb"
+	"github.com/magomedcoder/legion/internal/domain"
 	"github.com/magomedcoder/legion/pkg/logger"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
-const MetadataRunnerToken = "x-runner-token"
+const (
+	MetadataRunnerToken = "x-runner-token"
+
+	defaultLeaseTTL = 30 * time.Second
+)
+
+type RunnerAuthenticator interface {
+	Authenticate(ctx context.Context, token string, address string) (*domain.RunnerCredential, error)
+}
 
 type Registry struct {
 	runnerpb.UnimplementedRunnerServiceServer
-	pool     *Pool
-	regToken string
+	pool               *Pool
+	regToken           string
+	authenticator      RunnerAuthenticator
+	requireCredentials bool
+	leaseTTL           time.Duration
+	static             map[string]bool
+	leases             map[string]time.Time
+	mu                 sync.Mutex
 }
 
-func NewRegistry(pool *Pool, registrationToken string) *Registry {
-	return &Registry{
+type RegistryOption func(*Registry)
+
+func WithRunnerAuthenticator(authenticator RunnerAuthenticator, required bool) RegistryOption {
+	return func(r *Registry) {
+		r.authenticator = authenticator
+		r.requireCredentials = required && authenticator != nil
+	}
+}
+
+func WithLeaseTTL(ttl time.Duration) RegistryOption {
+	return func(r *Registry) {
+		if ttl > 0 {
+			r.leaseTTL = ttl
+		}
+	}
+}
+
+func WithStaticAddresses(addresses []string) RegistryOption {
+	return func(r *Registry) {
+		for _, a := range addresses {
+			if a != "" {
+				r.static[a] = true
+			}
+		}
+	}
+}
+
+func NewRegistry(pool *Pool, registrationToken string, opts ...RegistryOption) *Registry {
+	r := &Registry{
 		pool:     pool,
 		regToken: strings.TrimSpace(registrationToken),
+		leaseTTL: defaultLeaseTTL,
+		static:   make(map[string]bool),
+		leases:   make(map[string]time.Time),
 	}
-}
 
-func (r *Registry) validateRunnerToken(ctx context.Context) error {
-	if r.regToken == "" {
-		return nil
+	for _, opt := range opts {
+		opt(r)
 	}
+
+	return r
+}
+
+func runnerTokenFromContext(ctx context.Context) string {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return status.Error(codes.Unauthenticated, "метаданные не предоставлены")
+		return ""
 	}
 
 	vals := md.Get(MetadataRunnerToken)
-	if len(vals) == 0 || vals[0] == "" {
+	if len(vals) == 0 {
+		return ""
+	}
+
+	return vals[0]
+}
+
+func (r *Registry) validateRunner(ctx context.Context, address string) error {
+	token := runnerTokenFromContext(ctx)
+	if r.authenticator != nil && token != "" {
+		credential, err := r.authenticator.Authenticate(ctx, token, address)
+		switch {
+		case err == nil:
+			logger.D("Registry: раннер %s подтверждён учётными данными %q", address, credential.Name)
+			return nil
+		case errors.Is(err, domain.ErrRunnerAddressMismatch):
+			logger.W("Registry: попытка использовать учётные данные для чужого адреса %s", address)
+			return status.Error(codes.PermissionDenied, err.Error())
+		case errors.Is(err, domain.ErrRunnerCredentialRevoked):
+			return status.Error(codes.Unauthenticated, err.Error())
+		case !errors.Is(err, domain.ErrRunnerCredentialNotFound):
+			logger.E("Registry: проверка учётных данных раннера %s: %v", address, err)
+			return status.Error(codes.Internal, "ошибка проверки учётных данных раннера")
+		}
+	}
+
+	if r.requireCredentials {
+		return status.Error(codes.Unauthenticated, "требуются учётные данные раннера")
+	}
+
+	if r.regToken == "" {
+		return nil
+	}
+
+	if token == "" {
 		return status.Error(codes.Unauthenticated, "токен регистрации раннера не предоставлен")
 	}
 
-	if subtle.ConstantTimeCompare([]byte(vals[0]), []byte(r.regToken)) != 1 {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(r.regToken)) != 1 {
 		return status.Error(codes.Unauthenticated, "неверный токен регистрации раннера")
 	}
 
 	return nil
 }
 
-func (r *Registry) Register(ctx context.Context, req *runnerpb.RegisterRunnerRequest) (*commonpb.Empty, error) {
-	if err := r.validateRunnerToken(ctx); err != nil {
+func (r *Registry) lease() *runnerpb.RunnerLease {
+	interval := r.leaseTTL / 3
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	return &runnerpb.RunnerLease{
+		LeaseTtlSeconds:          int64(r.leaseTTL / time.Second),
+		HeartbeatIntervalSeconds: int64(interval / time.Second),
+	}
+}
+
+func (r *Registry) renewLease(address string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, known := r.leases[address]
+	r.leases[address] = time.Now().Add(r.leaseTTL)
+
+	return known
+}
+
+func (r *Registry) Register(ctx context.Context, req *runnerpb.RegisterRunnerRequest) (*runnerpb.RunnerLease, error) {
+	if err := r.validateRunner(ctx, req.GetAddress()); err != nil {
 		return nil, err
 	}
 
 	if req != nil && req.Address != "" {
 		logger.I("Registry: регистрация раннера %s", req.Address)
+		r.renewLease(req.Address)
 		r.pool.Add(req.Address)
 	}
 
-	return &commonpb.Empty{}, nil
+	return r.lease(), nil
+}
+
+func (r *Registry) Heartbeat(ctx context.Context, req *runnerpb.HeartbeatRequest) (*runnerpb.RunnerLease, error) {
+	if err := r.validateRunner(ctx, req.GetAddress()); err != nil {
+		return nil, err
+	}
+
+	if req == nil || req.Address == "" {
+		return nil, status.Error(codes.InvalidArgument, "адрес раннера не указан")
+	}
+
+	if !r.renewLease(req.Address) {
+		logger.I("Registry: раннер %s восстановлен по heartbeat", req.Address)
+	}
+	r.pool.ApplyHeartbeat(req.Address, req.Models, int(req.InFlight), req.Gpus)
+
+	return r.lease(), nil
 }
 
 func (r *Registry) Unregister(ctx context.Context, req *runnerpb.UnregisterRunnerRequest) (*commonpb.Empty, error) {
-	if err := r.validateRunnerToken(ctx); err != nil {
+	if err := r.validateRunner(ctx, req.GetAddress()); err != nil {
 		return nil, err
 	}
 
 	if req != nil && req.Address != "" {
 		logger.I("Registry: снятие с регистрации раннера %s", req.Address)
+		r.mu.Lock()
+		delete(r.leases, req.Address)
+		r.mu.Unlock()
 		r.pool.Remove(req.Address)
 	}
 
 	return &commonpb.Empty{}, nil
 }
+
+func (r *Registry) Adopt(addresses []string) {
+	for _, addr := range addresses {
+		if addr == "" || r.static[addr] {
+			continue
+		}
+		r.renewLease(addr)
+	}
+}
+
+func (r *Registry) ExpireLeases(now time.Time) []string {
+	r.mu.Lock()
+	var expired []string
+	for addr, deadline := range r.leases {
+		if now.After(deadline) {
+			expired = append(expired, addr)
+			delete(r.leases, addr)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, addr := range expired {
+		if r.static[addr] {
+			logger.W("Registry: истекла аренда статического раннера %s, адрес остаётся в пуле", addr)
+			continue
+		}
+		logger.W("Registry: истекла аренда раннера %s, удаление из пула", addr)
+		r.pool.Remove(addr)
+	}
+
+	return expired
+}
+
+func (r *Registry) StartLeaseReaper(ctx context.Context) {
+	interval := r.leaseTTL / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				r.ExpireLeases(now)
+			}
+		}
+	}()
+}
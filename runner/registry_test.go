@@ -3,9 +3,13 @@ package runner
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/magomedcoder/legion/api/pb/runnerpb"
+	"github.com/magomedcoder/legion/internal/domain"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestNewRegistry(t *testing.T) {
@@ -101,3 +105,165 @@ func TestRegistry_Register_withToken_acceptsCorrectToken(t *testing.T) {
 		t.Errorf("ожидался 1 раннер, получено %d", len(pool.GetRunners()))
 	}
 }
+
+func TestRegistry_Register_returnsLease(t *testing.T) {
+	pool := NewPool(nil)
+	r := NewRegistry(pool, "", WithLeaseTTL(30*time.Second))
+
+	lease, err := r.Register(context.Background(), &runnerpb.RegisterRunnerRequest{Address: "addr:1"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	if lease.LeaseTtlSeconds != 30 || lease.HeartbeatIntervalSeconds != 10 {
+		t.Errorf("неверные параметры аренды: %+v", lease)
+	}
+}
+
+func TestRegistry_Heartbeat_updatesPool(t *testing.T) {
+	pool := NewPool(nil)
+	r := NewRegistry(pool, "")
+
+	_, err := r.Heartbeat(context.Background(), &runnerpb.HeartbeatRequest{
+		Address:  "addr:1",
+		Models:   []string{"llama3"},
+		InFlight: 2,
+		Gpus:     []*runnerpb.GpuInfo{{UtilizationPercent: 40}},
+	})
+	if err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	runners := pool.GetRunners()
+	if len(runners) != 1 {
+		t.Fatalf("heartbeat неизвестного раннера должен добавить его в пул, получено %d", len(runners))
+	}
+
+	if runners[0].ReportedInFlight != 2 || runners[0].LastSeenAt.IsZero() || len(runners[0].Models) != 1 {
+		t.Errorf("heartbeat не обновил состояние раннера: %+v", runners[0])
+	}
+}
+
+func TestRegistry_Heartbeat_emptyAddress(t *testing.T) {
+	r := NewRegistry(NewPool(nil), "")
+	if _, err := r.Heartbeat(context.Background(), &runnerpb.HeartbeatRequest{}); err == nil {
+		t.Fatal("Heartbeat без адреса должен возвращать ошибку")
+	}
+}
+
+func TestRegistry_Heartbeat_withToken_requiresToken(t *testing.T) {
+	pool := NewPool(nil)
+	r := NewRegistry(pool, "secret-token")
+
+	if _, err := r.Heartbeat(context.Background(), &runnerpb.HeartbeatRequest{Address: "addr:1"}); err == nil {
+		t.Fatal("Heartbeat без токена при включённой проверке должен возвращать ошибку")
+	}
+
+	if len(pool.GetRunners()) != 0 {
+		t.Error("раннер не должен добавляться без токена")
+	}
+}
+
+func TestRegistry_ExpireLeases(t *testing.T) {
+	pool := NewPool([]string{"static:1"})
+	r := NewRegistry(pool, "", WithLeaseTTL(time.Minute), WithStaticAddresses([]string{"static:1"}))
+	ctx := context.Background()
+
+	_, _ = r.Register(ctx, &runnerpb.RegisterRunnerRequest{Address: "static:1"})
+	_, _ = r.Register(ctx, &runnerpb.RegisterRunnerRequest{Address: "dyn:1"})
+	_, _ = r.Register(ctx, &runnerpb.RegisterRunnerRequest{Address: "dyn:2"})
+
+	if expired := r.ExpireLeases(time.Now()); len(expired) != 0 {
+		t.Fatalf("аренды не должны истекать раньше срока: %v", expired)
+	}
+
+	r.mu.Lock()
+	r.leases["dyn:2"] = time.Now().Add(2 * time.Minute)
+	r.mu.Unlock()
+
+	expired := r.ExpireLeases(time.Now().Add(90 * time.Second))
+	if len(expired) != 2 {
+		t.Fatalf("ожидалось истечение 2 аренд, получено %v", expired)
+	}
+
+	addrs := make(map[string]bool)
+	for _, ri := range pool.GetRunners() {
+		addrs[ri.Address] = true
+	}
+
+	if !addrs["static:1"] || addrs["dyn:1"] || !addrs["dyn:2"] {
+		t.Errorf("после истечения аренды неверный состав пула: %v", addrs)
+	}
+}
+
+type fakeAuthenticator struct {
+	token   string
+	address string
+	err     error
+}
+
+func (f *fakeAuthenticator) Authenticate(_ context.Context, token string, address string) (*domain.RunnerCredential, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if token != f.token {
+		return nil, domain.ErrRunnerCredentialNotFound
+	}
+
+	if address != f.address {
+		return nil, domain.ErrRunnerAddressMismatch
+	}
+
+	return &domain.RunnerCredential{Name: "gpu-1", Address: address}, nil
+}
+
+func runnerCtx(token string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(MetadataRunnerToken, token))
+}
+
+func TestRegistry_credentials(t *testing.T) {
+	auth := &fakeAuthenticator{token: "lrt_1", address: "a:1"}
+
+	tests := []struct {
+		name     string
+		required bool
+		ctx      context.Context
+		address  string
+		code     codes.Code
+	}{
+		{"credential", true, runnerCtx("lrt_1"), "a:1", codes.OK},
+		{"wrong address", true, runnerCtx("lrt_1"), "b:1", codes.PermissionDenied},
+		{"shared token fallback", false, runnerCtx("shared"), "a:1", codes.OK},
+		{"shared token rejected", true, runnerCtx("shared"), "a:1", codes.Unauthenticated},
+		{"no token", true, context.Background(), "a:1", codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry(NewPool(nil), "shared", WithRunnerAuthenticator(auth, tt.required))
+			_, err := r.Register(tt.ctx, &runnerpb.RegisterRunnerRequest{
+				Address: tt.address,
+			})
+			if status.Code(err) != tt.code {
+				t.Errorf("Register: код %v, ожидался %v (%v)", status.Code(err), tt.code, err)
+			}
+		})
+	}
+}
+
+func TestRegistry_credentials_revoked(t *testing.T) {
+	pool := NewPool(nil)
+	r := NewRegistry(pool, "", WithRunnerAuthenticator(&fakeAuthenticator{err: domain.ErrRunnerCredentialRevoked}, false))
+
+	_, err := r.Heartbeat(runnerCtx("lrt_1"), &runnerpb.HeartbeatRequest{
+		Address: "a:1",
+	})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Heartbeat с отозванными учётными данными: %v", err)
+	}
+
+	if len(pool.GetRunners()) != 0 {
+		t.Error("раннер с отозванными учётными данными не должен попадать в пул")
+	}
+}
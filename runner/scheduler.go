@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/magomedcoder/legion/api/pb/runnerpb"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const (
+	SchedulingRoundRobin  = "round_robin"
+	SchedulingLeastLoaded = "least_loaded"
+
+	defaultQueueTimeout = 30 * time.Second
+)
+
+var ErrRunnersBusy = errors.New("все раннеры заняты")
+
+func WithScheduling(mode string) PoolOption {
+	return func(p *Pool) {
+		switch mode {
+		case SchedulingRoundRobin, SchedulingLeastLoaded:
+			p.scheduling = mode
+		case "":
+		default:
+			logger.W("Pool: неизвестный режим планирования %q, используется %q", mode, p.scheduling)
+		}
+	}
+}
+
+func WithConcurrencyLimits(defaultLimit int, perRunner map[string]int) PoolOption {
+	return func(p *Pool) {
+		if defaultLimit > 0 {
+			p.maxConcurrency = defaultLimit
+		}
+
+		for addr, limit := range perRunner {
+			p.concurrency[addr] = limit
+		}
+	}
+}
+
+func WithQueueTimeout(d time.Duration) PoolOption {
+	return func(p *Pool) {
+		if d > 0 {
+			p.queueTimeout = d
+		}
+	}
+}
+
+func (p *Pool) limitLocked(address string) int {
+	if limit, ok := p.concurrency[address]; ok {
+		return limit
+	}
+
+	return p.maxConcurrency
+}
+
+func (p *Pool) loadLocked(address string) float64 {
+	inflight := p.inflight[address]
+	if reported := p.reportedLoad[address]; reported > inflight {
+		inflight = reported
+	}
+
+	limit := p.limitLocked(address)
+	if limit <= 0 {
+		return float64(inflight)
+	}
+
+	return float64(inflight) / float64(limit)
+}
+
+func (p *Pool) chooseLocked(addrs []string) (string, bool) {
+	free := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if limit := p.limitLocked(a); limit <= 0 || p.inflight[a] < limit {
+			free = append(free, a)
+		}
+	}
+
+	if len(free) == 0 {
+		return "", false
+	}
+
+	offset := int(p.index.Add(1) % uint32(len(free)))
+	if p.scheduling != SchedulingLeastLoaded {
+		return free[offset], true
+	}
+
+	best := free[offset]
+	for i := 1; i < len(free); i++ {
+		a := free[(offset+i)%len(free)]
+		load, bestLoad := p.loadLocked(a), p.loadLocked(best)
+		if load < bestLoad || (load == bestLoad && p.gpuUtilization[a] < p.gpuUtilization[best]) {
+			best = a
+		}
+	}
+
+	return best, true
+}
+
+func (p *Pool) acquireRunner(ctx context.Context, model string, exclude map[string]bool) (string, error) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		addrs, err := p.eligibleRunners(ctx, model, exclude)
+		if err != nil {
+			return "", err
+		}
+
+		p.mu.Lock()
+		addr, ok := p.chooseLocked(addrs)
+		if ok {
+			p.inflight[addr]++
+		}
+		released := p.released
+		p.mu.Unlock()
+
+		if ok {
+			return addr, nil
+		}
+
+		if timer == nil {
+			logger.V("Pool: все раннеры с моделью %q заняты, ожидание до %s", model, p.queueTimeout)
+			timer = time.NewTimer(p.queueTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timer.C:
+			return "", fmt.Errorf("%w: модель %q, ожидание %s", ErrRunnersBusy, model, p.queueTimeout)
+		case <-released:
+		}
+	}
+}
+
+func (p *Pool) releaseRunner(address string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.inflight[address] > 0 {
+		p.inflight[address]--
+	}
+	if p.inflight[address] == 0 {
+		delete(p.inflight, address)
+	}
+
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+func (p *Pool) setGpuUtilization(address string, gpus []*runnerpb.GpuInfo) {
+	if len(gpus) == 0 {
+		return
+	}
+
+	var total uint32
+	for _, g := range gpus {
+		total += g.UtilizationPercent
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.hasAddressLocked(address) {
+		p.gpuUtilization[address] = total / uint32(len(gpus))
+	}
+}
@@ -2,19 +2,25 @@ package runner
 
 import (
 	"context"
+	"errors"
+	"github.com/magomedcoder/legion/api/pb/aichatpb"
 	"github.com/magomedcoder/legion/api/pb/commonpb"
 	"github.com/magomedcoder/legion/api/pb/runnerpb"
 	"github.com/magomedcoder/legion/internal/delivery/mappers"
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
 	gpu2 "github.com/magomedcoder/legion/runner/gpu"
 	"github.com/magomedcoder/legion/runner/provider"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"sync/atomic"
 )
 
 type Server struct {
 	runnerpb.UnimplementedRunnerServiceServer
 	textProvider provider.TextProvider
 	gpuCollector gpu2.Collector
+	inFlight     atomic.Int32
 }
 
 func NewServer(textProvider provider.TextProvider, gpuCollector gpu2.Collector) *Server {
@@ -66,17 +72,21 @@ func (s *Server) Generate(req *runnerpb.GenerateRequest, stream runnerpb.RunnerS
 		})
 	}
 
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+
 	sessionId := req.SessionId
 	model := req.Model
 	messages := mappers.AIMessagesFromProto(req.Messages, sessionId)
+	opts := mappers.GenerationOptionsFromProto(req.GenerationOptions)
+	if err := opts.Validate(); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
 
 	ctx := stream.Context()
-	ch, err := s.textProvider.SendMessage(ctx, sessionId, model, messages)
+	ch, usage, toolCalls, err := s.generate(ctx, sessionId, model, messages, mappers.AIToolsFromProto(req.Tools), opts)
 	if err != nil {
-		_ = stream.Send(&runnerpb.GenerateResponse{
-			Done: true,
-		})
-		return err
+		return backendStatusError(ctx, "генерация не запущена", err)
 	}
 
 	for chunk := range ch {
@@ -91,11 +101,84 @@ func (s *Server) Generate(req *runnerpb.GenerateRequest, stream runnerpb.RunnerS
 	}
 
 	return stream.Send(&runnerpb.GenerateResponse{
-		Done: true,
+		Done:      true,
+		Usage:     mappers.TokenUsageToProto(usage),
+		ToolCalls: mappers.AIToolCallsToProto(toolCalls.Calls),
 	})
 }
 
-func (s *Server) GetGpuInfo(ctx context.Context, _ *commonpb.Empty) (*runnerpb.GetGpuInfoResponse, error) {
+func (s *Server) generate(ctx context.Context, sessionId string, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	if len(tools) > 0 {
+		if tp, ok := s.textProvider.(provider.ToolProvider); ok {
+			ch, usage, toolCalls, err := tp.SendMessageWithTools(ctx, sessionId, model, messages, tools, opts)
+			if !errors.Is(err, domain.ErrToolCallsNotSupported) {
+				return ch, usage, toolCalls, err
+			}
+		}
+		logger.D("Server: движок не поддерживает инструменты, генерация для сессии %s без них", sessionId)
+	}
+
+	ch, usage, err := s.textProvider.SendMessage(ctx, sessionId, model, messages, opts)
+
+	return ch, usage, &domain.AIToolCalls{}, err
+}
+
+func (s *Server) Embed(ctx context.Context, req *aichatpb.EmbedRequest) (*aichatpb.EmbedResponse, error) {
+	if s.textProvider == nil {
+		return nil, status.Error(codes.Unavailable, "текстовый провайдер не подключён")
+	}
+
+	embedder, ok := s.textProvider.(provider.EmbeddingBackend)
+	if !ok {
+		return nil, status.Error(codes.Unimplemented, domain.ErrEmbeddingsNotSupported.Error())
+	}
+
+	if err := domain.ValidateEmbeddingInputs(req.GetInputs()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+
+	vectors, err := embedder.Embed(ctx, req.GetModel(), req.GetInputs())
+	if err != nil {
+		if errors.Is(err, domain.ErrEmbeddingsNotSupported) {
+			return nil, status.Error(codes.Unimplemented, err.Error())
+		}
+		return nil, backendStatusError(ctx, "эмбеддинги не получены", err)
+	}
+
+	return &aichatpb.EmbedResponse{
+		Model:      req.GetModel(),
+		Embeddings: mappers.EmbeddingsToProto(vectors),
+	}, nil
+}
+
+func backendStatusError(ctx context.Context, message string, err error) error {
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, domain.ErrModelNotFound):
+		code = codes.NotFound
+	case errors.Is(err, domain.ErrInvalidGenerationRequest), errors.Is(err, domain.ErrInvalidGenerationOptions):
+		code = codes.InvalidArgument
+	case errors.Is(err, domain.ErrBackendNotReady):
+		code = codes.FailedPrecondition
+	case errors.Is(err, domain.ErrBackendUnavailable):
+		code = codes.Unavailable
+	}
+
+	return status.Errorf(code, "%s: %v", message, err)
+}
+
+func (s *Server) InFlight() int32 {
+	return s.inFlight.Load()
+}
+
+func (s *Server) CollectGpus() []*runnerpb.GpuInfo {
 	list := s.gpuCollector.Collect()
 	gpus := make([]*runnerpb.GpuInfo, len(list))
 	for i := range list {
@@ -108,7 +191,26 @@ func (s *Server) GetGpuInfo(ctx context.Context, _ *commonpb.Empty) (*runnerpb.G
 		}
 	}
 
-	return &runnerpb.GetGpuInfoResponse{Gpus: gpus}, nil
+	return gpus
+}
+
+func (s *Server) GetGpuInfo(ctx context.Context, _ *commonpb.Empty) (*runnerpb.GetGpuInfoResponse, error) {
+	return &runnerpb.GetGpuInfoResponse{Gpus: s.CollectGpus()}, nil
+}
+
+func (s *Server) HeartbeatRequest(ctx context.Context, address string) *runnerpb.HeartbeatRequest {
+	req := &runnerpb.HeartbeatRequest{
+		Address:  address,
+		InFlight: s.InFlight(),
+		Gpus:     s.CollectGpus(),
+	}
+	if s.textProvider != nil {
+		if models, err := s.textProvider.GetModels(ctx); err == nil {
+			req.Models = models
+		}
+	}
+
+	return req
 }
 
 func (s *Server) GetServerInfo(ctx context.Context, _ *commonpb.Empty) (*runnerpb.ServerInfo, error) {
@@ -126,5 +228,25 @@ func (s *Server) GetServerInfo(ctx context.Context, _ *commonpb.Empty) (*runnerp
 		}
 	}
 
+	if r, ok := s.textProvider.(provider.ModelResidency); ok {
+		for _, m := range r.ResidentModels() {
+			out.LoadedModels = append(out.LoadedModels, &runnerpb.LoadedModel{
+				Name:       m.Name,
+				SizeMb:     uint64(m.SizeBytes / (1024 * 1024)),
+				InUse:      int32(m.InUse),
+				LastUsedAt: m.LastUsedAt.Unix(),
+			})
+		}
+
+		for _, e := range r.ModelEvents() {
+			out.ModelEvents = append(out.ModelEvents, &runnerpb.ModelEvent{
+				Type:   string(e.Type),
+				Model:  e.Model,
+				SizeMb: uint64(e.SizeBytes / (1024 * 1024)),
+				At:     e.At.Unix(),
+			})
+		}
+	}
+
 	return out, nil
 }
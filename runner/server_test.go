@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/runner/service"
+)
+
+type residentTextProvider struct {
+	fakeTextProvider
+	resident []service.ResidentModel
+	events   []service.ModelEvent
+}
+
+func (r *residentTextProvider) ResidentModels() []service.ResidentModel {
+	return r.resident
+}
+
+func (r *residentTextProvider) ModelEvents() []service.ModelEvent {
+	return r.events
+}
+
+func TestServer_GetServerInfo_modelResidency(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	tp := &residentTextProvider{
+		resident: []service.ResidentModel{
+			{Name: "a.gguf", SizeBytes: 512 << 20, InUse: 1, LastUsedAt: at},
+		},
+		events: []service.ModelEvent{
+			{Type: service.ModelEventLoad, Model: "a.gguf", SizeBytes: 512 << 20, At: at},
+			{Type: service.ModelEventEvict, Model: "b.gguf", SizeBytes: 256 << 20, At: at},
+		},
+	}
+
+	info, err := NewServer(tp, nil).GetServerInfo(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("GetServerInfo: %v", err)
+	}
+
+	if len(info.LoadedModels) != 1 {
+		t.Fatalf("ожидалась 1 загруженная модель, получено %d", len(info.LoadedModels))
+	}
+
+	m := info.LoadedModels[0]
+	if m.Name != "a.gguf" || m.SizeMb != 512 || m.InUse != 1 || m.LastUsedAt != at.Unix() {
+		t.Errorf("загруженная модель: %+v", m)
+	}
+
+	if len(info.ModelEvents) != 2 || info.ModelEvents[1].Type != "evict" || info.ModelEvents[1].SizeMb != 256 {
+		t.Errorf("события моделей: %+v", info.ModelEvents)
+	}
+}
+
+type noToolsTextProvider struct {
+	fakeTextProvider
+	toolCalls atomic.Int32
+}
+
+func (n *noToolsTextProvider) SendMessageWithTools(context.Context, string, string, []*domain.AIChatMessage, []*domain.AITool, *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	n.toolCalls.Add(1)
+	return nil, nil, nil, fmt.Errorf("%w: ollama вернул статус 400: модель не поддерживает инструменты", domain.ErrToolCallsNotSupported)
+}
+
+func TestServer_Generate_fallsBackWithoutTools(t *testing.T) {
+	tp := &noToolsTextProvider{fakeTextProvider: fakeTextProvider{models: []string{"gemma"}, reply: "ответ"}}
+	addr := startFakeRunner(t, tp)
+	p := NewPool([]string{addr})
+
+	ch, _, toolCalls, err := p.SendMessageWithTools(context.Background(), "s", "gemma", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "привет", domain.AIChatMessageRoleUser),
+	}, []*domain.AITool{{Name: "get_task"}}, nil)
+	if err != nil {
+		t.Fatalf("SendMessageWithTools: %v", err)
+	}
+
+	if got := collect(ch); got != "ответ" {
+		t.Errorf("ожидался ответ без инструментов, получено %q", got)
+	}
+
+	if !toolCalls.Empty() || tp.toolCalls.Load() != 1 || tp.calls.Load() != 1 {
+		t.Errorf("неверный переход на генерацию без инструментов: вызовы=%+v, с инструментами=%d, без=%d", toolCalls, tp.toolCalls.Load(), tp.calls.Load())
+	}
+}
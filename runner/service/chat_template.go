@@ -0,0 +1,206 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+const (
+	ChatTemplateChatML  = "chatml"
+	ChatTemplateLlama3  = "llama3"
+	ChatTemplateGemma   = "gemma"
+	ChatTemplateMistral = "mistral"
+	ChatTemplatePlain   = "plain"
+)
+
+type chatTemplate struct {
+	name   string
+	render func(messages []*domain.AIChatMessage) string
+	stop   []string
+}
+
+var chatTemplates = map[string]chatTemplate{
+	ChatTemplateChatML: {
+		name:   ChatTemplateChatML,
+		render: renderChatML,
+		stop:   []string{"<|im_end|>", "<|im_start|>"},
+	},
+	ChatTemplateLlama3: {
+		name:   ChatTemplateLlama3,
+		render: renderLlama3,
+		stop:   []string{"<|eot_id|>", "<|start_header_id|>"},
+	},
+	ChatTemplateGemma: {
+		name:   ChatTemplateGemma,
+		render: renderGemma,
+		stop:   []string{"<end_of_turn>", "<start_of_turn>"},
+	},
+	ChatTemplateMistral: {
+		name:   ChatTemplateMistral,
+		render: renderMistral,
+		stop:   []string{"</s>", "[INST]"},
+	},
+	ChatTemplatePlain: {
+		name:   ChatTemplatePlain,
+		render: renderPlain,
+		stop:   []string{"\nUser:"},
+	},
+}
+
+func chatTemplateByName(name string) (chatTemplate, bool) {
+	t, ok := chatTemplates[strings.ToLower(strings.TrimSpace(name))]
+	return t, ok
+}
+
+func detectChatTemplate(raw string) chatTemplate {
+	switch {
+	case strings.Contains(raw, "<|im_start|>"):
+		return chatTemplates[ChatTemplateChatML]
+	case strings.Contains(raw, "<|start_header_id|>"):
+		return chatTemplates[ChatTemplateLlama3]
+	case strings.Contains(raw, "<start_of_turn>"):
+		return chatTemplates[ChatTemplateGemma]
+	case strings.Contains(raw, "[INST]"):
+		return chatTemplates[ChatTemplateMistral]
+	default:
+		return chatTemplates[ChatTemplatePlain]
+	}
+}
+
+func splitSystem(messages []*domain.AIChatMessage) (string, []*domain.AIChatMessage) {
+	var system []string
+	turns := make([]*domain.AIChatMessage, 0, len(messages))
+	for _, m := range messages {
+		if m == nil {
+			continue
+		}
+
+		if m.Role == domain.AIChatMessageRoleSystem {
+			system = append(system, m.Content)
+			continue
+		}
+		turns = append(turns, m)
+	}
+
+	return strings.Join(system, "\n\n"), turns
+}
+
+func renderChatML(messages []*domain.AIChatMessage) string {
+	var b strings.Builder
+	for _, m := range messages {
+		if m == nil {
+			continue
+		}
+
+		b.WriteString("<|im_start|>")
+		b.WriteString(string(m.Role))
+		b.WriteString("\n")
+		b.WriteString(m.Content)
+		b.WriteString("<|im_end|>\n")
+	}
+	b.WriteString("<|im_start|>assistant\n")
+
+	return b.String()
+}
+
+func renderLlama3(messages []*domain.AIChatMessage) string {
+	var b strings.Builder
+	for _, m := range messages {
+		if m == nil {
+			continue
+		}
+
+		b.WriteString("<|start_header_id|>")
+		b.WriteString(string(m.Role))
+		b.WriteString("<|end_header_id|>\n\n")
+		b.WriteString(strings.TrimSpace(m.Content))
+		b.WriteString("<|eot_id|>")
+	}
+	b.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n")
+
+	return b.String()
+}
+
+func renderGemma(messages []*domain.AIChatMessage) string {
+	system, turns := splitSystem(messages)
+
+	var b strings.Builder
+	for i, m := range turns {
+		role := "user"
+		if m.Role == domain.AIChatMessageRoleAssistant {
+			role = "model"
+		}
+
+		content := strings.TrimSpace(m.Content)
+		if i == 0 && system != "" && role == "user" {
+			content = system + "\n\n" + content
+		}
+
+		b.WriteString("<start_of_turn>")
+		b.WriteString(role)
+		b.WriteString("\n")
+		b.WriteString(content)
+		b.WriteString("<end_of_turn>\n")
+	}
+	b.WriteString("<start_of_turn>model\n")
+
+	return b.String()
+}
+
+func renderMistral(messages []*domain.AIChatMessage) string {
+	system, turns := splitSystem(messages)
+
+	var b strings.Builder
+	systemUsed := false
+	for _, m := range turns {
+		content := strings.TrimSpace(m.Content)
+		if m.Role == domain.AIChatMessageRoleAssistant {
+			b.WriteString(" ")
+			b.WriteString(content)
+			b.WriteString("</s>")
+			continue
+		}
+
+		if system != "" && !systemUsed {
+			content = system + "\n\n" + content
+			systemUsed = true
+		}
+		b.WriteString("[INST] ")
+		b.WriteString(content)
+		b.WriteString(" [/INST]")
+	}
+
+	if system != "" && !systemUsed {
+		b.WriteString("[INST] ")
+		b.WriteString(system)
+		b.WriteString(" [/INST]")
+	}
+
+	return b.String()
+}
+
+func renderPlain(messages []*domain.AIChatMessage) string {
+	var b strings.Builder
+	for _, m := range messages {
+		if m == nil {
+			continue
+		}
+
+		role := "User"
+		switch m.Role {
+		case domain.AIChatMessageRoleAssistant:
+			role = "Assistant"
+		case domain.AIChatMessageRoleSystem:
+			role = "System"
+		}
+
+		b.WriteString(role)
+		b.WriteString(": ")
+		b.WriteString(m.Content)
+		b.WriteString("\n")
+	}
+	b.WriteString("Assistant: ")
+
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func templateMessages() []*domain.AIChatMessage {
+	return []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "Ты помощник.", domain.AIChatMessageRoleSystem),
+		domain.NewAIChatMessage("s", "Привет", domain.AIChatMessageRoleUser),
+		domain.NewAIChatMessage("s", "Здравствуйте!", domain.AIChatMessageRoleAssistant),
+		domain.NewAIChatMessage("s", "Как дела?", domain.AIChatMessageRoleUser),
+	}
+}
+
+func TestDetectChatTemplate(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"{% for message in messages %}<|im_start|>{{ message.role }}", ChatTemplateChatML},
+		{"{{ '<|start_header_id|>' + message['role'] + '<|end_header_id|>' }}", ChatTemplateLlama3},
+		{"{{ '<start_of_turn>' + role + '\n' }}", ChatTemplateGemma},
+		{"{{ '[INST] ' + message['content'] + ' [/INST]' }}", ChatTemplateMistral},
+		{"", ChatTemplatePlain},
+	}
+
+	for _, tt := range tests {
+		if got := detectChatTemplate(tt.raw); got.name != tt.want {
+			t.Errorf("detectChatTemplate(%q) = %q, ожидалось %q", tt.raw, got.name, tt.want)
+		}
+	}
+}
+
+func TestChatTemplateByName(t *testing.T) {
+	if tmpl, ok := chatTemplateByName(" ChatML "); !ok || tmpl.name != ChatTemplateChatML {
+		t.Errorf("chatTemplateByName(ChatML) = %q, %v", tmpl.name, ok)
+	}
+
+	if _, ok := chatTemplateByName("jinja"); ok {
+		t.Error("неизвестный шаблон не должен находиться")
+	}
+}
+
+func TestRenderChatML(t *testing.T) {
+	got := renderChatML(templateMessages())
+	want := "<|im_start|>system\nТы помощник.<|im_end|>\n" +
+		"<|im_start|>user\nПривет<|im_end|>\n" +
+		"<|im_start|>assistant\nЗдравствуйте!<|im_end|>\n" +
+		"<|im_start|>user\nКак дела?<|im_end|>\n" +
+		"<|im_start|>assistant\n"
+	if got != want {
+		t.Errorf("renderChatML:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestRenderLlama3(t *testing.T) {
+	got := renderLlama3(templateMessages())
+	if !strings.HasPrefix(got, "<|start_header_id|>system<|end_header_id|>\n\nТы помощник.<|eot_id|>") {
+		t.Errorf("системное сообщение должно идти отдельной ролью: %q", got)
+	}
+
+	if !strings.HasSuffix(got, "<|start_header_id|>assistant<|end_header_id|>\n\n") {
+		t.Errorf("промпт должен заканчиваться заголовком ассистента: %q", got)
+	}
+}
+
+func TestRenderGemma(t *testing.T) {
+	got := renderGemma(templateMessages())
+	want := "<start_of_turn>user\nТы помощник.\n\nПривет<end_of_turn>\n" +
+		"<start_of_turn>model\nЗдравствуйте!<end_of_turn>\n" +
+		"<start_of_turn>user\nКак дела?<end_of_turn>\n" +
+		"<start_of_turn>model\n"
+	if got != want {
+		t.Errorf("renderGemma:\n%s\nожидалось:\n%s", got, want)
+	}
+}
+
+func TestRenderMistral(t *testing.T) {
+	got := renderMistral(templateMessages())
+	want := "[INST] Ты помощник.\n\nПривет [/INST] Здравствуйте!</s>[INST] Как дела? [/INST]"
+	if got != want {
+		t.Errorf("renderMistral:\n%s\nожидалось:\n%s", got, want)
+	}
+
+	only := renderMistral([]*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "Только система", domain.AIChatMessageRoleSystem),
+	})
+	if only != "[INST] Только система [/INST]" {
+		t.Errorf("системное сообщение без пользовательского не должно теряться: %q", only)
+	}
+}
+
+func TestRenderPlain_system(t *testing.T) {
+	got := renderPlain(templateMessages())
+	if !strings.HasPrefix(got, "System: Ты помощник.\nUser: Привет\n") || !strings.HasSuffix(got, "Assistant: ") {
+		t.Errorf("renderPlain: %q", got)
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func backendStatusError(statusCode int, err error) error {
+	switch {
+	case statusCode == http.StatusNotFound:
+		return fmt.Errorf("%w: %v", domain.ErrModelNotFound, err)
+	case statusCode == http.StatusUnauthorized, statusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %v", domain.ErrBackendNotReady, err)
+	case statusCode == http.StatusTooManyRequests, statusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: %v", domain.ErrBackendUnavailable, err)
+	case statusCode >= http.StatusBadRequest:
+		return fmt.Errorf("%w: %v", domain.ErrInvalidGenerationRequest, err)
+	default:
+		return err
+	}
+}
+
+func backendRequestError(err error) error {
+	return fmt.Errorf("%w: не удалось отправить запрос: %v", domain.ErrBackendUnavailable, err)
+}
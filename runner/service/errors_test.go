@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+func TestBackendStatusError(t *testing.T) {
+	cases := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, domain.ErrModelNotFound},
+		{http.StatusBadRequest, domain.ErrInvalidGenerationRequest},
+		{http.StatusRequestEntityTooLarge, domain.ErrInvalidGenerationRequest},
+		{http.StatusUnauthorized, domain.ErrBackendNotReady},
+		{http.StatusTooManyRequests, domain.ErrBackendUnavailable},
+		{http.StatusBadGateway, domain.ErrBackendUnavailable},
+	}
+
+	for _, tc := range cases {
+		if err := backendStatusError(tc.status, errors.New("ошибка")); !errors.Is(err, tc.want) {
+			t.Errorf("статус %d: ожидалась %v, получено %v", tc.status, tc.want, err)
+		}
+	}
+}
@@ -9,37 +9,58 @@ import (
 	llama "github.com/magomedcoder/legion/pkg/llama.cpp"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 
 	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
 )
 
-const defaultChunkSize = 128
+type llamaModel struct {
+	model         *llama.LLama
+	template      chatTemplate
+	embeddingSize int
+	mu            sync.Mutex
+}
 
 type LlamaService struct {
-	modelsDir        string
-	currentModelName string
-	chunkSize        int
-	predictOpts      []llama.PredictOption
-	mu               sync.Mutex
-	model            *llama.LLama
+	modelsDir     string
+	predictOpts   []llama.PredictOption
+	chatTemplates map[string]string
+	embedding     map[string]bool
+	memoryBudget  int64
+	mu            sync.Mutex
+	models        *modelCache[*llamaModel]
 }
 
 type LlamaOption func(*LlamaService)
 
-func WithChunkSize(n int) LlamaOption {
+func WithPredictOptions(opts ...llama.PredictOption) LlamaOption {
+	return func(s *LlamaService) {
+		s.predictOpts = opts
+	}
+}
+
+func WithChatTemplates(templates map[string]string) LlamaOption {
+	return func(s *LlamaService) {
+		s.chatTemplates = templates
+	}
+}
+
+func WithEmbeddingModels(models []string) LlamaOption {
 	return func(s *LlamaService) {
-		if n > 0 {
-			s.chunkSize = n
+		s.embedding = make(map[string]bool, len(models))
+		for _, m := range models {
+			s.embedding[m] = true
 		}
 	}
 }
 
-func WithPredictOptions(opts ...llama.PredictOption) LlamaOption {
+func WithMemoryBudget(bytes int64) LlamaOption {
 	return func(s *LlamaService) {
-		s.predictOpts = opts
+		s.memoryBudget = bytes
 	}
 }
 
@@ -53,50 +74,93 @@ func NewLlamaService(modelPath string, opts ...LlamaOption) *LlamaService {
 
 	s := &LlamaService{
 		modelsDir: modelsDir,
-		chunkSize: defaultChunkSize,
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
-	if s.chunkSize <= 0 {
-		s.chunkSize = defaultChunkSize
-	}
+	s.models = newModelCache(s.memoryBudget, s.loadModel, freeLlamaModel, s.modelSize)
 
 	return s
 }
 
-func (s *LlamaService) ensureModel(modelName string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *LlamaService) modelSize(modelName string) int64 {
+	info, err := os.Stat(filepath.Join(s.modelsDir, modelName))
+	if err != nil {
+		return 0
+	}
 
+	return info.Size()
+}
+
+func (s *LlamaService) loadModel(modelName string) (*llamaModel, error) {
+	var modelOpts []llama.ModelOption
+	if s.embedding[modelName] {
+		modelOpts = append(modelOpts, llama.EnableEmbeddings)
+	}
+
+	m, err := llama.New(filepath.Join(s.modelsDir, modelName), modelOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: llama: не удалось загрузить модель %q: %v", domain.ErrBackendNotReady, modelName, err)
+	}
+	logger.I("llama: модель %s загружена", modelName)
+
+	return &llamaModel{
+		model:         m,
+		template:      s.resolveChatTemplate(modelName, m),
+		embeddingSize: m.GetModelInfo().EmbeddingSize,
+	}, nil
+}
+
+func freeLlamaModel(m *llamaModel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.model.Free()
+	logger.I("llama: модель выгружена из памяти")
+}
+
+func (s *LlamaService) acquireModel(ctx context.Context, modelName string) (*llamaModel, func(), error) {
 	if s.modelsDir == "" {
-		return fmt.Errorf("llama: путь к папке с моделями не задан")
+		return nil, nil, fmt.Errorf("%w: llama: путь к папке с моделями не задан", domain.ErrBackendNotReady)
 	}
+
+	s.mu.Lock()
+	names := s.modelNamesLocked()
+	s.mu.Unlock()
 	if modelName == "" {
-		return fmt.Errorf("llama: укажите модель (доступные: %s)", strings.Join(s.modelNamesLocked(), ", "))
+		return nil, nil, fmt.Errorf("%w: llama: укажите модель (доступные: %s)", domain.ErrInvalidGenerationRequest, strings.Join(names, ", "))
 	}
 
-	fullPath := filepath.Join(s.modelsDir, modelName)
-	if s.model != nil && s.currentModelName == modelName {
-		return nil
+	if !slices.Contains(names, modelName) {
+		return nil, nil, fmt.Errorf("%w: llama: модели %q нет в папке %q", domain.ErrModelNotFound, modelName, s.modelsDir)
 	}
 
-	if s.model != nil {
-		s.model.Free()
-		s.model = nil
-		s.currentModelName = ""
-	}
+	return s.models.Acquire(ctx, modelName)
+}
 
-	m, err := llama.New(fullPath)
-	if err != nil {
-		return fmt.Errorf("llama: не удалось загрузить модель %q: %w", modelName, err)
+func (s *LlamaService) resolveChatTemplate(modelName string, m *llama.LLama) chatTemplate {
+	if name, ok := s.chatTemplates[modelName]; ok {
+		if tmpl, ok := chatTemplateByName(name); ok {
+			logger.I("llama: шаблон чата %q для модели %s задан в конфигурации", tmpl.name, modelName)
+			return tmpl
+		}
+		logger.W("llama: неизвестный шаблон чата %q для модели %s, используется шаблон модели", name, modelName)
 	}
 
-	s.model = m
-	s.currentModelName = modelName
-	return nil
+	tmpl := detectChatTemplate(m.GetChatTemplate(""))
+	logger.I("llama: шаблон чата модели %s: %s", modelName, tmpl.name)
+
+	return tmpl
+}
+
+func (s *LlamaService) ResidentModels() []ResidentModel {
+	return s.models.Resident()
+}
+
+func (s *LlamaService) ModelEvents() []ModelEvent {
+	return s.models.Events()
 }
 
 func (s *LlamaService) modelNamesLocked() []string {
@@ -126,13 +190,17 @@ func (s *LlamaService) modelNamesLocked() []string {
 }
 
 func (s *LlamaService) CheckConnection(ctx context.Context) (bool, error) {
-	models, err := s.GetModels(ctx)
-	if err != nil || len(models) == 0 {
-		return false, fmt.Errorf("llama: нет моделей в папке %q", s.modelsDir)
+	if s.modelsDir == "" {
+		return false, fmt.Errorf("llama: путь к папке с моделями не задан")
 	}
 
-	if err := s.ensureModel(models[0]); err != nil {
-		return false, err
+	if _, err := os.ReadDir(s.modelsDir); err != nil {
+		return false, fmt.Errorf("llama: папка с моделями %q недоступна: %w", s.modelsDir, err)
+	}
+
+	models, _ := s.GetModels(ctx)
+	if len(models) == 0 {
+		return false, fmt.Errorf("llama: нет моделей в папке %q", s.modelsDir)
 	}
 
 	return true, nil
@@ -144,68 +212,131 @@ func (s *LlamaService) GetModels(ctx context.Context) ([]string, error) {
 	return s.modelNamesLocked(), nil
 }
 
-func (s *LlamaService) SendMessage(ctx context.Context, model string, messages []*domain.Message) (chan string, error) {
-	if err := s.ensureModel(model); err != nil {
-		return nil, err
+func generationPredictOptions(opts *domain.GenerationOptions) []llama.PredictOption {
+	if opts.IsEmpty() {
+		return nil
+	}
+
+	var out []llama.PredictOption
+	if opts.Temperature != nil {
+		out = append(out, llama.SetTemperature(*opts.Temperature))
+	}
+
+	if opts.TopP != nil {
+		out = append(out, llama.SetTopP(*opts.TopP))
 	}
 
-	prompt := buildPrompt(messages)
+	if opts.TopK != nil {
+		out = append(out, llama.SetTopK(int(*opts.TopK)))
+	}
+
+	if opts.MaxTokens != nil {
+		out = append(out, llama.SetTokens(int(*opts.MaxTokens)))
+	}
+
+	if len(opts.Stop) > 0 {
+		out = append(out, llama.SetStopWords(opts.Stop...))
+	}
+
+	if opts.Seed != nil {
+		out = append(out, llama.SetSeed(int(*opts.Seed)))
+	}
+
+	return out
+}
+
+func (s *LlamaService) SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	m, release, err := s.acquireModel(ctx, model)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prompt := m.template.render(messages)
+
+	stop := append([]string(nil), m.template.stop...)
+	if opts != nil {
+		stop = append(stop, opts.Stop...)
+	}
+	stream := newTokenStream(stop)
 
 	out := make(chan string, 32)
+	usage := &domain.TokenUsage{}
+	send := func(chunk string) bool {
+		if chunk == "" {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case out <- chunk:
+			return true
+		}
+	}
+
+	predictOpts := append(append([]llama.PredictOption(nil), s.predictOpts...), generationPredictOptions(opts)...)
+	predictOpts = append(predictOpts, llama.SetStopWords(stop...))
+	predictOpts = append(predictOpts, llama.SetTokenCallback(func(token string) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		usage.CompletionTokens++
+
+		chunk, stopped := stream.Push(token)
+		return send(chunk) && !stopped
+	}))
+
 	go func() {
 		defer close(out)
+		defer release()
 
-		s.mu.Lock()
-		text, err := s.model.Predict(prompt, s.predictOpts...)
-		s.mu.Unlock()
-		if err != nil {
+		m.mu.Lock()
+		if n, _, err := m.model.TokenizeString(prompt); err == nil {
+			usage.PromptTokens = n
+		}
+		_, err := m.model.Predict(prompt, predictOpts...)
+		m.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			logger.E("llama: ошибка генерации: %v", err)
 			return
 		}
 
-		chunkSize := s.chunkSize
-		for len(text) > 0 {
-			if ctx.Err() != nil {
-				return
-			}
-
-			n := chunkSize
-			if len(text) < n {
-				n = len(text)
-			}
-
-			chunk := text[:n]
-			text = text[n:]
-
-			if strings.TrimSpace(chunk) == "" {
-				continue
-			}
-
-			select {
-			case <-ctx.Done():
-				return
-			case out <- chunk:
-			}
-		}
+		send(stream.Flush())
 	}()
 
-	return out, nil
+	return out, usage, nil
 }
 
-func buildPrompt(messages []*domain.Message) string {
-	var b strings.Builder
-	for _, m := range messages {
-		role := "User"
-		if m.Role == domain.MessageRoleAssistant {
-			role = "Assistant"
-		}
+func (s *LlamaService) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	if !s.embedding[model] {
+		return nil, fmt.Errorf("llama: модель %q не указана в llama.embedding_models", model)
+	}
 
-		b.WriteString(role)
-		b.WriteString(": ")
-		b.WriteString(m.Content)
-		b.WriteString("\n")
+	m, release, err := s.acquireModel(ctx, model)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	b.WriteString("Assistant: ")
+	out := make([][]float32, 0, len(inputs))
+	for _, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
-	return b.String()
+		vec, err := m.model.Embeddings(in, llama.SetTokens(m.embeddingSize))
+		if err != nil {
+			return nil, fmt.Errorf("llama: %w", err)
+		}
+
+		if len(vec) > m.embeddingSize {
+			vec = vec[:m.embeddingSize]
+		}
+		out = append(out, vec)
+	}
+
+	return out, nil
 }
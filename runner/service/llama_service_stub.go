@@ -13,6 +13,18 @@ type LlamaService struct{}
 
 type LlamaOption func(*LlamaService)
 
+func WithChatTemplates(templates map[string]string) LlamaOption {
+	return func(s *LlamaService) {}
+}
+
+func WithEmbeddingModels(models []string) LlamaOption {
+	return func(s *LlamaService) {}
+}
+
+func WithMemoryBudget(bytes int64) LlamaOption {
+	return func(s *LlamaService) {}
+}
+
 func NewLlamaService(modelPath string, opts ...LlamaOption) *LlamaService {
 	return &LlamaService{}
 }
@@ -25,8 +37,8 @@ func (s *LlamaService) GetModels(ctx context.Context) ([]string, error) {
 	return nil, fmt.Errorf("llama отключена")
 }
 
-func (s *LlamaService) SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage) (chan string, error) {
+func (s *LlamaService) SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
 	ch := make(chan string)
 	close(ch)
-	return ch, fmt.Errorf("llama отключена")
+	return ch, nil, fmt.Errorf("llama отключена")
 }
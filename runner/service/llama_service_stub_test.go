@@ -42,7 +42,7 @@ func TestLlamaService_stub_GetModels(t *testing.T) {
 
 func TestLlamaService_stub_SendMessage(t *testing.T) {
 	svc := NewLlamaService("/path")
-	ch, err := svc.SendMessage(context.Background(), "m", []*domain.AIChatMessage{})
+	ch, _, err := svc.SendMessage(context.Background(), "m", []*domain.AIChatMessage{}, nil)
 	if err == nil {
 		t.Error("ожидалась ошибка (llama отключена)")
 	}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type ModelEventType string
+
+const (
+	ModelEventLoad  ModelEventType = "load"
+	ModelEventEvict ModelEventType = "evict"
+
+	maxModelEvents = 32
+)
+
+type ResidentModel struct {
+	Name       string
+	SizeBytes  int64
+	InUse      int
+	LastUsedAt time.Time
+}
+
+type ModelEvent struct {
+	Type      ModelEventType
+	Model     string
+	SizeBytes int64
+	At        time.Time
+}
+
+type cachedModel[T any] struct {
+	name     string
+	value    T
+	size     int64
+	refs     int
+	lastUsed time.Time
+	ready    chan struct{}
+	err      error
+}
+
+type modelCache[T any] struct {
+	budget int64
+	load   func(name string) (T, error)
+	free   func(T)
+	size   func(name string) int64
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cachedModel[T]
+	used    int64
+	events  []ModelEvent
+	changed chan struct{}
+}
+
+func newModelCache[T any](budget int64, load func(string) (T, error), free func(T), size func(string) int64) *modelCache[T] {
+	return &modelCache[T]{
+		budget:  budget,
+		load:    load,
+		free:    free,
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*cachedModel[T]),
+		changed: make(chan struct{}),
+	}
+}
+
+func (c *modelCache[T]) Acquire(ctx context.Context, name string) (T, func(), error) {
+	var zero T
+	for {
+		c.mu.Lock()
+		if e, ok := c.entries[name]; ok {
+			e.refs++
+			c.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				c.release(e)
+				return zero, nil, ctx.Err()
+			case <-e.ready:
+			}
+
+			if e.err != nil {
+				c.release(e)
+				return zero, nil, e.err
+			}
+
+			return e.value, func() { c.release(e) }, nil
+		}
+
+		size := c.size(name)
+		if c.makeRoomLocked(size) {
+			e := &cachedModel[T]{
+				name:  name,
+				size:  size,
+				refs:  1,
+				ready: make(chan struct{}),
+			}
+			c.entries[name] = e
+			c.used += size
+			c.mu.Unlock()
+
+			value, err := c.load(name)
+
+			c.mu.Lock()
+			e.value, e.err = value, err
+			e.lastUsed = c.now()
+			if err != nil {
+				delete(c.entries, name)
+				c.used -= size
+				c.notifyLocked()
+			} else {
+				c.eventLocked(ModelEventLoad, e)
+			}
+			close(e.ready)
+			c.mu.Unlock()
+
+			if err != nil {
+				return zero, nil, err
+			}
+
+			return value, func() { c.release(e) }, nil
+		}
+
+		wait := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return zero, nil, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+func (c *modelCache[T]) release(e *cachedModel[T]) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.refs--
+	e.lastUsed = c.now()
+	c.notifyLocked()
+}
+
+func (c *modelCache[T]) fitsLocked(size int64) bool {
+	if len(c.entries) == 0 {
+		return true
+	}
+
+	if c.budget <= 0 {
+		return false
+	}
+
+	return c.used+size <= c.budget
+}
+
+func (c *modelCache[T]) makeRoomLocked(size int64) bool {
+	for !c.fitsLocked(size) {
+		var victim *cachedModel[T]
+		for _, e := range c.entries {
+			if e.refs > 0 {
+				continue
+			}
+
+			if victim == nil || e.lastUsed.Before(victim.lastUsed) {
+				victim = e
+			}
+		}
+
+		if victim == nil {
+			return false
+		}
+
+		delete(c.entries, victim.name)
+		c.used -= victim.size
+		c.free(victim.value)
+		c.eventLocked(ModelEventEvict, victim)
+	}
+
+	return true
+}
+
+func (c *modelCache[T]) notifyLocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func (c *modelCache[T]) eventLocked(t ModelEventType, e *cachedModel[T]) {
+	c.events = append(c.events, ModelEvent{
+		Type:      t,
+		Model:     e.name,
+		SizeBytes: e.size,
+		At:        c.now(),
+	})
+
+	if len(c.events) > maxModelEvents {
+		c.events = append([]ModelEvent(nil), c.events[len(c.events)-maxModelEvents:]...)
+	}
+}
+
+func (c *modelCache[T]) Resident() []ResidentModel {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make([]ResidentModel, 0, len(c.entries))
+	for _, e := range c.entries {
+		select {
+		case <-e.ready:
+		default:
+			continue
+		}
+
+		out = append(out, ResidentModel{
+			Name:       e.name,
+			SizeBytes:  e.size,
+			InUse:      e.refs,
+			LastUsedAt: e.lastUsed,
+		})
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].LastUsedAt.After(out[j].LastUsedAt)
+	})
+
+	return out
+}
+
+func (c *modelCache[T]) Events() []ModelEvent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]ModelEvent(nil), c.events...)
+}
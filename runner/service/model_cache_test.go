@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeModelLoader struct {
+	mu    sync.Mutex
+	sizes map[string]int64
+	loads []string
+	freed []string
+	fail  map[string]error
+}
+
+func newFakeModelLoader(sizes map[string]int64) *fakeModelLoader {
+	return &fakeModelLoader{
+		sizes: sizes,
+		fail:  make(map[string]error),
+	}
+}
+
+func (f *fakeModelLoader) cache(budget int64) *modelCache[string] {
+	clock := time.Unix(0, 0)
+	c := newModelCache(budget, f.load, f.free, func(name string) int64 {
+		return f.sizes[name]
+	})
+	c.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+
+	return c
+}
+
+func (f *fakeModelLoader) load(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.fail[name]; err != nil {
+		return "", err
+	}
+	f.loads = append(f.loads, name)
+
+	return "model:" + name, nil
+}
+
+func (f *fakeModelLoader) free(value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.freed = append(f.freed, value)
+}
+
+func residentNames(c *modelCache[string]) map[string]bool {
+	out := make(map[string]bool)
+	for _, m := range c.Resident() {
+		out[m.Name] = true
+	}
+
+	return out
+}
+
+func TestModelCache_keepsSeveralModelsWithinBudget(t *testing.T) {
+	f := newFakeModelLoader(map[string]int64{"a": 40, "b": 40})
+	c := f.cache(100)
+
+	for _, name := range []string{"a", "b", "a", "b"} {
+		value, release, err := c.Acquire(context.Background(), name)
+		if err != nil {
+			t.Fatalf("Acquire(%s): %v", name, err)
+		}
+
+		if value != "model:"+name {
+			t.Fatalf("Acquire(%s) = %q", name, value)
+		}
+		release()
+	}
+
+	if len(f.loads) != 2 || len(f.freed) != 0 {
+		t.Fatalf("модели не должны перезагружаться: loads=%v freed=%v", f.loads, f.freed)
+	}
+
+	if got := residentNames(c); !got["a"] || !got["b"] {
+		t.Fatalf("обе модели должны быть загружены: %v", got)
+	}
+}
+
+func TestModelCache_evictsLeastRecentlyUsed(t *testing.T) {
+	f := newFakeModelLoader(map[string]int64{"a": 40, "b": 40, "c": 40})
+	c := f.cache(100)
+
+	for _, name := range []string{"a", "b", "a", "c"} {
+		_, release, err := c.Acquire(context.Background(), name)
+		if err != nil {
+			t.Fatalf("Acquire(%s): %v", name, err)
+		}
+		release()
+	}
+
+	if len(f.freed) != 1 || f.freed[0] != "model:b" {
+		t.Fatalf("должна быть выгружена давно не используемая модель b: %v", f.freed)
+	}
+
+	if got := residentNames(c); !got["a"] || !got["c"] || got["b"] {
+		t.Fatalf("загруженные модели: %v", got)
+	}
+
+	events := c.Events()
+	if len(events) != 4 {
+		t.Fatalf("ожидалось 4 события, получено %d: %+v", len(events), events)
+	}
+
+	if last := events[len(events)-1]; last.Type != ModelEventLoad || last.Model != "c" {
+		t.Fatalf("последнее событие: %+v", last)
+	}
+
+	if evict := events[2]; evict.Type != ModelEventEvict || evict.Model != "b" {
+		t.Fatalf("событие выгрузки: %+v", evict)
+	}
+}
+
+func TestModelCache_zeroBudgetKeepsSingleModel(t *testing.T) {
+	f := newFakeModelLoader(map[string]int64{"a": 1, "b": 1})
+	c := f.cache(0)
+
+	for _, name := range []string{"a", "b"} {
+		_, release, err := c.Acquire(context.Background(), name)
+		if err != nil {
+			t.Fatalf("Acquire(%s): %v", name, err)
+		}
+		release()
+	}
+
+	if got := residentNames(c); len(got) != 1 || !got["b"] {
+		t.Fatalf("без бюджета должна оставаться одна модель: %v", got)
+	}
+}
+
+func TestModelCache_inUseModelIsNotEvicted(t *testing.T) {
+	f := newFakeModelLoader(map[string]int64{"a": 60, "b": 60})
+	c := f.cache(100)
+
+	_, releaseA, err := c.Acquire(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Acquire(a): %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := c.Acquire(ctx, "b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидалось ожидание освобождения модели, получено %v", err)
+	}
+
+	if len(f.freed) != 0 {
+		t.Fatalf("используемая модель не должна выгружаться: %v", f.freed)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, release, err := c.Acquire(context.Background(), "b")
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	releaseA()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Acquire(b): %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("модель b не загрузилась после освобождения a")
+	}
+
+	if len(f.freed) != 1 || f.freed[0] != "model:a" {
+		t.Fatalf("после освобождения должна выгружаться a: %v", f.freed)
+	}
+}
+
+func TestModelCache_loadError(t *testing.T) {
+	f := newFakeModelLoader(map[string]int64{"a": 10})
+	f.fail["a"] = errors.New("broken")
+	c := f.cache(100)
+
+	if _, _, err := c.Acquire(context.Background(), "a"); err == nil {
+		t.Fatal("ожидалась ошибка загрузки")
+	}
+
+	if got := c.Resident(); len(got) != 0 {
+		t.Fatalf("неудачная загрузка не должна оставаться в кэше: %+v", got)
+	}
+
+	delete(f.fail, "a")
+	if _, release, err := c.Acquire(context.Background(), "a"); err != nil {
+		t.Fatalf("повторная загрузка: %v", err)
+	} else {
+		release()
+	}
+}
@@ -78,7 +78,155 @@ func (o *OllamaService) GetModels(ctx context.Context) ([]string, error) {
 	return names, nil
 }
 
-func (o *OllamaService) SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage) (chan string, error) {
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+func (o *OllamaService) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	jsonBody, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/embed", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, backendRequestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ollamaStatusError(resp)
+	}
+
+	var data ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать эмбеддинги: %w", err)
+	}
+
+	if len(data.Embeddings) != len(inputs) {
+		return nil, fmt.Errorf("ollama вернул %d эмбеддингов вместо %d", len(data.Embeddings), len(inputs))
+	}
+
+	return data.Embeddings, nil
+}
+
+func ollamaOptions(opts *domain.GenerationOptions) map[string]interface{} {
+	if opts.IsEmpty() {
+		return nil
+	}
+
+	options := make(map[string]interface{})
+	if opts.Temperature != nil {
+		options["temperature"] = *opts.Temperature
+	}
+
+	if opts.TopP != nil {
+		options["top_p"] = *opts.TopP
+	}
+
+	if opts.TopK != nil {
+		options["top_k"] = *opts.TopK
+	}
+
+	if opts.MaxTokens != nil {
+		options["num_predict"] = *opts.MaxTokens
+	}
+
+	if len(opts.Stop) > 0 {
+		options["stop"] = opts.Stop
+	}
+
+	if opts.Seed != nil {
+		options["seed"] = *opts.Seed
+	}
+
+	return options
+}
+
+func ollamaStatusError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error != "" {
+		return backendStatusError(resp.StatusCode, fmt.Errorf("ollama вернул статус %d: %s", resp.StatusCode, body.Error))
+	}
+
+	return backendStatusError(resp.StatusCode, fmt.Errorf("ollama вернул статус: %d", resp.StatusCode))
+}
+
+func ollamaTools(tools []*domain.AITool) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(tools))
+	for i, t := range tools {
+		var parameters interface{} = json.RawMessage(t.Parameters)
+		if len(t.Parameters) == 0 {
+			parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		out[i] = map[string]interface{}{
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  parameters,
+			},
+		}
+	}
+
+	return out
+}
+
+func ollamaToolCalls(raw interface{}, offset int) []*domain.AIToolCall {
+	items, _ := raw.([]interface{})
+	out := make([]*domain.AIToolCall, 0, len(items))
+	for _, item := range items {
+		call, _ := item.(map[string]interface{})
+		function, _ := call["function"].(map[string]interface{})
+		name, _ := function["name"].(string)
+		if name == "" {
+			continue
+		}
+
+		args := "{}"
+		if function["arguments"] != nil {
+			if data, err := json.Marshal(function["arguments"]); err == nil {
+				args = string(data)
+			}
+		}
+
+		id, _ := call["id"].(string)
+		if id == "" {
+			id = fmt.Sprintf("call_%d", offset+len(out))
+		}
+		out = append(out, &domain.AIToolCall{
+			Id:        id,
+			Name:      name,
+			Arguments: args,
+		})
+	}
+
+	return out
+}
+
+func (o *OllamaService) SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch, usage, _, err := o.chat(ctx, model, messages, nil, opts)
+	return ch, usage, err
+}
+
+func (o *OllamaService) SendMessageWithTools(ctx context.Context, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	return o.chat(ctx, model, messages, tools, opts)
+}
+
+func (o *OllamaService) chat(ctx context.Context, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
 	ollamaMessages := make([]map[string]interface{}, len(messages))
 	for i, msg := range messages {
 		ollamaMessages[i] = msg.AIToMap()
@@ -89,29 +237,38 @@ func (o *OllamaService) SendMessage(ctx context.Context, model string, messages
 		"messages": ollamaMessages,
 		"stream":   true,
 	}
+	if options := ollamaOptions(opts); len(options) > 0 {
+		requestBody["options"] = options
+	}
+
+	if len(tools) > 0 {
+		requestBody["tools"] = ollamaTools(tools)
+	}
 
 	jsonBody, err := json.Marshal(requestBody)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+		return nil, nil, nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/api/chat", bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+		return nil, nil, nil, fmt.Errorf("не удалось создать запрос: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := o.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось отправить запрос: %w", err)
+		return nil, nil, nil, backendRequestError(err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("ollama вернул статус: %d", resp.StatusCode)
+		defer resp.Body.Close()
+		return nil, nil, nil, toolsStatusError(resp, tools, ollamaStatusError(resp))
 	}
 
 	output := make(chan string, 100)
+	usage := &domain.TokenUsage{}
+	toolCalls := &domain.AIToolCalls{}
 
 	go func() {
 		defer resp.Body.Close()
@@ -130,6 +287,7 @@ func (o *OllamaService) SendMessage(ctx context.Context, model string, messages
 			}
 
 			if message, ok := data["message"].(map[string]interface{}); ok {
+				toolCalls.Calls = append(toolCalls.Calls, ollamaToolCalls(message["tool_calls"], len(toolCalls.Calls))...)
 				if content, ok := message["content"].(string); ok && content != "" {
 					select {
 					case <-ctx.Done():
@@ -140,6 +298,12 @@ func (o *OllamaService) SendMessage(ctx context.Context, model string, messages
 			}
 
 			if done, ok := data["done"].(bool); ok && done {
+				if n, ok := data["prompt_eval_count"].(float64); ok {
+					usage.PromptTokens = int32(n)
+				}
+				if n, ok := data["eval_count"].(float64); ok {
+					usage.CompletionTokens = int32(n)
+				}
 				break
 			}
 		}
@@ -148,5 +312,5 @@ func (o *OllamaService) SendMessage(ctx context.Context, model string, messages
 		}
 	}()
 
-	return output, nil
+	return output, usage, toolCalls, nil
 }
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/runner/config"
+)
+
+func TestOllamaService_SendMessage_options(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Decode: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"message":{"content":"ok"},"done":true,"prompt_eval_count":7,"eval_count":3}` + "\n"))
+	}))
+	defer srv.Close()
+
+	svc := NewOllamaService(config.Ollama{BaseURL: srv.URL})
+	temperature, maxTokens, seed := float32(0.5), int32(32), int64(11)
+	ch, usage, err := svc.SendMessage(context.Background(), "llama3", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser),
+	}, &domain.GenerationOptions{
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+		Stop:        []string{"###"},
+		Seed:        &seed,
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	var out string
+	for chunk := range ch {
+		out += chunk
+	}
+	if out != "ok" {
+		t.Errorf("ответ %q, ожидалось ok", out)
+	}
+
+	if usage.PromptTokens != 7 || usage.CompletionTokens != 3 {
+		t.Errorf("неверный usage: %+v", usage)
+	}
+
+	options, ok := body["options"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("options не переданы: %v", body)
+	}
+
+	if options["temperature"] != 0.5 || options["num_predict"] != float64(32) || options["seed"] != float64(11) {
+		t.Errorf("неверные options: %v", options)
+	}
+
+	if stop, _ := options["stop"].([]interface{}); len(stop) != 1 || stop[0] != "###" {
+		t.Errorf("неверный stop: %v", options["stop"])
+	}
+
+	if _, ok := options["top_p"]; ok {
+		t.Error("незаданный top_p не должен передаваться")
+	}
+}
+
+func TestOllamaOptions_empty(t *testing.T) {
+	if got := ollamaOptions(nil); got != nil {
+		t.Errorf("ollamaOptions(nil) = %v, ожидалось nil", got)
+	}
+}
+
+func TestOllamaService_Embed(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Decode: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"model":"nomic","embeddings":[[0.1,0.2],[0.3,0.4]]}`))
+	}))
+	defer srv.Close()
+
+	svc := NewOllamaService(config.Ollama{BaseURL: srv.URL})
+	vectors, err := svc.Embed(context.Background(), "nomic", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	if len(vectors) != 2 || vectors[1][1] != 0.4 {
+		t.Errorf("неверные эмбеддинги: %v", vectors)
+	}
+
+	if input, _ := body["input"].([]interface{}); body["model"] != "nomic" || len(input) != 2 {
+		t.Errorf("неверное тело запроса: %v", body)
+	}
+
+	if _, err := svc.Embed(context.Background(), "nomic", []string{"a"}); err == nil {
+		t.Error("ожидалась ошибка при несовпадении числа эмбеддингов")
+	}
+}
+
+func TestOllamaService_SendMessageWithTools(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Decode: %v", err)
+		}
+		_, _ = w.Write([]byte(`{"message":{"content":"","tool_calls":[{"function":{"name":"get_task","arguments":{"task_id":"t1"}}}]},"done":false}` + "\n"))
+		_, _ = w.Write([]byte(`{"message":{"content":""},"done":true,"prompt_eval_count":5,"eval_count":2}` + "\n"))
+	}))
+	defer srv.Close()
+
+	call := domain.NewAIChatMessage("s", "", domain.AIChatMessageRoleAssistant)
+	call.ToolCalls = []*domain.AIToolCall{{Id: "c0", Name: "get_task", Arguments: `{"task_id":"t0"}`}}
+	svc := NewOllamaService(config.Ollama{BaseURL: srv.URL})
+	ch, _, toolCalls, err := svc.SendMessageWithTools(context.Background(), "llama3", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "что с задачей?", domain.AIChatMessageRoleUser),
+		call,
+		domain.NewAIToolResultMessage("s", call.ToolCalls[0], `{"name":"t0"}`),
+	}, []*domain.AITool{{Name: "get_task", Description: "задача", Parameters: json.RawMessage(`{"type":"object"}`)}}, nil)
+	if err != nil {
+		t.Fatalf("SendMessageWithTools: %v", err)
+	}
+	for range ch {
+	}
+
+	if len(toolCalls.Calls) != 1 || toolCalls.Calls[0].Name != "get_task" || toolCalls.Calls[0].Arguments != `{"task_id":"t1"}` || toolCalls.Calls[0].Id == "" {
+		t.Fatalf("неверные вызовы инструментов: %+v", toolCalls.Calls)
+	}
+
+	tools, _ := body["tools"].([]interface{})
+	if len(tools) != 1 {
+		t.Fatalf("инструменты не переданы: %v", body)
+	}
+
+	function := tools[0].(map[string]interface{})["function"].(map[string]interface{})
+	if function["name"] != "get_task" || function["parameters"].(map[string]interface{})["type"] != "object" {
+		t.Errorf("неверное описание инструмента: %v", function)
+	}
+
+	messages, _ := body["messages"].([]interface{})
+	if len(messages) != 3 || messages[2].(map[string]interface{})["tool_name"] != "get_task" {
+		t.Errorf("результат инструмента передан неверно: %v", body["messages"])
+	}
+}
+
+func TestOllamaService_SendMessageWithTools_unsupportedModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"registry.ollama.ai/library/gemma:2b does not support tools"}`))
+	}))
+	defer srv.Close()
+
+	svc := NewOllamaService(config.Ollama{BaseURL: srv.URL})
+	messages := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "привет", domain.AIChatMessageRoleUser)}
+	tools := []*domain.AITool{{Name: "get_task"}}
+
+	_, _, _, err := svc.SendMessageWithTools(context.Background(), "gemma:2b", messages, tools, nil)
+	if !errors.Is(err, domain.ErrToolCallsNotSupported) {
+		t.Errorf("ожидалась ErrToolCallsNotSupported, получено %v", err)
+	}
+
+	_, _, err = svc.SendMessage(context.Background(), "gemma:2b", messages, nil)
+	if !errors.Is(err, domain.ErrInvalidGenerationRequest) || errors.Is(err, domain.ErrToolCallsNotSupported) {
+		t.Errorf("без инструментов должна возвращаться исходная ошибка, получено %v", err)
+	}
+}
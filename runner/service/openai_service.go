@@ -0,0 +1,436 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+	"github.com/magomedcoder/legion/runner/config"
+)
+
+type OpenAIService struct {
+	baseURL string
+	apiKey  string
+	allowed map[string]bool
+	client  *http.Client
+}
+
+func NewOpenAIService(conf config.OpenAI) *OpenAIService {
+	allowed := make(map[string]bool, len(conf.Models))
+	for _, m := range conf.Models {
+		if m = strings.TrimSpace(m); m != "" {
+			allowed[m] = true
+		}
+	}
+
+	return &OpenAIService{
+		baseURL: strings.TrimRight(conf.BaseURL, "/"),
+		apiKey:  conf.APIKey,
+		allowed: allowed,
+		client: &http.Client{
+			Timeout: 150 * time.Second,
+		},
+	}
+}
+
+func (o *OpenAIService) newRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, o.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
+
+func (o *OpenAIService) isAllowed(model string) bool {
+	return len(o.allowed) == 0 || o.allowed[model]
+}
+
+func openAIStatusError(resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && body.Error.Message != "" {
+		return backendStatusError(resp.StatusCode, fmt.Errorf("openai вернул статус %d: %s", resp.StatusCode, body.Error.Message))
+	}
+
+	return backendStatusError(resp.StatusCode, fmt.Errorf("openai вернул статус: %d", resp.StatusCode))
+}
+
+func (o *OpenAIService) CheckConnection(ctx context.Context) (bool, error) {
+	req, err := o.newRequest(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("ошибка подключения: %w", err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+type openAIModelsResponse struct {
+	Data []struct {
+		Id string `json:"id"`
+	} `json:"data"`
+}
+
+func (o *OpenAIService) GetModels(ctx context.Context) ([]string, error) {
+	req, err := o.newRequest(ctx, http.MethodGet, "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка подключения: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, openAIStatusError(resp)
+	}
+
+	var data openAIModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать список моделей: %w", err)
+	}
+
+	names := make([]string, 0, len(data.Data))
+	for _, m := range data.Data {
+		if m.Id != "" && o.isAllowed(m.Id) {
+			names = append(names, m.Id)
+		}
+	}
+
+	return names, nil
+}
+
+type openAIEmbeddingsResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (o *OpenAIService) Embed(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	if !o.isAllowed(model) {
+		return nil, fmt.Errorf("%w: openai: модель %q не разрешена конфигурацией раннера", domain.ErrModelNotFound, model)
+	}
+
+	jsonBody, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+	}
+
+	req, err := o.newRequest(ctx, http.MethodPost, "/embeddings", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, backendRequestError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, openAIStatusError(resp)
+	}
+
+	var data openAIEmbeddingsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("не удалось прочитать эмбеддинги: %w", err)
+	}
+
+	out := make([][]float32, len(inputs))
+	for _, d := range data.Data {
+		if d.Index < 0 || d.Index >= len(out) {
+			return nil, fmt.Errorf("openai вернул эмбеддинг с неверным индексом %d", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+
+	for i, e := range out {
+		if e == nil {
+			return nil, fmt.Errorf("openai не вернул эмбеддинг для текста %d", i)
+		}
+	}
+
+	return out, nil
+}
+
+type openAIChatMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallId string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAIToolCall struct {
+	Id       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIChatRequest struct {
+	Model         string               `json:"model"`
+	Messages      []openAIChatMessage  `json:"messages"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	Stream        bool                 `json:"stream"`
+	Temperature   *float32             `json:"temperature,omitempty"`
+	TopP          *float32             `json:"top_p,omitempty"`
+	TopK          *int32               `json:"top_k,omitempty"`
+	MaxTokens     *int32               `json:"max_tokens,omitempty"`
+	Stop          []string             `json:"stop,omitempty"`
+	Seed          *int64               `json:"seed,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int32 `json:"prompt_tokens"`
+	CompletionTokens int32 `json:"completion_tokens"`
+}
+
+type openAIChatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				Id       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+const openAIMaxToolCalls = 64
+
+var openAIEmptyParameters = json.RawMessage(`{"type":"object","properties":{}}`)
+
+func newOpenAIChatRequest(model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) openAIChatRequest {
+	body := openAIChatRequest{
+		Model:         model,
+		Messages:      make([]openAIChatMessage, 0, len(messages)),
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	}
+	for _, m := range messages {
+		msg := openAIChatMessage{
+			Role:       string(m.Role),
+			Content:    m.Content,
+			ToolCallId: m.ToolCallId,
+		}
+		for _, call := range m.ToolCalls {
+			args := call.Arguments
+			if args == "" {
+				args = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, openAIToolCall{
+				Id:   call.Id,
+				Type: "function",
+				Function: openAIFunctionCall{
+					Name:      call.Name,
+					Arguments: args,
+				},
+			})
+		}
+		body.Messages = append(body.Messages, msg)
+	}
+
+	for _, t := range tools {
+		parameters := t.Parameters
+		if len(parameters) == 0 {
+			parameters = openAIEmptyParameters
+		}
+		body.Tools = append(body.Tools, openAITool{
+			Type: "function",
+			Function: openAIFunction{
+				Name:        t.Name,
+				Description: t.Description,
+				Parameters:  parameters,
+			},
+		})
+	}
+
+	if opts != nil {
+		body.Temperature = opts.Temperature
+		body.TopP = opts.TopP
+		body.TopK = opts.TopK
+		body.MaxTokens = opts.MaxTokens
+		body.Stop = opts.Stop
+		body.Seed = opts.Seed
+	}
+
+	return body
+}
+
+func (o *OpenAIService) SendMessage(ctx context.Context, model string, messages []*domain.AIChatMessage, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, error) {
+	ch, usage, _, err := o.chat(ctx, model, messages, nil, opts)
+	return ch, usage, err
+}
+
+func (o *OpenAIService) SendMessageWithTools(ctx context.Context, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	return o.chat(ctx, model, messages, tools, opts)
+}
+
+func (o *OpenAIService) chat(ctx context.Context, model string, messages []*domain.AIChatMessage, tools []*domain.AITool, opts *domain.GenerationOptions) (chan string, *domain.TokenUsage, *domain.AIToolCalls, error) {
+	if !o.isAllowed(model) {
+		return nil, nil, nil, fmt.Errorf("%w: openai: модель %q не разрешена конфигурацией раннера", domain.ErrModelNotFound, model)
+	}
+
+	jsonBody, err := json.Marshal(newOpenAIChatRequest(model, messages, tools, opts))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("не удалось сериализовать запрос: %w", err)
+	}
+
+	req, err := o.newRequest(ctx, http.MethodPost, "/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, nil, nil, backendRequestError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, nil, nil, toolsStatusError(resp, tools, openAIStatusError(resp))
+	}
+
+	output := make(chan string, 100)
+	usage := &domain.TokenUsage{}
+	toolCalls := &domain.AIToolCalls{}
+
+	go func() {
+		defer resp.Body.Close()
+		defer close(output)
+
+		var pending []*domain.AIToolCall
+		defer func() {
+			for _, call := range pending {
+				if call != nil && call.Name != "" {
+					toolCalls.Calls = append(toolCalls.Calls, call)
+				}
+			}
+		}()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if !strings.HasPrefix(line, "data:") {
+				continue
+			}
+
+			payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if payload == "[DONE]" {
+				return
+			}
+
+			var chunk openAIChatChunk
+			if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+				continue
+			}
+
+			if chunk.Usage != nil {
+				usage.PromptTokens = chunk.Usage.PromptTokens
+				usage.CompletionTokens = chunk.Usage.CompletionTokens
+			}
+
+			for _, choice := range chunk.Choices {
+				for _, delta := range choice.Delta.ToolCalls {
+					if delta.Index < 0 || delta.Index >= openAIMaxToolCalls {
+						continue
+					}
+
+					for len(pending) <= delta.Index {
+						pending = append(pending, nil)
+					}
+
+					call := pending[delta.Index]
+					if call == nil {
+						call = &domain.AIToolCall{}
+						pending[delta.Index] = call
+					}
+
+					if delta.Id != "" {
+						call.Id = delta.Id
+					}
+					call.Name += delta.Function.Name
+					call.Arguments += delta.Function.Arguments
+				}
+
+				if choice.Delta.Content == "" {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case output <- choice.Delta.Content:
+				}
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			logger.W("openai: ошибка чтения потока: %v", err)
+		}
+	}()
+
+	return output, usage, toolCalls, nil
+}
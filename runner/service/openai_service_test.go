@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/runner/config"
+)
+
+func newOpenAITestServer(t *testing.T, body *map[string]interface{}) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/models":
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"qwen"},{"id":"llama"}]}`))
+		case "/v1/chat/completions":
+			if body != nil {
+				if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+					t.Errorf("Decode: %v", err)
+				}
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, piece := range []string{"При", "вет"} {
+				_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", piece)
+			}
+			_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"))
+			_, _ = w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2}}\n\n"))
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		case "/v1/embeddings":
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"index":1,"embedding":[0.3]},{"index":0,"embedding":[0.1]}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestOpenAIService_GetModels_allowList(t *testing.T) {
+	srv := newOpenAITestServer(t, nil)
+
+	svc := NewOpenAIService(config.OpenAI{BaseURL: srv.URL + "/v1/", APIKey: "secret", Models: []string{"qwen"}})
+	models, err := svc.GetModels(context.Background())
+	if err != nil {
+		t.Fatalf("GetModels: %v", err)
+	}
+
+	if len(models) != 1 || models[0] != "qwen" {
+		t.Errorf("ожидалась только разрешённая модель qwen, получено %v", models)
+	}
+
+	ok, err := svc.CheckConnection(context.Background())
+	if err != nil || !ok {
+		t.Errorf("CheckConnection = %v, %v", ok, err)
+	}
+}
+
+func TestOpenAIService_SendMessage_stream(t *testing.T) {
+	var body map[string]interface{}
+	srv := newOpenAITestServer(t, &body)
+
+	svc := NewOpenAIService(config.OpenAI{BaseURL: srv.URL + "/v1", APIKey: "secret"})
+	temperature, maxTokens := float32(0.25), int32(16)
+	ch, usage, err := svc.SendMessage(context.Background(), "llama", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "Будь краток", domain.AIChatMessageRoleSystem),
+		domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser),
+	}, &domain.GenerationOptions{
+		Temperature: &temperature,
+		MaxTokens:   &maxTokens,
+		Stop:        []string{"###"},
+	})
+	if err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+
+	var out string
+	for chunk := range ch {
+		out += chunk
+	}
+
+	if out != "Привет" {
+		t.Errorf("ответ %q, ожидалось Привет", out)
+	}
+
+	if usage.PromptTokens != 9 || usage.CompletionTokens != 2 {
+		t.Errorf("неверный usage: %+v", usage)
+	}
+
+	if streamOptions, _ := body["stream_options"].(map[string]interface{}); streamOptions["include_usage"] != true {
+		t.Errorf("usage не запрошен: %v", body["stream_options"])
+	}
+
+	if body["model"] != "llama" || body["stream"] != true || body["temperature"] != 0.25 || body["max_tokens"] != float64(16) {
+		t.Errorf("неверное тело запроса: %v", body)
+	}
+
+	if _, ok := body["top_p"]; ok {
+		t.Error("незаданный top_p не должен передаваться")
+	}
+
+	messages, _ := body["messages"].([]interface{})
+	if len(messages) != 2 || messages[0].(map[string]interface{})["role"] != "system" {
+		t.Errorf("неверные сообщения: %v", body["messages"])
+	}
+}
+
+func TestOpenAIService_SendMessage_disallowedModel(t *testing.T) {
+	srv := newOpenAITestServer(t, nil)
+
+	svc := NewOpenAIService(config.OpenAI{BaseURL: srv.URL + "/v1", APIKey: "secret", Models: []string{"qwen"}})
+	if _, _, err := svc.SendMessage(context.Background(), "llama", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser),
+	}, nil); !errors.Is(err, domain.ErrModelNotFound) {
+		t.Fatalf("ожидалась ErrModelNotFound для неразрешённой модели, получено %v", err)
+	}
+}
+
+func TestOpenAIService_statusError(t *testing.T) {
+	srv := newOpenAITestServer(t, nil)
+
+	svc := NewOpenAIService(config.OpenAI{BaseURL: srv.URL + "/v1", APIKey: "wrong"})
+	_, _, err := svc.SendMessage(context.Background(), "llama", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "hi", domain.AIChatMessageRoleUser),
+	}, nil)
+	if !errors.Is(err, domain.ErrBackendNotReady) || !strings.HasSuffix(err.Error(), "openai вернул статус 401: invalid api key") {
+		t.Fatalf("ожидалась ошибка авторизации, получено %v", err)
+	}
+}
+
+func TestOpenAIService_Embed(t *testing.T) {
+	srv := newOpenAITestServer(t, nil)
+
+	svc := NewOpenAIService(config.OpenAI{BaseURL: srv.URL + "/v1", APIKey: "secret"})
+	vectors, err := svc.Embed(context.Background(), "bge", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+
+	if len(vectors) != 2 || vectors[0][0] != 0.1 || vectors[1][0] != 0.3 {
+		t.Errorf("эмбеддинги должны упорядочиваться по index: %v", vectors)
+	}
+
+	if _, err := svc.Embed(context.Background(), "bge", []string{"a", "b", "c"}); err == nil {
+		t.Error("ожидалась ошибка, если сервер вернул не все эмбеддинги")
+	}
+}
+
+func TestOpenAIService_SendMessageWithTools(t *testing.T) {
+	var body openAIChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Decode: %v", err)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"name":"get_task","arguments":"{\"task"}}]}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"_id\":\"t1\"}"}}]}}]}` + "\n\n"))
+		_, _ = w.Write([]byte(`data: {"choices":[{"delta":{},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":4,"completion_tokens":6}}` + "\n\n"))
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	call := domain.NewAIChatMessage("s", "", domain.AIChatMessageRoleAssistant)
+	call.ToolCalls = []*domain.AIToolCall{{Id: "call_0", Name: "search_users"}}
+	svc := NewOpenAIService(config.OpenAI{BaseURL: srv.URL})
+	ch, usage, toolCalls, err := svc.SendMessageWithTools(context.Background(), "gpt", []*domain.AIChatMessage{
+		domain.NewAIChatMessage("s", "что с задачей?", domain.AIChatMessageRoleUser),
+		call,
+		domain.NewAIToolResultMessage("s", call.ToolCalls[0], `{"users":[]}`),
+	}, []*domain.AITool{{Name: "get_task"}}, nil)
+	if err != nil {
+		t.Fatalf("SendMessageWithTools: %v", err)
+	}
+	for range ch {
+	}
+
+	if len(toolCalls.Calls) != 1 || *toolCalls.Calls[0] != (domain.AIToolCall{Id: "call_1", Name: "get_task", Arguments: `{"task_id":"t1"}`}) {
+		t.Fatalf("фрагменты вызова должны собираться: %+v", toolCalls.Calls)
+	}
+
+	if usage.PromptTokens != 4 || usage.CompletionTokens != 6 {
+		t.Errorf("неверный usage: %+v", usage)
+	}
+
+	if len(body.Tools) != 1 || body.Tools[0].Type != "function" || string(body.Tools[0].Function.Parameters) != string(openAIEmptyParameters) {
+		t.Errorf("неверные инструменты: %+v", body.Tools)
+	}
+
+	if len(body.Messages) != 3 || body.Messages[1].ToolCalls[0].Function.Arguments != "{}" || body.Messages[2].ToolCallId != "call_0" {
+		t.Errorf("неверная история с инструментами: %+v", body.Messages)
+	}
+}
+
+func TestOpenAIService_SendMessageWithTools_unsupportedModel(t *testing.T) {
+	message := "bad request"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprintf(w, `{"error":{"message":%q}}`, message)
+	}))
+	defer srv.Close()
+
+	svc := NewOpenAIService(config.OpenAI{BaseURL: srv.URL + "/v1", APIKey: "secret"})
+	messages := []*domain.AIChatMessage{domain.NewAIChatMessage("s", "привет", domain.AIChatMessageRoleUser)}
+	tools := []*domain.AITool{{Name: "get_task"}}
+
+	_, _, _, err := svc.SendMessageWithTools(context.Background(), "qwen", messages, tools, nil)
+	if err == nil || errors.Is(err, domain.ErrToolCallsNotSupported) {
+		t.Errorf("посторонняя ошибка не должна считаться отсутствием инструментов: %v", err)
+	}
+
+	message = `"auto" tool choice requires --enable-auto-tool-choice and --tool-call-parser to be set`
+	_, _, _, err = svc.SendMessageWithTools(context.Background(), "qwen", messages, tools, nil)
+	if !errors.Is(err, domain.ErrToolCallsNotSupported) {
+		t.Errorf("ожидалась ErrToolCallsNotSupported, получено %v", err)
+	}
+}
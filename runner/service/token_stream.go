@@ -0,0 +1,115 @@
+package service
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenStream struct {
+	stop    []string
+	pending []byte
+	held    string
+	started bool
+	stopped bool
+}
+
+func newTokenStream(stop []string) *tokenStream {
+	list := make([]string, 0, len(stop))
+	for _, s := range stop {
+		if s != "" {
+			list = append(list, s)
+		}
+	}
+
+	return &tokenStream{
+		stop: list,
+	}
+}
+
+func (t *tokenStream) Push(piece string) (string, bool) {
+	if t.stopped {
+		return "", true
+	}
+
+	t.pending = append(t.pending, piece...)
+	cut := runeBoundary(t.pending)
+	text := t.held + string(t.pending[:cut])
+	t.pending = append(t.pending[:0], t.pending[cut:]...)
+
+	if idx := t.stopIndex(text); idx >= 0 {
+		t.stopped = true
+		t.held = ""
+		t.pending = nil
+		return t.emit(text[:idx]), true
+	}
+
+	keep := t.stopPrefixLen(text)
+	t.held = text[len(text)-keep:]
+
+	return t.emit(text[:len(text)-keep]), false
+}
+
+func (t *tokenStream) Flush() string {
+	if t.stopped {
+		return ""
+	}
+
+	text := t.held + string(t.pending)
+	t.held = ""
+	t.pending = nil
+
+	return t.emit(text)
+}
+
+func (t *tokenStream) emit(text string) string {
+	if !t.started {
+		text = strings.TrimLeft(text, " ")
+		if text == "" {
+			return ""
+		}
+		t.started = true
+	}
+
+	return text
+}
+
+func (t *tokenStream) stopIndex(text string) int {
+	first := -1
+	for _, s := range t.stop {
+		if idx := strings.Index(text, s); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+
+	return first
+}
+
+func (t *tokenStream) stopPrefixLen(text string) int {
+	longest := 0
+	for _, s := range t.stop {
+		for n := len(s) - 1; n > longest; n-- {
+			if n <= len(text) && strings.HasSuffix(text, s[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+
+	return longest
+}
+
+func runeBoundary(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(b[i]) {
+			continue
+		}
+
+		if utf8.FullRune(b[i:]) {
+			return len(b)
+		}
+
+		return i
+	}
+
+	return len(b)
+}
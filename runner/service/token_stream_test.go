@@ -0,0 +1,85 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTokenStream_runeSafe(t *testing.T) {
+	ts := newTokenStream(nil)
+	word := []byte("Привет, 世界")
+
+	var out strings.Builder
+	for i := range word {
+		chunk, stop := ts.Push(string(word[i : i+1]))
+		if stop {
+			t.Fatal("без стоп-последовательностей генерация не должна останавливаться")
+		}
+
+		if !utf8.ValidString(chunk) {
+			t.Fatalf("фрагмент %q разрезает символ UTF-8", chunk)
+		}
+		out.WriteString(chunk)
+	}
+	out.WriteString(ts.Flush())
+
+	if out.String() != "Привет, 世界" {
+		t.Errorf("получено %q", out.String())
+	}
+}
+
+func TestTokenStream_stopSequence(t *testing.T) {
+	ts := newTokenStream([]string{"</s>", "User:"})
+
+	var out strings.Builder
+	stopped := false
+	for _, piece := range []string{" Hello", " wor", "ld\nUs", "er: next"} {
+		chunk, stop := ts.Push(piece)
+		out.WriteString(chunk)
+		if stop {
+			stopped = true
+			break
+		}
+	}
+
+	if !stopped {
+		t.Fatal("генерация должна остановиться на стоп-последовательности")
+	}
+
+	if out.String() != "Hello world\n" {
+		t.Errorf("получено %q, стоп-последовательность не должна попадать в ответ", out.String())
+	}
+
+	if chunk, stop := ts.Push("more"); chunk != "" || !stop {
+		t.Error("после остановки поток не должен выдавать текст")
+	}
+}
+
+func TestTokenStream_heldPrefixFlushed(t *testing.T) {
+	ts := newTokenStream([]string{"###"})
+
+	chunk, _ := ts.Push("answer #")
+	if chunk != "answer " {
+		t.Errorf("возможное начало стоп-последовательности должно удерживаться, получено %q", chunk)
+	}
+
+	if rest := ts.Flush(); rest != "#" {
+		t.Errorf("Flush должен вернуть удержанный текст, получено %q", rest)
+	}
+}
+
+func TestRuneBoundary(t *testing.T) {
+	b := []byte("ж")
+	if got := runeBoundary(b[:1]); got != 0 {
+		t.Errorf("неполный символ: %d, ожидалось 0", got)
+	}
+
+	if got := runeBoundary(b); got != 2 {
+		t.Errorf("полный символ: %d, ожидалось 2", got)
+	}
+
+	if got := runeBoundary([]byte{0xff}); got != 1 {
+		t.Errorf("некорректный байт не должен удерживаться: %d", got)
+	}
+}
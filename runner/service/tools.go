@@ -0,0 +1,31 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+var toolsUnsupportedMarkers = []string{
+	"does not support tools",
+	"tools are not supported",
+	"tool calling is not supported",
+	"enable-auto-tool-choice",
+}
+
+func toolsStatusError(resp *http.Response, tools []*domain.AITool, err error) error {
+	if len(tools) == 0 || resp.StatusCode != http.StatusBadRequest {
+		return err
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, marker := range toolsUnsupportedMarkers {
+		if strings.Contains(message, marker) {
+			return fmt.Errorf("%w: %v", domain.ErrToolCallsNotSupported, err)
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,155 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+	"github.com/magomedcoder/legion/pkg/logger"
+)
+
+const persistTimeout = 5 * time.Second
+
+func WithRunnerRepository(repo domain.RunnerRepository) PoolOption {
+	return func(p *Pool) {
+		p.repo = repo
+	}
+}
+
+func (p *Pool) Restore(ctx context.Context) ([]string, error) {
+	if p.repo == nil {
+		return nil, nil
+	}
+
+	runners, err := p.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	restored := make([]string, 0, len(runners))
+	for _, r := range runners {
+		if r == nil || r.Address == "" {
+			continue
+		}
+
+		if !p.hasAddressLocked(r.Address) {
+			p.addresses = append(p.addresses, r.Address)
+		}
+		p.applyStoredLocked(r)
+
+		restored = append(restored, r.Address)
+	}
+	logger.I("Pool: восстановлено раннеров из базы: %d", len(restored))
+
+	return restored, nil
+}
+
+func (p *Pool) applyStoredLocked(r *domain.Runner) {
+	if r.Enabled {
+		delete(p.disabled, r.Address)
+	} else {
+		p.disabled[r.Address] = true
+	}
+
+	if len(r.Labels) > 0 {
+		p.labels[r.Address] = copyLabels(r.Labels)
+	}
+
+	if r.LastSeenAt != nil {
+		p.lastSeen[r.Address] = *r.LastSeenAt
+	}
+}
+
+func (p *Pool) lookup(address string) *domain.Runner {
+	if p.repo == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), persistTimeout)
+	defer cancel()
+
+	r, err := p.repo.Get(ctx, address)
+	if err != nil {
+		if !errors.Is(err, domain.ErrRunnerNotFound) {
+			logger.W("Pool: не удалось загрузить состояние раннера %s: %v", address, err)
+		}
+		return nil
+	}
+
+	return r
+}
+
+func (p *Pool) SetRunnerLabels(address string, labels map[string]string) bool {
+	p.mu.Lock()
+	if !p.hasAddressLocked(address) {
+		p.mu.Unlock()
+		return false
+	}
+
+	if len(labels) == 0 {
+		delete(p.labels, address)
+	} else {
+		p.labels[address] = copyLabels(labels)
+	}
+	p.mu.Unlock()
+
+	p.persist(address)
+
+	return true
+}
+
+func (p *Pool) persist(address string) {
+	if p.repo == nil {
+		return
+	}
+
+	p.mu.RLock()
+	if !p.hasAddressLocked(address) {
+		p.mu.RUnlock()
+		return
+	}
+
+	r := &domain.Runner{
+		Address:   address,
+		Enabled:   !p.disabled[address],
+		Labels:    copyLabels(p.labels[address]),
+		UpdatedAt: time.Now(),
+	}
+	if seen, ok := p.lastSeen[address]; ok {
+		r.LastSeenAt = &seen
+	}
+	p.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), persistTimeout)
+	defer cancel()
+
+	if err := p.repo.Save(ctx, r); err != nil {
+		logger.W("Pool: не удалось сохранить состояние раннера %s: %v", address, err)
+	}
+}
+
+func (p *Pool) Forget(ctx context.Context, address string) error {
+	p.Remove(address)
+	if p.repo == nil {
+		return nil
+	}
+
+	return p.repo.Delete(ctx, address)
+}
+
+func copyLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+
+	return out
+}
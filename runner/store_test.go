@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/internal/domain"
+)
+
+type memoryRunnerRepo struct {
+	mu      sync.Mutex
+	runners map[string]domain.Runner
+	fail    bool
+}
+
+func newMemoryRunnerRepo() *memoryRunnerRepo {
+	return &memoryRunnerRepo{
+		runners: make(map[string]domain.Runner),
+	}
+}
+
+func (m *memoryRunnerRepo) List(context.Context) ([]*domain.Runner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return nil, errors.New("база недоступна")
+	}
+
+	out := make([]*domain.Runner, 0, len(m.runners))
+	for _, r := range m.runners {
+		r := r
+		out = append(out, &r)
+	}
+
+	return out, nil
+}
+
+func (m *memoryRunnerRepo) Get(_ context.Context, address string) (*domain.Runner, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return nil, errors.New("база недоступна")
+	}
+
+	r, ok := m.runners[address]
+	if !ok {
+		return nil, domain.ErrRunnerNotFound
+	}
+
+	return &r, nil
+}
+
+func (m *memoryRunnerRepo) Save(_ context.Context, runner *domain.Runner) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return errors.New("база недоступна")
+	}
+	m.runners[runner.Address] = *runner
+
+	return nil
+}
+
+func (m *memoryRunnerRepo) Delete(_ context.Context, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.fail {
+		return errors.New("база недоступна")
+	}
+	delete(m.runners, address)
+
+	return nil
+}
+
+func findRunner(runners []RunnerInfo, address string) (RunnerInfo, bool) {
+	for _, r := range runners {
+		if r.Address == address {
+			return r, true
+		}
+	}
+
+	return RunnerInfo{}, false
+}
+
+func TestPool_Restore_mergesWithConfig(t *testing.T) {
+	repo := newMemoryRunnerRepo()
+	before := NewPool([]string{"static:1"}, WithRunnerRepository(repo))
+	before.Add("dynamic:1")
+	before.ApplyHeartbeat("dynamic:1", nil, 0, nil)
+	before.SetRunnerEnabled("static:1", false)
+	if !before.SetRunnerLabels("dynamic:1", map[string]string{"gpu": "a100"}) {
+		t.Fatal("SetRunnerLabels: раннер не найден")
+	}
+
+	after := NewPool([]string{"static:1", "static:2"}, WithRunnerRepository(repo))
+	restored, err := after.Restore(context.Background())
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if len(restored) != 2 {
+		t.Errorf("восстановлено %d раннеров, ожидалось 2: %v", len(restored), restored)
+	}
+
+	runners := after.GetRunners()
+	if len(runners) != 3 {
+		t.Fatalf("ожидалось 3 раннера после объединения с конфигом, получено %d", len(runners))
+	}
+
+	if r, _ := findRunner(runners, "static:1"); r.Enabled {
+		t.Error("отключение static:1 должно пережить перезапуск")
+	}
+
+	if r, _ := findRunner(runners, "static:2"); !r.Enabled {
+		t.Error("static:2 из конфига должен быть включён")
+	}
+
+	dynamic, ok := findRunner(runners, "dynamic:1")
+	if !ok {
+		t.Fatal("динамический раннер должен быть восстановлен")
+	}
+
+	if dynamic.Labels["gpu"] != "a100" || dynamic.LastSeenAt.IsZero() {
+		t.Errorf("метки или last_seen не восстановлены: %+v", dynamic)
+	}
+}
+
+func TestPool_Remove_keepsPersistedState(t *testing.T) {
+	repo := newMemoryRunnerRepo()
+	before := NewPool(nil, WithRunnerRepository(repo))
+	before.Add("dynamic:1")
+	before.SetRunnerEnabled("dynamic:1", false)
+	before.SetRunnerLabels("dynamic:1", map[string]string{"gpu": "a100"})
+	before.Remove("dynamic:1")
+
+	if len(before.GetRunners()) != 0 {
+		t.Fatal("снятый с регистрации раннер должен пропасть из пула")
+	}
+
+	before.Add("dynamic:1")
+	r, ok := findRunner(before.GetRunners(), "dynamic:1")
+	if !ok || r.Enabled || r.Labels["gpu"] != "a100" {
+		t.Errorf("повторно зарегистрированный раннер должен сохранить отключение и метки: %+v", r)
+	}
+	before.Remove("dynamic:1")
+
+	after := NewPool(nil, WithRunnerRepository(repo))
+	if _, err := after.Restore(context.Background()); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	r, ok = findRunner(after.GetRunners(), "dynamic:1")
+	if !ok || r.Enabled || r.Labels["gpu"] != "a100" {
+		t.Errorf("отключение и метки должны пережить перезапуск: %+v", r)
+	}
+}
+
+func TestPool_Forget_deletesPersistedState(t *testing.T) {
+	repo := newMemoryRunnerRepo()
+	p := NewPool(nil, WithRunnerRepository(repo))
+	p.Add("dynamic:1")
+	p.SetRunnerEnabled("dynamic:1", false)
+
+	if err := p.Forget(context.Background(), "dynamic:1"); err != nil {
+		t.Fatalf("Forget: %v", err)
+	}
+
+	if len(p.GetRunners()) != 0 {
+		t.Fatal("удалённый раннер должен пропасть из пула")
+	}
+
+	p.Add("dynamic:1")
+	if r, ok := findRunner(p.GetRunners(), "dynamic:1"); !ok || !r.Enabled {
+		t.Errorf("после удаления администратором раннер должен регистрироваться заново: %+v", r)
+	}
+}
+
+func TestPool_Restore_noRepository(t *testing.T) {
+	p := NewPool([]string{"a:1"})
+	restored, err := p.Restore(context.Background())
+	if err != nil || restored != nil {
+		t.Errorf("без репозитория Restore должен быть пустым: %v, %v", restored, err)
+	}
+
+	if !p.SetRunnerLabels("a:1", map[string]string{"zone": "eu"}) {
+		t.Error("метки должны применяться и без репозитория")
+	}
+
+	if p.SetRunnerLabels("missing:1", map[string]string{"zone": "eu"}) {
+		t.Error("SetRunnerLabels для неизвестного раннера должен вернуть false")
+	}
+}
+
+func TestPool_persistFailureKeepsMemoryState(t *testing.T) {
+	repo := newMemoryRunnerRepo()
+	repo.fail = true
+	p := NewPool([]string{"a:1"}, WithRunnerRepository(repo))
+	p.SetRunnerEnabled("a:1", false)
+
+	if r, _ := findRunner(p.GetRunners(), "a:1"); r.Enabled {
+		t.Error("ошибка сохранения не должна откатывать состояние в памяти")
+	}
+
+	if _, err := p.Restore(context.Background()); err == nil {
+		t.Error("ожидалась ошибка Restore при недоступной базе")
+	}
+}
+
+func TestRegistry_Adopt(t *testing.T) {
+	pool := NewPool([]string{"static:1", "dynamic:1"})
+	r := NewRegistry(pool, "", WithStaticAddresses([]string{"static:1"}), WithLeaseTTL(time.Minute))
+	r.Adopt([]string{"static:1", "dynamic:1"})
+
+	expired := r.ExpireLeases(time.Now().Add(2 * time.Minute))
+	if len(expired) != 1 || expired[0] != "dynamic:1" {
+		t.Errorf("истечь должна только аренда восстановленного динамического раннера: %v", expired)
+	}
+
+	if len(pool.GetRunners()) != 1 {
+		t.Errorf("в пуле должен остаться только статический раннер, получено %d", len(pool.GetRunners()))
+	}
+}
@@ -0,0 +1,301 @@
+package runner
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/magomedcoder/legion/api/pb/runnerpb"
+	"google.golang.org/grpc"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	return certPath, keyPath
+}
+
+type testPKI struct {
+	caFile     string
+	serverCert string
+	serverKey  string
+	clientCert string
+	clientKey  string
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+
+	dir := t.TempDir()
+	notAfter := time.Now().Add(time.Hour)
+
+	ca := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "legion test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}, nil)
+
+	server := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "runner"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}, ca)
+
+	client := newTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "legion"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	pki := testPKI{}
+	pki.caFile, _ = ca.write(t, dir, "ca")
+	pki.serverCert, pki.serverKey = server.write(t, dir, "server")
+	pki.clientCert, pki.clientKey = client.write(t, dir, "client")
+
+	return pki
+}
+
+func startTLSRunner(t *testing.T, serverTLS ServerTLS) string {
+	t.Helper()
+
+	creds, err := serverTLS.TransportCredentials()
+	if err != nil {
+		t.Fatalf("ServerTLS: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	srv := grpc.NewServer(grpc.Creds(creds))
+	runnerpb.RegisterRunnerServiceServer(srv, NewServer(&fakeTextProvider{models: []string{"m"}}, nil))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	return lis.Addr().String()
+}
+
+func pingWith(t *testing.T, addr string, clientTLS ClientTLS) error {
+	t.Helper()
+
+	creds, err := clientTLS.TransportCredentials()
+	if err != nil {
+		t.Fatalf("ClientTLS: %v", err)
+	}
+
+	pool := NewPool([]string{addr}, WithTransportCredentials(creds))
+	t.Cleanup(func() {
+		pool.Remove(addr)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return pool.ping(ctx, addr)
+}
+
+func TestTLS_serverVerification(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := startTLSRunner(t, ServerTLS{
+		Enabled:  true,
+		CertFile: pki.serverCert,
+		KeyFile:  pki.serverKey,
+	})
+
+	if err := pingWith(t, addr, ClientTLS{Enabled: true, CAFile: pki.caFile}); err != nil {
+		t.Fatalf("ping по TLS с доверенным CA: %v", err)
+	}
+
+	if err := pingWith(t, addr, ClientTLS{}); err == nil {
+		t.Error("ожидалась ошибка при подключении без TLS к TLS-раннеру")
+	}
+
+	if err := pingWith(t, addr, ClientTLS{Enabled: true}); err == nil {
+		t.Error("ожидалась ошибка: сертификат раннера не подписан системным CA")
+	}
+}
+
+func TestTLS_mutual(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := startTLSRunner(t, ServerTLS{
+		Enabled:      true,
+		CertFile:     pki.serverCert,
+		KeyFile:      pki.serverKey,
+		ClientCAFile: pki.caFile,
+	})
+
+	err := pingWith(t, addr, ClientTLS{
+		Enabled:  true,
+		CAFile:   pki.caFile,
+		CertFile: pki.clientCert,
+		KeyFile:  pki.clientKey,
+	})
+	if err != nil {
+		t.Fatalf("ping по mTLS: %v", err)
+	}
+
+	if err := pingWith(t, addr, ClientTLS{Enabled: true, CAFile: pki.caFile}); err == nil {
+		t.Error("ожидалась ошибка при подключении без клиентского сертификата")
+	}
+}
+
+func registerWith(t *testing.T, addr string, clientTLS ClientTLS) error {
+	t.Helper()
+
+	creds, err := clientTLS.TransportCredentials()
+	if err != nil {
+		t.Fatalf("ClientTLS: %v", err)
+	}
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = runnerpb.NewRunnerServiceClient(conn).Register(ctx, &runnerpb.RegisterRunnerRequest{Address: "10.0.0.1:50052"})
+
+	return err
+}
+
+func TestTLS_coreServerMutual(t *testing.T) {
+	pki := newTestPKI(t)
+	creds, err := ServerTLS{
+		Enabled:      true,
+		CertFile:     pki.serverCert,
+		KeyFile:      pki.serverKey,
+		ClientCAFile: pki.caFile,
+	}.TransportCredentials()
+	if err != nil {
+		t.Fatalf("ServerTLS: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	pool := NewPool(nil)
+	srv := grpc.NewServer(grpc.Creds(creds))
+	runnerpb.RegisterRunnerServiceServer(srv, NewRegistry(pool, ""))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+	addr := lis.Addr().String()
+
+	err = registerWith(t, addr, ClientTLS{
+		Enabled:  true,
+		CAFile:   pki.caFile,
+		CertFile: pki.clientCert,
+		KeyFile:  pki.clientKey,
+	})
+	if err != nil {
+		t.Fatalf("регистрация по mTLS: %v", err)
+	}
+
+	if len(pool.GetRunners()) != 1 {
+		t.Errorf("ожидался 1 зарегистрированный раннер, получено %d", len(pool.GetRunners()))
+	}
+
+	if err := registerWith(t, addr, ClientTLS{Enabled: true, CAFile: pki.caFile}); err == nil {
+		t.Error("ожидалась ошибка регистрации без клиентского сертификата")
+	}
+
+	if err := registerWith(t, addr, ClientTLS{}); err == nil {
+		t.Error("ожидалась ошибка регистрации без TLS")
+	}
+}
+
+func TestTLS_invalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	bogus := filepath.Join(dir, "bogus.pem")
+	if err := os.WriteFile(bogus, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := (ClientTLS{Enabled: true, CAFile: bogus}).TransportCredentials(); err == nil {
+		t.Error("ожидалась ошибка для CA без PEM-сертификатов")
+	}
+
+	if _, err := (ClientTLS{Enabled: true, CAFile: filepath.Join(dir, "missing.pem")}).TransportCredentials(); err == nil {
+		t.Error("ожидалась ошибка для отсутствующего CA")
+	}
+
+	if _, err := (ServerTLS{Enabled: true, CertFile: bogus, KeyFile: bogus}).TransportCredentials(); err == nil {
+		t.Error("ожидалась ошибка для некорректного сертификата сервера")
+	}
+}